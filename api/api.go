@@ -1,13 +1,10 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	gildasai "github.com/gildasch/gildas-ai"
-	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,18 +17,18 @@ type classifierResult struct {
 
 func ClassifyHandler(classifiers map[string]gildasai.Classifier, html bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		imageURL := strings.TrimPrefix(c.Query("imageurl"), "/")
-
-		if imageURL == "" {
-			c.HTML(http.StatusOK, "predictions.html", nil)
+		img, imageURL, err := requestImage(c, "image", "imageurl", true)
+		if err != nil {
+			abortWithImageError(c, err)
 			return
 		}
 
-		img, err := imageutils.FromURL(imageURL)
-		if err != nil {
-			c.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				fmt.Sprintf("cannot read remote image %q: %v\n", imageURL, err))
+		if img == nil && !html {
+			abortWithImageError(c, errNoImage("image", "imageurl"))
+			return
+		}
+		if img == nil {
+			c.HTML(http.StatusOK, "predictions.html", nil)
 			return
 		}
 
@@ -58,7 +55,7 @@ func ClassifyHandler(classifiers map[string]gildasai.Classifier, html bool) gin.
 
 		if html {
 			c.HTML(http.StatusOK, "predictions.html", gin.H{
-				"imageURL": imageURL,
+				"imageURL": imageSrc(img, imageURL),
 				"results":  resp,
 			})
 			return
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	// the test images are served on localhost
+	imageutils.RemoteFetcher.AllowPrivate = true
+}
+
+func testRouter() *gin.Engine {
+	r := gin.New()
+	r.LoadHTMLGlob("../templates/*.html")
+	return r
+}
+
+// imageServer serves a blank image of 400x200 pixels at /image.png
+func imageServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/image.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	}))
+}
+
+func get(r *gin.Engine, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+// pngData returns the PNG of a blank image of width x height pixels
+func pngData(t *testing.T, width, height int) []byte {
+	var b bytes.Buffer
+	require.NoError(t, png.Encode(&b, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return b.Bytes()
+}
+
+// upload returns a multipart form with files as its fields
+func upload(t *testing.T, files map[string][]byte) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for field, data := range files {
+		part, err := mw.CreateFormFile(field, field+".png")
+		require.NoError(t, err)
+		_, err = part.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	return &body, mw.FormDataContentType()
+}
+
+func post(r *gin.Engine, target string, body io.Reader, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestClassifyHandler(t *testing.T) {
+	server := imageServer()
+	defer server.Close()
+
+	cat := &gildasaitest.Classifier{Predictions: []gildasai.Predictions{{
+		{Network: "fake", Label: "cat", Score: 0.9},
+	}}}
+	broken := &gildasaitest.Classifier{}
+	broken.Errors = map[string]error{"Classify": errors.New("broken")}
+
+	r := testRouter()
+	r.GET("/object/api", ClassifyHandler(map[string]gildasai.Classifier{
+		"cat":    cat,
+		"broken": broken,
+	}, false))
+
+	w := get(r, "/object/api?imageurl="+url.QueryEscape(server.URL+"/image.png"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var results []struct {
+		Classifier  string
+		Predictions gildasai.Predictions
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+	for _, res := range results {
+		if res.Classifier == "cat" {
+			assert.Equal(t, "cat", res.Predictions[0].Label)
+		} else {
+			assert.Empty(t, res.Predictions)
+		}
+	}
+
+	calls := cat.Calls("Classify")
+	require.Len(t, calls, 1)
+	assert.Equal(t, image.Rect(0, 0, 400, 200), calls[0].Args[0].(image.Image).Bounds())
+
+	w = get(r, "/object/api?imageurl="+url.QueryEscape(server.URL+"/missing.png"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(r, "/object/api")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestClassifyHandlerUploads(t *testing.T) {
+	cat := &gildasaitest.Classifier{Predictions: []gildasai.Predictions{{
+		{Network: "fake", Label: "cat", Score: 0.9},
+	}}}
+
+	r := testRouter()
+	r.POST("/object/api", ClassifyHandler(map[string]gildasai.Classifier{"cat": cat}, false))
+	r.POST("/object", ClassifyHandler(map[string]gildasai.Classifier{"cat": cat}, true))
+
+	body, contentType := upload(t, map[string][]byte{"image": pngData(t, 300, 100)})
+	w := post(r, "/object/api", body, contentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "cat")
+
+	// the type of raw bodies is detected
+	w = post(r, "/object/api", bytes.NewReader(pngData(t, 200, 100)), "application/octet-stream")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	calls := cat.Calls("Classify")
+	require.Len(t, calls, 2)
+	assert.Equal(t, image.Rect(0, 0, 300, 100), calls[0].Args[0].(image.Image).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 200, 100), calls[1].Args[0].(image.Image).Bounds())
+
+	body, contentType = upload(t, map[string][]byte{"image": pngData(t, 300, 100)})
+	w = post(r, "/object", body, contentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "data:image/jpeg;base64,")
+
+	w = post(r, "/object/api", bytes.NewReader(pngData(t, 200, 100)), "application/x-www-form-urlencoded")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// forms are still read
+	w = post(r, "/object/api", strings.NewReader("imageurl="), "application/x-www-form-urlencoded")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "no image")
+
+	w = post(r, "/object/api", strings.NewReader("not an image"), "image/png")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	body, contentType = upload(t, map[string][]byte{"image": []byte("not an image")})
+	w = post(r, "/object/api", body, contentType)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/object/api", bytes.NewReader(pngData(t, 10, 10)))
+	req.Header.Set("Content-Type", "image/png")
+	req.ContentLength = maxUploadSize + 1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = post(r, "/object/api", io.MultiReader(
+		bytes.NewReader(pngData(t, 10, 10)),
+		io.LimitReader(zeros{}, maxUploadSize)), "image/png")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	assert.Equal(t, 4, cat.CallCount("Classify"), "only the valid images are classified")
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
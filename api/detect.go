@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	"net/http"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gin-gonic/gin"
+)
+
+type detectedFace struct {
+	Box   image.Rectangle `json:"box"`
+	Score float32         `json:"score"`
+	// Landmarks are the 68 points of the face, in pixels on the image
+	Landmarks   []image.Point        `json:"landmarks"`
+	Descriptors gildasai.Descriptors `json:"descriptors,omitempty"`
+	// Aligned is the JPEG of the aligned face, as a base64 data URI
+	Aligned string `json:"aligned,omitempty"`
+}
+
+// detectFaces returns the faces of img, with their descriptors and their
+// aligned crops when asked for
+func detectFaces(extractor *gildasai.Extractor, img image.Image, descriptors, aligned bool) ([]detectedFace, error) {
+	faces, err := extractor.ExtractFaces(img, descriptors)
+	if err != nil && err != gildasai.ErrNoFaceDetected {
+		return nil, err
+	}
+
+	detected := []detectedFace{}
+	for _, f := range faces {
+		d := detectedFace{
+			Box:         f.Detection.Box,
+			Score:       f.Detection.Score,
+			Landmarks:   f.Landmarks,
+			Descriptors: f.Descriptors,
+		}
+		if aligned {
+			d.Aligned = toHTMLBase64(f.Aligned)
+		}
+		detected = append(detected, d)
+	}
+
+	return detected, nil
+}
+
+// FaceDetectHandler returns as JSON the faces of the image uploaded as
+// image or given by imageurl. Their descriptors and aligned crops are
+// added with descriptors=true and aligned=true.
+func FaceDetectHandler(extractor *gildasai.Extractor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		img, _, err := requestImage(c, "image", "imageurl", true)
+		if err != nil {
+			abortWithImageError(c, err)
+			return
+		}
+		if img == nil {
+			abortWithImageError(c, errNoImage("image", "imageurl"))
+			return
+		}
+
+		faces, err := detectFaces(extractor, img, c.Query("descriptors") == "true", c.Query("aligned") == "true")
+		if err != nil {
+			fmt.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, faces)
+	}
+}
+
+type faceComparison struct {
+	Faces1 []detectedFace `json:"faces1"`
+	Faces2 []detectedFace `json:"faces2"`
+	// Distances[i][j] is the distance between Faces1[i] and Faces2[j]
+	Distances [][]float32 `json:"distances"`
+}
+
+// FaceCompareHandler returns as JSON the faces of two images, uploaded as
+// image1 and image2 or given by imageurl1 and imageurl2, and the
+// distances between them
+func FaceCompareHandler(extractor *gildasai.Extractor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var imgs []image.Image
+		for _, n := range []string{"1", "2"} {
+			img, _, err := requestImage(c, "image"+n, "imageurl"+n, false)
+			if err != nil {
+				abortWithImageError(c, err)
+				return
+			}
+			if img == nil {
+				abortWithImageError(c, errNoImage("image"+n, "imageurl"+n))
+				return
+			}
+			imgs = append(imgs, img)
+		}
+
+		aligned := c.Query("aligned") == "true"
+		var comparison faceComparison
+		var err error
+		comparison.Faces1, err = detectFaces(extractor, imgs[0], true, aligned)
+		if err == nil {
+			comparison.Faces2, err = detectFaces(extractor, imgs[1], true, aligned)
+		}
+		if err != nil {
+			fmt.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		comparison.Distances = [][]float32{}
+		for _, f1 := range comparison.Faces1 {
+			distances := []float32{}
+			for _, f2 := range comparison.Faces2 {
+				d, err := f1.Descriptors.DistanceTo(f2.Descriptors)
+				if err != nil {
+					fmt.Println(err)
+					c.AbortWithStatus(http.StatusInternalServerError)
+					return
+				}
+				distances = append(distances, d)
+			}
+			comparison.Distances = append(comparison.Distances, distances)
+		}
+
+		if c.Query("descriptors") != "true" {
+			for i := range comparison.Faces1 {
+				comparison.Faces1[i].Descriptors = nil
+			}
+			for i := range comparison.Faces2 {
+				comparison.Faces2[i].Descriptors = nil
+			}
+		}
+
+		c.JSON(http.StatusOK, comparison)
+	}
+}
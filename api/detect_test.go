@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func detectExtractor() *gildasai.Extractor {
+	return &gildasai.Extractor{
+		Detector: &gildasaitest.Detector{Detections: [][]gildasai.Detection{{
+			{Box: image.Rect(50, 50, 150, 150), Score: 0.9},
+		}}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+}
+
+func TestFaceDetectHandler(t *testing.T) {
+	server := imageServer()
+	defer server.Close()
+
+	r := testRouter()
+	r.GET("/api/faces", FaceDetectHandler(detectExtractor()))
+	r.POST("/api/faces", FaceDetectHandler(detectExtractor()))
+
+	w := get(r, "/api/faces")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	imageURL := url.QueryEscape(server.URL + "/image.png")
+
+	w = get(r, "/api/faces?imageurl="+imageURL)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var faces []detectedFace
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &faces))
+	require.Len(t, faces, 1)
+	assert.Equal(t, image.Rect(50, 50, 150, 150), faces[0].Box)
+	assert.Equal(t, float32(0.9), faces[0].Score)
+	landmarks := gildasaitest.FaceLandmarks
+	assert.Equal(t, landmarks.PointsOnImage(image.NewRGBA(faces[0].Box)), faces[0].Landmarks)
+	assert.Empty(t, faces[0].Descriptors)
+	assert.Empty(t, faces[0].Aligned)
+
+	w = get(r, "/api/faces?descriptors=true&aligned=true&imageurl="+imageURL)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &faces))
+	require.Len(t, faces, 1)
+	assert.Len(t, faces[0].Descriptors, 128)
+	assert.True(t, strings.HasPrefix(faces[0].Aligned, "data:image/jpeg;base64,"))
+
+	body, contentType := upload(t, map[string][]byte{"image": pngData(t, 200, 200)})
+	w = post(r, "/api/faces", body, contentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &faces))
+	assert.Len(t, faces, 1)
+}
+
+func TestFaceCompareHandler(t *testing.T) {
+	server := imageServer()
+	defer server.Close()
+
+	r := testRouter()
+	r.GET("/api/faces/compare", FaceCompareHandler(detectExtractor()))
+	r.POST("/api/faces/compare", FaceCompareHandler(detectExtractor()))
+
+	imageURL := url.QueryEscape(server.URL + "/image.png")
+
+	w := get(r, "/api/faces/compare?imageurl1="+imageURL)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(r, "/api/faces/compare?imageurl1="+imageURL+"&imageurl2="+url.QueryEscape(server.URL+"/missing.png"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(r, "/api/faces/compare?imageurl1="+imageURL+"&imageurl2="+imageURL)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var comparison faceComparison
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &comparison))
+	require.Len(t, comparison.Faces1, 1)
+	require.Len(t, comparison.Faces2, 1)
+	assert.Empty(t, comparison.Faces1[0].Descriptors)
+	// the fake descriptors of the n-th face start with n
+	assert.Equal(t, [][]float32{{1}}, comparison.Distances)
+
+	body, contentType := upload(t, map[string][]byte{"image1": pngData(t, 200, 200)})
+	w = post(r, "/api/faces/compare?imageurl2="+imageURL, body, contentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &comparison))
+	assert.Len(t, comparison.Distances, 1)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func zipUpload(t *testing.T, names ...string) (*bytes.Buffer, string) {
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for _, name := range names {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, 200, 200))))
+	}
+	require.NoError(t, zw.Close())
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image_zip", "images.zip")
+	require.NoError(t, err)
+	_, err = part.Write(zipped.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	return &body, mw.FormDataContentType()
+}
+
+func TestFacesBatchHandlers(t *testing.T) {
+	extractor := &gildasai.Extractor{
+		Detector: &gildasaitest.Detector{Detections: [][]gildasai.Detection{{
+			{Box: image.Rect(50, 50, 150, 150), Score: 0.9},
+		}}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+	batches := map[string]*gildasai.Batch{}
+
+	r := testRouter()
+	r.POST("/faces", FacesPostBatchHandler(extractor, batches))
+	r.GET("/faces/batch/:batchID", FacesGetBatchHandler(batches))
+	r.GET("/faces/batch/:batchID/sources/:name", FaceSourceHandler(batches))
+	r.GET("/faces/batch/:batchID/cropped/:name", FaceCroppedHandler(batches))
+
+	body, contentType := zipUpload(t, "a.png", "b.png")
+	req := httptest.NewRequest(http.MethodPost, "/faces", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusFound, w.Code)
+	location := w.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/faces/batch/"), location)
+	require.Len(t, batches, 1)
+
+	w = get(r, location)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), location+"/cropped/0.jpg")
+	assert.Contains(t, w.Body.String(), location+"/sources/a.png")
+
+	w = get(r, location+"/cropped/1.jpg?resize=50")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+
+	w = get(r, location+"/sources/b.png")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = get(r, location+"/cropped/2.jpg")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(r, "/faces/batch/unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -2,35 +2,160 @@ package api
 
 import (
 	"bytes"
-	"database/sql"
-	"encoding/base32"
-	"encoding/json"
 	"fmt"
+	"html/template"
 	"image"
 	"image/draw"
 	"image/jpeg"
 	"net/http"
 	"sort"
-	"strings"
+	"strconv"
+	"sync"
 
 	gildasai "github.com/gildasch/gildas-ai"
 	"github.com/gildasch/gildas-ai/imageutils"
-	"github.com/gildasch/gildas-ai/sqlite"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 )
 
-func FacesearchHandler(store *sqlite.Store, clusters *FaceClusters) gin.HandlerFunc {
+// FacesearchStore holds the faces and their neighbours
+type FacesearchStore interface {
+	gildasai.FaceStore
+	gildasai.FaceNeighbourStore
+}
+
+// FacesearchHandler shows the biggest clusters or, given a photo
+// uploaded as image or at imageurl, the faces of clusters and the
+// clusters matching its faces. Without html, it returns the matches as
+// JSON.
+func FacesearchHandler(extractor *gildasai.Extractor, clusters *FaceClusters, html bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.HTML(http.StatusOK, "facesearch.html", gin.H{
-			"Clusters": clusters.Best(100),
-		})
+		img, _, err := requestImage(c, "image", "imageurl", true)
+		if err != nil {
+			abortWithImageError(c, err)
+			return
+		}
+		if img == nil && !html {
+			abortWithImageError(c, errNoImage("image", "imageurl"))
+			return
+		}
+		if img == nil {
+			c.HTML(http.StatusOK, "facesearch.html", gin.H{
+				"Clusters": clusters.Best(100),
+			})
+			return
+		}
+
+		searched, err := searchFaces(extractor, clusters, img)
+		if err != nil {
+			fmt.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if html {
+			c.HTML(http.StatusOK, "facesearch.html", gin.H{
+				"Searched": true,
+				"Search":   searched,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, searched)
 	}
 }
 
-func FacesearchDetectionHandler(store *sqlite.Store, clusters *FaceClusters) gin.HandlerFunc {
+// maxSearchMatches is the number of stored faces matching a searched face
+const maxSearchMatches = 20
+
+// SearchedFace is a face of a searched photo with the stored faces, and
+// the clusters, matching it by increasing distance
+type SearchedFace struct {
+	Box       image.Rectangle
+	Score     float32
+	Thumbnail template.URL
+	Faces     []SearchMatch
+	Clusters  []SearchMatch
+}
+
+// SearchMatch is a stored face, or the first face of a cluster at the
+// distance of its face matching, with its thumbnail
+type SearchMatch struct {
+	Detection
+	// Matches are the other faces of the cluster
+	Matches   int `json:",omitempty"`
+	Thumbnail string
+}
+
+func searchMatch(d Detection, matches int) SearchMatch {
+	return SearchMatch{
+		Detection: d,
+		Matches:   matches,
+		Thumbnail: fmt.Sprintf("/facesearch/%d/detection.jpg", d.FaceID),
+	}
+}
+
+// searchFaces extracts the faces of img and returns them with the faces
+// indexed by clusters and the clusters matching them. The store is not
+// read: the faces are those of the last update of clusters.
+func searchFaces(extractor *gildasai.Extractor, clusters *FaceClusters, img image.Image) ([]*SearchedFace, error) {
+	searched := []*SearchedFace{}
+	items, err := extractor.ExtractItems(img)
+	if err != nil && err != gildasai.ErrNoFaceDetected {
+		return nil, errors.Wrap(err, "error extracting the faces")
+	}
+	if len(items) == 0 {
+		return searched, nil
+	}
+
+	for _, item := range items {
+		s := &SearchedFace{
+			Box:       item.Detection.Box,
+			Score:     item.Detection.Score,
+			Thumbnail: template.URL(toHTMLBase64(cropImage(img, item.Detection.Box))),
+		}
+
+		inClusters := map[int64]bool{}
+		for _, n := range clusters.Nearest(item.Descriptors, maxSearchMatches, gildasai.MaxFaceDistance) {
+			s.Faces = append(s.Faces, searchMatch(detectionOf(n.Face, n.Distance), 0))
+
+			cluster := clusters.Find(n.Face.ID)
+			if cluster == nil || inClusters[cluster.FaceID] {
+				continue
+			}
+			inClusters[cluster.FaceID] = true
+			first := cluster.Detection
+			first.Distance = n.Distance
+			s.Clusters = append(s.Clusters, searchMatch(first, cluster.Matches))
+		}
+
+		searched = append(searched, s)
+	}
+
+	return searched, nil
+}
+
+// FacesearchDetectionHandler shows the nearest neighbours of a face
+func FacesearchDetectionHandler(store FacesearchStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		match := clusters.Find(c.Param("detection"))
+		faceID, err := strconv.ParseInt(c.Param("detection"), 10, 64)
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		neighbours, ok, err := store.GetNeighbours(faceID)
+		if err != nil {
+			fmt.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		match := neighboursMatches(neighbours)
 
 		c.HTML(http.StatusOK, "facesearch.html", gin.H{
 			"Clusters": []*Matches{match},
@@ -39,21 +164,21 @@ func FacesearchDetectionHandler(store *sqlite.Store, clusters *FaceClusters) gin
 	}
 }
 
-func FacesearchAgainstHandler(store *sqlite.Store) gin.HandlerFunc {
+func FacesearchAgainstHandler(store FacesearchStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id1, network1, detectionJSON1, err := readDetectionID(c.Param("detection"))
+		faceID1, err := strconv.ParseInt(c.Param("detection"), 10, 64)
 		if err != nil {
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
 
-		id2, network2, detectionJSON2, err := readDetectionID(c.Param("detection2"))
+		faceID2, err := strconv.ParseInt(c.Param("detection2"), 10, 64)
 		if err != nil {
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
 
-		match, err := against(store, id1, network1, detectionJSON1, id2, network2, detectionJSON2)
+		match, err := against(store, faceID1, faceID2)
 		if err != nil {
 			fmt.Println(err)
 			c.AbortWithStatus(http.StatusInternalServerError)
@@ -67,68 +192,173 @@ func FacesearchAgainstHandler(store *sqlite.Store) gin.HandlerFunc {
 	}
 }
 
-func FacesearchDetectionImageHandler() gin.HandlerFunc {
+func FacesearchDetectionImageHandler(store FacesearchStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, _, detectionJSON, err := readDetectionID(c.Param("detection"))
-		if err != nil {
-			c.AbortWithStatus(http.StatusBadRequest)
+		item, ok := getFace(c, store)
+		if !ok {
 			return
 		}
 
-		var detection gildasai.Detection
-		err = json.Unmarshal([]byte(detectionJSON), &detection)
-		if err != nil {
-			c.AbortWithStatus(http.StatusBadRequest)
+		c.Data(200, "image/jpeg", crop(item.Identifier, item.Detection.Box))
+	}
+}
+
+func FacesearchLandmarkImageHandler(store FacesearchStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		item, ok := getFace(c, store)
+		if !ok {
 			return
 		}
 
-		c.Data(200, "image/jpeg", crop(id, detection.Box))
+		c.Data(200, "image/jpeg", landmarksImage(item.Landmarks))
+	}
+}
+
+// getFace returns the face whose ID is the detection param, or aborts
+// the request and returns false
+func getFace(c *gin.Context, store FacesearchStore) (*gildasai.FaceItem, bool) {
+	faceID, err := strconv.ParseInt(c.Param("detection"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return nil, false
+	}
+
+	item, ok, err := store.GetFace(faceID)
+	if err != nil {
+		fmt.Println(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
 	}
+	if !ok {
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	return item, true
 }
 
-func FacesearchLandmarkImageHandler(store *sqlite.Store) gin.HandlerFunc {
+const maxAveragedFaces = 50
+
+func FacesearchAverageImageHandler(store FacesearchStore, clusters *FaceClusters) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, network, detectionJSON, err := readDetectionID(c.Param("detection"))
+		faceID, err := strconv.ParseInt(c.Param("detection"), 10, 64)
 		if err != nil {
 			c.AbortWithStatus(http.StatusBadRequest)
 			return
 		}
 
-		var landmarksJSON string
-		err = store.QueryRow(`
-select landmarks from faces
-where id = $1 and network = $2 and detection = $3
-`, id, network, detectionJSON).Scan(&landmarksJSON)
-		if err == sql.ErrNoRows {
+		match := clusters.Find(faceID)
+		if match == nil {
 			c.AbortWithStatus(http.StatusNotFound)
 			return
 		}
+
+		detections := append([]Detection{match.Detection}, match.Detections...)
+		if len(detections) > maxAveragedFaces {
+			detections = detections[:maxAveragedFaces]
+		}
+
+		items, sources, err := faceItemsWithSources(store, detections)
 		if err != nil {
 			fmt.Println(err)
-			c.AbortWithStatus(http.StatusBadRequest)
+			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 
-		var landmarks gildasai.Landmarks
-		err = json.Unmarshal([]byte(landmarksJSON), &landmarks)
+		average, err := gildasai.AverageFace(items, sources)
 		if err != nil {
+			fmt.Println(err)
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 
-		c.Data(200, "image/jpeg", landmarksImage(landmarks))
+		var b bytes.Buffer
+		err = jpeg.Encode(&b, average, nil)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Data(200, "image/jpeg", b.Bytes())
 	}
 }
 
+func faceItemsWithSources(store FacesearchStore, detections []Detection) ([]gildasai.FaceItem, []image.Image, error) {
+	var items []gildasai.FaceItem
+	var sources []image.Image
+	loaded := map[string]image.Image{}
+
+	for _, d := range detections {
+		item, ok, err := store.GetFace(d.FaceID)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error reading landmarks of face %d", d.FaceID)
+		}
+		if !ok {
+			return nil, nil, errors.Errorf("face %d not found", d.FaceID)
+		}
+
+		source, ok := loaded[d.ID]
+		if !ok {
+			source, err = imageutils.FromFile(d.ID)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "error reading source image %q", d.ID)
+			}
+			loaded[d.ID] = source
+		}
+
+		items = append(items, gildasai.FaceItem{
+			Detection: item.Detection,
+			Landmarks: item.Landmarks,
+		})
+		sources = append(sources, source)
+	}
+
+	return items, sources, nil
+}
+
 const (
 	threshold = 0.35
 )
 
+// FaceClusters are the clusters of the face graph, along with the index
+// of the descriptors of the faces searched by FacesearchHandler
 type FaceClusters struct {
-	Clusters map[string]*Matches
+	mu       sync.RWMutex
+	Clusters map[int64]*Matches
+	index    *gildasai.FaceIndex
+}
+
+// Update calculates again the clusters and the index of the faces of
+// store, once its face graph is updated
+func (fc *FaceClusters) Update(store FacesearchStore) error {
+	updated, err := CalculateClusters(store)
+	if err != nil {
+		return err
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.Clusters = updated.Clusters
+	fc.index = updated.index
+	return nil
+}
+
+// Nearest returns the n indexed faces nearest to descriptors, up to
+// maxDistance, by increasing distance
+func (fc *FaceClusters) Nearest(descriptors gildasai.Descriptors, n int, maxDistance float32) []gildasai.FaceNeighbour {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	if fc.index == nil {
+		return nil
+	}
+	return fc.index.Nearest(descriptors, n, maxDistance)
 }
 
 func (fc *FaceClusters) Best(n int) []*Matches {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
 	var l []*Matches
 	for _, m := range fc.Clusters {
 		l = append(l, m)
@@ -142,14 +372,17 @@ func (fc *FaceClusters) Best(n int) []*Matches {
 	return l[:n]
 }
 
-func (fc *FaceClusters) Find(detectionID string) *Matches {
+func (fc *FaceClusters) Find(faceID int64) *Matches {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
 	for _, m := range fc.Clusters {
-		if detectionID == m.DetectionID {
+		if faceID == m.FaceID {
 			return m
 		}
 
 		for _, d := range m.Detections {
-			if detectionID == d.DetectionID {
+			if faceID == d.FaceID {
 				return m
 			}
 		}
@@ -159,11 +392,25 @@ func (fc *FaceClusters) Find(detectionID string) *Matches {
 }
 
 type Detection struct {
-	DetectionID                string
-	ID, Network, DetectionJSON string
-	Distance                   float32
-	Score                      float32
-	Class                      float32
+	FaceID      int64
+	ID, Network string
+	Box         image.Rectangle
+	Distance    float32
+	Score       float32
+	Class       float32
+}
+
+// detectionOf returns the Detection of face at distance
+func detectionOf(face *gildasai.FaceItem, distance float32) Detection {
+	return Detection{
+		FaceID:   face.ID,
+		ID:       face.Identifier,
+		Network:  face.Network,
+		Box:      face.Detection.Box,
+		Distance: distance,
+		Score:    face.Detection.Score,
+		Class:    face.Detection.Class,
+	}
 }
 
 type Matches struct {
@@ -173,254 +420,111 @@ type Matches struct {
 	Detections  []Detection
 }
 
-func CalculateClusters(store *sqlite.Store) (*FaceClusters, error) {
-	rows, err := store.Query(`
-select id1, network1, detection1, id2, network2, detection2, distance
-from face_distances
-where distance != 0
-order by distance
-`)
+// CalculateClusters groups the faces of the graph of store linked by
+// neighbours up to threshold, and indexes the descriptors of the faces
+func CalculateClusters(store FacesearchStore) (*FaceClusters, error) {
+	nodes, err := store.GetAllNeighbours()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	faces, err := store.GetAllFaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the faces")
+	}
 
 	clusters := &FaceClusters{
-		Clusters: map[string]*Matches{},
-	}
-	whereIs := map[string]string{}
-	for rows.Next() {
-		var id1, network1, detectionJSON1 string
-		var id2, network2, detectionJSON2 string
-		var distance float32
-		err := rows.Scan(
-			&id1, &network1, &detectionJSON1,
-			&id2, &network2, &detectionJSON2,
-			&distance)
-		if err != nil {
-			return nil, err
-		}
-
-		detectionID1 := makeDetectionID(id1, network1, detectionJSON1)
-		detectionID2 := makeDetectionID(id2, network2, detectionJSON2)
-
-		at1, ok1 := whereIs[detectionID1]
-		at2, ok2 := whereIs[detectionID2]
-
-		if ok1 && ok2 && at1 == at2 {
-			continue
-		}
-
-		if distance > threshold {
-			if !ok1 {
-				clusters.Clusters[detectionID1] = &Matches{
-					Detection: Detection{
-						DetectionID:   detectionID1,
-						ID:            id1,
-						Network:       network1,
-						DetectionJSON: detectionJSON1,
-					},
-					Matches:     0,
-					AvgDistance: 0,
-				}
-				whereIs[detectionID1] = detectionID1
-			}
-
-			if !ok2 {
-				clusters.Clusters[detectionID2] = &Matches{
-					Detection: Detection{
-						DetectionID:   detectionID2,
-						ID:            id2,
-						Network:       network2,
-						DetectionJSON: detectionJSON2,
-					},
-					Matches:     0,
-					AvgDistance: 0,
+		Clusters: map[int64]*Matches{},
+		index:    gildasai.NewFaceIndex(faces),
+	}
+	for _, component := range gildasai.Components(nodes, threshold) {
+		// the distance of each face to its nearest face of the cluster
+		nearest := map[int64]float32{}
+		for _, l := range component.Links {
+			for _, face := range []*gildasai.FaceItem{l.Face1, l.Face2} {
+				if d, ok := nearest[face.ID]; !ok || l.Distance < d {
+					nearest[face.ID] = l.Distance
 				}
-				whereIs[detectionID2] = detectionID2
 			}
-
-			continue
 		}
 
-		if !ok1 && !ok2 {
-			clusters.Clusters[detectionID1] = &Matches{
-				Detection: Detection{
-					DetectionID:   detectionID1,
-					ID:            id1,
-					Network:       network1,
-					DetectionJSON: detectionJSON1,
-				},
-				Matches:     1,
-				AvgDistance: distance,
-				Detections: []Detection{
-					{
-						DetectionID:   detectionID2,
-						ID:            id2,
-						Network:       network2,
-						DetectionJSON: detectionJSON2,
-						Distance:      distance,
-					},
-				},
-			}
-			whereIs[detectionID1] = detectionID1
-			whereIs[detectionID2] = detectionID1
-
-			continue
+		first := component.Faces[0]
+		m := &Matches{
+			Detection: detectionOf(first, 0),
+			Matches:   len(component.Links),
 		}
-
-		if !ok1 {
-			clusters.Clusters[at2].Matches++
-			clusters.Clusters[at2].AvgDistance += distance
-			clusters.Clusters[at2].Detections = append(clusters.Clusters[at2].Detections,
-				Detection{
-					DetectionID:   detectionID1,
-					ID:            id1,
-					Network:       network1,
-					DetectionJSON: detectionJSON1,
-					Distance:      distance,
-				})
-			whereIs[detectionID1] = at2
-
-			continue
+		for _, face := range component.Faces[1:] {
+			m.Detections = append(m.Detections, detectionOf(face, nearest[face.ID]))
 		}
-
-		if !ok2 {
-			clusters.Clusters[at1].Matches++
-			clusters.Clusters[at1].AvgDistance += distance
-			clusters.Clusters[at1].Detections = append(clusters.Clusters[at1].Detections,
-				Detection{
-					DetectionID:   detectionID2,
-					ID:            id2,
-					Network:       network2,
-					DetectionJSON: detectionJSON2,
-					Distance:      distance,
-				})
-			whereIs[detectionID2] = at1
-
-			continue
-		}
-
-		clusters.Clusters[at1].Matches += clusters.Clusters[at2].Matches + 1
-		clusters.Clusters[at1].AvgDistance += clusters.Clusters[at2].AvgDistance + distance
-		clusters.Clusters[at1].Detections = append(clusters.Clusters[at1].Detections,
-			Detection{
-				DetectionID:   detectionID2,
-				ID:            id2,
-				Network:       network2,
-				DetectionJSON: detectionJSON2,
-				Distance:      distance,
-			})
-		clusters.Clusters[at1].Detections = append(clusters.Clusters[at1].Detections,
-			clusters.Clusters[at2].Detections...)
-		whereIs[detectionID2] = at1
-
-		var toUpdate []string
-		for id, at := range whereIs {
-			if at == at2 {
-				whereIs[id] = at1
-			}
+		sort.SliceStable(m.Detections, func(i, j int) bool {
+			return m.Detections[i].Distance < m.Detections[j].Distance
+		})
+		for _, l := range component.Links {
+			m.AvgDistance += l.Distance
 		}
-		for _, id := range toUpdate {
-			whereIs[id] = at1
+		if m.Matches > 0 {
+			m.AvgDistance = m.AvgDistance / float32(m.Matches)
 		}
 
-		delete(clusters.Clusters, at2)
+		clusters.Clusters[first.ID] = m
 	}
 
-	for _, m := range clusters.Clusters {
+	return clusters, nil
+}
+
+// neighboursMatches returns the matches of a face with its neighbours
+func neighboursMatches(n *gildasai.FaceNeighbours) *Matches {
+	m := &Matches{
+		Detection: detectionOf(n.Face, 0),
+		Matches:   len(n.Neighbours),
+	}
+	for _, neighbour := range n.Neighbours {
+		m.AvgDistance += neighbour.Distance
+		m.Detections = append(m.Detections, detectionOf(neighbour.Face, neighbour.Distance))
+	}
+	if m.Matches > 0 {
 		m.AvgDistance = m.AvgDistance / float32(m.Matches)
 	}
-
-	return clusters, nil
+	return m
 }
 
-func against(store *sqlite.Store, id1, network1, detectionJSON1, id2, network2, detectionJSON2 string) (*Matches, error) {
-	var descrsJSON1 string
-	err := store.QueryRow(`
-select descriptors
-from faces
-where id = $1 and network = $2 and detection = $3
-`, id1, network1, detectionJSON1).Scan(&descrsJSON1)
+func against(store FacesearchStore, faceID1, faceID2 int64) (*Matches, error) {
+	item1, ok, err := store.GetFace(faceID1)
 	if err != nil {
 		return nil, err
 	}
-	var descrs1 gildasai.Descriptors
-	err = json.Unmarshal([]byte(descrsJSON1), &descrs1)
-	if err != nil {
-		return nil, err
+	if !ok {
+		return nil, errors.Errorf("face %d not found", faceID1)
 	}
 
-	var descrsJSON2 string
-	err = store.QueryRow(`
-select descriptors
-from faces
-where id = $1 and network = $2 and detection = $3
-`, id2, network2, detectionJSON2).Scan(&descrsJSON2)
+	item2, ok, err := store.GetFace(faceID2)
 	if err != nil {
 		return nil, err
 	}
-	var descrs2 gildasai.Descriptors
-	err = json.Unmarshal([]byte(descrsJSON2), &descrs2)
-	if err != nil {
-		return nil, err
+	if !ok {
+		return nil, errors.Errorf("face %d not found", faceID2)
 	}
 
-	distance, err := descrs1.DistanceTo(descrs2)
+	distance, err := item1.Descriptors.DistanceTo(item2.Descriptors)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Matches{
-		Detection: Detection{
-			DetectionID:   makeDetectionID(id1, network1, detectionJSON1),
-			ID:            id1,
-			Network:       network1,
-			DetectionJSON: detectionJSON1,
-		},
+		Detection:   detectionOf(item1, 0),
 		Matches:     1,
 		AvgDistance: distance,
-		Detections: []Detection{
-			{
-				DetectionID:   makeDetectionID(id2, network2, detectionJSON2),
-				ID:            id2,
-				Network:       network2,
-				DetectionJSON: detectionJSON2,
-				Distance:      distance,
-			},
-		},
+		Detections:  []Detection{detectionOf(item2, distance)},
 	}, nil
 }
 
-func makeDetectionID(id, network, detectionJSON string) string {
-	return base32.StdEncoding.EncodeToString([]byte(id + "|" + network + "|" + detectionJSON))
-}
-
-func readDetectionID(detectionID string) (id, network, detectionJSON string, err error) {
-	data, err := base32.StdEncoding.DecodeString(detectionID)
-	if err != nil {
-		return "", "", "", err
-	}
-
-	splitted := strings.Split(string(data), "|")
-	if len(splitted) != 3 {
-		return "", "", "", errors.Errorf("expected 3 parts in detectionID, found %d", len(splitted))
-	}
-
-	return splitted[0], splitted[1], splitted[2], nil
-}
-
 func crop(filename string, box image.Rectangle) []byte {
 	img, err := imageutils.FromFile(filename)
 	if err != nil {
 		return []byte{}
 	}
 
-	out := image.NewRGBA(box)
-	draw.Draw(out, out.Bounds(), img, box.Min, draw.Src)
-
 	var b bytes.Buffer
-	err = jpeg.Encode(&b, out, nil)
+	err = jpeg.Encode(&b, cropImage(img, box), nil)
 	if err != nil {
 		return []byte{}
 	}
@@ -428,6 +532,12 @@ func crop(filename string, box image.Rectangle) []byte {
 	return b.Bytes()
 }
 
+func cropImage(img image.Image, box image.Rectangle) image.Image {
+	out := image.NewRGBA(box)
+	draw.Draw(out, out.Bounds(), img, box.Min, draw.Src)
+	return out
+}
+
 func landmarksImage(landmarks gildasai.Landmarks) []byte {
 	out := landmarks.DrawOnImage(image.NewRGBA(image.Rect(0, 0, 200, 200)))
 
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func facesearchStore(t *testing.T) (*memory.Store, []*gildasai.FaceItem) {
+	store := memory.NewStore()
+
+	faces := []*gildasai.FaceItem{
+		{Identifier: "a.jpg", Network: "face-api-js", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10), Score: 0.95}, Landmarks: gildasaitest.FaceLandmarks, Descriptors: gildasai.Descriptors{0, 0}},
+		{Identifier: "b.jpg", Network: "face-api-js", Detection: gildasai.Detection{Box: image.Rect(0, 0, 20, 20), Score: 0.95}, Landmarks: gildasaitest.FaceLandmarks, Descriptors: gildasai.Descriptors{0, 0.2}},
+		{Identifier: "c.jpg", Network: "face-api-js", Detection: gildasai.Detection{Box: image.Rect(0, 0, 30, 30), Score: 0.95}, Landmarks: gildasaitest.FaceLandmarks, Descriptors: gildasai.Descriptors{1, 1}},
+	}
+	for _, f := range faces {
+		require.NoError(t, store.StoreFace(f))
+	}
+	require.NoError(t, store.StoreNeighbours([]*gildasai.FaceNeighbours{
+		{Face: faces[0], Neighbours: []gildasai.FaceNeighbour{{Face: faces[1], Distance: 0.2}}},
+		{Face: faces[1], Neighbours: []gildasai.FaceNeighbour{{Face: faces[0], Distance: 0.2}, {Face: faces[2], Distance: 1.28}}},
+		{Face: faces[2], Neighbours: []gildasai.FaceNeighbour{{Face: faces[1], Distance: 1.28}}},
+	}))
+
+	return store, faces
+}
+
+func TestCalculateClusters(t *testing.T) {
+	store, faces := facesearchStore(t)
+
+	clusters, err := CalculateClusters(store)
+	require.NoError(t, err)
+	require.Len(t, clusters.Clusters, 2)
+
+	match := clusters.Find(faces[1].ID)
+	require.NotNil(t, match)
+	assert.Equal(t, faces[0].ID, match.FaceID)
+	assert.Equal(t, "a.jpg", match.ID)
+	assert.Equal(t, image.Rect(0, 0, 10, 10), match.Box)
+	assert.Equal(t, 1, match.Matches)
+	assert.Equal(t, float32(0.2), match.AvgDistance)
+
+	alone := clusters.Find(faces[2].ID)
+	require.NotNil(t, alone)
+	assert.Equal(t, 0, alone.Matches)
+	assert.Equal(t, float32(0), alone.AvgDistance)
+}
+
+func TestFacesearchDetectionHandler(t *testing.T) {
+	store, faces := facesearchStore(t)
+
+	r := testRouter()
+	r.GET("/facesearch/:detection/matches", FacesearchDetectionHandler(store))
+
+	w := get(r, "/facesearch/"+strconv.FormatInt(faces[1].ID, 10)+"/matches")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Matches: 2")
+	assert.Contains(t, w.Body.String(), "/facesearch/"+strconv.FormatInt(faces[2].ID, 10)+"/detection.jpg")
+
+	w = get(r, "/facesearch/"+strconv.FormatInt(faces[2].ID+1, 10)+"/matches")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFacesearchHandler(t *testing.T) {
+	server := imageServer()
+	defer server.Close()
+
+	store, faces := facesearchStore(t)
+	clusters, err := CalculateClusters(store)
+	require.NoError(t, err)
+
+	extractor := &gildasai.Extractor{
+		Detector: &gildasaitest.Detector{Detections: [][]gildasai.Detection{{
+			{Box: image.Rect(50, 50, 150, 150), Score: 0.95},
+		}}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{Descriptors: []gildasai.Descriptors{{0, 0.1}}},
+	}
+
+	r := testRouter()
+	r.GET("/facesearch", FacesearchHandler(extractor, clusters, true))
+	r.POST("/facesearch", FacesearchHandler(extractor, clusters, true))
+	r.GET("/api/facesearch", FacesearchHandler(extractor, clusters, false))
+
+	w := get(r, "/facesearch")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Matches: 1")
+	assert.Contains(t, w.Body.String(), "/facesearch/"+strconv.FormatInt(faces[0].ID, 10)+"/average.jpg",
+		"the clusters are shown with their average face")
+
+	w = get(r, "/api/facesearch")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(r, "/api/facesearch?imageurl="+url.QueryEscape(server.URL+"/missing.png"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(r, "/api/facesearch?imageurl="+url.QueryEscape(server.URL+"/image.png"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var searched []SearchedFace
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &searched))
+	require.Len(t, searched, 1)
+	assert.Equal(t, image.Rect(50, 50, 150, 150), searched[0].Box)
+	require.Len(t, searched[0].Faces, 2, "the third face is too far")
+	assert.Equal(t, faces[0].ID, searched[0].Faces[0].FaceID)
+	assert.InDelta(t, 0.1, searched[0].Faces[0].Distance, 1e-6)
+	assert.Equal(t, "/facesearch/"+strconv.FormatInt(faces[0].ID, 10)+"/detection.jpg", searched[0].Faces[0].Thumbnail)
+	assert.Equal(t, faces[1].ID, searched[0].Faces[1].FaceID)
+	require.Len(t, searched[0].Clusters, 1)
+	assert.Equal(t, faces[0].ID, searched[0].Clusters[0].FaceID)
+	assert.Equal(t, 1, searched[0].Clusters[0].Matches)
+
+	r.GET("/api/facesearch/none", FacesearchHandler(&gildasai.Extractor{
+		Detector:   &gildasaitest.Detector{},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}, clusters, false))
+	w = get(r, "/api/facesearch/none?imageurl="+url.QueryEscape(server.URL+"/image.png"))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	body, contentType := upload(t, map[string][]byte{"image": pngData(t, 200, 200)})
+	w = post(r, "/facesearch", body, contentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "data:image/jpeg;base64,")
+	assert.Contains(t, w.Body.String(), "/facesearch/"+strconv.FormatInt(faces[1].ID, 10)+"/detection.jpg")
+
+	// the searches use the faces indexed by the last update of the
+	// clusters
+	exact := &gildasai.FaceItem{Identifier: "d.jpg", Network: "face-api-js", Detection: gildasai.Detection{Box: image.Rect(0, 0, 40, 40), Score: 0.95}, Landmarks: gildasaitest.FaceLandmarks, Descriptors: gildasai.Descriptors{0, 0.1}}
+	require.NoError(t, store.StoreFace(exact))
+	search := func() []SearchedFace {
+		w := get(r, "/api/facesearch?imageurl="+url.QueryEscape(server.URL+"/image.png"))
+		require.Equal(t, http.StatusOK, w.Code)
+		var searched []SearchedFace
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &searched))
+		require.Len(t, searched, 1)
+		return searched
+	}
+	assert.Len(t, search()[0].Faces, 2)
+
+	require.NoError(t, clusters.Update(store))
+	searched = search()
+	require.Len(t, searched[0].Faces, 3)
+	assert.Equal(t, exact.ID, searched[0].Faces[0].FaceID)
+}
+
+func TestAgainst(t *testing.T) {
+	store, faces := facesearchStore(t)
+
+	match, err := against(store, faces[0].ID, faces[1].ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.2, match.AvgDistance, 1e-6)
+	assert.Equal(t, faces[0].ID, match.FaceID)
+	require.Len(t, match.Detections, 1)
+	assert.Equal(t, faces[1].ID, match.Detections[0].FaceID)
+
+	_, err = against(store, faces[0].ID, faces[2].ID+1)
+	assert.Error(t, err)
+}
+
+func TestFacesearchLandmarkImageHandler(t *testing.T) {
+	store, faces := facesearchStore(t)
+
+	r := testRouter()
+	r.GET("/facesearch/:detection/landmarks.jpg", FacesearchLandmarkImageHandler(store))
+
+	w := get(r, "/facesearch/"+strconv.FormatInt(faces[0].ID, 10)+"/landmarks.jpg")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+
+	w = get(r, "/facesearch/"+strconv.FormatInt(faces[2].ID+1, 10)+"/landmarks.jpg")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = get(r, "/facesearch/not-an-id/landmarks.jpg")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
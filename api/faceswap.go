@@ -18,36 +18,74 @@ import (
 	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/gildasch/gildas-ai/imageutils/gifutils"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 )
 
 func FaceSwapHandler(extractor *gildasai.Extractor, detector gildasai.Landmark) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		srcURL := strings.TrimPrefix(c.Query("src"), "/")
-		dstURL := strings.TrimPrefix(c.Query("dst"), "/")
-		blur, _ := strconv.ParseFloat(c.Query("blur"), 64)
+		srcURL := requestImageURL(c, "src")
+		dstURL := requestImageURL(c, "dst")
+		blur, _ := strconv.ParseFloat(requestParam(c, "blur"), 64)
+		regions := requestParam(c, "regions")
+		withQuality := requestParam(c, "quality") != ""
 
-		if srcURL == "" || dstURL == "" {
+		srcData, _, err := uploadedImage(c, "src", false)
+		if err != nil {
+			abortWithImageError(c, err)
+			return
+		}
+		dstData, dstType, err := uploadedImage(c, "dst", false)
+		if err != nil {
+			abortWithImageError(c, err)
+			return
+		}
+
+		if (srcData == nil && srcURL == "") || (dstData == nil && dstURL == "") {
 			c.HTML(http.StatusOK, "faceswap.html", gin.H{
-				"src": srcURL,
-				"dst": dstURL,
+				"src":     srcURL,
+				"dst":     dstURL,
+				"regions": regions,
 			})
 			return
 		}
 
-		src, err := imageutils.FromURL(srcURL)
-		if err != nil {
+		opts, ok := swapOptions[regions]
+		if !ok {
 			c.AbortWithStatusJSON(
 				http.StatusBadRequest,
-				fmt.Sprintf("cannot read remote image %q: %v\n", srcURL, err))
+				fmt.Sprintf("unknown swap regions %q\n", regions))
 			return
 		}
+		opts.Blur = blur
 
-		if strings.Contains(strings.ToLower(dstURL), ".gif") {
-			dstGIF, err := imageutils.GIFFromURL(dstURL)
-			if err != nil {
+		src, _, err := requestImage(c, "src", "src", false)
+		if err != nil {
+			abortWithImageError(c, err)
+			return
+		}
+
+		if dstType == "image/gif" || (dstData == nil && strings.Contains(strings.ToLower(dstURL), ".gif")) {
+			if withQuality {
 				c.AbortWithStatusJSON(
 					http.StatusBadRequest,
-					fmt.Sprintf("cannot read remote image %q: %v\n", dstURL, err))
+					"the quality of the swap is not evaluated for gifs\n")
+				return
+			}
+
+			var dstGIF *gif.GIF
+			if dstData != nil {
+				dstGIF, err = imageutils.RemoteFetcher.DecodeGIF(dstData)
+				if err != nil {
+					err = errors.Wrap(err, "cannot decode gif uploaded as dst")
+				}
+			} else {
+				dstGIF, err = imageutils.GIFFromURL(dstURL)
+				if err != nil {
+					err = errors.Wrapf(err, "cannot read remote image %q", dstURL)
+				}
+			}
+			if err != nil {
+				abortWithImageError(c, err)
 				return
 			}
 
@@ -63,10 +101,10 @@ func FaceSwapHandler(extractor *gildasai.Extractor, detector gildasai.Landmark)
 			for i := 0; i < len(dstGIF.Image); i++ {
 				draw.Draw(dst, dstGIF.Image[i].Bounds(), dstGIF.Image[i], dstGIF.Image[i].Bounds().Min, draw.Over)
 
-				if blur == 0 {
-					blur = 0.7
+				if opts.Blur == 0 {
+					opts.Blur = 0.7
 				}
-				out, err := gildasai.FaceSwap(extractor, detector, dst, src, blur)
+				out, err := gildasai.FaceSwapWithOptions(extractor, detector, dst, src, opts)
 				if err != nil {
 					continue
 				}
@@ -76,24 +114,35 @@ func FaceSwapHandler(extractor *gildasai.Extractor, detector gildasai.Landmark)
 
 			outGIF, err := gifutils.MakeGIFFromImages(
 				outImages, time.Duration(dstGIF.Delay[0])*10*time.Millisecond, gifutils.StandardQuantizer{})
+			if err != nil {
+				c.AbortWithStatusJSON(
+					http.StatusInternalServerError,
+					fmt.Sprintf("error making gif: %v\n", err))
+				return
+			}
 
 			c.HTML(http.StatusOK, "faceswap.html", gin.H{
-				"src": srcURL,
-				"dst": dstURL,
-				"out": template.URL(toHTMLBase64GIF(outGIF)),
+				"src":     srcURL,
+				"dst":     dstURL,
+				"regions": regions,
+				"out":     template.URL(toHTMLBase64GIF(outGIF)),
 			})
 			return
 		}
 
-		dst, err := imageutils.FromURL(dstURL)
+		dst, _, err := requestImage(c, "dst", "dst", false)
 		if err != nil {
-			c.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				fmt.Sprintf("cannot read remote image %q: %v\n", dstURL, err))
+			abortWithImageError(c, err)
 			return
 		}
 
-		out, err := gildasai.FaceSwap(extractor, detector, dst, src, blur)
+		var out image.Image
+		var quality *gildasai.SwapQuality
+		if withQuality {
+			out, quality, err = gildasai.FaceSwapWithQuality(extractor, detector, dst, src, opts)
+		} else {
+			out, err = gildasai.FaceSwapWithOptions(extractor, detector, dst, src, opts)
+		}
 		if err != nil {
 			c.AbortWithStatusJSON(
 				http.StatusBadRequest,
@@ -102,13 +151,22 @@ func FaceSwapHandler(extractor *gildasai.Extractor, detector gildasai.Landmark)
 		}
 
 		c.HTML(http.StatusOK, "faceswap.html", gin.H{
-			"src": srcURL,
-			"dst": dstURL,
-			"out": template.URL(toHTMLBase64(out)),
+			"src":     srcURL,
+			"dst":     dstURL,
+			"regions": regions,
+			"quality": quality,
+			"out":     template.URL(toHTMLBase64(out)),
 		})
 	}
 }
 
+var swapOptions = map[string]gildasai.SwapOptions{
+	"":           {},
+	"face":       {},
+	"expression": gildasai.SwapExpressionPreserving,
+	"eyes":       gildasai.SwapEyesOnly,
+}
+
 func toHTMLBase64(img image.Image) string {
 	var buf bytes.Buffer
 	_ = jpeg.Encode(&buf, img, nil)
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"net/http"
+	"net/url"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaceSwapHandlerErrors(t *testing.T) {
+	server := imageServer()
+	defer server.Close()
+
+	detector := &gildasaitest.Detector{}
+	extractor := &gildasai.Extractor{
+		Detector:   detector,
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+
+	r := testRouter()
+	r.GET("/faceswap", FaceSwapHandler(extractor, &gildasaitest.Landmark{}))
+
+	w := get(r, "/faceswap")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	imageURL := url.QueryEscape(server.URL + "/image.png")
+
+	w = get(r, "/faceswap?src="+imageURL+"&dst="+imageURL+"&regions=nose")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get(r, "/faceswap?src="+url.QueryEscape(server.URL+"/missing.png")+"&dst="+imageURL)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// no face is detected on the source
+	w = get(r, "/faceswap?src="+imageURL+"&dst="+imageURL)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 1, detector.CallCount("Detect"))
+
+	r.POST("/faceswap", FaceSwapHandler(extractor, &gildasaitest.Landmark{}))
+
+	// only the source is uploaded
+	body, contentType := upload(t, map[string][]byte{"src": pngData(t, 400, 200)})
+	w = post(r, "/faceswap", body, contentType)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body, contentType = upload(t, map[string][]byte{"src": pngData(t, 400, 200), "dst": []byte("not an image")})
+	w = post(r, "/faceswap", body, contentType)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// no face is detected on the uploaded source
+	body, contentType = upload(t, map[string][]byte{"src": pngData(t, 400, 200), "dst": pngData(t, 400, 200)})
+	w = post(r, "/faceswap", body, contentType)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 2, detector.CallCount("Detect"))
+
+	// the quality of the swap of a gif is not evaluated
+	var g bytes.Buffer
+	require.NoError(t, gif.Encode(&g, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil))
+	body, contentType = upload(t, map[string][]byte{"src": pngData(t, 400, 200), "dst": g.Bytes()})
+	w = post(r, "/faceswap?quality=1", body, contentType)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "not evaluated for gifs")
+	assert.Equal(t, 2, detector.CallCount("Detect"))
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// maxUploadSize is the maximum size of an uploaded image
+const maxUploadSize = 32 << 20
+
+// errImageTooLarge is returned for the images uploaded above maxUploadSize
+var errImageTooLarge = errors.Wrapf(imageutils.ErrTooLarge, "upload larger than %d bytes", maxUploadSize)
+
+// errNoImage is returned when no image is uploaded as field nor given by
+// the URL of urlParam
+func errNoImage(field, urlParam string) error {
+	return errors.Errorf("no image uploaded as %s nor given as %s", field, urlParam)
+}
+
+// abortWithImageError aborts the request with err, as a 413 when the
+// image is too large and a 400 otherwise
+func abortWithImageError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch errors.Cause(err) {
+	case imageutils.ErrTooLarge, imageutils.ErrTooManyPixels:
+		status = http.StatusRequestEntityTooLarge
+	}
+	c.AbortWithStatusJSON(status, err.Error())
+}
+
+// requestImage returns the image of the request: uploaded as the field of
+// a multipart form, sent as the body of the request when raw is true or,
+// else, at the URL of the urlParam param, then also returned. The image
+// is nil when none is given.
+func requestImage(c *gin.Context, field, urlParam string, raw bool) (image.Image, string, error) {
+	data, _, err := uploadedImage(c, field, raw)
+	if err != nil {
+		return nil, "", err
+	}
+	if data != nil {
+		img, err := imageutils.RemoteFetcher.Decode(data)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "cannot decode image uploaded as %s", field)
+		}
+		return img, "", nil
+	}
+
+	imageURL := requestImageURL(c, urlParam)
+	if imageURL == "" {
+		return nil, "", nil
+	}
+
+	img, err := imageutils.FromURL(imageURL)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "cannot read remote image %q", imageURL)
+	}
+	return img, imageURL, nil
+}
+
+// requestImageURL returns the URL of the urlParam param
+func requestImageURL(c *gin.Context, urlParam string) string {
+	return strings.TrimPrefix(requestParam(c, urlParam), "/")
+}
+
+// requestParam returns the value of the query param name or, else, of
+// the form field name
+func requestParam(c *gin.Context, name string) string {
+	if v := c.Query(name); v != "" {
+		return v
+	}
+	return c.PostForm(name)
+}
+
+// uploadedImage returns the content, and its detected type, of the image
+// uploaded as the field of a multipart form or, when raw is true, sent as
+// the body of the request. It returns nil when none is uploaded.
+func uploadedImage(c *gin.Context, field string, raw bool) ([]byte, string, error) {
+	contentType := c.ContentType()
+	multipart := strings.HasPrefix(contentType, "multipart/form-data")
+	if !multipart && (!raw || c.Request.Body == nil || c.Request.Method == http.MethodGet) {
+		return nil, "", nil
+	}
+	// clients like curl send raw bodies as forms by default
+	form := contentType == "application/x-www-form-urlencoded"
+
+	if c.Request.ContentLength > maxUploadSize && !multipart {
+		return nil, "", errImageTooLarge
+	}
+
+	var r io.Reader
+	if multipart {
+		// leave room for the other fields of the form
+		if c.Request.ContentLength > 2*maxUploadSize {
+			return nil, "", errImageTooLarge
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*maxUploadSize)
+
+		fileHeader, err := c.FormFile(field)
+		if err == http.ErrMissingFile {
+			return nil, "", nil
+		}
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "cannot read image uploaded as %s", field)
+		}
+		if fileHeader.Size > maxUploadSize {
+			return nil, "", errImageTooLarge
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "cannot open image uploaded as %s", field)
+		}
+		defer file.Close()
+		r = file
+	} else {
+		r = c.Request.Body
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxUploadSize+1))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "cannot read image uploaded as %s", field)
+	}
+	if len(data) > maxUploadSize {
+		return nil, "", errImageTooLarge
+	}
+	if len(data) == 0 && !multipart {
+		return nil, "", nil
+	}
+
+	detected := http.DetectContentType(data)
+	if form && !strings.HasPrefix(detected, "image/") {
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+		return nil, "", nil
+	}
+	if !strings.HasPrefix(detected, "image/") {
+		return nil, "", errors.Errorf("unsupported content type %q for image uploaded as %s", detected, field)
+	}
+
+	return data, detected, nil
+}
+
+// imageSrc returns the src of an img element showing img, its URL unless
+// it was uploaded
+func imageSrc(img image.Image, imageURL string) interface{} {
+	if imageURL != "" {
+		return imageURL
+	}
+	return template.URL(toHTMLBase64(img))
+}
+
+// jpegSrc returns the src of an img element showing the JPEG of data
+func jpegSrc(data []byte) template.URL {
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data))
+}
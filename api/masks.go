@@ -10,7 +10,6 @@ import (
 	"time"
 
 	gildasai "github.com/gildasch/gildas-ai"
-	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -25,15 +24,37 @@ type MaskResult struct {
 
 func MaskHandler(detector Detector, store map[string]MaskResult) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		imageURL := strings.TrimPrefix(c.Query("imageurl"), "/")
+		img, imageURL, err := requestImage(c, "image", "imageurl", true)
+		if err != nil {
+			abortWithImageError(c, err)
+			return
+		}
 
-		if imageURL == "" {
+		if img == nil {
 			c.HTML(http.StatusOK, "masks.html", nil)
 			return
 		}
 
+		// the results of the uploaded images are not kept
+		if imageURL == "" {
+			res, err := calculateMask(detector, img)
+			if err != nil {
+				c.AbortWithStatusJSON(
+					http.StatusBadRequest,
+					fmt.Sprintf("error processing uploaded image: %v\n", err))
+				return
+			}
+
+			c.HTML(http.StatusOK, "masks.html", gin.H{
+				"imageURL":     imageSrc(img, imageURL),
+				"maskImageURL": jpegSrc(res.jpgData),
+				"elapsed":      res.elapsed,
+			})
+			return
+		}
+
 		if _, ok := store[imageURL]; !ok {
-			res, err := calculateMask(detector, imageURL)
+			res, err := calculateMask(detector, img)
 			if err != nil {
 				c.AbortWithStatusJSON(
 					http.StatusBadRequest,
@@ -66,12 +87,7 @@ func MaskImageHandler(store map[string]MaskResult) gin.HandlerFunc {
 	}
 }
 
-func calculateMask(detector Detector, imageURL string) (*MaskResult, error) {
-	img, err := imageutils.FromURL(imageURL)
-	if err != nil {
-		return nil, err
-	}
-
+func calculateMask(detector Detector, img image.Image) (*MaskResult, error) {
 	start := time.Now()
 	masks, err := detector.Detect(img)
 	if err != nil {
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"net/http"
+	"net/url"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskHandler(t *testing.T) {
+	server := imageServer()
+	defer server.Close()
+
+	detector := &gildasaitest.MaskDetector{Masks: [][]gildasai.Mask{{{
+		Box:   image.Rect(10, 10, 50, 50),
+		Mask:  image.NewUniform(image.White),
+		Score: 0.9,
+		Label: "person",
+	}}}}
+	store := map[string]MaskResult{}
+
+	r := testRouter()
+	r.GET("/masks", MaskHandler(detector, store))
+	r.GET("/masks/result.jpg", MaskImageHandler(store))
+
+	imageURL := url.QueryEscape(server.URL + "/image.png")
+	w := get(r, "/masks?imageurl="+imageURL)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/masks/result.jpg")
+
+	// the result is kept in the store
+	w = get(r, "/masks?imageurl="+imageURL)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, detector.CallCount("Detect"))
+
+	w = get(r, "/masks/result.jpg?imageurl="+imageURL)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Body.Bytes())
+
+	w = get(r, "/masks/result.jpg?imageurl=other")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMaskHandlerUpload(t *testing.T) {
+	detector := &gildasaitest.MaskDetector{Masks: [][]gildasai.Mask{{{
+		Box:   image.Rect(10, 10, 50, 50),
+		Mask:  image.NewUniform(image.White),
+		Score: 0.9,
+		Label: "person",
+	}}}}
+	store := map[string]MaskResult{}
+
+	r := testRouter()
+	r.POST("/masks", MaskHandler(detector, store))
+
+	body, contentType := upload(t, map[string][]byte{"image": pngData(t, 100, 100)})
+	w := post(r, "/masks", body, contentType)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "data:image/jpeg;base64,")
+	assert.Empty(t, store, "the results of the uploads are not kept")
+
+	w = post(r, "/masks", bytes.NewReader(pngData(t, 100, 100)), "image/png")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, detector.CallCount("Detect"))
+}
+
+func TestMaskHandlerError(t *testing.T) {
+	server := imageServer()
+	defer server.Close()
+
+	detector := &gildasaitest.MaskDetector{}
+	detector.Errors = map[string]error{"Detect": errors.New("broken")}
+
+	r := testRouter()
+	r.GET("/masks", MaskHandler(detector, map[string]MaskResult{}))
+
+	w := get(r, "/masks?imageurl="+url.QueryEscape(server.URL+"/image.png"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
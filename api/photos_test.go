@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhotosHandler(t *testing.T) {
+	store := &gildasaitest.PredictionStore{Search: []*gildasai.PredictionItem{
+		{Identifier: "pictures/cat.jpg"},
+	}}
+
+	r := testRouter()
+	r.GET("/photos", PhotosHandler(store))
+
+	w := get(r, "/photos?query=cat&after=a")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `src="/photos/pictures/cat.jpg"`)
+	assert.Equal(t, []interface{}{"cat", "a", 100}, store.Calls("SearchPrediction")[0].Args)
+
+	store.Errors = map[string]error{"SearchPrediction": errors.New("broken")}
+	w = get(r, "/photos")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetPhotoHandler(t *testing.T) {
+	f, err := ioutil.TempFile("", "photo")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("jpeg data")
+	require.NoError(t, err)
+	f.Close()
+
+	store := &gildasaitest.PredictionStore{Predictions: map[string]*gildasai.PredictionItem{
+		f.Name(): {Identifier: f.Name()},
+	}}
+
+	r := testRouter()
+	r.GET("/photos/*filename", GetPhotoHandler(store))
+
+	w := get(r, "/photos/"+f.Name())
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "jpeg data", w.Body.String())
+
+	w = get(r, "/photos/etc/passwd")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
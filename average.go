@@ -0,0 +1,218 @@
+package gildasai
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/gildasch/gildas-ai/imageutils/distort"
+	"github.com/pkg/errors"
+)
+
+const (
+	averageFaceSize = 200
+
+	// position of the eyes centers in the average face, relative to its size
+	averageLeftEyeX  = 0.35
+	averageRightEyeX = 0.65
+	averageEyesY     = 0.4
+)
+
+// AverageFace builds a composite face out of items: every face is aligned
+// on the eyes, warped to the mean landmark shape and the pixel values are
+// averaged. sources[i] must be the image items[i] was detected in. The
+// faces without 68 landmarks are skipped, an error being returned when
+// none is left.
+func AverageFace(items []FaceItem, sources []image.Image) (image.Image, error) {
+	if len(items) != len(sources) {
+		return nil, errors.Errorf("got %d faces for %d sources", len(items), len(sources))
+	}
+
+	var aligned [][]complex128
+	var transforms []similarity
+	var faces []image.Image
+	for i, item := range items {
+		points := toComplex(item.Landmarks.PointsOnImage(item.Detection.Box))
+		if len(points) != 68 {
+			continue
+		}
+
+		t := eyesSimilarity(points, averageFaceSize)
+		aligned = append(aligned, t.applyAll(points))
+		transforms = append(transforms, t)
+		faces = append(faces, sources[i])
+	}
+
+	if len(aligned) == 0 {
+		return nil, errors.Errorf("none of the %d faces has 68 landmarks to average", len(items))
+	}
+
+	mean := meanShape(aligned)
+
+	sum := make([][4]float64, averageFaceSize*averageFaceSize)
+	for i := range faces {
+		warped := warpSimilarity(faces[i], transforms[i], averageFaceSize)
+
+		warped, err := distort.Distort(warped,
+			withFrame(toPoints(aligned[i]), averageFaceSize),
+			withFrame(toPoints(mean), averageFaceSize))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error warping face %d to the mean shape", i)
+		}
+
+		accumulate(sum, warped, averageFaceSize)
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, averageFaceSize, averageFaceSize))
+	for y := 0; y < averageFaceSize; y++ {
+		for x := 0; x < averageFaceSize; x++ {
+			s := sum[y*averageFaceSize+x]
+			if s[3] == 0 {
+				out.Set(x, y, color.RGBA{A: 255})
+				continue
+			}
+			out.Set(x, y, color.RGBA{
+				R: uint8(s[0] / s[3]),
+				G: uint8(s[1] / s[3]),
+				B: uint8(s[2] / s[3]),
+				A: 255,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// similarity is the transform z -> a*z + b of the complex plane, that is a
+// rotation and scale followed by a translation.
+type similarity struct {
+	a, b complex128
+}
+
+func (s similarity) apply(z complex128) complex128 {
+	return s.a*z + s.b
+}
+
+func (s similarity) applyAll(points []complex128) []complex128 {
+	out := make([]complex128, len(points))
+	for i, p := range points {
+		out[i] = s.apply(p)
+	}
+	return out
+}
+
+func (s similarity) invert(z complex128) complex128 {
+	return (z - s.b) / s.a
+}
+
+// eyesSimilarity returns the transform moving the eyes of the 68 points
+// landmarks to their position in the average face of the given size.
+func eyesSimilarity(points []complex128, size int) similarity {
+	// 36 to 41 is the right eye, 42 to 47 is the left eye (as seen on the
+	// picture, the right eye is on the left)
+	from1, from2 := centroid(points[36:42]), centroid(points[42:48])
+	to1 := complex(averageLeftEyeX*float64(size), averageEyesY*float64(size))
+	to2 := complex(averageRightEyeX*float64(size), averageEyesY*float64(size))
+
+	if from1 == from2 {
+		return similarity{a: 1, b: to1 - from1}
+	}
+
+	a := (to2 - to1) / (from2 - from1)
+	return similarity{a: a, b: to1 - a*from1}
+}
+
+func centroid(points []complex128) complex128 {
+	var sum complex128
+	for _, p := range points {
+		sum += p
+	}
+	return sum / complex(float64(len(points)), 0)
+}
+
+func meanShape(shapes [][]complex128) []complex128 {
+	mean := make([]complex128, len(shapes[0]))
+	for _, s := range shapes {
+		for i, p := range s {
+			mean[i] += p
+		}
+	}
+	for i := range mean {
+		mean[i] /= complex(float64(len(shapes)), 0)
+	}
+	return mean
+}
+
+// warpSimilarity renders the part of img that t moves into the square of
+// the given size. Pixels falling outside of img are left transparent.
+func warpSimilarity(img image.Image, t similarity, size int) image.Image {
+	out := image.NewRGBA(image.Rect(0, 0, size, size))
+	bounds := img.Bounds()
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			src := t.invert(complex(float64(x)+0.5, float64(y)+0.5))
+			p := image.Point{
+				X: int(math.Floor(real(src))),
+				Y: int(math.Floor(imag(src))),
+			}
+			if !p.In(bounds) {
+				continue
+			}
+			out.Set(x, y, img.At(p.X, p.Y))
+		}
+	}
+
+	return out
+}
+
+// withFrame adds fixed control points around the border of the face so
+// that the distortion does not move the whole picture.
+func withFrame(points []image.Point, size int) []image.Point {
+	max := size - 1
+	return append(points,
+		image.Point{0, 0}, image.Point{max / 2, 0}, image.Point{max, 0},
+		image.Point{0, max / 2}, image.Point{max, max / 2},
+		image.Point{0, max}, image.Point{max / 2, max}, image.Point{max, max})
+}
+
+func toPoints(points []complex128) []image.Point {
+	out := make([]image.Point, len(points))
+	for i, p := range points {
+		out[i] = image.Point{
+			X: int(math.Round(real(p))),
+			Y: int(math.Round(imag(p))),
+		}
+	}
+	return out
+}
+
+func accumulate(sum [][4]float64, img image.Image, size int) {
+	rgba := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := rgba.RGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+			// colors are alpha-premultiplied
+			alpha := float64(c.A) / 255
+			s := &sum[y*size+x]
+			s[0] += float64(c.R)
+			s[1] += float64(c.G)
+			s[2] += float64(c.B)
+			s[3] += alpha
+		}
+	}
+}
+
+func toComplex(points []image.Point) []complex128 {
+	out := make([]complex128, len(points))
+	for i, p := range points {
+		out[i] = complex(float64(p.X), float64(p.Y))
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package gildasai
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEyesSimilarity(t *testing.T) {
+	points := toComplex(gaspardLandmarks.PointsOnImage(gaspardBounds))
+	require.Len(t, points, 68)
+
+	s := eyesSimilarity(points, 200)
+
+	rightEye := s.apply(centroid(points[36:42]))
+	leftEye := s.apply(centroid(points[42:48]))
+
+	assert.InDelta(t, 70, real(rightEye), 0.001)
+	assert.InDelta(t, 80, imag(rightEye), 0.001)
+	assert.InDelta(t, 130, real(leftEye), 0.001)
+	assert.InDelta(t, 80, imag(leftEye), 0.001)
+
+	p := points[30]
+	assert.InDelta(t, real(p), real(s.invert(s.apply(p))), 0.001)
+	assert.InDelta(t, imag(p), imag(s.invert(s.apply(p))), 0.001)
+}
+
+func TestMeanShape(t *testing.T) {
+	mean := meanShape([][]complex128{
+		{complex(0, 0), complex(10, 20)},
+		{complex(2, 4), complex(20, 10)},
+	})
+
+	assert.Equal(t, []complex128{complex(1, 2), complex(15, 15)}, mean)
+}
+
+func TestWarpSimilarity(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 10, 20, 20))
+	img.Set(12, 13, color.RGBA{R: 255, A: 255})
+
+	// scales by 2 and moves (10, 10) to the origin
+	warped := warpSimilarity(img, similarity{a: 2, b: complex(-20, -20)}, 20)
+
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, warped.At(4, 6))
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, warped.At(5, 7))
+	assert.Equal(t, color.RGBA{}, warped.At(6, 6))
+	assert.Equal(t, color.RGBA{}, warped.At(19, 19))
+}
+
+func TestAverageFaceMismatchedSources(t *testing.T) {
+	_, err := AverageFace([]FaceItem{{}}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+// Package backend runs the models independently of the library doing the
+// inference. The tensorflow backend is built with the tensorflow build
+// tag.
+package backend
+
+import (
+	"github.com/pkg/errors"
+)
+
+var ErrNoBackend = errors.New("no inference backend, build with -tags tensorflow")
+
+// Default is the backend used to load the models, nil when none is built
+var Default Backend
+
+// Backend loads models
+type Backend interface {
+	// LoadSavedModel loads the saved model of dir with the tags
+	LoadSavedModel(dir string, tags []string, opts *Options) (Model, error)
+	// LoadGraph loads the frozen graph of filename
+	LoadGraph(filename string, opts *Options) (Model, error)
+}
+
+// Options tune how a model runs
+type Options struct {
+	// InterOpThreads is the number of threads running independent
+	// operations in parallel. 0 lets the backend choose.
+	InterOpThreads int
+}
+
+// Model is a loaded model
+type Model interface {
+	// Run feeds inputs to the operations of their names and returns the
+	// tensors of outputs, in the same order
+	Run(inputs map[string]*Tensor, outputs []string) ([]*Tensor, error)
+	Close() error
+}
+
+// LoadSavedModel loads the saved model of dir with the default backend
+func LoadSavedModel(dir string, tags []string, opts *Options) (Model, error) {
+	if Default == nil {
+		return nil, ErrNoBackend
+	}
+	return Default.LoadSavedModel(dir, tags, opts)
+}
+
+// LoadGraph loads the frozen graph of filename with the default backend
+func LoadGraph(filename string, opts *Options) (Model, error) {
+	if Default == nil {
+		return nil, ErrNoBackend
+	}
+	return Default.LoadGraph(filename, opts)
+}
@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFloat32(t *testing.T) {
+	tensor := NewFloat32([]int64{2, 3}, []float32{1, 2, 3, 4, 5, 6})
+
+	assert.NoError(t, tensor.CheckFloat32(2, 3))
+	assert.NoError(t, tensor.CheckFloat32(-1, 3))
+	assert.Error(t, tensor.CheckFloat32(2))
+	assert.Error(t, tensor.CheckFloat32(3, 2))
+	assert.Error(t, NewFloat32([]int64{2, 3}, []float32{1}).CheckFloat32(2, 3))
+	assert.Error(t, NewUint8([]int64{1}, []uint8{1}).CheckFloat32(1))
+
+	var missing *Tensor
+	assert.Error(t, missing.CheckFloat32(1))
+}
+
+func TestRow(t *testing.T) {
+	tensor := NewFloat32([]int64{2, 3}, []float32{1, 2, 3, 4, 5, 6})
+
+	row := tensor.Row(0)
+	assert.Equal(t, []float32{1, 2, 3}, row)
+	assert.Equal(t, []float32{4, 5, 6}, tensor.Row(1))
+
+	row = append(row, 10)
+	assert.Equal(t, []float32{4, 5, 6}, tensor.Row(1))
+}
+
+func TestFake(t *testing.T) {
+	output := NewFloat32([]int64{1}, []float32{42})
+	model := &FakeModel{Outputs: map[string]*Tensor{"out": output}}
+	b := &Fake{Models: map[string]*FakeModel{"model": model}}
+
+	_, err := b.LoadGraph("other", nil)
+	assert.Error(t, err)
+
+	m, err := b.LoadSavedModel("model", []string{"tag"}, nil)
+	require.NoError(t, err)
+
+	input := NewUint8([]int64{1}, []uint8{1})
+	result, err := m.Run(map[string]*Tensor{"in": input}, []string{"out"})
+	require.NoError(t, err)
+	assert.Equal(t, []*Tensor{output}, result)
+	assert.Equal(t, []map[string]*Tensor{{"in": input}}, model.Inputs)
+
+	_, err = m.Run(nil, []string{"missing"})
+	assert.Error(t, err)
+
+	require.NoError(t, m.Close())
+	assert.True(t, model.Closed)
+}
+
+func TestNoBackend(t *testing.T) {
+	if Default != nil {
+		t.Skip("built with a backend")
+	}
+
+	_, err := LoadGraph("model.pb", nil)
+	assert.Equal(t, ErrNoBackend, err)
+}
+
+func TestFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "saved", "variables"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "saved", "saved_model.pb"), []byte("graph"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "saved", "variables", "variables.data"), []byte("weights"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "detector.pb"), []byte("detector"), 0644))
+
+	fingerprint, err := Fingerprint("face-api-js", filepath.Join(dir, "detector.pb"), filepath.Join(dir, "saved"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(fingerprint, "face-api-js@"))
+	assert.Len(t, fingerprint, len("face-api-js@")+12)
+
+	again, err := Fingerprint("face-api-js", filepath.Join(dir, "detector.pb"), filepath.Join(dir, "saved"))
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint, again)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "saved", "variables", "variables.data"), []byte("retrained"), 0644))
+	retrained, err := Fingerprint("face-api-js", filepath.Join(dir, "detector.pb"), filepath.Join(dir, "saved"))
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, retrained)
+
+	_, err = Fingerprint("face-api-js", filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Fake is a backend whose models are loaded from Models, by directory or
+// file name
+type Fake struct {
+	Models map[string]*FakeModel
+}
+
+func (f *Fake) LoadSavedModel(dir string, tags []string, opts *Options) (Model, error) {
+	return f.load(dir)
+}
+
+func (f *Fake) LoadGraph(filename string, opts *Options) (Model, error) {
+	return f.load(filename)
+}
+
+func (f *Fake) load(name string) (Model, error) {
+	m, ok := f.Models[name]
+	if !ok {
+		return nil, errors.Errorf("no fake model %q", name)
+	}
+	return m, nil
+}
+
+// FakeModel returns scripted outputs and records its inputs
+type FakeModel struct {
+	// Outputs are the tensors returned by Run, by operation name
+	Outputs map[string]*Tensor
+	// Err, when set, is returned by Run
+	Err error
+
+	Inputs []map[string]*Tensor
+	Closed bool
+}
+
+func (m *FakeModel) Run(inputs map[string]*Tensor, outputs []string) ([]*Tensor, error) {
+	m.Inputs = append(m.Inputs, inputs)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	var out []*Tensor
+	for _, name := range outputs {
+		t, ok := m.Outputs[name]
+		if !ok {
+			return nil, errors.Errorf("no fake output %q", name)
+		}
+		out = append(out, t)
+	}
+
+	return out, nil
+}
+
+func (m *FakeModel) Close() error {
+	m.Closed = true
+	return nil
+}
@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Fingerprint identifies a version of the model name by the content of
+// the files of paths, the directories being walked in lexical order. It
+// is name, @ and the beginning of the SHA-256 of the files.
+func Fingerprint(name string, paths ...string) (string, error) {
+	h := sha256.New()
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(path, file)
+			if err != nil {
+				return err
+			}
+			// the names of the files of the directories are part of the
+			// fingerprint, not where the model is
+			io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			_, err = io.Copy(h, f)
+			return err
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "error hashing the model files of %q", path)
+		}
+	}
+
+	return name + "@" + hex.EncodeToString(h.Sum(nil))[:12], nil
+}
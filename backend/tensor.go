@@ -0,0 +1,60 @@
+package backend
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Tensor is a dense tensor whose values are stored row-major in Float32
+// or Uint8
+type Tensor struct {
+	Shape   []int64
+	Float32 []float32
+	Uint8   []uint8
+}
+
+// NewFloat32 returns a tensor of float32 values
+func NewFloat32(shape []int64, values []float32) *Tensor {
+	return &Tensor{Shape: shape, Float32: values}
+}
+
+// NewUint8 returns a tensor of uint8 values
+func NewUint8(shape []int64, values []uint8) *Tensor {
+	return &Tensor{Shape: shape, Uint8: values}
+}
+
+// Size returns the number of values of a tensor of shape
+func Size(shape []int64) int {
+	size := 1
+	for _, d := range shape {
+		size *= int(d)
+	}
+	return size
+}
+
+// CheckFloat32 returns an error if t is not a float32 tensor of shape
+// dims, -1 matching any dimension
+func (t *Tensor) CheckFloat32(dims ...int64) error {
+	if t == nil {
+		return errors.New("tensor is missing")
+	}
+	if len(t.Shape) != len(dims) {
+		return errors.Errorf("tensor has shape %v, expected %v", t.Shape, dims)
+	}
+	for i, d := range dims {
+		if d != -1 && t.Shape[i] != d {
+			return errors.Errorf("tensor has shape %v, expected %v", t.Shape, dims)
+		}
+	}
+	if len(t.Float32) != Size(t.Shape) {
+		return errors.Errorf("tensor of shape %v has %d float32 values", t.Shape, len(t.Float32))
+	}
+	return nil
+}
+
+// Row returns the float32 values of t at index i of its first dimension.
+// Its capacity ends with the row so that appending to it does not
+// overwrite the next one.
+func (t *Tensor) Row(i int) []float32 {
+	stride := Size(t.Shape[1:])
+	return t.Float32[i*stride : (i+1)*stride : (i+1)*stride]
+}
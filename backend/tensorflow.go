@@ -0,0 +1,146 @@
+//go:build tensorflow
+// +build tensorflow
+
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+
+	"github.com/pkg/errors"
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+func init() {
+	Default = tensorflowBackend{}
+}
+
+type tensorflowBackend struct{}
+
+func (tensorflowBackend) LoadSavedModel(dir string, tags []string, opts *Options) (Model, error) {
+	model, err := tf.LoadSavedModel(dir, tags, sessionOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return &tensorflowModel{graph: model.Graph, session: model.Session}, nil
+}
+
+func (tensorflowBackend) LoadGraph(filename string, opts *Options) (Model, error) {
+	model, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading model file %q", filename)
+	}
+
+	graph := tf.NewGraph()
+	if err := graph.Import(model, ""); err != nil {
+		return nil, errors.Wrapf(err, "error importing model to new graph")
+	}
+
+	session, err := tf.NewSession(graph, sessionOptions(opts))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating new session from graph")
+	}
+
+	return &tensorflowModel{graph: graph, session: session}, nil
+}
+
+// sessionOptions returns the serialized ConfigProto of opts
+func sessionOptions(opts *Options) *tf.SessionOptions {
+	if opts == nil || opts.InterOpThreads <= 0 {
+		return nil
+	}
+
+	// inter_op_parallelism_threads is the varint field 5
+	config := []byte{0x28}
+	config = append(config, make([]byte, binary.MaxVarintLen64)...)
+	n := binary.PutUvarint(config[1:], uint64(opts.InterOpThreads))
+
+	return &tf.SessionOptions{Config: config[:1+n]}
+}
+
+type tensorflowModel struct {
+	graph   *tf.Graph
+	session *tf.Session
+}
+
+func (m *tensorflowModel) Run(inputs map[string]*Tensor, outputs []string) ([]*Tensor, error) {
+	feeds := map[tf.Output]*tf.Tensor{}
+	for name, t := range inputs {
+		op := m.graph.Operation(name)
+		if op == nil {
+			return nil, errors.Errorf("no operation %q in the graph", name)
+		}
+
+		tensor, err := toTensorflow(t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error converting input %q", name)
+		}
+		feeds[op.Output(0)] = tensor
+	}
+
+	var fetches []tf.Output
+	for _, name := range outputs {
+		op := m.graph.Operation(name)
+		if op == nil {
+			return nil, errors.Errorf("no operation %q in the graph", name)
+		}
+		fetches = append(fetches, op.Output(0))
+	}
+
+	result, err := m.session.Run(feeds, fetches, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Tensor
+	for i, r := range result {
+		t, err := fromTensorflow(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error converting output %q", outputs[i])
+		}
+		out = append(out, t)
+	}
+
+	return out, nil
+}
+
+func (m *tensorflowModel) Close() error {
+	return m.session.Close()
+}
+
+func toTensorflow(t *Tensor) (*tf.Tensor, error) {
+	if t.Uint8 != nil {
+		return tf.ReadTensor(tf.Uint8, t.Shape, bytes.NewReader(t.Uint8))
+	}
+
+	buf := make([]byte, 4*len(t.Float32))
+	for i, v := range t.Float32 {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+
+	return tf.ReadTensor(tf.Float, t.Shape, bytes.NewReader(buf))
+}
+
+func fromTensorflow(t *tf.Tensor) (*Tensor, error) {
+	var buf bytes.Buffer
+	if _, err := t.WriteContentsTo(&buf); err != nil {
+		return nil, err
+	}
+
+	switch t.DataType() {
+	case tf.Uint8:
+		return NewUint8(t.Shape(), buf.Bytes()), nil
+	case tf.Float:
+		b := buf.Bytes()
+		values := make([]float32, len(b)/4)
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+		return NewFloat32(t.Shape(), values), nil
+	}
+
+	return nil, errors.Errorf("unsupported tensor type %v", t.DataType())
+}
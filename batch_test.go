@@ -0,0 +1,142 @@
+package gildasai
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchModels fakes landmark and descriptor models able to run on up to
+// size faces per call
+type batchModels struct {
+	size             int
+	landmarksCalls   []int
+	descriptorsCalls []int
+}
+
+func (b *batchModels) Detect(img image.Image) (*Landmarks, error) {
+	panic("Detect should not be called on a BatchLandmark")
+}
+
+func (b *batchModels) DetectBatch(imgs []image.Image) ([]*Landmarks, error) {
+	var out []*Landmarks
+	for i := 0; i < len(imgs); i += b.size {
+		n := len(imgs) - i
+		if n > b.size {
+			n = b.size
+		}
+		b.landmarksCalls = append(b.landmarksCalls, n)
+		for j := 0; j < n; j++ {
+			out = append(out, gaspardLandmarks)
+		}
+	}
+	return out, nil
+}
+
+func (b *batchModels) Compute(img image.Image) (Descriptors, error) {
+	panic("Compute should not be called on a BatchDescriptor")
+}
+
+func (b *batchModels) ComputeBatch(imgs []image.Image) ([]Descriptors, error) {
+	var out []Descriptors
+	for i := 0; i < len(imgs); i += b.size {
+		n := len(imgs) - i
+		if n > b.size {
+			n = b.size
+		}
+		b.descriptorsCalls = append(b.descriptorsCalls, n)
+		for j := 0; j < n; j++ {
+			out = append(out, Descriptors{float32(i + j)})
+		}
+	}
+	return out, nil
+}
+
+func TestExtractBatch(t *testing.T) {
+	var detections []Detection
+	for i := 0; i < 20; i++ {
+		detections = append(detections, Detection{
+			Box:   image.Rect(i*50, 0, i*50+50, 60),
+			Score: 0.9,
+		})
+	}
+
+	models := &batchModels{size: 16}
+	e := &Extractor{
+		Detector:   &mockDetector{detect: [][]Detection{detections}},
+		Landmark:   models,
+		Descriptor: models,
+	}
+
+	items, err := e.ExtractItems(image.NewRGBA(image.Rect(0, 0, 1000, 100)))
+	require.NoError(t, err)
+	require.Len(t, items, 20)
+
+	assert.Equal(t, []int{16, 4}, models.landmarksCalls)
+	assert.Equal(t, []int{16, 4}, models.descriptorsCalls)
+	for i, item := range items {
+		assert.Equal(t, detections[i], item.Detection)
+		assert.Equal(t, Descriptors{float32(i)}, item.Descriptors)
+	}
+}
+
+func TestExtractWithoutBatch(t *testing.T) {
+	e := &Extractor{
+		Detector: &mockDetector{detect: [][]Detection{{
+			{Box: image.Rect(0, 0, 50, 50), Score: 0.9},
+			{Box: image.Rect(50, 0, 100, 50), Score: 0.9},
+		}}},
+		Landmark:   &mockLandmark{landmarks: []*Landmarks{gaspardLandmarks, gaspardLandmarks}},
+		Descriptor: &mockDescriptor{descriptors: []Descriptors{{1}, {2}}},
+	}
+
+	items, err := e.ExtractItems(image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, Descriptors{1}, items[0].Descriptors)
+	assert.Equal(t, Descriptors{2}, items[1].Descriptors)
+}
+
+// batchSquaresDetector is a squaresDetector running on all the tiles at
+// once
+type batchSquaresDetector struct {
+	squaresDetector
+	calls int
+}
+
+func (b *batchSquaresDetector) DetectBatch(imgs []image.Image) ([][]Detection, error) {
+	b.calls++
+	var out [][]Detection
+	for _, img := range imgs {
+		detections, err := b.squaresDetector.Detect(img)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, detections)
+	}
+	return out, nil
+}
+
+func TestTiledDetectorBatch(t *testing.T) {
+	squares := []image.Rectangle{
+		image.Rect(100, 100, 140, 140),
+		image.Rect(700, 500, 760, 560),
+	}
+	img := imageWithSquares(image.Rect(0, 0, 1000, 800), squares...)
+
+	fake := &batchSquaresDetector{}
+	d := &TiledDetector{Detector: fake, TileSize: 400, Overlap: 100, MergeIoU: 0.4}
+
+	detections, err := d.Detect(img)
+	require.NoError(t, err)
+
+	var boxes []image.Rectangle
+	for _, d := range detections {
+		boxes = append(boxes, d.Box)
+	}
+	assert.ElementsMatch(t, squares, boxes)
+	assert.Equal(t, 1, fake.calls)
+	assert.Len(t, fake.seen, 10, "9 tiles and the downscaled image")
+}
@@ -0,0 +1,1074 @@
+// Package bolt implements the stores on bbolt, an embedded key/value
+// database written in pure Go, for the binaries built without cgo.
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/pkg/errors"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// The keys are made of fields separated by sep, which the identifiers,
+// networks, labels and JSON detections do not contain. Being the lowest
+// byte, it keeps the keys of an identifier together and first.
+const sep = "\x00"
+
+var (
+	// predictions maps id, network, label to the score followed by the
+	// fingerprint of the model
+	predictionsBucket = []byte("predictions")
+	// labels indexes the predictions by lowercase label: lowercase label,
+	// id, network, label
+	labelsBucket = []byte("labels")
+	// scores indexes the predictions by decreasing score: score, id,
+	// network, label
+	scoresBucket = []byte("scores")
+	// faces maps a sequence number to the JSON face item
+	facesBucket = []byte("faces")
+	// faceIDs indexes the faces by id: id, sequence number
+	faceIDsBucket = []byte("face_ids")
+	// faceKeys maps id, network, JSON detection to the sequence number
+	faceKeysBucket = []byte("face_keys")
+	// distances maps the sequence numbers of the two faces to the
+	// distance
+	distancesBucket = []byte("face_id_distances")
+	// compared holds the sequence numbers of the faces compared to the
+	// other faces
+	comparedBucket = []byte("compared_faces")
+	// neighbours maps the sequence number of a face to its JSON
+	// neighbours
+	neighboursBucket = []byte("face_neighbours")
+	// sources maps an id to its JSON source
+	sourcesBucket = []byte("sources")
+)
+
+type Store struct {
+	db *bbolt.DB
+}
+
+func NewStore(filename string) (*Store, error) {
+	db, err := bbolt.Open(filename, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening bolt store %q", filename)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, b := range [][]byte{
+			predictionsBucket, labelsBucket, scoresBucket,
+			facesBucket, faceIDsBucket, faceKeysBucket,
+			distancesBucket, comparedBucket, neighboursBucket, sourcesBucket,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return errors.Wrapf(err, "error creating bucket %q", b)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func key(fields ...string) []byte {
+	return []byte(strings.Join(fields, sep))
+}
+
+func splitKey(k []byte) []string {
+	return strings.Split(string(k), sep)
+}
+
+func encodeFloat(f float32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, math.Float32bits(f))
+	return b
+}
+
+func decodeFloat(b []byte) float32 {
+	return math.Float32frombits(binary.BigEndian.Uint32(b))
+}
+
+func encodePrediction(p gildasai.Prediction) []byte {
+	return append(encodeFloat(p.Score), p.Model...)
+}
+
+// decodePrediction returns the score and the model of the value of the
+// predictions bucket, whose model is missing for the predictions stored
+// before the models were fingerprinted
+func decodePrediction(v []byte) (float32, string, error) {
+	if len(v) < 4 {
+		return 0, "", errors.Errorf("invalid prediction %x", v)
+	}
+	return decodeFloat(v), string(v[4:]), nil
+}
+
+// descendingScore encodes score so that the higher scores come first in
+// the byte order
+func descendingScore(score float32) []byte {
+	bits := math.Float32bits(score)
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, ^bits)
+	return b
+}
+
+// scan calls fn on the keys and values of b starting with prefix
+func scan(b *bbolt.Bucket, prefix []byte, fn func(k, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// predictionsOf returns the predictions of id by decreasing score
+func predictionsOf(tx *bbolt.Tx, id string) (gildasai.Predictions, error) {
+	var preds gildasai.Predictions
+	err := scan(tx.Bucket(predictionsBucket), key(id, ""), func(k, v []byte) error {
+		fields := splitKey(k)
+		if len(fields) != 3 {
+			return errors.Errorf("invalid prediction key %q", k)
+		}
+		score, model, err := decodePrediction(v)
+		if err != nil {
+			return err
+		}
+		preds = append(preds, gildasai.Prediction{
+			Network: fields[1],
+			Model:   model,
+			Label:   fields[2],
+			Score:   score,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(preds, func(i, j int) bool {
+		return preds[i].Score > preds[j].Score
+	})
+	return preds, nil
+}
+
+func (s *Store) GetPrediction(id string) (*gildasai.PredictionItem, bool, error) {
+	var preds gildasai.Predictions
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		preds, err = predictionsOf(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(preds) == 0 {
+		return nil, false, nil
+	}
+
+	return &gildasai.PredictionItem{
+		Identifier:  id,
+		Predictions: preds}, true, nil
+}
+
+func (s *Store) StorePrediction(id string, item *gildasai.PredictionItem) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return putPredictions(tx, item.Identifier, item.Predictions)
+	})
+}
+
+func putPredictions(tx *bbolt.Tx, id string, preds gildasai.Predictions) error {
+	predictions := tx.Bucket(predictionsBucket)
+	labels := tx.Bucket(labelsBucket)
+	scores := tx.Bucket(scoresBucket)
+
+	for _, p := range preds {
+		k := key(id, p.Network, p.Label)
+		if predictions.Get(k) != nil {
+			return errors.Errorf("prediction %q of network %q already stored for %q", p.Label, p.Network, id)
+		}
+
+		err := predictions.Put(k, encodePrediction(p))
+		if err != nil {
+			return err
+		}
+		err = labels.Put(key(strings.ToLower(p.Label), id, p.Network, p.Label), []byte{})
+		if err != nil {
+			return err
+		}
+		err = scores.Put(append(descendingScore(p.Score), k...), []byte{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SearchPrediction behaves like its sqlite counterpart. With a query, it
+// returns all the predictions of the identifiers having a label
+// containing query, case-insensitively, among the first n matching
+// predictions. Without, it returns the n best predictions. In both cases,
+// only the identifiers after after are considered.
+func (s *Store) SearchPrediction(query, after string, n int) ([]*gildasai.PredictionItem, error) {
+	var found []prediction
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		if query != "" {
+			found, err = searchLabel(tx, strings.ToLower(query), after, n)
+		} else {
+			found, err = best(tx, after, n)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error searching bolt store")
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Score > found[j].Score
+	})
+
+	var items []*gildasai.PredictionItem
+	byID := map[string]*gildasai.PredictionItem{}
+	for _, p := range found {
+		item, ok := byID[p.id]
+		if !ok {
+			item = &gildasai.PredictionItem{Identifier: p.id}
+			byID[p.id] = item
+			items = append(items, item)
+		}
+		item.Predictions = append(item.Predictions, p.Prediction)
+	}
+
+	return items, nil
+}
+
+type prediction struct {
+	id string
+	gildasai.Prediction
+}
+
+// searchLabel returns all the predictions of the identifiers after after
+// having one of the first n predictions, by identifier, whose label
+// contains query. Each distinct label of the index is read once.
+func searchLabel(tx *bbolt.Tx, query, after string, n int) ([]prediction, error) {
+	var matches []string
+
+	c := tx.Bucket(labelsBucket).Cursor()
+	for k, _ := c.First(); k != nil; {
+		fields := splitKey(k)
+		if len(fields) != 4 {
+			return nil, errors.Errorf("invalid label key %q", k)
+		}
+
+		label := fields[0]
+		if !strings.Contains(label, query) {
+			// skip to the next label
+			k, _ = c.Seek(key(label + "\x01"))
+			continue
+		}
+
+		if fields[1] > after {
+			matches = append(matches, fields[1])
+		}
+		k, _ = c.Next()
+	}
+
+	sort.Strings(matches)
+	if n >= 0 && len(matches) > n {
+		matches = matches[:n]
+	}
+
+	var found []prediction
+	seen := map[string]bool{}
+	for _, id := range matches {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		preds, err := predictionsOf(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range preds {
+			found = append(found, prediction{id: id, Prediction: p})
+		}
+	}
+
+	return found, nil
+}
+
+// best returns the n best predictions of the identifiers after after
+func best(tx *bbolt.Tx, after string, n int) ([]prediction, error) {
+	var found []prediction
+
+	c := tx.Bucket(scoresBucket).Cursor()
+	for k, _ := c.First(); k != nil && (n < 0 || len(found) < n); k, _ = c.Next() {
+		if len(k) < 4 {
+			return nil, errors.Errorf("invalid score key %q", k)
+		}
+		fields := splitKey(k[4:])
+		if len(fields) != 3 {
+			return nil, errors.Errorf("invalid score key %q", k)
+		}
+		if fields[0] <= after {
+			continue
+		}
+
+		v := tx.Bucket(predictionsBucket).Get(k[4:])
+		if v == nil {
+			return nil, errors.Errorf("no prediction for score key %q", k)
+		}
+		score, model, err := decodePrediction(v)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, prediction{
+			id: fields[0],
+			Prediction: gildasai.Prediction{
+				Network: fields[1],
+				Model:   model,
+				Label:   fields[2],
+				Score:   score,
+			},
+		})
+	}
+
+	return found, nil
+}
+
+func faceKey(item *gildasai.FaceItem) ([]byte, error) {
+	detection, err := json.Marshal(item.Detection)
+	if err != nil {
+		return nil, err
+	}
+	return key(item.Identifier, item.Network, string(detection)), nil
+}
+
+// faceSeq is the key of faceID in the faces bucket
+func faceSeq(faceID int64) []byte {
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, uint64(faceID))
+	return seq
+}
+
+// getFace returns the face at seq, nil if there is none
+func getFace(tx *bbolt.Tx, seq []byte) (*gildasai.FaceItem, error) {
+	data := tx.Bucket(facesBucket).Get(seq)
+	if data == nil {
+		return nil, nil
+	}
+
+	var item gildasai.FaceItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+	item.ID = int64(binary.BigEndian.Uint64(seq))
+	return &item, nil
+}
+
+func (s *Store) StoreFace(item *gildasai.FaceItem) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return putFace(tx, item)
+	})
+}
+
+// putFace stores item and sets its ID, which is only valid once tx is
+// committed
+func putFace(tx *bbolt.Tx, item *gildasai.FaceItem) error {
+	k, err := faceKey(item)
+	if err != nil {
+		return err
+	}
+
+	faces := tx.Bucket(facesBucket)
+	faceKeys := tx.Bucket(faceKeysBucket)
+
+	if faceKeys.Get(k) != nil {
+		return errors.Errorf("face %v of network %q already stored for %q", item.Detection.Box, item.Network, item.Identifier)
+	}
+
+	n, err := faces.NextSequence()
+	if err != nil {
+		return err
+	}
+	seq := faceSeq(int64(n))
+
+	stored := *item
+	stored.ID = int64(n)
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	if err := faces.Put(seq, data); err != nil {
+		return err
+	}
+	if err := faceKeys.Put(k, seq); err != nil {
+		return err
+	}
+	if err := tx.Bucket(faceIDsBucket).Put(append(key(item.Identifier, ""), seq...), []byte{}); err != nil {
+		return err
+	}
+
+	item.ID = stored.ID
+	return nil
+}
+
+func (s *Store) GetFace(faceID int64) (*gildasai.FaceItem, bool, error) {
+	var item *gildasai.FaceItem
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		item, err = getFace(tx, faceSeq(faceID))
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return item, item != nil, nil
+}
+
+func (s *Store) GetFaces(id string) ([]*gildasai.FaceItem, bool, error) {
+	var items []*gildasai.FaceItem
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		prefix := key(id, "")
+
+		return scan(tx.Bucket(faceIDsBucket), prefix, func(k, _ []byte) error {
+			item, err := getFace(tx, k[len(prefix):])
+			if err != nil {
+				return err
+			}
+			if item == nil {
+				return errors.Errorf("no face for index key %q", k)
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(items) == 0 {
+		return nil, false, nil
+	}
+
+	return items, true, nil
+}
+
+// GetAllFaces returns the faces in the order they were stored
+func (s *Store) GetAllFaces() ([]*gildasai.FaceItem, error) {
+	var items []*gildasai.FaceItem
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(facesBucket).ForEach(func(seq, _ []byte) error {
+			item, err := getFace(tx, seq)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func distanceKey(item1, item2 *gildasai.FaceItem) []byte {
+	return append(faceSeq(item1.ID), faceSeq(item2.ID)...)
+}
+
+// StoreFaceDistance stores the distance from item1 to item2, which must
+// have been stored
+func (s *Store) StoreFaceDistance(item1, item2 *gildasai.FaceItem, distance float32) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		for _, item := range []*gildasai.FaceItem{item1, item2} {
+			if tx.Bucket(facesBucket).Get(faceSeq(item.ID)) == nil {
+				return errors.Errorf("face %d of %q is not stored", item.ID, item.Identifier)
+			}
+		}
+
+		distances := tx.Bucket(distancesBucket)
+		k := distanceKey(item1, item2)
+		if distances.Get(k) != nil {
+			return errors.Errorf("distance between faces of %q and %q already stored", item1.Identifier, item2.Identifier)
+		}
+		return distances.Put(k, encodeFloat(distance))
+	})
+}
+
+// StoreFaceDistances stores distances, replacing the ones already
+// stored, and marks the faces of compared in the same transaction
+func (s *Store) StoreFaceDistances(distances []*gildasai.FaceDistance, compared []int64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		faces := tx.Bucket(facesBucket)
+		bucket := tx.Bucket(distancesBucket)
+		for _, d := range distances {
+			for _, item := range []*gildasai.FaceItem{d.Face1, d.Face2} {
+				if faces.Get(faceSeq(item.ID)) == nil {
+					return errors.Errorf("face %d of %q is not stored", item.ID, item.Identifier)
+				}
+			}
+			if err := bucket.Put(distanceKey(d.Face1, d.Face2), encodeFloat(d.Distance)); err != nil {
+				return err
+			}
+		}
+
+		for _, faceID := range compared {
+			seq := faceSeq(faceID)
+			if faces.Get(seq) == nil {
+				return errors.Errorf("face %d is not stored", faceID)
+			}
+			if err := tx.Bucket(comparedBucket).Put(seq, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) ComparedFaces() ([]int64, error) {
+	var faceIDs []int64
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(comparedBucket).ForEach(func(k, _ []byte) error {
+			faceIDs = append(faceIDs, int64(binary.BigEndian.Uint64(k)))
+			return nil
+		})
+	})
+	return faceIDs, err
+}
+
+func (s *Store) GetFaceDistance(item1, item2 *gildasai.FaceItem) (float32, bool, error) {
+	var distance []byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket(distancesBucket).Get(distanceKey(item1, item2)); v != nil {
+			distance = append(distance, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	if distance == nil {
+		return 0, false, nil
+	}
+
+	return decodeFloat(distance), true, nil
+}
+
+func (s *Store) GetAllFaceDistances() ([]*gildasai.FaceDistance, error) {
+	var distances []*gildasai.FaceDistance
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(distancesBucket).ForEach(func(k, v []byte) error {
+			if len(k) != 16 {
+				return errors.Errorf("invalid distance key %x", k)
+			}
+
+			face1, err := getIdentity(tx, k[:8])
+			if err != nil {
+				return err
+			}
+			face2, err := getIdentity(tx, k[8:])
+			if err != nil {
+				return err
+			}
+
+			distances = append(distances, &gildasai.FaceDistance{
+				Face1:    face1,
+				Face2:    face2,
+				Distance: decodeFloat(v),
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(distances, func(i, j int) bool {
+		return distances[i].Distance < distances[j].Distance
+	})
+	return distances, nil
+}
+
+// getIdentity returns the face of seq without its landmarks and
+// descriptors
+func getIdentity(tx *bbolt.Tx, seq []byte) (*gildasai.FaceItem, error) {
+	item, err := getFace(tx, seq)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, errors.Errorf("no face %x", seq)
+	}
+	return &gildasai.FaceItem{
+		ID:         item.ID,
+		Identifier: item.Identifier,
+		Network:    item.Network,
+		Detection:  item.Detection,
+	}, nil
+}
+
+// neighbours is the value of the neighbours bucket
+type neighbours struct {
+	FaceIDs   []int64
+	Distances []float32
+	Stale     bool `json:",omitempty"`
+}
+
+func decodeNeighbours(tx *bbolt.Tx, seq, data []byte) (*gildasai.FaceNeighbours, error) {
+	var stored neighbours
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, errors.Wrapf(err, "invalid neighbours of face %x", seq)
+	}
+
+	face, err := getIdentity(tx, seq)
+	if err != nil {
+		return nil, err
+	}
+	n := &gildasai.FaceNeighbours{Face: face, Stale: stored.Stale}
+	for i, faceID := range stored.FaceIDs {
+		neighbour, err := getIdentity(tx, faceSeq(faceID))
+		if err != nil {
+			return nil, err
+		}
+		n.Neighbours = append(n.Neighbours, gildasai.FaceNeighbour{Face: neighbour, Distance: stored.Distances[i]})
+	}
+	return n, nil
+}
+
+// StoreNeighbours replaces the neighbours of the faces of list in the
+// same transaction
+func (s *Store) StoreNeighbours(list []*gildasai.FaceNeighbours) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		faces := tx.Bucket(facesBucket)
+		for _, n := range list {
+			stored := neighbours{FaceIDs: []int64{}, Distances: []float32{}}
+			items := []*gildasai.FaceItem{n.Face}
+			for _, neighbour := range n.Neighbours {
+				items = append(items, neighbour.Face)
+				stored.FaceIDs = append(stored.FaceIDs, neighbour.Face.ID)
+				stored.Distances = append(stored.Distances, neighbour.Distance)
+			}
+			for _, item := range items {
+				if faces.Get(faceSeq(item.ID)) == nil {
+					return errors.Errorf("face %d of %q is not stored", item.ID, item.Identifier)
+				}
+			}
+
+			data, err := json.Marshal(stored)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(neighboursBucket).Put(faceSeq(n.Face.ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) GetNeighbours(faceID int64) (*gildasai.FaceNeighbours, bool, error) {
+	var n *gildasai.FaceNeighbours
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		seq := faceSeq(faceID)
+		data := tx.Bucket(neighboursBucket).Get(seq)
+		if data == nil {
+			return nil
+		}
+		var err error
+		n, err = decodeNeighbours(tx, seq, data)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return n, n != nil, nil
+}
+
+func (s *Store) GetAllNeighbours() ([]*gildasai.FaceNeighbours, error) {
+	var list []*gildasai.FaceNeighbours
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(neighboursBucket).ForEach(func(k, v []byte) error {
+			n, err := decodeNeighbours(tx, k, v)
+			if err != nil {
+				return err
+			}
+			list = append(list, n)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// source is the value of the sources bucket, the modification time being
+// in nanoseconds for the times to be equal once read
+type source struct {
+	Hash    string
+	Size    int64
+	ModTime int64
+}
+
+func decodeSource(id string, data []byte) (*gildasai.Source, error) {
+	var src source
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, errors.Wrapf(err, "invalid source of %q", id)
+	}
+	return &gildasai.Source{
+		Identifier: id,
+		Hash:       src.Hash,
+		Size:       src.Size,
+		ModTime:    time.Unix(0, src.ModTime),
+	}, nil
+}
+
+func (s *Store) GetSource(id string) (*gildasai.Source, bool, error) {
+	var src *gildasai.Source
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(sourcesBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		var err error
+		src, err = decodeSource(id, data)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return src, src != nil, nil
+}
+
+func (s *Store) StoreSource(src *gildasai.Source) error {
+	data, err := json.Marshal(&source{
+		Hash:    src.Hash,
+		Size:    src.Size,
+		ModTime: src.ModTime.UnixNano(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sourcesBucket).Put([]byte(src.Identifier), data)
+	})
+}
+
+func (s *Store) GetAllSources() ([]*gildasai.Source, error) {
+	var sources []*gildasai.Source
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sourcesBucket).ForEach(func(k, v []byte) error {
+			src, err := decodeSource(string(k), v)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, src)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sources, nil
+}
+
+// DeleteSource deletes the source of id with its predictions and its
+// faces, along with their index entries and distances
+func (s *Store) DeleteSource(id string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		all := func(string) bool { return true }
+		if err := deletePredictions(tx, id, all); err != nil {
+			return err
+		}
+		if err := deleteFaces(tx, id, all); err != nil {
+			return err
+		}
+		return tx.Bucket(sourcesBucket).Delete([]byte(id))
+	})
+}
+
+func (s *Store) PredictedIdentifiers() ([]string, error) {
+	ids := map[string]bool{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(predictionsBucket).ForEach(func(k, v []byte) error {
+			fields := splitKey(k)
+			if len(fields) != 3 {
+				return errors.Errorf("invalid prediction key %q", k)
+			}
+			ids[fields[0]] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(ids), nil
+}
+
+// deletion is a key to delete, bbolt cursors not supporting deletes
+// while iterating
+type deletion struct{ bucket, key []byte }
+
+func deleteAll(tx *bbolt.Tx, deletes []deletion) error {
+	for _, d := range deletes {
+		if err := tx.Bucket(d.bucket).Delete(d.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePredictions deletes the predictions of id of the networks
+// matching, with their index entries
+func deletePredictions(tx *bbolt.Tx, id string, match func(network string) bool) error {
+	var deletes []deletion
+	err := scan(tx.Bucket(predictionsBucket), key(id, ""), func(k, v []byte) error {
+		fields := splitKey(k)
+		if len(fields) != 3 {
+			return errors.Errorf("invalid prediction key %q", k)
+		}
+		if !match(fields[1]) {
+			return nil
+		}
+		k = append([]byte(nil), k...)
+		deletes = append(deletes,
+			deletion{predictionsBucket, k},
+			deletion{labelsBucket, key(strings.ToLower(fields[2]), id, fields[1], fields[2])},
+			deletion{scoresBucket, append(descendingScore(decodeFloat(v)), k...)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return deleteAll(tx, deletes)
+}
+
+// deleteFaces deletes the faces of id of the networks matching, with
+// their index entries, distances and neighbours
+func deleteFaces(tx *bbolt.Tx, id string, match func(network string) bool) error {
+	var deletes []deletion
+	faces := map[string]bool{}
+	prefix := key(id, "")
+	err := scan(tx.Bucket(faceIDsBucket), prefix, func(k, _ []byte) error {
+		seq := k[len(prefix):]
+		item, err := getFace(tx, seq)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return errors.Errorf("no face for index key %q", k)
+		}
+		if !match(item.Network) {
+			return nil
+		}
+		fk, err := faceKey(item)
+		if err != nil {
+			return err
+		}
+
+		faces[string(seq)] = true
+		deletes = append(deletes,
+			deletion{facesBucket, append([]byte(nil), seq...)},
+			deletion{faceKeysBucket, fk},
+			deletion{faceIDsBucket, append([]byte(nil), k...)},
+			deletion{comparedBucket, append([]byte(nil), seq...)},
+			deletion{neighboursBucket, append([]byte(nil), seq...)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(faces) > 0 {
+		err = tx.Bucket(distancesBucket).ForEach(func(k, _ []byte) error {
+			if len(k) == 16 && (faces[string(k[:8])] || faces[string(k[8:])]) {
+				deletes = append(deletes, deletion{distancesBucket, append([]byte(nil), k...)})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := deleteAll(tx, deletes); err != nil {
+		return err
+	}
+	return unlinkNeighbours(tx, faces)
+}
+
+// unlinkNeighbours removes the faces from the neighbours of the others,
+// marking them stale
+func unlinkNeighbours(tx *bbolt.Tx, faces map[string]bool) error {
+	if len(faces) == 0 {
+		return nil
+	}
+
+	bucket := tx.Bucket(neighboursBucket)
+	updated := map[string][]byte{}
+	err := bucket.ForEach(func(k, v []byte) error {
+		var stored neighbours
+		if err := json.Unmarshal(v, &stored); err != nil {
+			return errors.Wrapf(err, "invalid neighbours of face %x", k)
+		}
+
+		kept := neighbours{FaceIDs: []int64{}, Distances: []float32{}, Stale: stored.Stale}
+		for i, faceID := range stored.FaceIDs {
+			if faces[string(faceSeq(faceID))] {
+				kept.Stale = true
+				continue
+			}
+			kept.FaceIDs = append(kept.FaceIDs, faceID)
+			kept.Distances = append(kept.Distances, stored.Distances[i])
+		}
+		if len(kept.FaceIDs) == len(stored.FaceIDs) {
+			return nil
+		}
+
+		data, err := json.Marshal(kept)
+		if err != nil {
+			return err
+		}
+		updated[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, data := range updated {
+		if err := bucket.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) OutdatedFaces(network, model string) ([]string, error) {
+	ids := map[string]bool{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(facesBucket).ForEach(func(seq, _ []byte) error {
+			item, err := getFace(tx, seq)
+			if err != nil {
+				return err
+			}
+			if item.Network == network && item.Model != model {
+				ids[item.Identifier] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(ids), nil
+}
+
+func (s *Store) OutdatedPredictions(network, model string) ([]string, error) {
+	ids := map[string]bool{}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(predictionsBucket).ForEach(func(k, v []byte) error {
+			fields := splitKey(k)
+			if len(fields) != 3 {
+				return errors.Errorf("invalid prediction key %q", k)
+			}
+			_, m, err := decodePrediction(v)
+			if err != nil {
+				return err
+			}
+			if fields[1] == network && m != model {
+				ids[fields[0]] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(ids), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ReplaceFaces deletes the faces of network of id, with their distances,
+// and stores items in the same transaction
+func (s *Store) ReplaceFaces(id, network string, items []*gildasai.FaceItem) error {
+	stored := make([]gildasai.FaceItem, len(items))
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		err := deleteFaces(tx, id, func(n string) bool { return n == network })
+		if err != nil {
+			return err
+		}
+
+		for i, item := range items {
+			stored[i] = *item
+			if err := putFace(tx, &stored[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		item.ID = stored[i].ID
+	}
+	return nil
+}
+
+func (s *Store) ReplacePredictions(id, network string, predictions gildasai.Predictions) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		err := deletePredictions(tx, id, func(n string) bool { return n == network })
+		if err != nil {
+			return err
+		}
+		return putPredictions(tx, id, predictions)
+	})
+}
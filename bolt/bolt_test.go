@@ -0,0 +1,87 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempStore(t *testing.T) (*Store, func()) {
+	f, err := ioutil.TempFile("", "gildasai.*.bolt")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err := NewStore(f.Name())
+	require.NoError(t, err)
+	return s, func() {
+		s.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func TestStore(t *testing.T) {
+	gildasaitest.TestStore(t, func(t *testing.T) (gildasaitest.Store, func()) {
+		return tempStore(t)
+	})
+}
+
+func TestReopen(t *testing.T) {
+	f, err := ioutil.TempFile("", "gildasai.*.bolt")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.Remove(f.Name())
+
+	s, err := NewStore(f.Name())
+	require.NoError(t, err)
+	item := &gildasai.PredictionItem{
+		Identifier:  "my desk",
+		Predictions: gildasai.Predictions{{Network: "inception", Score: 0.769, Label: "tv"}},
+	}
+	require.NoError(t, s.StorePrediction(item.Identifier, item))
+	require.NoError(t, s.Close())
+
+	s, err = NewStore(f.Name())
+	require.NoError(t, err)
+	defer s.Close()
+
+	actual, ok, err := s.GetPrediction("my desk")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, item, actual)
+}
+
+func TestIdentifierPrefix(t *testing.T) {
+	s, close := tempStore(t)
+	defer close()
+
+	for _, id := range []string{"photo", "photo 2", "photos/1"} {
+		require.NoError(t, s.StorePrediction(id, &gildasai.PredictionItem{
+			Identifier:  id,
+			Predictions: gildasai.Predictions{{Network: "inception", Score: 0.5, Label: id}},
+		}))
+		require.NoError(t, s.StoreFace(&gildasai.FaceItem{Identifier: id, Network: "face-api-js"}))
+	}
+
+	item, ok, err := s.GetPrediction("photo")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, gildasai.Predictions{{Network: "inception", Score: 0.5, Label: "photo"}}, item.Predictions)
+
+	faces, ok, err := s.GetFaces("photo")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Len(t, faces, 1)
+}
+
+func TestDescendingScore(t *testing.T) {
+	scores := []float32{1e10, 1, 0.5, 0.001, 0, -0.001, -1}
+	for i := 1; i < len(scores); i++ {
+		assert.True(t, string(descendingScore(scores[i-1])) < string(descendingScore(scores[i])),
+			"%f before %f", scores[i-1], scores[i])
+	}
+}
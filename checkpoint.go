@@ -0,0 +1,83 @@
+package gildasai
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// checkpoint records the files handled by an extraction, one quoted
+// path per line. The nil checkpoint records nothing.
+type checkpoint struct {
+	path string
+	f    *os.File
+}
+
+// openCheckpoint opens the checkpoint of path, if any, and returns the
+// files it recorded
+func openCheckpoint(path string) (*checkpoint, map[string]bool, error) {
+	if path == "" {
+		return nil, nil, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error opening checkpoint %q", path)
+	}
+
+	handled := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		file, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			// a line cut by the interruption
+			continue
+		}
+		handled[file] = true
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, nil, errors.Wrapf(err, "error reading checkpoint %q", path)
+	}
+
+	return &checkpoint{path: path, f: f}, handled, nil
+}
+
+func (c *checkpoint) record(file string) error {
+	if c == nil {
+		return nil
+	}
+
+	// a line cut by an interruption is ended, not to be read with the
+	// next one
+	_, err := c.f.WriteString("\n" + strconv.Quote(file) + "\n")
+	if err != nil {
+		return errors.Wrapf(err, "error recording %q to checkpoint %q", file, c.path)
+	}
+	return nil
+}
+
+func (c *checkpoint) close() error {
+	if c == nil {
+		return nil
+	}
+
+	if err := c.f.Close(); err != nil {
+		return errors.Wrapf(err, "error closing checkpoint %q", c.path)
+	}
+	return nil
+}
+
+// remove removes the checkpoint of a completed extraction
+func (c *checkpoint) remove() error {
+	if c == nil {
+		return nil
+	}
+
+	if err := os.Remove(c.path); err != nil {
+		return errors.Wrapf(err, "error removing checkpoint %q", c.path)
+	}
+	return nil
+}
@@ -9,8 +9,11 @@ type Predictions []Prediction
 
 type Prediction struct {
 	Network string
-	Score   float32
-	Label   string
+	// Model is the fingerprint of the model which computed the
+	// prediction
+	Model string
+	Score float32
+	Label string
 }
 
 func (p Predictions) Best(n int) []Prediction {
@@ -18,6 +21,10 @@ func (p Predictions) Best(n int) []Prediction {
 		return p[i].Score > p[j].Score
 	})
 
+	if n > len(p) {
+		n = len(p)
+	}
+
 	return p[:n]
 }
 
@@ -6,7 +6,6 @@ import (
 	"image/jpeg"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 
 	gildasai "github.com/gildasch/gildas-ai"
@@ -44,7 +43,12 @@ func main() {
 
 func calculateDescriptors(extractor *gildasai.Extractor,
 	facesFolder string) (map[string]*gildasai.Descriptors, error) {
-	faceFiles, err := filepath.Glob(strings.TrimSuffix(facesFolder, "/") + "/*")
+	walker, err := gildasai.FolderWalkerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	faceFiles, err := walker.Files(strings.TrimSuffix(facesFolder, "/"))
 	if err != nil {
 		return nil, err
 	}
@@ -1,96 +1,74 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	gildasai "github.com/gildasch/gildas-ai"
-	"github.com/gildasch/gildas-ai/sqlite"
+	"github.com/gildasch/gildas-ai/stores"
 )
 
-const (
-	maxDistance = 0.6
+var (
+	incremental = flag.Bool("incremental", false, "only compare the faces which were not compared yet")
+	k           = flag.Int("k", 0, "only store the distances to the k nearest neighbours of each face")
+	workers     = flag.Int("workers", 0, "number of workers, one per CPU when 0")
+	maxDistance = flag.Float64("max-distance", gildasai.MaxFaceDistance, "distance above which the distances are not stored")
+	graph       = flag.Bool("graph", false, "update the graph of the k nearest neighbours of the faces instead, 10 when k is 0")
 )
 
 func usage() {
-	fmt.Printf("%s [sqlite-db-file]\n", os.Args[0])
+	fmt.Printf("%s [-incremental|-graph] [-k n] [-workers n] [-max-distance d] [sqlite-db-file|store-url]\n", os.Args[0])
 }
 
 func main() {
-	if len(os.Args) < 2 {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
 		usage()
 		return
 	}
 
-	sqliteFile := os.Args[1]
+	storeURL := flag.Arg(0)
 
-	store, err := sqlite.NewStore(sqliteFile)
+	store, err := stores.Open(storeURL)
 	if err != nil {
-		log.Fatal("could not create store with file "+sqliteFile+": ", err)
+		log.Fatal("could not create store "+storeURL+": ", err)
 	}
 	defer store.Close()
 
-	faceItems, err := store.GetAllFaces()
-	if err != nil {
-		log.Fatal("could not get all faces from store: ", err)
-	}
-
-	total := len(faceItems)
-	for i1, fi1 := range faceItems {
-		if len(fi1.Descriptors) == 0 || fi1.Detection.Score < 0.9 || fi1.Landmarks.Confidence() < 0.5 {
-			continue
+	if *graph {
+		g := &gildasai.FaceGraph{
+			Store:       store,
+			K:           *k,
+			MaxDistance: float32(*maxDistance),
+			Workers:     *workers,
 		}
-		for i2, fi2 := range faceItems[i1+1:] {
-			if len(fi2.Descriptors) == 0 || fi2.Detection.Score < 0.9 || fi2.Landmarks.Confidence() < 0.5 {
-				continue
-			}
-			fmt.Printf("\rprogress: %d/%d (distance with: %d/%d)", i1, total, i2, total)
-
-			fi1, fi2 := sort(fi1, fi2)
-
-			_, ok, err := store.GetFaceDistance(fi1, fi2)
-			if err != nil {
-				log.Fatal("could not get distance from store: ", err)
-			}
-			if ok {
-				continue
-			}
-
-			dist, err := fi1.Descriptors.DistanceTo(fi2.Descriptors)
-			if err != nil {
-				log.Fatal("could not calculate distance: ", err)
-			}
-
-			if dist > maxDistance {
-				continue
-			}
-
-			err = store.StoreFaceDistance(fi1, fi2, dist)
-			if err != nil {
-				log.Fatal("could not store distance to store: ", err)
-			}
+		updated, err := g.Update()
+		if err != nil {
+			log.Fatal("could not update the face graph: ", err)
 		}
+		fmt.Printf("updated the neighbours of %d faces\n", updated)
+		return
 	}
-}
 
-func sort(fi1, fi2 *gildasai.FaceItem) (s1, s2 *gildasai.FaceItem) {
-	detection1, err := json.Marshal(fi1.Detection)
-	if err != nil {
-		return fi1, fi2
+	job := &gildasai.DistanceJob{
+		Store:       store,
+		MaxDistance: float32(*maxDistance),
+		K:           *k,
+		Workers:     *workers,
+		Incremental: *incremental,
+		Progress: func(done, total int) {
+			fmt.Printf("\rprogress: %d/%d", done, total)
+		},
 	}
-	detection2, err := json.Marshal(fi2.Detection)
-	if err != nil {
-		return fi1, fi2
-	}
-
-	str1 := fi1.Identifier + fi1.Network + string(detection1)
-	str2 := fi2.Identifier + fi2.Network + string(detection2)
 
-	if str1 <= str2 {
-		return fi1, fi2
+	report, err := job.Run()
+	if err != nil {
+		log.Fatal("could not compute the distances: ", err)
 	}
 
-	return fi2, fi1
+	fmt.Printf("\ncompared %d of %d faces: %d distances computed, %d stored\n",
+		report.Compared, report.Faces, report.Computed, report.Stored)
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
 
 	gildasai "github.com/gildasch/gildas-ai"
@@ -73,7 +72,12 @@ func main() {
 		cache = sqliteCache
 	}
 
-	objects, err := inspectFolder(cache, classifier, imageFolder)
+	walker, err := gildasai.FolderWalkerFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	objects, err := inspectFolder(cache, classifier, walker, imageFolder)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -90,12 +94,12 @@ func main() {
 	}
 }
 
-func inspectFolder(cache Cache, classifier gildasai.Classifier, folder string) (map[string][]string, error) {
+func inspectFolder(cache Cache, classifier gildasai.Classifier, walker *gildasai.FolderWalker, folder string) (map[string][]string, error) {
 	if cache == nil && classifier == nil {
 		return nil, errors.New("cannot inspect without cache or classifier")
 	}
 
-	files, err := filepath.Glob(folder + "/*")
+	files, err := walker.Files(folder)
 	if err != nil {
 		return nil, err
 	}
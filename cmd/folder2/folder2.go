@@ -1,18 +1,37 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 
 	gildasai "github.com/gildasch/gildas-ai"
 	"github.com/gildasch/gildas-ai/faceapi"
-	"github.com/gildasch/gildas-ai/sqlite"
+	"github.com/gildasch/gildas-ai/stores"
+	"github.com/pkg/errors"
 )
 
 func usage() {
-	fmt.Printf("%s [model-root-folder] [image-folder]\n", os.Args[0])
+	fmt.Printf("%s [model-root-folder] [image-folder] [store-url]\n", os.Args[0])
+	fmt.Printf("%s gc [image-folder] [store-url]\n", os.Args[0])
+	fmt.Printf("%s reextract [model-root-folder] [image-folder] [store-url]\n", os.Args[0])
+	fmt.Printf("%s watch [model-root-folder] [image-folder] [store-url]\n", os.Args[0])
+	fmt.Printf("Only the new and modified files are extracted, gc deletes the faces and predictions of the deleted files\n")
+	fmt.Printf("reextract extracts again the faces computed by other versions of the models\n")
+	fmt.Printf("watch extracts the files and their FACE_NEIGHBOURS=10 nearest faces as they are added, modified or deleted, WATCH_DELAY=2s after their last change\n")
+	fmt.Printf("inotify is replaced by listing the folder every WATCH_POLL=30s\n")
+	fmt.Printf("The store defaults to the sqlite file image-folder/.inception.sqlite, bolt:///path/to/file.bolt is a pure Go alternative\n")
+	fmt.Printf("Large photos can be tiled with FACES_TILE_SIZE=1024 FACES_SCALES=1,0.5 FACES_MIN_SIZE=20\n")
+	fmt.Printf("Rotated photos are handled with FACES_ORIENTATION=retry or FACES_ORIENTATION=best\n")
+	fmt.Printf("The images of the subfolders are extracted, the files listed are set with FOLDER_RECURSIVE=false FOLDER_SYMLINKS=follow FOLDER_HIDDEN=true\n")
+	fmt.Printf("FOLDER_INCLUDE=*.jpg,*.png FOLDER_EXCLUDE=thumbnails,*/raw/* FOLDER_MIN_SIZE=10000 FOLDER_MAX_SIZE=50000000\n")
+	fmt.Printf("The files are extracted by FOLDER_WORKERS=4 workers, one per CPU by default, an interrupted extraction resumes where it stopped\n")
 }
 
 func main() {
@@ -21,37 +40,319 @@ func main() {
 		return
 	}
 
-	modelRootFolder := strings.TrimSuffix(os.Args[1], "/")
-	imageFolder := strings.TrimSuffix(os.Args[2], "/")
+	if os.Args[1] == "gc" {
+		if err := gc(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	command := ""
+	args := os.Args[1:]
+	if args[0] == "reextract" || args[0] == "watch" {
+		if len(args) < 3 {
+			usage()
+			return
+		}
+		command = args[0]
+		args = args[1:]
+	}
+
+	modelRootFolder := strings.TrimSuffix(args[0], "/")
+	imageFolder := strings.TrimSuffix(args[1], "/")
 
 	extractor, err := faceapi.NewDefaultExtractor(modelRootFolder)
 	if err != nil {
 		log.Fatal("could not load face extractor: ", err)
 	}
 
-	store, err := sqlite.NewStore(imageFolder + "/.inception.sqlite")
+	if err := configureExtractor(extractor); err != nil {
+		log.Fatal("could not configure the face extractor: ", err)
+	}
+
+	walker, err := gildasai.FolderWalkerFromEnv()
 	if err != nil {
-		log.Fatal("could not create store with file "+imageFolder+"/.inception.sqlite: ", err)
+		log.Fatal("could not configure the folder walker: ", err)
+	}
+
+	storeURL := imageFolder + "/.inception.sqlite"
+	if len(args) >= 3 {
+		storeURL = args[2]
+	}
+
+	store, err := stores.Open(storeURL)
+	if err != nil {
+		log.Fatal("could not create store "+storeURL+": ", err)
 	}
 	defer store.Close()
 
-	current, errors, done, total, err := gildasai.ExtractFacesFromFolder(imageFolder, extractor, store)
+	switch command {
+	case "reextract":
+		if err := reextractFaces(extractor, store); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "watch":
+		if err := watch(imageFolder, walker, extractor, store); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := extract(imageFolder, storeURL, walker, extractor, store); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// extract extracts the faces of the new and modified files of
+// imageFolder to the store of storeURL. An interrupted extraction resumes
+// where it stopped.
+func extract(imageFolder, storeURL string, walker *gildasai.FolderWalker, extractor *gildasai.Extractor, store stores.Store) error {
+	fe := &gildasai.FolderExtractor{
+		Walker:     walker,
+		Extractor:  extractor,
+		Store:      store,
+		Checkpoint: checkpointPath(imageFolder, storeURL),
+	}
+	if workers := os.Getenv("FOLDER_WORKERS"); workers != "" {
+		n, err := strconv.Atoi(workers)
+		if err != nil {
+			return errors.Wrapf(err, "invalid FOLDER_WORKERS %q", workers)
+		}
+		fe.Workers = n
+	}
+
+	ctx, stop := interruptible()
+	defer stop()
+
+	x, err := fe.Start(ctx, imageFolder)
 	if err != nil {
-		log.Fatal("could not run the extraction: ", err)
+		return errors.Wrap(err, "could not run the extraction")
 	}
 
-	var c string
 	processed := 0
+	for event := range x.Events() {
+		processed++
+		for _, err := range event.Errs {
+			fmt.Printf("\nerror on file %q: %v\n", event.File, err)
+		}
+		fmt.Printf("\rprogress: %d/%d (%s: %s)", processed, x.Total, event.Status, event.File)
+	}
+
+	report, err := x.Wait()
+	if err == context.Canceled {
+		fmt.Printf("\nthe extraction was interrupted, run it again to resume it\n")
+		printReport(report)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nthe extraction is complete\n")
+	printReport(report)
+	return nil
+}
+
+func printReport(report *gildasai.FolderReport) {
+	fmt.Printf("added: %d, modified: %d, unchanged: %d, skipped: %d, removed: %d, failed: %d\n",
+		len(report.Added), len(report.Modified), len(report.Unchanged), len(report.Skipped), len(report.Removed), len(report.Failed))
+	for _, file := range report.Added {
+		fmt.Printf("added %s\n", file)
+	}
+	for _, file := range report.Modified {
+		fmt.Printf("modified %s\n", file)
+	}
+	for _, file := range report.Removed {
+		fmt.Printf("removed %s\n", file)
+	}
+	for _, file := range report.Failed {
+		fmt.Printf("failed %s\n", file)
+	}
+}
+
+// watch extracts the faces of the files of imageFolder, and their
+// neighbours, as they are added or modified, and deletes them with the
+// files, until interrupted
+func watch(imageFolder string, walker *gildasai.FolderWalker, extractor *gildasai.Extractor, store stores.Store) error {
+	neighbours, err := gildasai.NeighboursFromEnv()
+	if err != nil {
+		return err
+	}
+
+	watcher, delay, err := gildasai.WatcherFromEnv(imageFolder, walker)
+	if err != nil {
+		return errors.Wrapf(err, "could not watch %q", imageFolder)
+	}
+	defer watcher.Close()
+
+	updater := &gildasai.FolderUpdater{
+		Root:       imageFolder,
+		Walker:     walker,
+		Extractor:  extractor,
+		Store:      store,
+		Neighbours: neighbours,
+	}
+
+	fmt.Printf("watching %s\n", imageFolder)
+	reports, errs := updater.Watch(watcher, delay)
 	for {
-		fmt.Printf("\rprogress: %d/%d (current: %s)", processed, total, c)
 		select {
-		case c = <-current:
-		case err = <-errors:
-			fmt.Printf("\nerror on file %d/%q: %v\n", processed, c, err)
-		case <-done:
-			fmt.Printf("\nthe extraction in complete\n")
-			return
+		case err, ok := <-errs:
+			if !ok {
+				return nil
+			}
+			fmt.Printf("error: %v\n", err)
+		case report, ok := <-reports:
+			if !ok {
+				return nil
+			}
+			if len(report.Added)+len(report.Modified)+len(report.Removed)+len(report.Failed) > 0 {
+				printReport(report)
+			}
 		}
+	}
+}
+
+// gc deletes the faces, distances and predictions of the deleted files.
+// Its arguments are [image-folder] [store-url].
+func gc(args []string) error {
+	imageFolder := strings.TrimSuffix(args[0], "/")
+
+	storeURL := imageFolder + "/.inception.sqlite"
+	if len(args) >= 2 {
+		storeURL = args[1]
+	}
+
+	store, err := stores.Open(storeURL)
+	if err != nil {
+		return errors.Wrapf(err, "could not open store %q", storeURL)
+	}
+	defer store.Close()
+
+	removed, err := gildasai.CollectGarbage(imageFolder, store)
+	for _, file := range removed {
+		fmt.Printf("removed %s\n", file)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removed: %d\n", len(removed))
+	return nil
+}
+
+// reextractFaces extracts again the faces computed by other versions of
+// the models of extractor
+func reextractFaces(extractor *gildasai.Extractor, store stores.Store) error {
+	ctx, stop := interruptible()
+	defer stop()
+
+	u, err := gildasai.ReextractFaces(ctx, extractor, store)
+	if err != nil {
+		return errors.Wrap(err, "could not run the re-extraction")
+	}
+	fmt.Printf("%d files have faces computed by other models than %s\n", u.Total, extractor.Fingerprint)
+
+	processed := 0
+	for event := range u.Events() {
 		processed++
+		if event.Err != nil {
+			fmt.Printf("\nerror on file %q: %v\n", event.ID, event.Err)
+		}
+		fmt.Printf("\rprogress: %d/%d (current: %s)", processed, u.Total, event.ID)
+	}
+
+	n, err := u.Wait()
+	if err == context.Canceled {
+		fmt.Printf("\nthe re-extraction was interrupted after %d files, run it again to resume it\n", n)
+		return nil
 	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nthe faces of %d files were extracted again\n", n)
+	return nil
+}
+
+// interruptible returns a context cancelled on interrupt and the function
+// releasing it
+func interruptible() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupt)
+		cancel()
+	}
+}
+
+var orientationModes = map[string]gildasai.OrientationMode{
+	"":      gildasai.UprightOnly,
+	"retry": gildasai.RetryRotated,
+	"best":  gildasai.BestRotation,
+}
+
+// configureExtractor sets the orientation mode of extractor and wraps its
+// detector in a TiledDetector if FACES_TILE_SIZE or FACES_SCALES are set
+func configureExtractor(extractor *gildasai.Extractor) error {
+	orientation := os.Getenv("FACES_ORIENTATION")
+	mode, ok := orientationModes[orientation]
+	if !ok {
+		return errors.Errorf("invalid FACES_ORIENTATION %q", orientation)
+	}
+	extractor.Orientation = mode
+
+	if size := os.Getenv("FACES_MIN_SIZE"); size != "" {
+		minSize, err := strconv.Atoi(size)
+		if err != nil {
+			return errors.Wrapf(err, "invalid FACES_MIN_SIZE %q", size)
+		}
+		extractor.MinFaceSize = minSize
+	}
+
+	tileSize, scales := os.Getenv("FACES_TILE_SIZE"), os.Getenv("FACES_SCALES")
+	if tileSize == "" && scales == "" {
+		return nil
+	}
+
+	size := 0
+	if tileSize != "" {
+		var err error
+		size, err = strconv.Atoi(tileSize)
+		if err != nil {
+			return errors.Wrapf(err, "invalid FACES_TILE_SIZE %q", tileSize)
+		}
+	}
+
+	tiled := gildasai.NewTiledDetector(extractor.Detector, size)
+
+	if scales != "" {
+		for _, s := range strings.Split(scales, ",") {
+			scale, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid scale %q in FACES_SCALES", s)
+			}
+			tiled.Scales = append(tiled.Scales, scale)
+		}
+	}
+
+	extractor.Detector = tiled
+	return nil
+}
+
+// checkpointPath returns the checkpoint of the extraction of imageFolder
+// to the store of storeURL, each store having its own
+func checkpointPath(imageFolder, storeURL string) string {
+	sum := sha256.Sum256([]byte(storeURL))
+	return imageFolder + "/.folder2." + hex.EncodeToString(sum[:6]) + ".checkpoint"
 }
@@ -15,7 +15,7 @@ import (
 	"github.com/gildasch/gildas-ai/imagenet"
 	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/gildasch/gildas-ai/maskrcnn"
-	"github.com/gildasch/gildas-ai/sqlite"
+	"github.com/gildasch/gildas-ai/stores"
 	"github.com/gin-contrib/cache"
 	"github.com/gin-contrib/cache/persistence"
 	"github.com/gin-gonic/gin"
@@ -24,9 +24,29 @@ import (
 func usage() {
 	fmt.Printf("Usage: %s [xception|resnet] path/to/image.jpg\n", os.Args[0])
 	fmt.Printf("Usage: %s web\n", os.Args[0])
+	fmt.Printf("Usage: %s migrate [-dry-run] [path/to/file.sqlite]\n", os.Args[0])
+	fmt.Printf("Usage: %s reclassify [xception|resnet|nasnet|pnasnet]...\n", os.Args[0])
+	fmt.Printf("reclassify computes again, with all the models by default, the predictions of the store computed by other versions of them\n")
+	fmt.Printf("The web store is set with STORE=bolt:///path/to/file.bolt or STORE=sqlite:///path/to/file.sqlite\n")
+	fmt.Printf("The photos added to a folder are extracted and classified as they appear with WATCH=path/to/photos\n")
+	fmt.Printf("The faces are clustered with their FACE_NEIGHBOURS=10 nearest faces\n")
+	fmt.Printf("The remote images are fetched within FETCH_TIMEOUT, FETCH_MAX_BYTES, FETCH_MAX_PIXELS and FETCH_MAX_REDIRECTS, from public addresses unless FETCH_ALLOW_PRIVATE=true\n")
 }
 
 func main() {
+	if len(os.Args) >= 2 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	fetcher, err := gildasai.FetcherFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	imageutils.RemoteFetcher = fetcher
+
 	modelsRoot := os.Getenv("MODELS_ROOT")
 
 	models := map[string]func(modelRoot string) (*imagenet.Model, func() error, error){
@@ -36,19 +56,27 @@ func main() {
 		"pnasnet":  imagenet.NewPnasnet,
 	}
 
-	detector, err := faceapi.NewDetectorFromFile("faceapi/frozen_inference_graph_face.pb")
+	if len(os.Args) >= 2 && os.Args[1] == "reclassify" {
+		if err := reclassifyModels(models, modelsRoot, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	detectorFile := "faceapi/frozen_inference_graph_face.pb"
+	detector, err := faceapi.NewDetectorFromFile(detectorFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	landmark, err := faceapi.NewLandmarkFromFile(
-		modelsRoot+"models/face-api-js-landmarks/face-api-landmarksnet_tf_1.8.0", "myTag")
+	landmarkDir := modelsRoot + "models/face-api-js-landmarks/face-api-landmarksnet_tf_1.8.0"
+	landmark, err := faceapi.NewLandmarkFromFile(landmarkDir, "myTag")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	descriptor, err := faceapi.NewDescriptorFromFile(
-		modelsRoot+"models/face-api-js-descriptors/face-api-descriptors_tf_1.8.0", "myTag")
+	descriptorDir := modelsRoot + "models/face-api-js-descriptors/face-api-descriptors_tf_1.8.0"
+	descriptor, err := faceapi.NewDescriptorFromFile(descriptorDir, "myTag")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -66,19 +94,23 @@ func main() {
 			classifiers[name] = m
 		}
 
-		sqliteLocation := ".inception.sqlite"
-		if os.Getenv("SQLITE_STORE") != "" {
-			sqliteLocation = os.Getenv("SQLITE_STORE")
+		dataStore, err := stores.Open(storeURL())
+		if err != nil {
+			log.Fatal(err)
 		}
-		sqliteStore, err := sqlite.NewStore(sqliteLocation)
+		defer dataStore.Close()
+
+		fingerprint, err := faceapi.Fingerprint(detectorFile, landmarkDir, descriptorDir)
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		extractor := &gildasai.Extractor{
-			Detector:   detector,
-			Landmark:   landmark,
-			Descriptor: descriptor}
+			Network:     faceapi.Network,
+			Fingerprint: fingerprint,
+			Detector:    detector,
+			Landmark:    landmark,
+			Descriptor:  descriptor}
 
 		app := gin.Default()
 		app.Static("/static", "./static")
@@ -94,7 +126,14 @@ func main() {
 			c.HTML(http.StatusOK, "index.html", nil)
 		})
 		app.GET("/object/api", api.ClassifyHandler(classifiers, false))
+		app.POST("/object/api", api.ClassifyHandler(classifiers, false))
 		app.GET("/object", api.ClassifyHandler(classifiers, true))
+		app.POST("/object", api.ClassifyHandler(classifiers, true))
+
+		app.GET("/api/faces", api.FaceDetectHandler(extractor))
+		app.POST("/api/faces", api.FaceDetectHandler(extractor))
+		app.GET("/api/faces/compare", api.FaceCompareHandler(extractor))
+		app.POST("/api/faces/compare", api.FaceCompareHandler(extractor))
 
 		batches := map[string]*gildasai.Batch{}
 		app.GET("/faces", api.FacesHomeHandler(batches))
@@ -103,11 +142,12 @@ func main() {
 		app.GET("/faces/batch/:batchID/sources/:name", api.FaceSourceHandler(batches))
 		app.GET("/faces/batch/:batchID/cropped/:name", api.FaceCroppedHandler(batches))
 
-		app.GET("/photos", api.PhotosHandler(sqliteStore))
-		app.GET("/photos/*filename", api.GetPhotoHandler(sqliteStore))
+		app.GET("/photos", api.PhotosHandler(dataStore))
+		app.GET("/photos/*filename", api.GetPhotoHandler(dataStore))
 
 		store := persistence.NewInMemoryStore(365 * 24 * time.Hour)
 		app.GET("/faceswap", cache.CachePage(store, 12*time.Hour, api.FaceSwapHandler(extractor, landmark)))
+		app.POST("/faceswap", api.FaceSwapHandler(extractor, landmark))
 
 		if modelsRoot != "" {
 			modelsRoot += "mask/"
@@ -120,18 +160,38 @@ func main() {
 		}
 		masksStore := map[string]api.MaskResult{}
 		app.GET("/masks", api.MaskHandler(maskDetector, masksStore))
+		app.POST("/masks", api.MaskHandler(maskDetector, masksStore))
 		app.GET("/masks/result.jpg", api.MaskImageHandler(masksStore))
 
-		clusters, err := api.CalculateClusters(sqliteStore)
+		neighbours, err := gildasai.NeighboursFromEnv()
 		if err != nil {
 			log.Fatal(err)
 		}
+		graph := &gildasai.FaceGraph{Store: dataStore, K: neighbours, MaxDistance: gildasai.MaxFaceDistance}
+		if _, err := graph.Update(); err != nil {
+			log.Fatal(err)
+		}
 
-		app.GET("/facesearch", api.FacesearchHandler(sqliteStore, clusters))
-		app.GET("/facesearch/:detection/matches", api.FacesearchDetectionHandler(sqliteStore, clusters))
-		app.GET("/facesearch/:detection/against/:detection2", api.FacesearchAgainstHandler(sqliteStore))
-		app.GET("/facesearch/:detection/detection.jpg", api.FacesearchDetectionImageHandler())
-		app.GET("/facesearch/:detection/landmarks.jpg", api.FacesearchLandmarkImageHandler(sqliteStore))
+		clusters, err := api.CalculateClusters(dataStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if folder := os.Getenv("WATCH"); folder != "" {
+			if err := watch(folder, extractor, classifiers, dataStore, neighbours, clusters); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		app.GET("/facesearch", api.FacesearchHandler(extractor, clusters, true))
+		app.POST("/facesearch", api.FacesearchHandler(extractor, clusters, true))
+		app.GET("/api/facesearch", api.FacesearchHandler(extractor, clusters, false))
+		app.POST("/api/facesearch", api.FacesearchHandler(extractor, clusters, false))
+		app.GET("/facesearch/:detection/matches", api.FacesearchDetectionHandler(dataStore))
+		app.GET("/facesearch/:detection/against/:detection2", api.FacesearchAgainstHandler(dataStore))
+		app.GET("/facesearch/:detection/detection.jpg", api.FacesearchDetectionImageHandler(dataStore))
+		app.GET("/facesearch/:detection/landmarks.jpg", api.FacesearchLandmarkImageHandler(dataStore))
+		app.GET("/facesearch/:detection/average.jpg", api.FacesearchAverageImageHandler(dataStore, clusters))
 
 		app.Run()
 	}
@@ -182,3 +242,14 @@ func main() {
 		fmt.Printf("%v (%f)\n", b.Label, b.Score)
 	}
 }
+
+// storeURL returns the URL of the store, set by STORE or SQLITE_STORE
+func storeURL() string {
+	if os.Getenv("STORE") != "" {
+		return os.Getenv("STORE")
+	}
+	if os.Getenv("SQLITE_STORE") != "" {
+		return os.Getenv("SQLITE_STORE")
+	}
+	return ".inception.sqlite"
+}
@@ -0,0 +1,65 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gildasch/gildas-ai/sqlite"
+	"github.com/pkg/errors"
+)
+
+// migrate upgrades the schema of a sqlite store. Its arguments are
+// [-dry-run] [path/to/file.sqlite].
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the migrations without applying them")
+	flags.Parse(args)
+
+	filename := ".inception.sqlite"
+	if os.Getenv("SQLITE_STORE") != "" {
+		filename = os.Getenv("SQLITE_STORE")
+	}
+	if flags.NArg() > 0 {
+		filename = flags.Arg(0)
+	}
+	filename = strings.TrimPrefix(filename, "sqlite://")
+
+	if _, err := os.Stat(strings.SplitN(filename, "?", 2)[0]); err != nil {
+		return errors.Wrapf(err, "cannot migrate %q", filename)
+	}
+
+	store, err := sqlite.Open(filename)
+	if err != nil {
+		return errors.Wrapf(err, "could not open store with file %q", filename)
+	}
+	defer store.Close()
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is at schema version %d\n", filename, version)
+
+	migrations, err := store.Migrate(*dryRun)
+	for _, m := range migrations {
+		if *dryRun {
+			fmt.Printf("would apply %d: %s\n", m.Version, m.Description)
+		} else {
+			fmt.Printf("applied %d: %s\n", m.Version, m.Description)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) == 0 {
+		fmt.Println("the schema is up to date")
+	}
+
+	return nil
+}
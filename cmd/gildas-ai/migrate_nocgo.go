@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import "github.com/pkg/errors"
+
+// migrate fails, the sqlite stores needing a binary built with cgo
+func migrate(args []string) error {
+	return errors.New("migrate needs a binary built with cgo")
+}
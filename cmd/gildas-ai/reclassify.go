@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/imagenet"
+	"github.com/gildasch/gildas-ai/stores"
+	"github.com/pkg/errors"
+)
+
+// reclassifyModels loads the models of names, all of them when empty, and
+// classifies again the images of the store they have outdated predictions
+// of
+func reclassifyModels(models map[string]func(modelRoot string) (*imagenet.Model, func() error, error), modelsRoot string, names []string) error {
+	if len(names) == 0 {
+		for name := range models {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var classifiers []reclassifier
+	for _, name := range names {
+		load, ok := models[name]
+		if !ok {
+			return errors.Errorf("unknown model %q", name)
+		}
+		m, close, err := load(modelsRoot)
+		if err != nil {
+			return errors.Wrapf(err, "could not load model %q", name)
+		}
+		defer close()
+
+		classifiers = append(classifiers, reclassifier{Classifier: m, Network: m.ID, Model: m.Fingerprint})
+	}
+
+	store, err := stores.Open(storeURL())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return reclassify(context.Background(), classifiers, store, os.Stdout)
+}
+
+// reclassifier is a classifier with the network and the version of the
+// model of its predictions
+type reclassifier struct {
+	gildasai.Classifier
+	Network, Model string
+}
+
+// reclassify classifies again, with each of classifiers, the images whose
+// predictions were computed by other versions of their models, writing
+// its progress to out
+func reclassify(ctx context.Context, classifiers []reclassifier, store gildasai.ModelStore, out io.Writer) error {
+	for _, c := range classifiers {
+		u, err := gildasai.Reclassify(ctx, c.Classifier, c.Network, c.Model, store)
+		if err != nil {
+			return errors.Wrapf(err, "could not reclassify the predictions of %s", c.Network)
+		}
+		fmt.Fprintf(out, "%d files have %s predictions computed by other models than %s\n", u.Total, c.Network, c.Model)
+
+		for event := range u.Events() {
+			if event.Err != nil {
+				fmt.Fprintf(out, "error on file %q: %v\n", event.ID, event.Err)
+			}
+		}
+
+		n, err := u.Wait()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "the %s predictions of %d files were computed again\n", c.Network, n)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReclassify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reclassify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	photo, missing := filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png")
+	f, err := os.Create(photo)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	require.NoError(t, f.Close())
+
+	store := memory.NewStore()
+	for _, id := range []string{photo, missing} {
+		require.NoError(t, store.StorePrediction(id, &gildasai.PredictionItem{
+			Identifier: id,
+			Predictions: gildasai.Predictions{
+				{Network: "xception", Model: "xception@v1", Label: "cow", Score: 0.4},
+				{Network: "resnet", Model: "resnet@v2", Label: "cow", Score: 0.5},
+			},
+		}))
+	}
+
+	xception := &gildasaitest.Classifier{Predictions: []gildasai.Predictions{{
+		{Network: "xception", Model: "xception@v2", Label: "ox", Score: 0.9},
+	}}}
+	resnet := &gildasaitest.Classifier{}
+
+	var out bytes.Buffer
+	err = reclassify(context.Background(), []reclassifier{
+		{Classifier: xception, Network: "xception", Model: "xception@v2"},
+		{Classifier: resnet, Network: "resnet", Model: "resnet@v2"},
+	}, store, &out)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, xception.CallCount("Classify"), "the missing file is not classified")
+	assert.Equal(t, 0, resnet.CallCount("Classify"), "the resnet predictions are up to date")
+	assert.Contains(t, out.String(), "error on file "+`"`+missing+`"`)
+	assert.Contains(t, out.String(), "the xception predictions of 1 files were computed again")
+
+	item, _, err := store.GetPrediction(photo)
+	require.NoError(t, err)
+	assert.Contains(t, item.Predictions, xception.Predictions[0][0])
+
+	outdated, err := store.OutdatedPredictions("xception", "xception@v2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{missing}, outdated)
+}
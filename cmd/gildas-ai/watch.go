@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/api"
+	"github.com/gildasch/gildas-ai/stores"
+	"github.com/pkg/errors"
+)
+
+// watch keeps the faces, neighbours and predictions of the photos of
+// folder up to date in store, and the clusters calculated from them
+func watch(folder string, extractor *gildasai.Extractor, classifiers map[string]gildasai.Classifier, store stores.Store, neighbours int, clusters *api.FaceClusters) error {
+	folder = strings.TrimSuffix(folder, "/")
+
+	walker, err := gildasai.FolderWalkerFromEnv()
+	if err != nil {
+		return errors.Wrap(err, "could not configure the folder walker")
+	}
+
+	watcher, delay, err := gildasai.WatcherFromEnv(folder, walker)
+	if err != nil {
+		return errors.Wrapf(err, "could not watch %q", folder)
+	}
+
+	var names []string
+	for name := range classifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	updater := &gildasai.FolderUpdater{
+		Root:       folder,
+		Walker:     walker,
+		Extractor:  extractor,
+		Store:      store,
+		Neighbours: neighbours,
+	}
+	for _, name := range names {
+		updater.Classifiers = append(updater.Classifiers, classifiers[name])
+	}
+
+	go func() {
+		reports, errs := updater.Watch(watcher, delay)
+		for {
+			select {
+			case err, ok := <-errs:
+				if !ok {
+					return
+				}
+				log.Printf("watch: %v", err)
+			case report, ok := <-reports:
+				if !ok {
+					return
+				}
+				if len(report.Added)+len(report.Modified)+len(report.Removed) == 0 {
+					continue
+				}
+				log.Printf("watch: added: %d, modified: %d, removed: %d, failed: %d",
+					len(report.Added), len(report.Modified), len(report.Removed), len(report.Failed))
+				if err := clusters.Update(store); err != nil {
+					log.Printf("watch: could not update the clusters: %v", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
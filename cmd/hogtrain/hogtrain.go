@@ -0,0 +1,427 @@
+// hogtrain trains the linear classifier of the hog face detector and
+// writes its weights as a go file.
+//
+// Positives are the faces of a few pictures annotated with the boxes of
+// the tensorflow detector, and the faces of LFW, whose boxes are found by
+// a first classifier trained on the annotated faces only. Negatives are
+// random windows away from the faces, then the false detections of the
+// previous classifier.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+
+	"github.com/gildasch/gildas-ai/hog"
+	"github.com/gildasch/gildas-ai/imageutils"
+)
+
+// annotated are faces boxes from the tensorflow detector. testdata/group.jpg
+// is left out to evaluate the detector.
+var annotated = map[string][]image.Rectangle{
+	"faceapi/pictures/1.jpg": {
+		image.Rect(929, 316, 1000, 399),
+		image.Rect(996, 276, 1074, 380),
+		image.Rect(825, 368, 899, 462),
+		image.Rect(651, 385, 725, 469),
+		image.Rect(710, 307, 780, 390)},
+	"faceapi/pictures/2.jpg": {
+		image.Rect(1084, 268, 1195, 420),
+		image.Rect(823, 158, 933, 294),
+		image.Rect(223, 110, 379, 301),
+		image.Rect(1376, 132, 1520, 336),
+		image.Rect(533, 100, 655, 283)},
+	"faceapi/pictures/3.png": {
+		image.Rect(528, 237, 762, 484)},
+	"testdata/gab.png": {
+		image.Rect(36, 55, 181, 281)},
+}
+
+// backgrounds are pictures without any face
+var backgrounds = []string{
+	"pictures/objects/*",
+	"static/sportcar.png",
+	"static/wine.png",
+	"tutorials/*/cat.jpg",
+}
+
+var (
+	root            = flag.String("root", "..", "root of the repository")
+	out             = flag.String("out", "weights.go", "output file")
+	seed            = flag.Int64("seed", 2, "random seed")
+	jitters         = flag.Int("jitters", 4, "number of jittered copies of each positive")
+	randomNeg       = flag.Int("negatives", 3000, "number of random negatives")
+	miningSteps     = flag.Int("mining", 6, "number of hard negatives mining steps")
+	c               = flag.Float64("c", 0.1, "SVM regularization parameter")
+	miningThreshold = flag.Float64("mining-threshold", -0.5, "minimum margin of the mined negatives")
+)
+
+// rng is the source of all the randomness, so that the training is
+// reproducible
+var rng *rand.Rand
+
+type sample struct {
+	img image.Image
+	box image.Rectangle
+}
+
+// scene is a picture with the boxes of all of its faces
+type scene struct {
+	img   image.Image
+	faces []image.Rectangle
+}
+
+func main() {
+	flag.Parse()
+	rng = rand.New(rand.NewSource(*seed))
+
+	images := map[string]image.Image{}
+	load := func(name string) image.Image {
+		if img, ok := images[name]; ok {
+			return img
+		}
+		img, err := imageutils.FromFile(filepath.Join(*root, name))
+		if err != nil {
+			log.Fatalf("could not read %q: %v", name, err)
+		}
+		images[name] = img
+		return img
+	}
+
+	var positives, negatives [][]float32
+	var faces []sample
+	var scenes []scene
+
+	for _, name := range annotatedNames() {
+		img := load(name)
+		for _, b := range annotated[name] {
+			faces = append(faces, sample{img: img, box: b})
+		}
+		scenes = append(scenes, scene{img: img, faces: annotated[name]})
+	}
+
+	for _, pattern := range backgrounds {
+		matches, err := filepath.Glob(filepath.Join(*root, pattern))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range matches {
+			rel, _ := filepath.Rel(*root, m)
+			scenes = append(scenes, scene{img: load(rel)})
+		}
+	}
+
+	positives = append(positives, augment(faces)...)
+	negatives = randomNegatives(scenes, *randomNeg)
+	log.Printf("%d annotated positives, %d random negatives", len(positives), len(negatives))
+
+	weights, bias := train(positives, negatives, *c)
+
+	// find the faces of LFW with this first classifier
+	lfw, err := filepath.Glob(filepath.Join(*root, "datasets/lfw/*/*.jpg"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var found int
+	for _, f := range lfw {
+		rel, _ := filepath.Rel(*root, f)
+		img := load(rel)
+		if box, ok := centralFace(img, weights, bias); ok {
+			faces = append(faces, sample{img: img, box: box})
+			found++
+		}
+	}
+	log.Printf("found %d faces in %d LFW pictures", found, len(lfw))
+
+	positives = augment(faces)
+
+	for step := 0; step < *miningSteps; step++ {
+		weights, bias = train(positives, negatives, *c)
+
+		hard := hardNegatives(scenes, weights, bias)
+		log.Printf("mining step %d: %d hard negatives", step, len(hard))
+		if len(hard) == 0 {
+			break
+		}
+		negatives = append(negatives, hard...)
+	}
+
+	weights, bias = train(positives, negatives, *c)
+	log.Printf("trained on %d positives and %d negatives", len(positives), len(negatives))
+
+	if err := write(*out, weights, bias); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// augment returns the features of faces, of their mirror and of slightly
+// moved and resized boxes.
+func augment(faces []sample) [][]float32 {
+	var out [][]float32
+	for _, f := range faces {
+		mirror := flip(f.img, f.box)
+		for _, img := range []image.Image{f.img, mirror} {
+			out = append(out, hog.WindowFeatures(img, f.box))
+			for j := 0; j < *jitters; j++ {
+				out = append(out, hog.WindowFeatures(img, jitter(f.box)))
+			}
+		}
+	}
+	return out
+}
+
+// flip returns a mirror image of img around the vertical axis at the
+// center of box, so that box is still the face.
+func flip(img image.Image, box image.Rectangle) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+
+	axis2 := box.Min.X + box.Max.X - 1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := box.Min.X; x < (box.Min.X+box.Max.X)/2; x++ {
+			x2 := axis2 - x
+			if x < b.Min.X || x2 >= b.Max.X {
+				continue
+			}
+			c1, c2 := out.RGBAAt(x, y), out.RGBAAt(x2, y)
+			out.SetRGBA(x, y, c2)
+			out.SetRGBA(x2, y, c1)
+		}
+	}
+
+	return out
+}
+
+func jitter(box image.Rectangle) image.Rectangle {
+	w, h := float64(box.Dx()), float64(box.Dy())
+	s := 1 + 0.1*(rng.Float64()-0.5)
+	dx := w * 0.06 * (rng.Float64() - 0.5)
+	dy := h * 0.06 * (rng.Float64() - 0.5)
+
+	cx := float64(box.Min.X+box.Max.X)/2 + dx
+	cy := float64(box.Min.Y+box.Max.Y)/2 + dy
+
+	return image.Rect(
+		int(cx-w*s/2), int(cy-h*s/2),
+		int(cx+w*s/2), int(cy+h*s/2))
+}
+
+func randomNegatives(scenes []scene, n int) [][]float32 {
+	var out [][]float32
+	for len(out) < n {
+		sc := scenes[rng.Intn(len(scenes))]
+		b := sc.img.Bounds()
+
+		maxW := b.Dx()
+		if maxH := b.Dy() * hog.WindowWidth / hog.WindowHeight; maxH < maxW {
+			maxW = maxH
+		}
+		if maxW < hog.WindowWidth {
+			continue
+		}
+		w := hog.WindowWidth + rng.Intn(maxW-hog.WindowWidth+1)
+		h := w * hog.WindowHeight / hog.WindowWidth
+		x := b.Min.X + rng.Intn(b.Dx()-w+1)
+		y := b.Min.Y + rng.Intn(b.Dy()-h+1)
+		box := image.Rect(x, y, x+w, y+h)
+
+		if overlapsAny(box, sc.faces) {
+			continue
+		}
+
+		out = append(out, hog.WindowFeatures(sc.img, box))
+	}
+	return out
+}
+
+// centralFace returns the best detection around the center of the LFW
+// picture img. LFW pictures are centered on a face of about 110 pixels.
+func centralFace(img image.Image, weights []float32, bias float32) (image.Rectangle, bool) {
+	d := detector(weights, bias)
+	d.Threshold = -5
+
+	detections, err := d.Detect(img)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	b := img.Bounds()
+	center := image.Pt((b.Min.X+b.Max.X)/2, (b.Min.Y+b.Max.Y)/2)
+	for _, det := range detections {
+		c := image.Pt((det.Box.Min.X+det.Box.Max.X)/2, (det.Box.Min.Y+det.Box.Max.Y)/2)
+		if abs(c.X-center.X) > 20 || abs(c.Y-center.Y) > 25 {
+			continue
+		}
+		if det.Box.Dx() < 80 || det.Box.Dx() > 150 {
+			continue
+		}
+		return det.Box, true
+	}
+
+	return image.Rectangle{}, false
+}
+
+// hardNegatives returns the windows detected as faces by the classifier
+// away from the faces of the scenes
+func hardNegatives(scenes []scene, weights []float32, bias float32) [][]float32 {
+	d := detector(weights, bias)
+	d.Threshold = float32(*miningThreshold)
+
+	var out [][]float32
+	for _, sc := range scenes {
+		detections, err := d.Detect(sc.img)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, det := range detections {
+			if overlapsAny(det.Box, sc.faces) {
+				continue
+			}
+			out = append(out, hog.WindowFeatures(sc.img, det.Box))
+		}
+	}
+
+	return out
+}
+
+// annotatedNames returns the keys of annotated in a stable order
+func annotatedNames() []string {
+	var names []string
+	for name := range annotated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func detector(weights []float32, bias float32) *hog.Detector {
+	d := hog.NewDetector()
+	d.Weights = weights
+	d.Bias = bias
+	return d
+}
+
+// overlapsAny tells if box is about the same as one of faces. Parts of faces
+// are not and make good negatives.
+func overlapsAny(box image.Rectangle, faces []image.Rectangle) bool {
+	area := func(r image.Rectangle) float64 { return float64(r.Dx()) * float64(r.Dy()) }
+	for _, f := range faces {
+		inter := area(box.Intersect(f))
+		if inter/(area(box)+area(f)-inter) > 0.3 {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// train fits a linear SVM with the dual coordinate descent method, the bias
+// being learnt as the weight of a constant feature.
+func train(positives, negatives [][]float32, c float64) ([]float32, float32) {
+	type labelled struct {
+		x []float32
+		y float64
+	}
+	var samples []labelled
+	for _, p := range positives {
+		samples = append(samples, labelled{x: p, y: 1})
+	}
+	for _, n := range negatives {
+		samples = append(samples, labelled{x: n, y: -1})
+	}
+
+	// positives are rarer, their errors cost more
+	cPos := c * float64(len(negatives)) / float64(len(positives))
+
+	w := make([]float64, hog.FeaturesLength+1)
+	alpha := make([]float64, len(samples))
+	qii := make([]float64, len(samples))
+	for i, s := range samples {
+		qii[i] = 1
+		for _, v := range s.x {
+			qii[i] += float64(v) * float64(v)
+		}
+	}
+
+	for epoch := 0; epoch < 50; epoch++ {
+		maxChange := 0.0
+		for _, i := range rng.Perm(len(samples)) {
+			s := samples[i]
+			upper := c
+			if s.y > 0 {
+				upper = cPos
+			}
+
+			dot := w[hog.FeaturesLength]
+			for k, v := range s.x {
+				dot += w[k] * float64(v)
+			}
+			g := s.y*dot - 1
+
+			a := math.Min(math.Max(alpha[i]-g/qii[i], 0), upper)
+			delta := (a - alpha[i]) * s.y
+			if delta == 0 {
+				continue
+			}
+			alpha[i] = a
+
+			for k, v := range s.x {
+				w[k] += delta * float64(v)
+			}
+			w[hog.FeaturesLength] += delta
+			maxChange = math.Max(maxChange, math.Abs(delta))
+		}
+		if maxChange < 1e-4 {
+			break
+		}
+	}
+
+	weights := make([]float32, hog.FeaturesLength)
+	for k := range weights {
+		weights[k] = float32(w[k])
+	}
+	return weights, float32(w[hog.FeaturesLength])
+}
+
+func write(filename string, weights []float32, bias float32) error {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by hogtrain; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package hog")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "const defaultBias = %g\n\n", bias)
+	fmt.Fprintln(&buf, "var defaultWeights = []float32{")
+	for i, v := range weights {
+		fmt.Fprintf(&buf, "%.6g,", v)
+		if i%8 == 7 {
+			fmt.Fprintln(&buf)
+		} else {
+			fmt.Fprint(&buf, " ")
+		}
+	}
+	fmt.Fprintln(&buf, "}")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, src, 0644)
+}
@@ -1,3 +1,6 @@
+//go:build tensorflow
+// +build tensorflow
+
 package datasets
 
 import (
@@ -0,0 +1,252 @@
+package gildasai
+
+import (
+	"image"
+	"image/draw"
+	"sort"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+)
+
+// TiledDetector runs Detector on overlapping tiles of the image, at one or
+// more scales, and merges the detections. It finds the small faces of
+// large photos without feeding the whole image to the model at once.
+type TiledDetector struct {
+	Detector Detector
+	// TileSize is the size, in pixels of the scaled image, of the square
+	// tiles. 0 means the image is not tiled. When tiled, the image is
+	// also detected on whole, downscaled to fit in a tile, for the large
+	// faces cut by the tiles.
+	TileSize int
+	// Overlap is the number of pixels shared by neighbour tiles. Faces
+	// smaller than Overlap are entirely inside of at least one tile, the
+	// ones cut by the border of a tile are dropped.
+	Overlap int
+	// Scales are the factors the image is resized by before being tiled.
+	// Empty means the original size only.
+	Scales []float64
+	// MergeIoU is the intersection over union above which two detections
+	// are the same face
+	MergeIoU float64
+}
+
+// NewTiledDetector returns a detector running d on tiles of tileSize
+// pixels overlapping by a quarter, at each of scales.
+func NewTiledDetector(d Detector, tileSize int, scales ...float64) *TiledDetector {
+	return &TiledDetector{
+		Detector: d,
+		TileSize: tileSize,
+		Overlap:  tileSize / 4,
+		Scales:   scales,
+		MergeIoU: 0.4,
+	}
+}
+
+// tilePass is the image scaled by scale and its tiles
+type tilePass struct {
+	scale  float64
+	bounds image.Rectangle
+	tiles  []image.Rectangle
+}
+
+func (t *TiledDetector) Detect(img image.Image) ([]Detection, error) {
+	scales := t.Scales
+	if len(scales) == 0 {
+		scales = []float64{1}
+	}
+
+	bounds := img.Bounds()
+
+	var passes []tilePass
+	var cropped []image.Image
+	add := func(s float64, size, overlap int) {
+		scaled := img
+		if s != 1 {
+			scaled = imaging.Resize(img,
+				int(float64(bounds.Dx())*s+0.5), int(float64(bounds.Dy())*s+0.5),
+				imaging.Linear)
+		}
+		sb := scaled.Bounds()
+		if sb.Empty() {
+			return
+		}
+
+		pass := tilePass{scale: s, bounds: sb, tiles: tiles(sb, size, overlap)}
+		for _, tile := range pass.tiles {
+			// the models expect images starting at (0, 0)
+			c := image.NewRGBA(image.Rect(0, 0, tile.Dx(), tile.Dy()))
+			draw.Draw(c, c.Bounds(), scaled, tile.Min, draw.Src)
+			cropped = append(cropped, c)
+		}
+		passes = append(passes, pass)
+	}
+
+	tiled := false
+	for _, s := range scales {
+		if s <= 0 {
+			return nil, errors.Errorf("invalid scale %f", s)
+		}
+		add(s, t.TileSize, t.Overlap)
+		if len(passes) > 0 && len(passes[len(passes)-1].tiles) > 1 {
+			tiled = true
+		}
+	}
+
+	// the faces larger than Overlap may be cut by all the tiles
+	if tiled {
+		s := float64(t.TileSize) / float64(bounds.Dx())
+		if h := float64(t.TileSize) / float64(bounds.Dy()); h < s {
+			s = h
+		}
+		if s > 1 {
+			s = 1
+		}
+		add(s, 0, 0)
+	}
+
+	tileDetections, err := detectAll(t.Detector, cropped)
+	if err != nil {
+		return nil, errors.Wrap(err, "error detecting faces on tiles")
+	}
+
+	var all []Detection
+	i := 0
+	for _, pass := range passes {
+		sb := pass.bounds
+		sx := float64(bounds.Dx()) / float64(sb.Dx())
+		sy := float64(bounds.Dy()) / float64(sb.Dy())
+
+		for _, tile := range pass.tiles {
+			for _, d := range tileDetections[i] {
+				box := d.Box.Add(tile.Min)
+				if t.Overlap > 0 && cutByTile(box, tile, sb) {
+					continue
+				}
+
+				d.Box = image.Rect(
+					int(float64(box.Min.X-sb.Min.X)*sx+0.5),
+					int(float64(box.Min.Y-sb.Min.Y)*sy+0.5),
+					int(float64(box.Max.X-sb.Min.X)*sx+0.5),
+					int(float64(box.Max.Y-sb.Min.Y)*sy+0.5)).Add(bounds.Min)
+				all = append(all, d)
+			}
+			i++
+		}
+	}
+
+	return MergeDetections(all, t.MergeIoU), nil
+}
+
+// detectAll runs d on each of imgs, at once if d is a BatchDetector
+func detectAll(d Detector, imgs []image.Image) ([][]Detection, error) {
+	if b, ok := d.(BatchDetector); ok {
+		detections, err := b.DetectBatch(imgs)
+		if err != nil {
+			return nil, err
+		}
+		if len(detections) != len(imgs) {
+			return nil, errors.Errorf("got %d results for %d images", len(detections), len(imgs))
+		}
+		return detections, nil
+	}
+
+	var detections [][]Detection
+	for i, img := range imgs {
+		ds, err := d.Detect(img)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error on image %d", i)
+		}
+		detections = append(detections, ds)
+	}
+
+	return detections, nil
+}
+
+// tiles returns the overlapping squares of size covering bounds, the last
+// ones of each row and column being aligned on the border of bounds.
+func tiles(bounds image.Rectangle, size, overlap int) []image.Rectangle {
+	if size <= 0 {
+		return []image.Rectangle{bounds}
+	}
+
+	step := size - overlap
+	if step < 1 {
+		step = 1
+	}
+
+	starts := func(min, max int) []int {
+		if max-min <= size {
+			return []int{min}
+		}
+		var out []int
+		for s := min; s+size < max; s += step {
+			out = append(out, s)
+		}
+		return append(out, max-size)
+	}
+
+	var out []image.Rectangle
+	for _, y := range starts(bounds.Min.Y, bounds.Max.Y) {
+		for _, x := range starts(bounds.Min.X, bounds.Max.X) {
+			out = append(out, image.Rect(x, y, x+size, y+size).Intersect(bounds))
+		}
+	}
+
+	return out
+}
+
+// cutByTile tells if box touches a border of tile that is not a border of
+// the whole image.
+func cutByTile(box, tile, bounds image.Rectangle) bool {
+	const margin = 2
+
+	return (tile.Min.X > bounds.Min.X && box.Min.X <= tile.Min.X+margin) ||
+		(tile.Min.Y > bounds.Min.Y && box.Min.Y <= tile.Min.Y+margin) ||
+		(tile.Max.X < bounds.Max.X && box.Max.X >= tile.Max.X-margin) ||
+		(tile.Max.Y < bounds.Max.Y && box.Max.Y >= tile.Max.Y-margin)
+}
+
+// MergeDetections keeps the best of the detections whose boxes overlap
+// with an intersection over union above iou, or whose box is mostly
+// inside of the box of a better one. The result is sorted by decreasing
+// score.
+func MergeDetections(detections []Detection, iou float64) []Detection {
+	sorted := make([]Detection, len(detections))
+	copy(sorted, detections)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Score > sorted[j].Score
+	})
+
+	var kept []Detection
+detections:
+	for _, d := range sorted {
+		for _, k := range kept {
+			if IntersectionOverUnion(d.Box, k.Box) > iou || mostlyInside(d.Box, k.Box) {
+				continue detections
+			}
+		}
+		kept = append(kept, d)
+	}
+
+	return kept
+}
+
+func boxArea(r image.Rectangle) float64 {
+	return float64(r.Dx()) * float64(r.Dy())
+}
+
+// IntersectionOverUnion returns the area of the intersection of r1 and r2
+// divided by the area of their union
+func IntersectionOverUnion(r1, r2 image.Rectangle) float64 {
+	inter := boxArea(r1.Intersect(r2))
+	if inter == 0 {
+		return 0
+	}
+	return inter / (boxArea(r1) + boxArea(r2) - inter)
+}
+
+// mostlyInside tells if most of inner is inside of outer
+func mostlyInside(inner, outer image.Rectangle) bool {
+	return boxArea(outer.Intersect(inner)) > 0.7*boxArea(inner)
+}
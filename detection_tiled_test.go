@@ -0,0 +1,176 @@
+package gildasai
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// squaresDetector detects the red squares of an image
+type squaresDetector struct {
+	seen []image.Rectangle
+}
+
+func (s *squaresDetector) Detect(img image.Image) ([]Detection, error) {
+	s.seen = append(s.seen, img.Bounds())
+
+	red := func(x, y int) bool {
+		if !(image.Point{x, y}).In(img.Bounds()) {
+			return false
+		}
+		r, g, _, _ := img.At(x, y).RGBA()
+		return r > 0x8000 && g < 0x8000
+	}
+
+	var detections []Detection
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !red(x, y) || red(x-1, y) || red(x, y-1) {
+				continue
+			}
+			maxX, maxY := x, y
+			for red(maxX, y) {
+				maxX++
+			}
+			for red(x, maxY) {
+				maxY++
+			}
+			detections = append(detections, Detection{
+				Box:   image.Rect(x, y, maxX, maxY),
+				Score: 0.9,
+				Class: 1,
+			})
+		}
+	}
+
+	return detections, nil
+}
+
+func imageWithSquares(bounds image.Rectangle, squares ...image.Rectangle) image.Image {
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.NewUniform(color.White), image.ZP, draw.Src)
+	for _, s := range squares {
+		draw.Draw(img, s, image.NewUniform(color.RGBA{R: 255, A: 255}), image.ZP, draw.Src)
+	}
+	return img
+}
+
+func TestTiledDetector(t *testing.T) {
+	squares := []image.Rectangle{
+		image.Rect(100, 100, 140, 140),
+		// on the border of the first tiles
+		image.Rect(380, 200, 420, 240),
+		image.Rect(700, 500, 760, 560),
+	}
+	img := imageWithSquares(image.Rect(0, 0, 1000, 800), squares...)
+
+	fake := &squaresDetector{}
+	d := &TiledDetector{Detector: fake, TileSize: 400, Overlap: 100, MergeIoU: 0.4}
+
+	detections, err := d.Detect(img)
+	require.NoError(t, err)
+
+	var boxes []image.Rectangle
+	for _, d := range detections {
+		boxes = append(boxes, d.Box)
+	}
+	assert.ElementsMatch(t, squares, boxes)
+
+	for _, seen := range fake.seen {
+		assert.Equal(t, image.Point{}, seen.Min)
+		assert.True(t, seen.Dx() <= 400 && seen.Dy() <= 400)
+	}
+}
+
+func TestTiledDetectorLargeFace(t *testing.T) {
+	// larger than the overlap and cut by all the tiles
+	square := image.Rect(250, 200, 550, 500)
+	img := imageWithSquares(image.Rect(0, 0, 1000, 800), square)
+
+	fake := &squaresDetector{}
+	d := &TiledDetector{Detector: fake, TileSize: 400, Overlap: 100, MergeIoU: 0.4}
+
+	detections, err := d.Detect(img)
+	require.NoError(t, err)
+	require.Len(t, detections, 1, "the face is found on the downscaled image")
+	assert.InDelta(t, square.Min.X, detections[0].Box.Min.X, 3)
+	assert.InDelta(t, square.Min.Y, detections[0].Box.Min.Y, 3)
+	assert.InDelta(t, square.Max.X, detections[0].Box.Max.X, 3)
+	assert.InDelta(t, square.Max.Y, detections[0].Box.Max.Y, 3)
+
+	assert.Contains(t, fake.seen, image.Rect(0, 0, 400, 320))
+}
+
+func TestTiledDetectorScales(t *testing.T) {
+	square := image.Rect(210, 160, 290, 240)
+	img := imageWithSquares(image.Rect(50, 50, 650, 450), square)
+
+	fake := &squaresDetector{}
+	d := NewTiledDetector(fake, 0, 0.5)
+
+	detections, err := d.Detect(img)
+	require.NoError(t, err)
+	require.Len(t, detections, 1)
+
+	assert.Equal(t, []image.Rectangle{image.Rect(0, 0, 300, 200)}, fake.seen)
+	assert.InDelta(t, square.Min.X, detections[0].Box.Min.X, 2)
+	assert.InDelta(t, square.Min.Y, detections[0].Box.Min.Y, 2)
+	assert.InDelta(t, square.Max.X, detections[0].Box.Max.X, 2)
+	assert.InDelta(t, square.Max.Y, detections[0].Box.Max.Y, 2)
+}
+
+func TestTiles(t *testing.T) {
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(10, 0, 110, 80),
+		image.Rect(60, 0, 160, 80),
+		image.Rect(90, 0, 190, 80),
+	}, tiles(image.Rect(10, 0, 190, 80), 100, 50))
+
+	assert.Equal(t, []image.Rectangle{image.Rect(0, 0, 50, 50)},
+		tiles(image.Rect(0, 0, 50, 50), 0, 0))
+}
+
+func TestMergeDetections(t *testing.T) {
+	merged := MergeDetections([]Detection{
+		{Box: image.Rect(0, 0, 100, 100), Score: 0.7},
+		{Box: image.Rect(5, 5, 105, 105), Score: 0.9},
+		// mostly inside of a better detection
+		{Box: image.Rect(20, 20, 50, 50), Score: 0.6},
+		{Box: image.Rect(200, 200, 300, 300), Score: 0.8},
+	}, 0.5)
+
+	assert.Equal(t, []Detection{
+		{Box: image.Rect(5, 5, 105, 105), Score: 0.9},
+		{Box: image.Rect(200, 200, 300, 300), Score: 0.8},
+	}, merged)
+}
+
+func TestExtractorMinFaceSize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	detections := []Detection{
+		{Box: image.Rect(0, 0, 30, 30), Score: 0.9},
+		{Box: image.Rect(50, 50, 150, 150), Score: 0.9},
+	}
+
+	e := &Extractor{
+		Detector: &mockDetector{detect: [][]Detection{detections}},
+		Landmark: &mockLandmark{landmarks: []*Landmarks{{}}},
+	}
+	found, _, err := e.ExtractLandmarks(img)
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	e = &Extractor{
+		Detector:    &mockDetector{detect: [][]Detection{detections}},
+		Landmark:    &mockLandmark{landmarks: []*Landmarks{{}, {}}},
+		MinFaceSize: 20,
+	}
+	found, _, err = e.ExtractLandmarks(img)
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+}
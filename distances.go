@@ -0,0 +1,463 @@
+package gildasai
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MaxFaceDistance is the distance above which the distances between
+// faces are not stored
+const MaxFaceDistance = 0.6
+
+// Comparable tells if face is detected and located confidently enough
+// for its distances to the other faces to be stored
+func Comparable(face *FaceItem) bool {
+	return len(face.Descriptors) > 0 &&
+		face.Detection.Score >= 0.9 &&
+		face.Landmarks.Confidence() >= 0.5
+}
+
+// DistanceStore keeps the faces and the distances between them
+type DistanceStore interface {
+	FaceStore
+	FaceDistanceStore
+}
+
+// DistanceJob computes the distances, up to MaxDistance, between the
+// comparable faces of Store and stores them. The descriptors are loaded
+// in a matrix whose blocks of rows are compared to all the faces by
+// parallel workers.
+type DistanceJob struct {
+	Store DistanceStore
+	// MaxDistance is the distance above which the distances are not
+	// stored, usually MaxFaceDistance
+	MaxDistance float32
+	// K, when positive, only keeps the distances from each face to its K
+	// nearest neighbours
+	K int
+	// Workers is the number of CPUs when 0
+	Workers int
+	// BlockSize is the number of faces compared to as many others at a
+	// time, 256 when 0
+	BlockSize int
+	// Incremental only compares the faces which were not compared yet,
+	// to all the faces
+	Incremental bool
+	// Progress, when set, is called with the number of faces compared
+	// after each block
+	Progress func(done, total int)
+}
+
+// DistanceReport counts the comparable faces, the ones compared by the
+// job, the distances computed and the ones stored
+type DistanceReport struct {
+	Faces, Compared int
+	Computed        int64
+	Stored          int
+}
+
+// distanceChunk is the number of distances stored per transaction
+const distanceChunk = 10000
+
+// Run computes and stores the distances, those of each block of faces as
+// it is compared. With K, the distances pushed out of the K nearest
+// neighbours of the faces compared before are kept.
+func (j *DistanceJob) Run() (*DistanceReport, error) {
+	faces, err := j.Store.GetAllFaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the faces")
+	}
+
+	compared := map[int64]bool{}
+	if j.Incremental {
+		faceIDs, err := j.Store.ComparedFaces()
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting the compared faces")
+		}
+		for _, faceID := range faceIDs {
+			compared[faceID] = true
+		}
+	}
+
+	report := &DistanceReport{}
+	groups := comparableGroups(faces)
+	var newIDs []int64
+	for _, group := range groups {
+		for _, face := range group {
+			report.Faces++
+			if !compared[face.ID] {
+				newIDs = append(newIDs, face.ID)
+			}
+		}
+	}
+	report.Compared = len(newIDs)
+
+	// the distances already stored bound the nearest neighbours of the
+	// faces compared before
+	var stored map[int64][]float32
+	if j.K > 0 && report.Compared < report.Faces {
+		all, err := j.Store.GetAllFaceDistances()
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting the face distances")
+		}
+		stored = map[int64][]float32{}
+		for _, d := range all {
+			stored[d.Face1.ID] = append(stored[d.Face1.ID], d.Distance)
+			stored[d.Face2.ID] = append(stored[d.Face2.ID], d.Distance)
+		}
+	}
+
+	// with K, a pair may be among the nearest neighbours of both its
+	// faces
+	var seen map[[2]int64]bool
+	if j.K > 0 {
+		seen = map[[2]int64]bool{}
+	}
+
+	var nearest []*FaceDistance
+	done := 0
+	for _, group := range groups {
+		m := newDescriptorMatrix(group, compared)
+		var candidates []pairDistance
+		err := j.compareRows(m, j.limits(m, stored), func(r blockResult) error {
+			report.Computed += r.computed
+			candidates = append(candidates, r.candidates...)
+
+			// without K, the faces of a block are marked as compared
+			// with its distances: those to the new faces of lower rows,
+			// if not stored yet, are computed again with these faces
+			var rowIDs []int64
+			if j.K <= 0 {
+				for _, row := range r.rows {
+					rowIDs = append(rowIDs, m.faces[row].ID)
+				}
+			}
+			distances := faceDistances(m, r.pairs, seen)
+			if err := j.store(distances, rowIDs); err != nil {
+				return err
+			}
+			report.Stored += len(distances)
+
+			done += len(r.rows)
+			if j.Progress != nil {
+				j.Progress(done, report.Compared)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if j.K > 0 {
+			nearest = append(nearest, faceDistances(m, nearestCandidates(m, candidates, stored, j.K), seen)...)
+		}
+	}
+
+	// with K, the faces are marked as compared once the distances
+	// which may be among the nearest neighbours of the faces compared
+	// before are stored
+	if j.K > 0 {
+		if err := j.store(nearest, newIDs); err != nil {
+			return nil, err
+		}
+		report.Stored += len(nearest)
+	}
+
+	return report, nil
+}
+
+// faceDistances returns the distances of pairs, skipping and adding to
+// seen the pairs it has, when not nil
+func faceDistances(m *descriptorMatrix, pairs []pairDistance, seen map[[2]int64]bool) []*FaceDistance {
+	var distances []*FaceDistance
+	for _, p := range pairs {
+		face1, face2 := m.faces[p.row], m.faces[p.column]
+		if face2.ID < face1.ID {
+			face1, face2 = face2, face1
+		}
+		if seen != nil {
+			key := [2]int64{face1.ID, face2.ID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		distances = append(distances, &FaceDistance{Face1: face1, Face2: face2, Distance: p.distance})
+	}
+	return distances
+}
+
+// store stores distances by chunks, marking the faces of compared with
+// the last one
+func (j *DistanceJob) store(distances []*FaceDistance, compared []int64) error {
+	if len(distances) == 0 && len(compared) == 0 {
+		return nil
+	}
+
+	for len(distances) > distanceChunk {
+		if err := j.Store.StoreFaceDistances(distances[:distanceChunk], nil); err != nil {
+			return errors.Wrap(err, "error storing the face distances")
+		}
+		distances = distances[distanceChunk:]
+	}
+
+	if err := j.Store.StoreFaceDistances(distances, compared); err != nil {
+		return errors.Wrap(err, "error storing the face distances")
+	}
+	return nil
+}
+
+// comparableGroups returns the comparable faces, grouped by dimension of
+// their descriptors as the others cannot be compared
+func comparableGroups(faces []*FaceItem) [][]*FaceItem {
+	byDim := map[int][]*FaceItem{}
+	var dims []int
+	for _, face := range faces {
+		if !Comparable(face) {
+			continue
+		}
+
+		dim := len(face.Descriptors)
+		if _, ok := byDim[dim]; !ok {
+			dims = append(dims, dim)
+		}
+		byDim[dim] = append(byDim[dim], face)
+	}
+	sort.Ints(dims)
+
+	var groups [][]*FaceItem
+	for _, dim := range dims {
+		groups = append(groups, byDim[dim])
+	}
+	return groups
+}
+
+// descriptorMatrix holds the descriptors of faces, of the same
+// dimension, one after the other
+type descriptorMatrix struct {
+	faces  []*FaceItem
+	dim    int
+	values []float32
+	// rows are the indices of the faces to compare, those not in
+	// compared
+	rows  []int
+	isNew []bool
+}
+
+func newDescriptorMatrix(faces []*FaceItem, compared map[int64]bool) *descriptorMatrix {
+	dim := len(faces[0].Descriptors)
+	m := &descriptorMatrix{
+		faces:  faces,
+		dim:    dim,
+		values: make([]float32, 0, len(faces)*dim),
+		isNew:  make([]bool, len(faces)),
+	}
+	for i, face := range faces {
+		m.values = append(m.values, face.Descriptors...)
+		if !compared[face.ID] {
+			m.rows = append(m.rows, i)
+			m.isNew[i] = true
+		}
+	}
+	return m
+}
+
+// distance is the distance between the faces i and k, computed as
+// Descriptors.DistanceTo does
+func (m *descriptorMatrix) distance(i, k int) float32 {
+	d1 := m.values[i*m.dim : (i+1)*m.dim]
+	d2 := m.values[k*m.dim : (k+1)*m.dim]
+
+	sum := float32(0)
+	for n := range d1 {
+		sum += (d1[n] - d2[n]) * (d1[n] - d2[n])
+	}
+
+	return float32(math.Sqrt(float64(sum)))
+}
+
+// pairDistance is the distance between the faces of indices row and
+// column of a matrix
+type pairDistance struct {
+	row, column int
+	distance    float32
+}
+
+// blockResult holds the distances of a block of rows to keep and, with
+// K, the ones which may be among the nearest neighbours of the faces
+// compared before
+type blockResult struct {
+	rows       []int
+	pairs      []pairDistance
+	candidates []pairDistance
+	computed   int64
+}
+
+// limits returns, with K, the distances under which the faces compared
+// before get a new nearest neighbour
+func (j *DistanceJob) limits(m *descriptorMatrix, stored map[int64][]float32) []float32 {
+	if j.K <= 0 {
+		return nil
+	}
+
+	limits := make([]float32, len(m.faces))
+	for i, face := range m.faces {
+		limits[i] = math.MaxFloat32
+		if s := stored[face.ID]; !m.isNew[i] && len(s) >= j.K {
+			limits[i] = s[j.K-1]
+		}
+	}
+	return limits
+}
+
+// compareRows compares the rows of m to all its faces with the workers
+// and calls handle with the result of each block, as they are compared.
+// Without K, a block holds the distances up to MaxDistance. With K, it
+// holds the distances from each row to its K nearest neighbours, by row
+// and increasing distance, and the candidates to the nearest neighbours
+// of the other faces, those under their limit. The comparison stops on
+// the first error of handle, which is returned.
+func (j *DistanceJob) compareRows(m *descriptorMatrix, limits []float32, handle func(blockResult) error) error {
+	size := j.BlockSize
+	if size <= 0 {
+		size = 256
+	}
+	workers := j.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	blocks := make(chan []int)
+	results := make(chan blockResult)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rows := range blocks {
+				results <- j.compareBlock(m, rows, limits, size)
+			}
+		}()
+	}
+	go func() {
+		defer func() {
+			close(blocks)
+			wg.Wait()
+			close(results)
+		}()
+		for start := 0; start < len(m.rows); start += size {
+			end := start + size
+			if end > len(m.rows) {
+				end = len(m.rows)
+			}
+			select {
+			case blocks <- m.rows[start:end]:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var err error
+	for r := range results {
+		if err != nil {
+			continue
+		}
+		if err = handle(r); err != nil {
+			close(stop)
+		}
+	}
+
+	return err
+}
+
+// compareBlock compares rows to all the faces of m, by blocks of size
+// columns
+func (j *DistanceJob) compareBlock(m *descriptorMatrix, rows []int, limits []float32, size int) blockResult {
+	r := blockResult{rows: rows}
+	nearest := make([][]pairDistance, len(rows))
+	for start := 0; start < len(m.faces); start += size {
+		end := start + size
+		if end > len(m.faces) {
+			end = len(m.faces)
+		}
+
+		for i, row := range rows {
+			for column := start; column < end; column++ {
+				if column == row {
+					continue
+				}
+				// without K, the distance between two new faces is
+				// computed once, from the lower row
+				if j.K <= 0 && m.isNew[column] && column < row {
+					continue
+				}
+
+				p := pairDistance{row, column, m.distance(row, column)}
+				r.computed++
+				if p.distance > j.MaxDistance {
+					continue
+				}
+
+				if j.K <= 0 {
+					r.pairs = append(r.pairs, p)
+					continue
+				}
+				nearest[i] = insertNearest(nearest[i], p, j.K)
+				if !m.isNew[column] && p.distance < limits[column] {
+					r.candidates = append(r.candidates, p)
+				}
+			}
+		}
+	}
+
+	for _, n := range nearest {
+		r.pairs = append(r.pairs, n...)
+	}
+	return r
+}
+
+// insertNearest inserts p in nearest, sorted by increasing distance, if
+// it is one of the k nearest
+func insertNearest(nearest []pairDistance, p pairDistance, k int) []pairDistance {
+	if len(nearest) == k && p.distance >= nearest[k-1].distance {
+		return nearest
+	}
+
+	i := sort.Search(len(nearest), func(i int) bool {
+		return nearest[i].distance > p.distance
+	})
+	if len(nearest) < k {
+		nearest = append(nearest, pairDistance{})
+	}
+	copy(nearest[i+1:], nearest[i:len(nearest)-1])
+	nearest[i] = p
+	return nearest
+}
+
+// nearestCandidates returns the candidates among the k nearest
+// neighbours of the faces compared before
+func nearestCandidates(m *descriptorMatrix, candidates []pairDistance, stored map[int64][]float32, k int) []pairDistance {
+	byColumn := map[int][]pairDistance{}
+	for _, c := range candidates {
+		byColumn[c.column] = append(byColumn[c.column], c)
+	}
+
+	var nearest []pairDistance
+	for column, cs := range byColumn {
+		sort.Slice(cs, func(a, b int) bool { return cs[a].distance < cs[b].distance })
+		s := stored[m.faces[column].ID]
+		for n, c := range cs {
+			closer := sort.Search(len(s), func(i int) bool { return s[i] >= c.distance })
+			if closer+n < k {
+				nearest = append(nearest, c)
+			}
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,225 @@
+package gildasai_test
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeRandomFaces stores n comparable faces with random descriptors of
+// dimension 16
+func storeRandomFaces(t *testing.T, store *memory.Store, r *rand.Rand, n int) {
+	for i := 0; i < n; i++ {
+		d := make(gildasai.Descriptors, 16)
+		for k := range d {
+			d[k] = 0.3 * r.Float32()
+		}
+		require.NoError(t, store.StoreFace(&gildasai.FaceItem{
+			Identifier:  fmt.Sprintf("photo%d.jpg", r.Int63()),
+			Network:     "fake",
+			Detection:   gildasai.Detection{Box: image.Rect(0, 0, 100, 100), Score: 0.95},
+			Landmarks:   gildasaitest.FaceLandmarks,
+			Descriptors: d,
+		}))
+	}
+}
+
+type facePair [2]int64
+
+func pairOf(face1, face2 *gildasai.FaceItem) facePair {
+	if face2.ID < face1.ID {
+		face1, face2 = face2, face1
+	}
+	return facePair{face1.ID, face2.ID}
+}
+
+// bruteForceDistances returns the distances up to MaxFaceDistance
+// between the comparable faces of store
+func bruteForceDistances(t *testing.T, store *memory.Store) map[facePair]float32 {
+	faces, err := store.GetAllFaces()
+	require.NoError(t, err)
+
+	distances := map[facePair]float32{}
+	for i, face1 := range faces {
+		for _, face2 := range faces[i+1:] {
+			if !gildasai.Comparable(face1) || !gildasai.Comparable(face2) {
+				continue
+			}
+			d, err := face1.Descriptors.DistanceTo(face2.Descriptors)
+			require.NoError(t, err)
+			if d <= gildasai.MaxFaceDistance {
+				distances[pairOf(face1, face2)] = d
+			}
+		}
+	}
+	return distances
+}
+
+func storedDistances(t *testing.T, store *memory.Store) map[facePair]float32 {
+	all, err := store.GetAllFaceDistances()
+	require.NoError(t, err)
+
+	distances := map[facePair]float32{}
+	for _, d := range all {
+		assert.True(t, d.Face1.ID < d.Face2.ID, "the face of lower ID is first")
+		distances[pairOf(d.Face1, d.Face2)] = d.Distance
+	}
+	return distances
+}
+
+func assertDistances(t *testing.T, expected, actual map[facePair]float32) {
+	require.Len(t, actual, len(expected))
+	for pair, d := range expected {
+		assert.InDelta(t, d, actual[pair], 1e-6, "%v", pair)
+	}
+}
+
+func TestDistanceJob(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	store := memory.NewStore()
+	storeRandomFaces(t, store, r, 60)
+	require.NoError(t, store.StoreFace(&gildasai.FaceItem{
+		Identifier:  "blurry.jpg",
+		Network:     "fake",
+		Detection:   gildasai.Detection{Score: 0.5},
+		Landmarks:   gildasaitest.FaceLandmarks,
+		Descriptors: make(gildasai.Descriptors, 16),
+	}))
+
+	job := &gildasai.DistanceJob{Store: store, MaxDistance: gildasai.MaxFaceDistance, Workers: 3, BlockSize: 7, Incremental: true}
+	report, err := job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, 60, report.Faces)
+	assert.Equal(t, 60, report.Compared)
+	assert.Equal(t, int64(60*59/2), report.Computed)
+
+	expected := bruteForceDistances(t, store)
+	require.NotEmpty(t, expected)
+	assert.Equal(t, len(expected), report.Stored)
+	assertDistances(t, expected, storedDistances(t, store))
+
+	storeRandomFaces(t, store, r, 10)
+	report, err = job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, 70, report.Faces)
+	assert.Equal(t, 10, report.Compared)
+	assert.Equal(t, int64(10*60+10*9/2), report.Computed, "only the pairs with new faces are computed")
+	assertDistances(t, bruteForceDistances(t, store), storedDistances(t, store))
+
+	compared, err := store.ComparedFaces()
+	require.NoError(t, err)
+	assert.Len(t, compared, 70)
+
+	report, err = job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, &gildasai.DistanceReport{Faces: 70}, report)
+}
+
+// failingDistanceStore fails to store the distances after stored calls
+type failingDistanceStore struct {
+	*memory.Store
+	stored int
+}
+
+func (s *failingDistanceStore) StoreFaceDistances(distances []*gildasai.FaceDistance, compared []int64) error {
+	if s.stored == 0 {
+		return errors.New("disk full")
+	}
+	s.stored--
+	return s.Store.StoreFaceDistances(distances, compared)
+}
+
+func TestDistanceJobStoresBlocks(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	store := memory.NewStore()
+	storeRandomFaces(t, store, r, 40)
+
+	job := &gildasai.DistanceJob{
+		Store:       &failingDistanceStore{Store: store, stored: 2},
+		MaxDistance: gildasai.MaxFaceDistance,
+		Workers:     1,
+		BlockSize:   8,
+		Incremental: true,
+	}
+	_, err := job.Run()
+	require.Error(t, err)
+
+	compared, err := store.ComparedFaces()
+	require.NoError(t, err)
+	assert.Len(t, compared, 16, "the faces of the blocks stored are compared")
+
+	job.Store = store
+	report, err := job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, 24, report.Compared)
+	assertDistances(t, bruteForceDistances(t, store), storedDistances(t, store))
+
+	job = &gildasai.DistanceJob{Store: memory.NewStore(), MaxDistance: 0}
+	storeRandomFaces(t, job.Store.(*memory.Store), r, 10)
+	report, err = job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, int64(10*9/2), report.Computed)
+	assert.Equal(t, 0, report.Stored, "only the identical faces are within a MaxDistance of 0")
+}
+
+// assertNearest asserts that the distances from each face to its k
+// nearest neighbours are stored, and that the distances stored after
+// the faces of before are among the k nearest neighbours of one of
+// their faces
+func assertNearest(t *testing.T, store *memory.Store, k int, before map[facePair]float32) {
+	all := bruteForceDistances(t, store)
+	neighbours := map[int64][]float32{}
+	for pair, d := range all {
+		neighbours[pair[0]] = append(neighbours[pair[0]], d)
+		neighbours[pair[1]] = append(neighbours[pair[1]], d)
+	}
+	// nearest tells if d is among the k nearest distances of faceID
+	nearest := func(faceID int64, d float32) bool {
+		closer := 0
+		for _, other := range neighbours[faceID] {
+			if other < d {
+				closer++
+			}
+		}
+		return closer < k
+	}
+
+	stored := storedDistances(t, store)
+	for pair, d := range all {
+		if nearest(pair[0], d) || nearest(pair[1], d) {
+			assert.Contains(t, stored, pair)
+		}
+	}
+	for pair, d := range stored {
+		if _, ok := before[pair]; ok {
+			continue
+		}
+		assert.True(t, nearest(pair[0], d) || nearest(pair[1], d), "%v", pair)
+	}
+}
+
+func TestDistanceJobNearest(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	store := memory.NewStore()
+	storeRandomFaces(t, store, r, 50)
+
+	job := &gildasai.DistanceJob{Store: store, MaxDistance: gildasai.MaxFaceDistance, K: 3, BlockSize: 8, Incremental: true}
+	report, err := job.Run()
+	require.NoError(t, err)
+	assert.True(t, report.Stored <= 50*3)
+	assertNearest(t, store, 3, nil)
+
+	before := storedDistances(t, store)
+	storeRandomFaces(t, store, r, 20)
+	_, err = job.Run()
+	require.NoError(t, err)
+	assertNearest(t, store, 3, before)
+}
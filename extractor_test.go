@@ -0,0 +1,167 @@
+package gildasai_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func twoFaces() []gildasai.Detection {
+	return []gildasai.Detection{
+		{Box: image.Rect(10, 10, 110, 130), Score: 0.9},
+		{Box: image.Rect(200, 20, 300, 140), Score: 0.8},
+		{Box: image.Rect(300, 20, 400, 140), Score: 0.3},
+	}
+}
+
+func TestExtractorWithFakes(t *testing.T) {
+	detector := &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}}
+	landmark := &gildasaitest.Landmark{}
+	descriptor := &gildasaitest.Descriptor{}
+	e := &gildasai.Extractor{
+		Network:    "fake",
+		Detector:   detector,
+		Landmark:   landmark,
+		Descriptor: descriptor,
+	}
+
+	items, err := e.ExtractItems(image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	assert.Equal(t, 1, detector.CallCount("Detect"))
+	assert.Equal(t, 2, descriptor.CallCount("Compute"))
+
+	calls := landmark.Calls("Detect")
+	require.Len(t, calls, 2)
+	assert.Equal(t, image.Rect(10, 10, 110, 130), calls[0].Args[0].(image.Image).Bounds())
+	assert.Equal(t, image.Rect(200, 20, 300, 140), calls[1].Args[0].(image.Image).Bounds())
+
+	for i, item := range items {
+		assert.Equal(t, "fake", item.Network)
+		assert.Equal(t, twoFaces()[i], item.Detection)
+		assert.Equal(t, gildasaitest.FaceLandmarks, item.Landmarks)
+		assert.Equal(t, float32(i), item.Descriptors[0])
+	}
+}
+
+func TestExtractorErrors(t *testing.T) {
+	failure := errors.New("failure")
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+
+	detector := &gildasaitest.Detector{}
+	detector.Errors = map[string]error{"Detect": failure}
+	e := &gildasai.Extractor{Detector: detector}
+	_, _, err := e.Extract(img)
+	assert.Error(t, err)
+
+	landmark := &gildasaitest.Landmark{}
+	landmark.Errors = map[string]error{"Detect": failure}
+	e = &gildasai.Extractor{
+		Detector: &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}},
+		Landmark: landmark,
+	}
+	_, _, err = e.ExtractLandmarks(img)
+	assert.Error(t, err)
+
+	descriptor := &gildasaitest.Descriptor{}
+	descriptor.Fail = func(method string, n int) error {
+		if n == 1 {
+			return failure
+		}
+		return nil
+	}
+	e = &gildasai.Extractor{
+		Detector:   &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: descriptor,
+	}
+	_, _, err = e.Extract(img)
+	assert.Error(t, err)
+	assert.Equal(t, 2, descriptor.CallCount("Compute"))
+}
+
+func TestExtractorNoFace(t *testing.T) {
+	e := &gildasai.Extractor{
+		Detector: &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()[2:]}},
+	}
+
+	_, _, err := e.Extract(image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	assert.Equal(t, gildasai.ErrNoFaceDetected, err)
+}
+
+func TestExtractFaces(t *testing.T) {
+	descriptor := &gildasaitest.Descriptor{}
+	e := &gildasai.Extractor{
+		Detector:   &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: descriptor,
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+
+	faces, err := e.ExtractFaces(img, false)
+	require.NoError(t, err)
+	require.Len(t, faces, 2)
+	assert.Equal(t, 0, descriptor.CallCount("Compute"))
+
+	landmarks := gildasaitest.FaceLandmarks
+	for i, f := range faces {
+		box := twoFaces()[i].Box
+		assert.Equal(t, twoFaces()[i], f.Detection)
+		assert.Equal(t, landmarks.PointsOnImage(image.NewRGBA(box)), f.Landmarks)
+		assert.NotNil(t, f.Aligned)
+		assert.Nil(t, f.Descriptors)
+	}
+
+	faces, err = e.ExtractFaces(img, true)
+	require.NoError(t, err)
+	require.Len(t, faces, 2)
+	assert.Equal(t, float32(1), faces[1].Descriptors[0])
+}
+
+func TestAverageFaceOfExtractedFaces(t *testing.T) {
+	box := image.Rect(100, 100, 300, 340)
+	e := &gildasai.Extractor{
+		Detector:   &gildasaitest.Detector{Detections: [][]gildasai.Detection{{{Box: box, Score: 0.95}}}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+
+	var items []gildasai.FaceItem
+	var sources []image.Image
+	for _, c := range []color.RGBA{{R: 200, A: 255}, {B: 200, A: 255}} {
+		img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+		draw.Draw(img, img.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
+
+		extracted, err := e.ExtractItems(img)
+		require.NoError(t, err)
+		require.Len(t, extracted, 1)
+		items = append(items, extracted[0])
+		sources = append(sources, img)
+	}
+
+	average, err := gildasai.AverageFace(items, sources)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 200, 200), average.Bounds())
+	r, g, b, _ := average.At(100, 100).RGBA()
+	assert.InDelta(t, 100, r>>8, 2, "the colors of the faces are averaged")
+	assert.InDelta(t, 0, g>>8, 2)
+	assert.InDelta(t, 100, b>>8, 2)
+
+	// a face without landmarks is skipped
+	items = append(items, gildasai.FaceItem{Detection: gildasai.Detection{Box: box}})
+	sources = append(sources, image.NewRGBA(image.Rect(0, 0, 400, 400)))
+	skipped, err := gildasai.AverageFace(items, sources)
+	require.NoError(t, err)
+	assert.Equal(t, average, skipped)
+
+	_, err = gildasai.AverageFace(items[2:], sources[2:])
+	assert.Error(t, err, "no face is left to average")
+}
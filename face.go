@@ -310,11 +310,42 @@ type Descriptor interface {
 	Compute(img image.Image) (Descriptors, error)
 }
 
+// BatchDetector is implemented by the detectors able to run on several
+// images with one call to their model
+type BatchDetector interface {
+	DetectBatch(imgs []image.Image) ([][]Detection, error)
+}
+
+// BatchLandmark is implemented by the landmark models able to run on
+// several faces with one call
+type BatchLandmark interface {
+	DetectBatch(imgs []image.Image) ([]*Landmarks, error)
+}
+
+// BatchDescriptor is implemented by the descriptor models able to run on
+// several faces with one call
+type BatchDescriptor interface {
+	ComputeBatch(imgs []image.Image) ([]Descriptors, error)
+}
+
+// DefaultMinFaceSize is the size, in pixels, under which detected faces
+// are ignored when Extractor.MinFaceSize is not set
+const DefaultMinFaceSize = 45
+
 type Extractor struct {
-	Network    string
-	Detector   Detector
-	Landmark   Landmark
-	Descriptor Descriptor
+	Network string
+	// Fingerprint identifies the version of the models, it is the Model
+	// of the extracted faces
+	Fingerprint string
+	Detector    Detector
+	Landmark    Landmark
+	Descriptor  Descriptor
+	// MinFaceSize is the minimum width and height, in pixels, of the
+	// faces extracted. 0 means DefaultMinFaceSize.
+	MinFaceSize int
+	// Orientation tells if faces are also looked for on rotated copies
+	// of the images
+	Orientation OrientationMode
 }
 
 func (e *Extractor) Extract(img image.Image) ([]image.Image, []Descriptors, error) {
@@ -358,58 +389,197 @@ func (e *Extractor) extract(img image.Image, detectionThreshold float32, skip in
 	centeredCollection []image.Image,
 	descriptorsCollection []Descriptors,
 	err error) {
+	x, err := e.extractOriented(img, detectionThreshold, skip)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+
+	return x.detections,
+		x.cropped,
+		x.landmarks,
+		x.landmarksOnImages,
+		x.centered,
+		x.descriptors, nil
+}
+
+// ExtractItems returns the faces of img, with everything but their
+// identifier
+func (e *Extractor) ExtractItems(img image.Image) ([]FaceItem, error) {
+	x, err := e.extractOriented(img, 0.6, none)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []FaceItem
+	for i := range x.detections {
+		items = append(items, FaceItem{
+			Network:     e.Network,
+			Model:       e.Fingerprint,
+			Detection:   x.detections[i],
+			Landmarks:   x.landmarks[i],
+			Descriptors: x.descriptors[i],
+			Orientation: x.orientation,
+			Quality:     x.detections[i].Score * x.landmarks[i].Confidence(),
+			Pose:        x.poses[i],
+		})
+	}
+
+	return items, nil
+}
+
+// ExtractedFace is a face of an image with its landmarks, in pixels on
+// the image, and its aligned crop
+type ExtractedFace struct {
+	Detection   Detection
+	Landmarks   []image.Point
+	Aligned     image.Image
+	Descriptors Descriptors
+}
+
+// ExtractFaces returns the faces of img and, only when descriptors is
+// true, computes their descriptors
+func (e *Extractor) ExtractFaces(img image.Image, descriptors bool) ([]ExtractedFace, error) {
+	skip := skipDescriptors
+	if descriptors {
+		skip = none
+	}
+
+	x, err := e.extractOriented(img, 0.6, skip)
+	if err != nil {
+		return nil, err
+	}
+
+	var faces []ExtractedFace
+	for i := range x.detections {
+		f := ExtractedFace{
+			Detection: x.detections[i],
+			Landmarks: x.landmarksOnImages[i],
+			Aligned:   x.centered[i],
+		}
+		if descriptors {
+			f.Descriptors = x.descriptors[i]
+		}
+		faces = append(faces, f)
+	}
+
+	return faces, nil
+}
+
+func (e *Extractor) extractUpright(img image.Image, detectionThreshold float32, skip int) (*extraction, error) {
 	allDetections, err := e.Detector.Detect(img)
 	if err != nil {
-		return nil, nil, nil, nil, nil, nil, errors.Wrap(err, "error detecting faces")
+		return nil, errors.Wrap(err, "error detecting faces")
 	}
 
 	detectionsOverThresholds := Above(allDetections, detectionThreshold)
 
 	if len(detectionsOverThresholds) == 0 {
-		return nil, nil, nil, nil, nil, nil, ErrNoFaceDetected
+		return &extraction{}, ErrNoFaceDetected
 	}
 
+	minFaceSize := e.MinFaceSize
+	if minFaceSize == 0 {
+		minFaceSize = DefaultMinFaceSize
+	}
+
+	x := &extraction{}
 	for _, d := range detectionsOverThresholds {
-		if d.Box.Dx() < 45 || d.Box.Dy() < 45 {
+		if d.Box.Dx() < minFaceSize || d.Box.Dy() < minFaceSize {
 			continue // face is too small
 		}
 
-		detections = append(detections, d)
+		x.detections = append(x.detections, d)
 
 		cropped := image.NewRGBA(d.Box)
 		draw.Draw(cropped, d.Box, img, d.Box.Min, draw.Src)
-		croppedCollection = append(croppedCollection, cropped)
+		x.cropped = append(x.cropped, cropped)
+	}
+
+	landmarks, err := detectLandmarks(e.Landmark, x.cropped)
+	if err != nil {
+		return nil, errors.Wrap(err, "error detecting landmarks")
+	}
+	for i, l := range landmarks {
+		x.landmarks = append(x.landmarks, *l)
+		x.landmarksOnImages = append(x.landmarksOnImages, l.PointsOnImage(x.cropped[i]))
+		x.poses = append(x.poses, l.Pose(x.detections[i].Box))
+	}
+
+	if skip == skipCenter {
+		return x, nil
+	}
 
-		landmarks, err := e.Landmark.Detect(cropped)
+	for i, l := range landmarks {
+		x.centered = append(x.centered, l.Center(x.cropped[i], img))
+	}
+
+	if skip == skipDescriptors {
+		return x, nil
+	}
+
+	x.descriptors, err = computeDescriptors(e.Descriptor, x.centered)
+	if err != nil {
+		return nil, errors.Wrap(err, "error computing descriptors")
+	}
+
+	return x, nil
+}
+
+// detectLandmarks runs l on all the faces, at once if l is a BatchLandmark
+func detectLandmarks(l Landmark, faces []image.Image) ([]*Landmarks, error) {
+	if len(faces) == 0 {
+		return nil, nil
+	}
+
+	if b, ok := l.(BatchLandmark); ok {
+		landmarks, err := b.DetectBatch(faces)
 		if err != nil {
-			return nil, nil, nil, nil, nil, nil, errors.Wrap(err, "error detecting landmarks")
+			return nil, err
+		}
+		if len(landmarks) != len(faces) {
+			return nil, errors.Errorf("got %d landmarks for %d faces", len(landmarks), len(faces))
 		}
-		landmarksCollection = append(landmarksCollection, *landmarks)
-		landmarksOnImages = append(landmarksOnImages, landmarks.PointsOnImage(cropped))
+		return landmarks, nil
+	}
 
-		if skip == skipCenter {
-			continue
+	var landmarks []*Landmarks
+	for _, f := range faces {
+		lm, err := l.Detect(f)
+		if err != nil {
+			return nil, err
 		}
+		landmarks = append(landmarks, lm)
+	}
 
-		centered := landmarks.Center(cropped, img)
-		centeredCollection = append(centeredCollection, centered)
+	return landmarks, nil
+}
 
-		if skip == skipDescriptors {
-			continue
-		}
+// computeDescriptors runs d on all the faces, at once if d is a
+// BatchDescriptor
+func computeDescriptors(d Descriptor, faces []image.Image) ([]Descriptors, error) {
+	if len(faces) == 0 {
+		return nil, nil
+	}
 
-		descriptors, err := e.Descriptor.Compute(centered)
+	if b, ok := d.(BatchDescriptor); ok {
+		descriptors, err := b.ComputeBatch(faces)
 		if err != nil {
-			return nil, nil, nil, nil, nil, nil, errors.Wrap(err, "error computing descriptors")
+			return nil, err
 		}
+		if len(descriptors) != len(faces) {
+			return nil, errors.Errorf("got %d descriptors for %d faces", len(descriptors), len(faces))
+		}
+		return descriptors, nil
+	}
 
-		descriptorsCollection = append(descriptorsCollection, descriptors)
+	var descriptors []Descriptors
+	for _, f := range faces {
+		descr, err := d.Compute(f)
+		if err != nil {
+			return nil, err
+		}
+		descriptors = append(descriptors, descr)
 	}
 
-	return detections,
-		croppedCollection,
-		landmarksCollection,
-		landmarksOnImages,
-		centeredCollection,
-		descriptorsCollection, nil
+	return descriptors, nil
 }
@@ -0,0 +1,54 @@
+package gildasai_test
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchProcess(t *testing.T) {
+	detector := &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()[:1]}}
+	detector.Fail = func(method string, n int) error {
+		if n == 1 {
+			return errors.New("failure")
+		}
+		return nil
+	}
+	e := &gildasai.Extractor{
+		Detector:   detector,
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+
+	jobs := map[string]image.Image{}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		jobs[name] = image.NewRGBA(image.Rect(0, 0, 200, 200))
+	}
+
+	notifications := make(chan gildasai.Progress, len(jobs))
+	b := &gildasai.Batch{Notifications: notifications}
+	b = b.Process(e, jobs)
+
+	assert.Equal(t, gildasai.Progress{Count: 3, OK: 2, Errors: 1}, b.Progress)
+	require.Len(t, b.Items, 2)
+	assert.Len(t, notifications, 3)
+
+	var errs []*gildasai.BatchError
+	for _, err := range b.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	require.Len(t, errs, 1)
+	assert.NotContains(t, []string{b.Items[0].Name, b.Items[1].Name}, errs[0].Name)
+
+	distances := b.Distances()
+	require.Len(t, distances, 2)
+	assert.Equal(t, float32(0), distances[0][0])
+	assert.Equal(t, float32(1), distances[0][1])
+}
@@ -0,0 +1,46 @@
+package faceapi
+
+import "image"
+
+// DefaultBatchSize is the maximum number of images stacked in one tensor
+// when the BatchSize of a model is not set
+const DefaultBatchSize = 16
+
+// chunks splits imgs in consecutive slices of at most size images
+func chunks(imgs []image.Image, size int) [][]image.Image {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	var out [][]image.Image
+	for len(imgs) > size {
+		out = append(out, imgs[:size])
+		imgs = imgs[size:]
+	}
+	if len(imgs) > 0 {
+		out = append(out, imgs)
+	}
+
+	return out
+}
+
+// sameSizeChunks splits imgs in consecutive slices of at most size images
+// having all the same dimensions, as a tensor needs to
+func sameSizeChunks(imgs []image.Image, size int) [][]image.Image {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	var out [][]image.Image
+	start := 0
+	for i := 1; i <= len(imgs); i++ {
+		if i < len(imgs) && i-start < size &&
+			imgs[i].Bounds().Size() == imgs[start].Bounds().Size() {
+			continue
+		}
+		out = append(out, imgs[start:i])
+		start = i
+	}
+
+	return out
+}
@@ -0,0 +1,40 @@
+package faceapi
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunks(t *testing.T) {
+	var imgs []image.Image
+	for i := 0; i < 20; i++ {
+		imgs = append(imgs, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	}
+
+	batches := chunks(imgs, 16)
+	assert.Len(t, batches, 2)
+	assert.Len(t, batches[0], 16)
+	assert.Len(t, batches[1], 4)
+
+	assert.Len(t, chunks(imgs, 0), 2)
+	assert.Empty(t, chunks(nil, 16))
+}
+
+func TestSameSizeChunks(t *testing.T) {
+	small := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	large := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	// same size, other origin
+	moved := image.NewRGBA(image.Rect(5, 5, 15, 15))
+
+	batches := sameSizeChunks([]image.Image{small, moved, small, large, large, small}, 2)
+
+	assert.Equal(t, [][]image.Image{
+		{small, moved},
+		{small},
+		{large, large},
+		{small},
+	}, batches)
+	assert.Empty(t, sameSizeChunks(nil, 2))
+}
@@ -4,14 +4,17 @@ import (
 	"image"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
+	"github.com/gildasch/gildas-ai/imageutils/preprocess"
 	"github.com/nfnt/resize"
 	"github.com/pkg/errors"
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 )
 
 type Descriptor struct {
-	graph   *tf.Graph
-	session *tf.Session
+	model backend.Model
+	// BatchSize is the maximum number of faces sent to the model at
+	// once by ComputeBatch. 0 means DefaultBatchSize.
+	BatchSize int
 }
 
 func NewDescriptor() (*Descriptor, error) {
@@ -19,83 +22,79 @@ func NewDescriptor() (*Descriptor, error) {
 }
 
 func NewDescriptorFromFile(modelName, tagName string) (*Descriptor, error) {
-	model, err := tf.LoadSavedModel(modelName, []string{tagName}, nil)
+	model, err := backend.LoadSavedModel(modelName, []string{tagName}, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err,
 			"failed to load saved model %q / tag %q", modelName, tagName)
 	}
 
-	return &Descriptor{
-		graph:   model.Graph,
-		session: model.Session,
-	}, nil
+	return &Descriptor{model: model}, nil
 }
 
 func (d *Descriptor) Close() error {
-	return d.session.Close()
+	return d.model.Close()
 }
 
 func (d *Descriptor) Compute(img image.Image) (gildasai.Descriptors, error) {
-	img = resize.Resize(150, 150, img, resize.NearestNeighbor)
-
-	tensor, err := imageToTensorDescriptors(img, uint(img.Bounds().Dy()), uint(img.Bounds().Dx()))
+	descriptors, err := d.ComputeBatch([]image.Image{img})
 	if err != nil {
-		return nil, errors.Wrap(err, "error converting image to tensor")
+		return nil, err
 	}
 
-	result, err := d.session.Run(
-		map[tf.Output]*tf.Tensor{
-			d.graph.Operation("input").Output(0): tensor,
-		},
-		[]tf.Output{
-			d.graph.Operation("output").Output(0),
-		},
-		nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "error running the tensorflow session")
-	}
+	return descriptors[0], nil
+}
 
-	if len(result) < 1 {
-		return nil, errors.New("result is empty")
-	}
+// ComputeBatch returns the descriptors of each of the faces imgs, running
+// the model on batches of d.BatchSize faces
+func (d *Descriptor) ComputeBatch(imgs []image.Image) ([]gildasai.Descriptors, error) {
+	var out []gildasai.Descriptors
 
-	res, ok := result[0].Value().([][]float32)
-	if !ok {
-		return nil, errors.Errorf("result has unexpected type %T", result[0].Value())
-	}
+	for _, batch := range chunks(imgs, d.BatchSize) {
+		resized := make([]image.Image, len(batch))
+		for i, img := range batch {
+			resized[i] = resize.Resize(150, 150, img, resize.NearestNeighbor)
+		}
 
-	if len(res) < 1 {
-		return nil, errors.New("descriptors are empty")
-	}
+		tensor, err := imageToTensorDescriptors(resized)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting image to tensor")
+		}
+
+		result, err := d.model.Run(
+			map[string]*backend.Tensor{"input": tensor},
+			[]string{"output"})
+		if err != nil {
+			return nil, errors.Wrap(err, "error running the model")
+		}
 
-	out := make(gildasai.Descriptors, 128)
+		if len(result) < 1 {
+			return nil, errors.New("result is empty")
+		}
+
+		if err := result[0].CheckFloat32(int64(len(batch)), 128); err != nil {
+			return nil, errors.Wrap(err, "unexpected descriptors")
+		}
 
-	for i, v := range res[0] {
-		out[i] = v
+		for i := range batch {
+			descriptors := make(gildasai.Descriptors, 128)
+			copy(descriptors, result[0].Row(i))
+			out = append(out, descriptors)
+		}
 	}
 
 	return out, nil
 }
 
-func imageToTensorDescriptors(img image.Image, imageHeight, imageWidth uint) (*tf.Tensor, error) {
-	var image [1][][][3]float32
-
-	for j := 0; j < int(imageHeight); j++ {
-		image[0] = append(image[0], make([][3]float32, imageWidth))
-	}
+var descriptorsOptions = preprocess.Options{
+	Mean: [3]float32{122.782, 117.001, 104.298},
+	Std:  255,
+}
 
-	for i := 0; i < int(imageWidth); i++ {
-		for j := 0; j < int(imageHeight); j++ {
-			r, g, b, _ := img.At(i, j).RGBA()
-			image[0][j][i][0] = convertDescriptors(r, 122.782)
-			image[0][j][i][1] = convertDescriptors(g, 117.001)
-			image[0][j][i][2] = convertDescriptors(b, 104.298)
-		}
+func imageToTensorDescriptors(imgs []image.Image) (*backend.Tensor, error) {
+	batch, err := preprocess.Float32s(imgs, descriptorsOptions)
+	if err != nil {
+		return nil, err
 	}
 
-	return tf.NewTensor(image)
-}
-
-func convertDescriptors(value uint32, mean float32) float32 {
-	return (float32(value>>8) - mean) / float32(255)
+	return batch.Tensor(), nil
 }
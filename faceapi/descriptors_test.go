@@ -2,14 +2,21 @@ package faceapi
 
 import (
 	"fmt"
+	"image"
 	"testing"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
 	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestDescriptors(t *testing.T) {
+	if backend.Default == nil {
+		t.Skip(backend.ErrNoBackend)
+	}
+
 	d, err := NewDescriptor()
 	require.NoError(t, err)
 
@@ -28,3 +35,30 @@ func TestDescriptors(t *testing.T) {
 		}
 	}
 }
+
+func TestDescriptorsBatchFakeModel(t *testing.T) {
+	values := make([]float32, 3*128)
+	for i := range values {
+		values[i] = float32(i)
+	}
+	model := &backend.FakeModel{Outputs: map[string]*backend.Tensor{
+		"output": backend.NewFloat32([]int64{3, 128}, values),
+	}}
+	d := &Descriptor{model: model, BatchSize: 2}
+
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	_, err := d.ComputeBatch([]image.Image{img, img, img})
+	// the fake model returns 3 descriptors for the batch of 2 faces
+	assert.Error(t, err)
+
+	d.BatchSize = 3
+	model.Inputs = nil
+	descriptors, err := d.ComputeBatch([]image.Image{img, img, img})
+	require.NoError(t, err)
+
+	require.Len(t, model.Inputs, 1)
+	assert.Equal(t, []int64{3, 150, 150, 3}, model.Inputs[0]["input"].Shape)
+
+	require.Len(t, descriptors, 3)
+	assert.Equal(t, gildasai.Descriptors(values[128:256]), descriptors[1])
+}
@@ -2,16 +2,18 @@ package faceapi
 
 import (
 	"image"
-	"io/ioutil"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
+	"github.com/gildasch/gildas-ai/imageutils/preprocess"
 	"github.com/pkg/errors"
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 )
 
 type Detector struct {
-	graph   *tf.Graph
-	session *tf.Session
+	model backend.Model
+	// BatchSize is the maximum number of images sent to the model at
+	// once by DetectBatch. 0 means DefaultBatchSize.
+	BatchSize int
 }
 
 func NewDetector() (*Detector, error) {
@@ -19,116 +21,116 @@ func NewDetector() (*Detector, error) {
 }
 
 func NewDetectorFromFile(modelFilename string) (*Detector, error) {
-	model, err := ioutil.ReadFile(modelFilename)
+	model, err := backend.LoadGraph(modelFilename, nil)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error reading model file %q", modelFilename)
+		return nil, errors.Wrapf(err, "failed to load model %q", modelFilename)
 	}
 
-	graph := tf.NewGraph()
-	if err := graph.Import(model, ""); err != nil {
-		return nil, errors.Wrapf(err, "error importing model to new graph")
-	}
+	return &Detector{model: model}, nil
+}
 
-	session, err := tf.NewSession(graph, nil)
+func (d *Detector) Close() error {
+	return d.model.Close()
+}
+
+func (d *Detector) Detect(img image.Image) ([]gildasai.Detection, error) {
+	detections, err := d.DetectBatch([]image.Image{img})
 	if err != nil {
-		return nil, errors.Wrapf(err, "error creating new session from graph")
+		return nil, err
 	}
 
-	return &Detector{
-		graph:   graph,
-		session: session,
-	}, nil
+	return detections[0], nil
 }
 
-func (d *Detector) Close() error {
-	return d.session.Close()
+// DetectBatch returns the detections on each of imgs. The consecutive
+// images of the same size are run together, by batches of d.BatchSize.
+func (d *Detector) DetectBatch(imgs []image.Image) ([][]gildasai.Detection, error) {
+	var out [][]gildasai.Detection
+
+	for _, batch := range sameSizeChunks(imgs, d.BatchSize) {
+		detections, err := d.detectSameSize(batch)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, detections...)
+	}
+
+	return out, nil
 }
 
-func (d *Detector) Detect(img image.Image) ([]gildasai.Detection, error) {
-	tensor, err := imageToTensorDetection(img, uint(img.Bounds().Dy()), uint(img.Bounds().Dx()))
+func (d *Detector) detectSameSize(imgs []image.Image) ([][]gildasai.Detection, error) {
+	tensor, err := imageToTensorDetection(imgs)
 	if err != nil {
 		return nil, errors.Wrap(err, "error converting image to tensor")
 	}
 
-	result, err := d.session.Run(
-		map[tf.Output]*tf.Tensor{
-			d.graph.Operation("image_tensor").Output(0): tensor,
-		},
-		[]tf.Output{
-			d.graph.Operation("detection_boxes").Output(0),
-			d.graph.Operation("detection_scores").Output(0),
-			d.graph.Operation("detection_classes").Output(0),
-			d.graph.Operation("num_detections").Output(0),
-		},
-		nil)
+	result, err := d.model.Run(
+		map[string]*backend.Tensor{"image_tensor": tensor},
+		[]string{
+			"detection_boxes",
+			"detection_scores",
+			"detection_classes",
+			"num_detections",
+		})
 	if err != nil {
-		return nil, errors.Wrap(err, "error running the tensorflow session")
+		return nil, errors.Wrap(err, "error running the model")
 	}
 
-	boxBatches, ok := result[0].Value().([][][]float32)
-	if !ok || len(boxBatches) < 1 {
-		return nil, errors.New("detection_boxes has unexprected shape")
+	if len(result) < 4 {
+		return nil, errors.New("result is incomplete")
 	}
-	boxes := boxBatches[0]
 
-	scoreBatches, ok := result[1].Value().([][]float32)
-	if !ok || len(scoreBatches) < 1 {
-		return nil, errors.Errorf("detection_scores has unexprected shape %T", result[0].Value())
+	n := int64(len(imgs))
+	boxes, scores, classes, numDetections := result[0], result[1], result[2], result[3]
+	if err := boxes.CheckFloat32(n, -1, 4); err != nil {
+		return nil, errors.Wrap(err, "detection_boxes has unexpected shape")
 	}
-	scores := scoreBatches[0]
-
-	classBatches, ok := result[2].Value().([][]float32)
-	if !ok || len(classBatches) < 1 {
-		return nil, errors.New("detection_classes has unexprected shape")
+	if err := scores.CheckFloat32(n, boxes.Shape[1]); err != nil {
+		return nil, errors.Wrap(err, "detection_scores has unexpected shape")
 	}
-	classes := classBatches[0]
-
-	numDetectionsBatches, ok := result[3].Value().([]float32)
-	if !ok || len(numDetectionsBatches) < 1 {
-		return nil, errors.New("num_detections has unexprected shape")
+	if err := classes.CheckFloat32(n, boxes.Shape[1]); err != nil {
+		return nil, errors.Wrap(err, "detection_classes has unexpected shape")
 	}
-	numDetections := int(numDetectionsBatches[0])
-
-	var detections []gildasai.Detection
-	for i := 0; i < numDetections; i++ {
-		detections = append(detections, gildasai.Detection{
-			Box: image.Rectangle{
-				Min: image.Point{
-					X: int(float32(img.Bounds().Max.X) * boxes[i][1]),
-					Y: int(float32(img.Bounds().Max.Y) * boxes[i][0]),
-				},
-				Max: image.Point{
-					X: int(float32(img.Bounds().Max.X) * boxes[i][3]),
-					Y: int(float32(img.Bounds().Max.Y) * boxes[i][2]),
-				},
-			},
-			Score: scores[i],
-			Class: classes[i],
-		})
+	if err := numDetections.CheckFloat32(n); err != nil {
+		return nil, errors.Wrap(err, "num_detections has unexpected shape")
 	}
 
-	return detections, nil
-}
-
-func imageToTensorDetection(img image.Image, imageHeight, imageWidth uint) (*tf.Tensor, error) {
-	var image [1][][][3]uint8
+	out := make([][]gildasai.Detection, len(imgs))
+	for b, img := range imgs {
+		imgBoxes, imgScores, imgClasses := boxes.Row(b), scores.Row(b), classes.Row(b)
 
-	for j := 0; j < int(imageHeight); j++ {
-		image[0] = append(image[0], make([][3]uint8, imageWidth))
-	}
+		count := int(numDetections.Float32[b])
+		if count > len(imgScores) {
+			count = len(imgScores)
+		}
 
-	for i := 0; i < int(imageWidth); i++ {
-		for j := 0; j < int(imageHeight); j++ {
-			r, g, b, _ := img.At(i, j).RGBA()
-			image[0][j][i][0] = convertDetection(r)
-			image[0][j][i][1] = convertDetection(g)
-			image[0][j][i][2] = convertDetection(b)
+		for i := 0; i < count; i++ {
+			box := imgBoxes[4*i : 4*i+4]
+			out[b] = append(out[b], gildasai.Detection{
+				Box: image.Rectangle{
+					Min: image.Point{
+						X: int(float32(img.Bounds().Max.X) * box[1]),
+						Y: int(float32(img.Bounds().Max.Y) * box[0]),
+					},
+					Max: image.Point{
+						X: int(float32(img.Bounds().Max.X) * box[3]),
+						Y: int(float32(img.Bounds().Max.Y) * box[2]),
+					},
+				},
+				Score: imgScores[i],
+				Class: imgClasses[i],
+			})
 		}
 	}
 
-	return tf.NewTensor(image)
+	return out, nil
 }
 
-func convertDetection(value uint32) uint8 {
-	return uint8(value >> 8)
+func imageToTensorDetection(imgs []image.Image) (*backend.Tensor, error) {
+	batch, err := preprocess.Uint8s(imgs)
+	if err != nil {
+		return nil, err
+	}
+
+	return batch.Tensor(), nil
 }
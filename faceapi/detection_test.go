@@ -8,12 +8,17 @@ import (
 	"testing"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
 	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestDetection(t *testing.T) {
+	if backend.Default == nil {
+		t.Skip(backend.ErrNoBackend)
+	}
+
 	d, err := NewDetector()
 	require.NoError(t, err)
 
@@ -60,6 +65,10 @@ func TestDetection(t *testing.T) {
 }
 
 func TestDetection2(t *testing.T) {
+	if backend.Default == nil {
+		t.Skip(backend.ErrNoBackend)
+	}
+
 	d, err := NewDetector()
 	require.NoError(t, err)
 
@@ -82,3 +91,55 @@ func TestDetection2(t *testing.T) {
 
 	imageutils.AssertImageEqual(t, "detection-in-4-expected.png", actual)
 }
+
+func TestDetectBatchFakeModel(t *testing.T) {
+	model := &backend.FakeModel{Outputs: map[string]*backend.Tensor{
+		"detection_boxes": backend.NewFloat32([]int64{2, 3, 4}, []float32{
+			0.1, 0.2, 0.5, 0.6, 0, 0, 1, 1, 0, 0, 0, 0,
+			0.5, 0.5, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0,
+		}),
+		"detection_scores": backend.NewFloat32([]int64{2, 3}, []float32{
+			0.9, 0.2, 0,
+			0.8, 0, 0,
+		}),
+		"detection_classes": backend.NewFloat32([]int64{2, 3}, []float32{
+			1, 1, 0,
+			1, 0, 0,
+		}),
+		"num_detections": backend.NewFloat32([]int64{2}, []float32{2, 1}),
+	}}
+	d := &Detector{model: model}
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	img.Pix[0], img.Pix[1], img.Pix[2] = 10, 20, 30
+
+	detections, err := d.DetectBatch([]image.Image{img, img})
+	require.NoError(t, err)
+
+	require.Len(t, model.Inputs, 1)
+	input := model.Inputs[0]["image_tensor"]
+	assert.Equal(t, []int64{2, 50, 100, 3}, input.Shape)
+	assert.Equal(t, []uint8{10, 20, 30}, input.Uint8[:3])
+
+	assert.Equal(t, [][]gildasai.Detection{
+		{
+			{Box: image.Rect(20, 5, 60, 25), Score: 0.9, Class: 1},
+			{Box: image.Rect(0, 0, 100, 50), Score: 0.2, Class: 1},
+		},
+		{
+			{Box: image.Rect(50, 25, 100, 50), Score: 0.8, Class: 1},
+		},
+	}, detections)
+}
+
+func TestDetectFakeModelBadShape(t *testing.T) {
+	d := &Detector{model: &backend.FakeModel{Outputs: map[string]*backend.Tensor{
+		"detection_boxes":   backend.NewFloat32([]int64{1, 1, 3}, []float32{0, 0, 1}),
+		"detection_scores":  backend.NewFloat32([]int64{1, 1}, []float32{1}),
+		"detection_classes": backend.NewFloat32([]int64{1, 1}, []float32{1}),
+		"num_detections":    backend.NewFloat32([]int64{1}, []float32{1}),
+	}}}
+
+	_, err := d.Detect(image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	assert.Error(t, err)
+}
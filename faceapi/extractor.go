@@ -1,9 +1,22 @@
 package faceapi
 
-import gildasai "github.com/gildasch/gildas-ai"
+import (
+	"os"
 
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
+	"github.com/gildasch/gildas-ai/hog"
+)
+
+// Network is the network of the faces extracted with the face-api.js
+// models
+const Network = "face-api-js"
+
+// NewDefaultExtractor loads the face-api.js models from modelRoot. When
+// the tensorflow detection model is missing, the pure Go hog detector is
+// used instead.
 func NewDefaultExtractor(modelRoot string) (*gildasai.Extractor, error) {
-	detector, err := NewDetectorFromFile(modelRoot + "/frozen_inference_graph_face.pb")
+	detector, detectorFile, err := newDefaultDetector(modelRoot)
 	if err != nil {
 		return nil, err
 	}
@@ -18,10 +31,40 @@ func NewDefaultExtractor(modelRoot string) (*gildasai.Extractor, error) {
 		return nil, err
 	}
 
+	fingerprint, err := Fingerprint(detectorFile, modelRoot+"/landmarksnet", modelRoot+"/descriptorsnet")
+	if err != nil {
+		return nil, err
+	}
+
 	return &gildasai.Extractor{
-		Network:    "face-api-js",
-		Detector:   detector,
-		Landmark:   landmark,
-		Descriptor: descriptor,
+		Network:     Network,
+		Fingerprint: fingerprint,
+		Detector:    detector,
+		Landmark:    landmark,
+		Descriptor:  descriptor,
 	}, nil
 }
+
+// Fingerprint returns the fingerprint of the face-api.js models, the
+// detector being the hog one when detectorFile is empty
+func Fingerprint(detectorFile, landmarkDir, descriptorDir string) (string, error) {
+	if detectorFile == "" {
+		return backend.Fingerprint(Network+"+hog", landmarkDir, descriptorDir)
+	}
+	return backend.Fingerprint(Network, detectorFile, landmarkDir, descriptorDir)
+}
+
+// newDefaultDetector returns the detector and its model file, empty for
+// the hog detector
+func newDefaultDetector(modelRoot string) (gildasai.Detector, string, error) {
+	modelFilename := modelRoot + "/frozen_inference_graph_face.pb"
+	if _, err := os.Stat(modelFilename); os.IsNotExist(err) {
+		return hog.NewDetector(), "", nil
+	}
+
+	detector, err := NewDetectorFromFile(modelFilename)
+	if err != nil {
+		return nil, "", err
+	}
+	return detector, modelFilename, nil
+}
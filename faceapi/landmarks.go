@@ -4,14 +4,17 @@ import (
 	"image"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
+	"github.com/gildasch/gildas-ai/imageutils/preprocess"
 	"github.com/nfnt/resize"
 	"github.com/pkg/errors"
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 )
 
 type Landmark struct {
-	graph   *tf.Graph
-	session *tf.Session
+	model backend.Model
+	// BatchSize is the maximum number of faces sent to the model at
+	// once by DetectBatch. 0 means DefaultBatchSize.
+	BatchSize int
 }
 
 func NewLandmark() (*Landmark, error) {
@@ -19,79 +22,79 @@ func NewLandmark() (*Landmark, error) {
 }
 
 func NewLandmarkFromFile(modelName, tagName string) (*Landmark, error) {
-	model, err := tf.LoadSavedModel(modelName, []string{tagName}, nil)
+	model, err := backend.LoadSavedModel(modelName, []string{tagName}, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err,
 			"failed to load saved model %q / tag %q", modelName, tagName)
 	}
 
-	return &Landmark{
-		graph:   model.Graph,
-		session: model.Session,
-	}, nil
+	return &Landmark{model: model}, nil
 }
 
 func (d *Landmark) Close() error {
-	return d.session.Close()
+	return d.model.Close()
 }
 
 func (d *Landmark) Detect(img image.Image) (*gildasai.Landmarks, error) {
-	img = resize.Resize(112, 112, img, resize.NearestNeighbor)
-
-	tensor, err := imageToTensorLandmarks(img, uint(img.Bounds().Dy()), uint(img.Bounds().Dx()))
+	landmarks, err := d.DetectBatch([]image.Image{img})
 	if err != nil {
-		return nil, errors.Wrap(err, "error converting image to tensor")
+		return nil, err
 	}
 
-	result, err := d.session.Run(
-		map[tf.Output]*tf.Tensor{
-			d.graph.Operation("input").Output(0): tensor,
-		},
-		[]tf.Output{
-			d.graph.Operation("output").Output(0),
-		},
-		nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "error running the tensorflow session")
-	}
+	return landmarks[0], nil
+}
 
-	if len(result) < 1 {
-		return nil, errors.New("result is empty")
-	}
+// DetectBatch returns the landmarks of each of the faces imgs, running
+// the model on batches of d.BatchSize faces
+func (d *Landmark) DetectBatch(imgs []image.Image) ([]*gildasai.Landmarks, error) {
+	var out []*gildasai.Landmarks
 
-	res, ok := result[0].Value().([][]float32)
-	if !ok {
-		return nil, errors.Errorf("result has unexpected type %T", result[0].Value())
-	}
+	for _, batch := range chunks(imgs, d.BatchSize) {
+		resized := make([]image.Image, len(batch))
+		for i, img := range batch {
+			resized[i] = resize.Resize(112, 112, img, resize.NearestNeighbor)
+		}
 
-	if len(res) < 1 {
-		return nil, errors.New("landmarks are empty")
-	}
+		tensor, err := imageToTensorLandmarks(resized)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting image to tensor")
+		}
 
-	return &gildasai.Landmarks{
-		Coords: res[0],
-	}, nil
-}
+		result, err := d.model.Run(
+			map[string]*backend.Tensor{"input": tensor},
+			[]string{"output"})
+		if err != nil {
+			return nil, errors.Wrap(err, "error running the model")
+		}
 
-func imageToTensorLandmarks(img image.Image, imageHeight, imageWidth uint) (*tf.Tensor, error) {
-	var image [1][][][3]float32
+		if len(result) < 1 {
+			return nil, errors.New("result is empty")
+		}
 
-	for j := 0; j < int(imageHeight); j++ {
-		image[0] = append(image[0], make([][3]float32, imageWidth))
-	}
+		if err := result[0].CheckFloat32(int64(len(batch)), -1); err != nil {
+			return nil, errors.Wrap(err, "unexpected landmarks")
+		}
 
-	for i := 0; i < int(imageWidth); i++ {
-		for j := 0; j < int(imageHeight); j++ {
-			r, g, b, _ := img.At(i, j).RGBA()
-			image[0][j][i][0] = convertLandmarks(r, 122.782)
-			image[0][j][i][1] = convertLandmarks(g, 117.001)
-			image[0][j][i][2] = convertLandmarks(b, 104.298)
+		for i := range batch {
+			out = append(out, &gildasai.Landmarks{
+				Coords: result[0].Row(i),
+			})
 		}
 	}
 
-	return tf.NewTensor(image)
+	return out, nil
 }
 
-func convertLandmarks(value uint32, mean float32) float32 {
-	return (float32(value>>8) - mean) / float32(255)
+var landmarksOptions = preprocess.Options{
+	Mean: [3]float32{122.782, 117.001, 104.298},
+	Std:  255,
+}
+
+func imageToTensorLandmarks(imgs []image.Image) (*backend.Tensor, error) {
+	batch, err := preprocess.Float32s(imgs, landmarksOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return batch.Tensor(), nil
 }
@@ -7,11 +7,17 @@ import (
 	"testing"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
 	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestLandmarksDetection(t *testing.T) {
+	if backend.Default == nil {
+		t.Skip(backend.ErrNoBackend)
+	}
+
 	l, err := NewLandmark()
 	require.NoError(t, err)
 
@@ -28,6 +34,10 @@ func TestLandmarksDetection(t *testing.T) {
 }
 
 func TestFullImage(t *testing.T) {
+	if backend.Default == nil {
+		t.Skip(backend.ErrNoBackend)
+	}
+
 	testImage, err := imageutils.FromFile("pictures/2.jpg")
 	require.NoError(t, err)
 
@@ -61,3 +71,27 @@ func TestFullImage(t *testing.T) {
 		imageutils.AssertImageEqual(t, fmt.Sprintf("expected/2-%d-cropped.png", i), cropped)
 	}
 }
+
+func TestLandmarksBatchFakeModel(t *testing.T) {
+	coords := make([]float32, 2*136)
+	for i := range coords {
+		coords[i] = float32(i) / 1000
+	}
+	model := &backend.FakeModel{Outputs: map[string]*backend.Tensor{
+		"output": backend.NewFloat32([]int64{2, 136}, coords),
+	}}
+	l := &Landmark{model: model}
+
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	landmarks, err := l.DetectBatch([]image.Image{img, img})
+	require.NoError(t, err)
+
+	require.Len(t, model.Inputs, 1)
+	input := model.Inputs[0]["input"]
+	assert.Equal(t, []int64{2, 112, 112, 3}, input.Shape)
+	assert.InDelta(t, -122.782/255, input.Float32[0], 1e-6)
+
+	require.Len(t, landmarks, 2)
+	assert.Equal(t, coords[:136], landmarks[0].Coords)
+	assert.Equal(t, coords[136:], landmarks[1].Coords)
+}
@@ -0,0 +1,63 @@
+package gildasai
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/pkg/errors"
+)
+
+// FetcherFromEnv returns the default imageutils.Fetcher configured by the
+// environment variables FETCH_TIMEOUT (a duration), FETCH_MAX_BYTES,
+// FETCH_MAX_PIXELS, FETCH_MAX_REDIRECTS and FETCH_ALLOW_PRIVATE
+func FetcherFromEnv() (*imageutils.Fetcher, error) {
+	return fetcherFromEnv(os.Getenv)
+}
+
+func fetcherFromEnv(getenv func(string) string) (*imageutils.Fetcher, error) {
+	f := imageutils.DefaultFetcher()
+
+	if v := getenv("FETCH_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid FETCH_TIMEOUT %q", v)
+		}
+		f.Timeout = timeout
+	}
+
+	if v := getenv("FETCH_MAX_BYTES"); v != "" {
+		maxBytes, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid FETCH_MAX_BYTES %q", v)
+		}
+		f.MaxBytes = maxBytes
+	}
+
+	for _, i := range []struct {
+		env   string
+		value *int
+	}{
+		{"FETCH_MAX_PIXELS", &f.MaxPixels},
+		{"FETCH_MAX_REDIRECTS", &f.MaxRedirects},
+	} {
+		if v := getenv(i.env); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s %q", i.env, v)
+			}
+			*i.value = parsed
+		}
+	}
+
+	if v := getenv("FETCH_ALLOW_PRIVATE"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid FETCH_ALLOW_PRIVATE %q", v)
+		}
+		f.AllowPrivate = allow
+	}
+
+	return f, nil
+}
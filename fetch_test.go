@@ -0,0 +1,46 @@
+package gildasai
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcherFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	f, err := fetcherFromEnv(env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, imageutils.DefaultFetcher(), f)
+
+	f, err = fetcherFromEnv(env(map[string]string{
+		"FETCH_TIMEOUT":       "5s",
+		"FETCH_MAX_BYTES":     "1000000",
+		"FETCH_MAX_PIXELS":    "4000000",
+		"FETCH_MAX_REDIRECTS": "0",
+		"FETCH_ALLOW_PRIVATE": "true",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, &imageutils.Fetcher{
+		Timeout:      5 * time.Second,
+		MaxBytes:     1000000,
+		MaxPixels:    4000000,
+		MaxRedirects: 0,
+		AllowPrivate: true,
+	}, f)
+
+	for _, vars := range []map[string]string{
+		{"FETCH_TIMEOUT": "5"},
+		{"FETCH_MAX_BYTES": "big"},
+		{"FETCH_MAX_PIXELS": "many"},
+		{"FETCH_ALLOW_PRIVATE": "maybe"},
+	} {
+		_, err := fetcherFromEnv(env(vars))
+		assert.Error(t, err, "%v", vars)
+	}
+}
@@ -1,71 +1,499 @@
 package gildasai
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/pkg/errors"
 )
 
-func ExtractFacesFromFolder(path string, extractor *Extractor, store FaceStore) (current chan string, errs chan error, done chan bool, total int, err error) {
-	files, err := filepath.Glob(path + "/*")
+// FolderStore keeps the faces of the files of a folder and the state of
+// the files they were extracted from
+type FolderStore interface {
+	FaceStore
+	SourceStore
+}
+
+// FolderReport tells what changed in a folder since its last extraction
+type FolderReport struct {
+	// Added are the files extracted for the first time
+	Added []string
+	// Modified are the files whose content changed, extracted again
+	Modified  []string
+	Unchanged []string
+	// Failed are the files which could not be read or extracted, they
+	// are tried again on the next extraction
+	Failed []string
+	// Removed are the files deleted from the folder, whose faces and
+	// predictions were deleted
+	Removed []string
+	// Skipped are the files handled by the interrupted extraction which
+	// was resumed
+	Skipped []string
+}
+
+// add adds file to the list of status
+func (r *FolderReport) add(file string, status FileStatus) {
+	switch status {
+	case FileAdded:
+		r.Added = append(r.Added, file)
+	case FileModified:
+		r.Modified = append(r.Modified, file)
+	case FileUnchanged:
+		r.Unchanged = append(r.Unchanged, file)
+	case FileFailed:
+		r.Failed = append(r.Failed, file)
+	case FileSkipped:
+		r.Skipped = append(r.Skipped, file)
+	}
+}
+
+func (r *FolderReport) sort() {
+	for _, files := range [][]string{r.Added, r.Modified, r.Unchanged, r.Failed, r.Removed, r.Skipped} {
+		sort.Strings(files)
+	}
+}
+
+// FileStatus tells how a file of a folder was handled
+type FileStatus int
+
+const (
+	FileAdded FileStatus = iota
+	FileModified
+	FileUnchanged
+	FileFailed
+	// FileSkipped files were handled by the interrupted extraction which
+	// was resumed
+	FileSkipped
+)
+
+var fileStatusNames = map[FileStatus]string{
+	FileAdded:     "added",
+	FileModified:  "modified",
+	FileUnchanged: "unchanged",
+	FileFailed:    "failed",
+	FileSkipped:   "skipped",
+}
+
+func (s FileStatus) String() string {
+	return fileStatusNames[s]
+}
+
+// FolderEvent tells how a file of a folder was handled
+type FolderEvent struct {
+	File   string
+	Status FileStatus
+	// Errs are the errors of a failed file
+	Errs []error
+}
+
+type sourceState int
+
+const (
+	sourceAdded sourceState = iota
+	sourceModified
+	sourceUnchanged
+)
+
+// FolderExtractor extracts, in parallel, the faces of the files of a
+// folder which are new or whose content changed since their last
+// extraction, the faces and distances of a changed file being deleted
+// first
+type FolderExtractor struct {
+	Walker    *FolderWalker
+	Extractor *Extractor
+	Store     FolderStore
+	// Workers is the number of files extracted at once, the number of
+	// CPUs when 0
+	Workers int
+	// Checkpoint is the file recording the files handled, for an
+	// interrupted extraction to resume without handling them again,
+	// unless their size or modification time changed since. It is
+	// removed once an extraction completes. There is no checkpoint when
+	// empty.
+	Checkpoint string
+}
+
+// FolderExtraction is an extraction running in the background
+type FolderExtraction struct {
+	// Total is the number of files of the folder
+	Total int
+
+	events chan FolderEvent
+	report *FolderReport
+	err    error
+}
+
+// Events are the events of the files, as they are handled. They are
+// closed once the extraction is over.
+func (x *FolderExtraction) Events() <-chan FolderEvent {
+	return x.events
+}
+
+// Wait waits for the end of the extraction, discarding the events which
+// were not read, and returns its report. The error is the one of the
+// context when the extraction was stopped before its end.
+func (x *FolderExtraction) Wait() (*FolderReport, error) {
+	for range x.events {
+	}
+	return x.report, x.err
+}
+
+// Start starts the extraction of the files of path. Cancelling ctx
+// stops it once the files being extracted are, whether the events are
+// read or not.
+func (fe *FolderExtractor) Start(ctx context.Context, path string) (*FolderExtraction, error) {
+	files, err := fe.Walker.Files(path)
 	if err != nil {
-		return nil, nil, nil, 0, err
+		return nil, err
 	}
 
-	total = len(files)
-	current = make(chan string)
-	errs = make(chan error)
-	done = make(chan bool)
+	checkpoint, handled, err := openCheckpoint(fe.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
 
-	go func() {
-		for _, file := range files {
-			current <- file
+	workers := fe.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
 
-			_, ok, _ := store.GetFaces(file)
-			if ok {
-				continue
-			}
+	x := &FolderExtraction{
+		Total:  len(files),
+		events: make(chan FolderEvent, workers),
+		report: &FolderReport{},
+	}
 
-			img, err := imageutils.FromFile(file)
-			if err != nil {
-				errs <- errors.Wrapf(err, "error reading image from file %q", file)
-				continue
-			}
+	go fe.run(ctx, x, files, handled, checkpoint, workers)
 
-			detections, landmarks, descrs, err := extractor.ExtractPrimitives(img)
-			if err != nil && err != ErrNoFaceDetected {
-				errs <- errors.Wrapf(err, "error extracting face primitives from image %q", file)
-				continue
-			}
+	return x, nil
+}
+
+// run extracts files with workers, records them to checkpoint and sends
+// their events to x until ctx is done
+func (fe *FolderExtractor) run(ctx context.Context, x *FolderExtraction, files []string, handled map[string]bool, checkpoint *checkpoint, workers int) {
+	defer close(x.events)
+
+	jobs := make(chan string)
+	results := make(chan FolderEvent)
 
-			if len(detections) == 0 {
-				err = store.StoreFace(&FaceItem{
-					Identifier: file,
-					Network:    extractor.Network,
-				})
-				if err != nil {
-					errs <- errors.Wrapf(err, "error storing face primitives from image %q", file)
-				}
-				continue
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the results are always read, for the files extracted to be
+			// recorded
+			for file := range jobs {
+				status, errs := updateFile(file, fe.Extractor, fe.Store)
+				results <- FolderEvent{File: file, Status: status, Errs: errs}
 			}
+		}()
+	}
+
+	// the files handled before an interruption are skipped unless they
+	// changed since
+	var skipped []FolderEvent
+	var pending []string
+	for _, file := range files {
+		if handled[file] && sourceKept(file, fe.Store) {
+			skipped = append(skipped, FolderEvent{File: file, Status: FileSkipped})
+			continue
+		}
+		pending = append(pending, file)
+	}
 
-			for i := range detections {
-				err = store.StoreFace(&FaceItem{
-					Identifier:  file,
-					Network:     extractor.Network,
-					Detection:   detections[i],
-					Landmarks:   landmarks[i],
-					Descriptors: descrs[i],
-				})
-				if err != nil {
-					errs <- errors.Wrapf(err, "error storing face primitives from image %q", file)
-					continue
-				}
+	go func() {
+		defer close(jobs)
+		for _, file := range pending {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case jobs <- file:
+			case <-ctx.Done():
+				return
 			}
 		}
-		done <- true
 	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	send := func(event FolderEvent) {
+		x.report.add(event.File, event.Status)
+		select {
+		case x.events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	for _, event := range skipped {
+		send(event)
+	}
+
+	var checkpointErr error
+	for event := range results {
+		if event.Status != FileFailed && checkpointErr == nil {
+			if checkpointErr = checkpoint.record(event.File); checkpointErr != nil {
+				event.Errs = append(event.Errs, checkpointErr)
+			}
+		}
+		send(event)
+	}
+
+	x.report.sort()
+
+	if err := checkpoint.close(); err != nil && checkpointErr == nil {
+		checkpointErr = err
+	}
+
+	x.err = ctx.Err()
+	if x.err == nil && checkpointErr == nil {
+		if err := checkpoint.remove(); err != nil {
+			x.err = err
+		}
+	}
+}
+
+// updateFile extracts the faces of file if it is new or modified and
+// returns how it was handled
+func updateFile(file string, extractor *Extractor, store FolderStore) (FileStatus, []error) {
+	source, state, err := checkSource(file, store)
+	if err != nil {
+		return FileFailed, []error{err}
+	}
+	if state == sourceUnchanged {
+		return FileUnchanged, nil
+	}
+
+	if state == sourceModified {
+		if err := store.DeleteSource(file); err != nil {
+			return FileFailed, []error{errors.Wrapf(err, "error deleting the faces of modified file %q", file)}
+		}
+	}
+
+	failures := extractFile(file, extractor, store)
+	if len(failures) > 0 {
+		// the faces stored before the failure are deleted, for the file
+		// not to be considered extracted on the next run
+		if err := store.DeleteSource(file); err != nil {
+			failures = append(failures, errors.Wrapf(err, "error deleting the faces of failed file %q", file))
+		}
+		return FileFailed, failures
+	}
+
+	// the source is stored last, for a failed extraction to be tried
+	// again
+	if err := store.StoreSource(source); err != nil {
+		return FileFailed, []error{errors.Wrapf(err, "error storing the source %q", file)}
+	}
+
+	if state == sourceModified {
+		return FileModified, nil
+	}
+	return FileAdded, nil
+}
+
+// checkSource returns the current source of file and whether it changed
+// since it was stored. The content is only hashed when the size or the
+// modification time differ. The files extracted before the sources were
+// stored are considered unchanged.
+func checkSource(file string, store FolderStore) (*Source, sourceState, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error reading file %q", file)
+	}
+
+	known, ok, err := store.GetSource(file)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error reading the source of %q", file)
+	}
+	if ok && known.Size == info.Size() && known.ModTime.Equal(info.ModTime()) {
+		return known, sourceUnchanged, nil
+	}
+
+	hash, err := hashFile(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	source := &Source{
+		Identifier: file,
+		Hash:       hash,
+		Size:       info.Size(),
+		ModTime:    info.ModTime(),
+	}
+
+	if ok {
+		if known.Hash != hash {
+			return source, sourceModified, nil
+		}
+		// touched but not modified
+		if err := store.StoreSource(source); err != nil {
+			return nil, 0, errors.Wrapf(err, "error storing the source %q", file)
+		}
+		return source, sourceUnchanged, nil
+	}
+
+	_, extracted, err := store.GetFaces(file)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "error reading the faces of %q", file)
+	}
+	if extracted {
+		if err := store.StoreSource(source); err != nil {
+			return nil, 0, errors.Wrapf(err, "error storing the source %q", file)
+		}
+		return source, sourceUnchanged, nil
+	}
+
+	return source, sourceAdded, nil
+}
+
+// sourceKept tells if the source of file is stored with its current size
+// and modification time, without hashing its content
+func sourceKept(file string, store SourceStore) bool {
+	info, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+
+	known, ok, err := store.GetSource(file)
+	if err != nil || !ok {
+		return false
+	}
+	return known.Size == info.Size() && known.ModTime.Equal(info.ModTime())
+}
+
+// hashFile returns the hexadecimal SHA-256 of the content of file
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "error opening file %q", file)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "error reading file %q", file)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// extractFile stores the faces of file and returns the errors
+func extractFile(file string, extractor *Extractor, store FaceStore) []error {
+	items, err := faceItems(file, extractor)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, item := range items {
+		err = store.StoreFace(item)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "error storing face primitives from image %q", file))
+		}
+	}
+
+	return errs
+}
+
+// faceItems returns the faces of file, or an empty face if there is
+// none, for the file not to be extracted again
+func faceItems(file string, extractor *Extractor) ([]*FaceItem, error) {
+	img, err := imageutils.FromFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading image from file %q", file)
+	}
+
+	items, err := extractor.ExtractItems(img)
+	if err != nil && err != ErrNoFaceDetected {
+		return nil, errors.Wrapf(err, "error extracting face primitives from image %q", file)
+	}
+
+	if len(items) == 0 {
+		return []*FaceItem{{
+			Identifier: file,
+			Network:    extractor.Network,
+			Model:      extractor.Fingerprint,
+		}}, nil
+	}
+
+	var faces []*FaceItem
+	for i := range items {
+		items[i].Identifier = file
+		faces = append(faces, &items[i])
+	}
+
+	return faces, nil
+}
+
+// CollectGarbage deletes the sources, faces, face distances and
+// predictions of path, or of the files of path, which do not exist
+// anymore, and returns these files.
+func CollectGarbage(path string, store FolderStore) (removed []string, err error) {
+	sources, err := store.GetAllSources()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the sources")
+	}
+	faces, err := store.GetAllFaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the faces")
+	}
+	predicted, err := store.PredictedIdentifiers()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the predicted identifiers")
+	}
+
+	// the files extracted before the sources were stored only have faces,
+	// the files without faces may only have predictions
+	var ids []string
+	seen := map[string]bool{}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, s := range sources {
+		add(s.Identifier)
+	}
+	for _, f := range faces {
+		add(f.Identifier)
+	}
+	for _, id := range predicted {
+		add(id)
+	}
+
+	prefix := strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator)
+	for _, id := range ids {
+		if id != path && !strings.HasPrefix(id, prefix) {
+			continue
+		}
+
+		_, err := os.Stat(id)
+		if err == nil {
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return removed, errors.Wrapf(err, "error checking file %q", id)
+		}
+
+		if err := store.DeleteSource(id); err != nil {
+			return removed, errors.Wrapf(err, "error deleting vanished file %q", id)
+		}
+		removed = append(removed, id)
+	}
 
-	return current, errs, done, total, nil
+	return removed, nil
 }
@@ -0,0 +1,421 @@
+package gildasai_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePNG(t *testing.T, filename string) {
+	f, err := os.Create(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, 400, 200))))
+}
+
+func TestExtractFacesFromFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"known.png", "faces.png", "noface.png"} {
+		writePNG(t, filepath.Join(dir, name))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644))
+
+	store := &gildasaitest.FaceStore{Faces: map[string][]*gildasai.FaceItem{
+		filepath.Join(dir, "known.png"): {{Identifier: filepath.Join(dir, "known.png")}},
+	}}
+
+	// the files are handled in lexical order: faces.png, known.png,
+	// noface.png and notes.txt
+	e := &gildasai.Extractor{
+		Network: "fake",
+		Detector: &gildasaitest.Detector{Detections: [][]gildasai.Detection{
+			twoFaces(),
+			nil,
+		}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+
+	fe := &gildasai.FolderExtractor{Walker: &gildasai.FolderWalker{}, Extractor: e, Store: store, Workers: 1}
+	x, err := fe.Start(context.Background(), dir)
+	require.NoError(t, err)
+	assert.Equal(t, 4, x.Total)
+
+	var seen []string
+	var failures []error
+	for event := range x.Events() {
+		seen = append(seen, filepath.Base(event.File)+" "+event.Status.String())
+		failures = append(failures, event.Errs...)
+	}
+	report, err := x.Wait()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"faces.png added", "known.png unchanged", "noface.png added", "notes.txt failed"}, seen)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, &gildasai.FolderReport{
+		Added:     []string{filepath.Join(dir, "faces.png"), filepath.Join(dir, "noface.png")},
+		Unchanged: []string{filepath.Join(dir, "known.png")},
+		Failed:    []string{filepath.Join(dir, "notes.txt")},
+	}, report)
+	assert.Len(t, store.Sources, 3, "the sources of the known and extracted files are stored")
+
+	faces, ok, err := store.GetFaces(filepath.Join(dir, "faces.png"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, faces, 2)
+	assert.Equal(t, "fake", faces[0].Network)
+	assert.Equal(t, twoFaces()[0], faces[0].Detection)
+
+	noface, ok, err := store.GetFaces(filepath.Join(dir, "noface.png"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, []*gildasai.FaceItem{{
+		ID:         3,
+		Identifier: filepath.Join(dir, "noface.png"),
+		Network:    "fake",
+	}}, noface)
+
+	assert.Len(t, store.Calls("StoreFace"), 3)
+}
+
+func TestExtractFacesFromFolderStoreError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writePNG(t, filepath.Join(dir, "faces.png"))
+
+	store := &gildasaitest.FaceStore{}
+	store.Errors = map[string]error{"StoreFace": errors.New("disk full")}
+	e := &gildasai.Extractor{
+		Detector:   &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+
+	report, failures := extractFolder(t, dir, e, store)
+	assert.Len(t, failures, 2)
+	assert.Equal(t, []string{filepath.Join(dir, "faces.png")}, report.Failed)
+}
+
+// failingStore fails to store the faces after the first one
+type failingStore struct {
+	*gildasaitest.FaceStore
+	stored int
+}
+
+func (s *failingStore) StoreFace(item *gildasai.FaceItem) error {
+	s.stored++
+	if s.stored > 1 {
+		return errors.New("disk full")
+	}
+	return s.FaceStore.StoreFace(item)
+}
+
+func TestExtractFacesFromFolderPartialStoreError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	photo := filepath.Join(dir, "faces.png")
+	writePNG(t, photo)
+
+	store := &gildasaitest.FaceStore{}
+	e := &gildasai.Extractor{
+		Detector:   &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+
+	report, failures := extractFolder(t, dir, e, &failingStore{FaceStore: store})
+	assert.Len(t, failures, 1)
+	assert.Equal(t, []string{photo}, report.Failed)
+	assert.Empty(t, store.Faces[photo], "the partial faces are deleted")
+	assert.NotContains(t, store.Sources, photo)
+
+	report, failures = extractFolder(t, dir, e, store)
+	assert.Empty(t, failures)
+	assert.Equal(t, []string{photo}, report.Added, "the failed file is extracted again")
+	assert.Len(t, store.Faces[photo], 2)
+}
+
+// extractFolder runs a FolderExtractor and returns its report and errors
+func extractFolder(t *testing.T, dir string, e *gildasai.Extractor, store gildasai.FolderStore) (*gildasai.FolderReport, []error) {
+	fe := &gildasai.FolderExtractor{Walker: &gildasai.FolderWalker{}, Extractor: e, Store: store}
+	x, err := fe.Start(context.Background(), dir)
+	require.NoError(t, err)
+
+	var failures []error
+	for event := range x.Events() {
+		failures = append(failures, event.Errs...)
+	}
+
+	report, err := x.Wait()
+	require.NoError(t, err)
+	return report, failures
+}
+
+func TestExtractFacesFromFolderChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	photo, other := filepath.Join(dir, "photo.png"), filepath.Join(dir, "other.png")
+	writePNG(t, photo)
+	writePNG(t, other)
+
+	store := &gildasaitest.FaceStore{}
+	e := &gildasai.Extractor{
+		Network:    "fake",
+		Detector:   &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}},
+		Landmark:   &gildasaitest.Landmark{},
+		Descriptor: &gildasaitest.Descriptor{},
+	}
+
+	report, failures := extractFolder(t, dir, e, store)
+	assert.Empty(t, failures)
+	assert.Equal(t, []string{other, photo}, report.Added)
+	firstFaces := store.Faces[photo]
+	require.Len(t, firstFaces, 2)
+
+	report, failures = extractFolder(t, dir, e, store)
+	assert.Empty(t, failures)
+	assert.Equal(t, &gildasai.FolderReport{Unchanged: []string{other, photo}}, report)
+	assert.Len(t, store.Calls("StoreFace"), 4, "the unchanged files are not extracted again")
+
+	// touched without being modified
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(photo, later, later))
+	report, failures = extractFolder(t, dir, e, store)
+	assert.Empty(t, failures)
+	assert.Equal(t, &gildasai.FolderReport{Unchanged: []string{other, photo}}, report)
+	assert.True(t, store.Sources[photo].ModTime.Equal(later))
+	assert.Len(t, store.Calls("StoreFace"), 4)
+
+	require.NoError(t, ioutil.WriteFile(photo, append(pngBytes(t), 0), 0644))
+	report, failures = extractFolder(t, dir, e, store)
+	assert.Empty(t, failures)
+	assert.Equal(t, &gildasai.FolderReport{
+		Modified:  []string{photo},
+		Unchanged: []string{other},
+	}, report)
+	assert.Len(t, store.Calls("DeleteSource"), 1)
+	require.Len(t, store.Faces[photo], 2)
+	assert.NotEqual(t, firstFaces[0].ID, store.Faces[photo][0].ID, "the faces were extracted again")
+
+	require.NoError(t, os.Remove(photo))
+	removed, err := gildasai.CollectGarbage(dir, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{photo}, removed)
+	assert.NotContains(t, store.Faces, photo)
+	assert.NotContains(t, store.Sources, photo)
+	assert.Contains(t, store.Faces, other)
+}
+
+func TestCollectGarbageOutsideOfFolder(t *testing.T) {
+	store := &gildasaitest.FaceStore{Faces: map[string][]*gildasai.FaceItem{
+		"/somewhere/else/missing.png": {{Identifier: "/somewhere/else/missing.png"}},
+		"/photos/missing.png":         {{Identifier: "/photos/missing.png"}},
+	}}
+
+	removed, err := gildasai.CollectGarbage("/photos", store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/photos/missing.png"}, removed)
+	assert.Contains(t, store.Faces, "/somewhere/else/missing.png")
+}
+
+func TestCollectGarbagePredictionsOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kept, missing := filepath.Join(dir, "kept.png"), filepath.Join(dir, "missing.png")
+	writePNG(t, kept)
+
+	store := memory.NewStore()
+	for _, id := range []string{kept, missing, "/somewhere/else/missing.png"} {
+		require.NoError(t, store.StorePrediction(id, &gildasai.PredictionItem{
+			Identifier:  id,
+			Predictions: gildasai.Predictions{{Network: "xception", Label: "ox", Score: 0.9}},
+		}))
+	}
+
+	removed, err := gildasai.CollectGarbage(dir, store)
+	require.NoError(t, err)
+	assert.Equal(t, []string{missing}, removed)
+
+	ids, err := store.PredictedIdentifiers()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/somewhere/else/missing.png", kept}, ids)
+}
+
+func pngBytes(t *testing.T) []byte {
+	var b bytes.Buffer
+	require.NoError(t, png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 400, 200))))
+	return b.Bytes()
+}
+
+func TestFolderExtractorWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for i := 0; i < 8; i++ {
+		files = append(files, filepath.Join(dir, fmt.Sprintf("%d.png", i)))
+		writePNG(t, files[i])
+	}
+
+	// the detections wait for 4 of them to run at once
+	var mu sync.Mutex
+	running := 0
+	all := make(chan struct{})
+	detector := &gildasaitest.Detector{}
+	detector.Fail = func(method string, n int) error {
+		mu.Lock()
+		running++
+		if running == 4 {
+			close(all)
+		}
+		mu.Unlock()
+
+		select {
+		case <-all:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("the files are not extracted in parallel")
+		}
+	}
+
+	fe := &gildasai.FolderExtractor{
+		Walker: &gildasai.FolderWalker{},
+		Extractor: &gildasai.Extractor{
+			Detector:   detector,
+			Landmark:   &gildasaitest.Landmark{},
+			Descriptor: &gildasaitest.Descriptor{},
+		},
+		Store:   &gildasaitest.FaceStore{},
+		Workers: 4,
+	}
+	x, err := fe.Start(context.Background(), dir)
+	require.NoError(t, err)
+
+	report, err := x.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, &gildasai.FolderReport{Added: files}, report)
+}
+
+func TestFolderExtractorResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for i := 0; i < 6; i++ {
+		files = append(files, filepath.Join(dir, fmt.Sprintf("%d.png", i)))
+		writePNG(t, files[i])
+	}
+
+	detector := &gildasaitest.Detector{}
+	store := &gildasaitest.FaceStore{}
+	fe := &gildasai.FolderExtractor{
+		Walker: &gildasai.FolderWalker{},
+		Extractor: &gildasai.Extractor{
+			Detector:   detector,
+			Landmark:   &gildasaitest.Landmark{},
+			Descriptor: &gildasaitest.Descriptor{},
+		},
+		Store:      store,
+		Workers:    1,
+		Checkpoint: filepath.Join(dir, ".checkpoint"),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	x, err := fe.Start(ctx, dir)
+	require.NoError(t, err)
+	<-x.Events()
+	cancel()
+
+	interrupted, err := x.Wait()
+	assert.Equal(t, context.Canceled, err)
+	require.NotEmpty(t, interrupted.Added)
+	assert.True(t, len(interrupted.Added) < len(files), "the extraction stops with the files being extracted")
+	assert.FileExists(t, fe.Checkpoint)
+
+	// modified since its extraction
+	modified := interrupted.Added[0]
+	require.NoError(t, ioutil.WriteFile(modified, append(pngBytes(t), 0), 0644))
+
+	checked := store.CallCount("GetSource")
+	x, err = fe.Start(context.Background(), dir)
+	require.NoError(t, err)
+	resumed, err := x.Wait()
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, interrupted.Added[1:], resumed.Skipped)
+	assert.Equal(t, []string{modified}, resumed.Modified)
+	assert.ElementsMatch(t, files, append(append(append([]string{}, resumed.Skipped...), resumed.Modified...), resumed.Added...))
+	assert.Equal(t, len(files)+1, store.CallCount("GetSource")-checked, "the skipped files are checked once")
+	assert.Equal(t, len(files)+1, detector.CallCount("Detect"), "each file is extracted once, the modified one twice")
+	_, err = os.Stat(fe.Checkpoint)
+	assert.True(t, os.IsNotExist(err), "the checkpoint is removed once the extraction completes")
+}
+
+func TestFolderExtractorStopWithoutReading(t *testing.T) {
+	dir, err := ioutil.TempDir("", "folder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 10; i++ {
+		writePNG(t, filepath.Join(dir, fmt.Sprintf("%d.png", i)))
+	}
+
+	fe := &gildasai.FolderExtractor{
+		Walker: &gildasai.FolderWalker{},
+		Extractor: &gildasai.Extractor{
+			Detector:   &gildasaitest.Detector{Fake: gildasaitest.Fake{Latency: 10 * time.Millisecond}},
+			Landmark:   &gildasaitest.Landmark{},
+			Descriptor: &gildasaitest.Descriptor{},
+		},
+		Store:   &gildasaitest.FaceStore{},
+		Workers: 1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	x, err := fe.Start(ctx, dir)
+	require.NoError(t, err)
+
+	// the events are not read
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		report, err := x.Wait()
+		assert.Equal(t, context.Canceled, err)
+		assert.True(t, len(report.Added) < 10)
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the extraction did not stop")
+	}
+}
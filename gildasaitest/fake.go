@@ -0,0 +1,81 @@
+// Package gildasaitest provides configurable fakes of the gildasai
+// interfaces: their outputs are scripted, their calls are recorded and
+// they can fail or be slow on demand. TestStore is the conformance suite
+// of the store implementations.
+package gildasaitest
+
+import (
+	"sync"
+	"time"
+)
+
+// Call is a recorded call to a method of a fake
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Fake is embedded by all the fakes
+type Fake struct {
+	// Errors are returned by every call to the method of their name
+	Errors map[string]error
+	// Fail, when set, is called with the method name and the index of
+	// the call to this method, and its error is returned when not nil
+	Fail func(method string, n int) error
+	// Latency is waited by every call
+	Latency time.Duration
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// record records a call and returns its index among the calls to method
+// along with the error to return, if any
+func (f *Fake) record(method string, args ...interface{}) (int, error) {
+	f.mu.Lock()
+	n := 0
+	for _, c := range f.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	f.calls = append(f.calls, Call{Method: method, Args: args})
+	errs, fail, latency := f.Errors, f.Fail, f.Latency
+	f.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	if err, ok := errs[method]; ok && err != nil {
+		return n, err
+	}
+	if fail != nil {
+		if err := fail(method, n); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// Calls returns the calls to method, or all the calls if method is empty
+func (f *Fake) Calls(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []Call
+	for _, c := range f.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// CallCount returns the number of calls to method, or of all the calls if
+// method is empty
+func (f *Fake) CallCount(method string) int {
+	return len(f.Calls(method))
+}
@@ -0,0 +1,122 @@
+package gildasaitest
+
+import (
+	"errors"
+	"image"
+	"testing"
+	"time"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectorScript(t *testing.T) {
+	d := &Detector{Detections: [][]gildasai.Detection{
+		{{Score: 1}},
+		{{Score: 2}},
+	}}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+
+	for _, expected := range []float32{1, 2, 2} {
+		detections, err := d.Detect(img)
+		require.NoError(t, err)
+		assert.Equal(t, expected, detections[0].Score)
+	}
+
+	assert.Equal(t, 3, d.CallCount("Detect"))
+	assert.Equal(t, []interface{}{img}, d.Calls("Detect")[0].Args)
+}
+
+func TestFakeErrors(t *testing.T) {
+	failure := errors.New("failure")
+
+	l := &Landmark{}
+	l.Fail = func(method string, n int) error {
+		if n == 1 {
+			return failure
+		}
+		return nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	landmarks, err := l.Detect(img)
+	require.NoError(t, err)
+	assert.Equal(t, FaceLandmarks, *landmarks)
+	assert.Len(t, landmarks.Coords, 2*68)
+
+	_, err = l.Detect(img)
+	assert.Equal(t, failure, err)
+	_, err = l.Detect(img)
+	assert.NoError(t, err)
+
+	s := &FaceStore{}
+	s.Errors = map[string]error{"StoreFace": failure}
+	assert.Equal(t, failure, s.StoreFace(&gildasai.FaceItem{Identifier: "a"}))
+	_, ok, err := s.GetFaces("a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFakeLatency(t *testing.T) {
+	c := &Classifier{}
+	c.Latency = 20 * time.Millisecond
+
+	start := time.Now()
+	_, err := c.Classify(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= c.Latency)
+}
+
+func TestDescriptorDefault(t *testing.T) {
+	d := &Descriptor{}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+
+	d1, err := d.Compute(img)
+	require.NoError(t, err)
+	d2, err := d.Compute(img)
+	require.NoError(t, err)
+
+	assert.Len(t, d1, 128)
+	distance, err := d1.DistanceTo(d2)
+	require.NoError(t, err)
+	assert.Equal(t, float32(1), distance)
+}
+
+func TestStores(t *testing.T) {
+	faces := &FaceStore{}
+	require.NoError(t, faces.StoreFace(&gildasai.FaceItem{Identifier: "b"}))
+	require.NoError(t, faces.StoreFace(&gildasai.FaceItem{Identifier: "a"}))
+	require.NoError(t, faces.StoreFace(&gildasai.FaceItem{Identifier: "a", Network: "n"}))
+
+	items, ok, err := faces.GetFaces("a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, items, 2)
+
+	all, err := faces.GetAllFaces()
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "b", all[2].Identifier)
+
+	distances := &FaceDistanceStore{}
+	require.NoError(t, distances.StoreFaceDistance(all[0], all[2], 0.5))
+	d, ok, err := distances.GetFaceDistance(all[0], all[2])
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.5), d)
+	_, ok, err = distances.GetFaceDistance(all[1], all[2])
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	predictions := &PredictionStore{Search: []*gildasai.PredictionItem{{Identifier: "x"}}}
+	require.NoError(t, predictions.StorePrediction("y", &gildasai.PredictionItem{Identifier: "y"}))
+	item, ok, err := predictions.GetPrediction("y")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "y", item.Identifier)
+	found, err := predictions.SearchPrediction("cat", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, predictions.Search, found)
+	assert.Equal(t, []interface{}{"cat", "", 10}, predictions.Calls("SearchPrediction")[0].Args)
+}
@@ -0,0 +1,17 @@
+package gildasaitest
+
+import (
+	gildasai "github.com/gildasch/gildas-ai"
+)
+
+var (
+	_ gildasai.Detector           = &Detector{}
+	_ gildasai.Landmark           = &Landmark{}
+	_ gildasai.Descriptor         = &Descriptor{}
+	_ gildasai.Classifier         = &Classifier{}
+	_ gildasai.MaskDetector       = &MaskDetector{}
+	_ gildasai.PredictionStore    = &PredictionStore{}
+	_ gildasai.FaceStore          = &FaceStore{}
+	_ gildasai.FaceDistanceStore  = &FaceDistanceStore{}
+	_ gildasai.FaceNeighbourStore = &FaceNeighbourStore{}
+)
@@ -0,0 +1,80 @@
+package gildasaitest
+
+import (
+	gildasai "github.com/gildasch/gildas-ai"
+)
+
+// FaceLandmarks are the 68 landmarks of a real face, relative to its
+// detection box. They are returned by Landmark when nothing is scripted.
+var FaceLandmarks = gildasai.Landmarks{
+	Coords: []float32{
+		0.042623054, 0.49933276,
+		0.05878957, 0.5952669,
+		0.08569466, 0.6833858,
+		0.12724529, 0.7606633,
+		0.1818431, 0.8499347,
+		0.24745741, 0.9136888,
+		0.30940554, 0.95313835,
+		0.38454503, 0.9982595,
+		0.513531, 1.0121591,
+		0.6429833, 0.9539188,
+		0.7473073, 0.8844672,
+		0.82714605, 0.82072514,
+		0.8914305, 0.7313234,
+		0.9243405, 0.63034034,
+		0.9271319, 0.5325623,
+		0.9256201, 0.4253915,
+		0.9112879, 0.30790403,
+		0.046376243, 0.3809035,
+		0.07394844, 0.34416354,
+		0.116788834, 0.32137054,
+		0.16632016, 0.31397152,
+		0.21358678, 0.3226959,
+		0.40847805, 0.2808878,
+		0.4565229, 0.25242186,
+		0.5230905, 0.23079628,
+		0.6066938, 0.22973013,
+		0.6841315, 0.2454338,
+		0.32519954, 0.39748943,
+		0.3244735, 0.45990664,
+		0.31987455, 0.5123901,
+		0.32122666, 0.56566656,
+		0.30662543, 0.6214479,
+		0.32983726, 0.6243069,
+		0.36725587, 0.62359726,
+		0.41130427, 0.6057981,
+		0.44566816, 0.59171915,
+		0.13693592, 0.43995512,
+		0.15758857, 0.42265582,
+		0.20519389, 0.4100879,
+		0.25911996, 0.42105013,
+		0.21946324, 0.4449932,
+		0.16966568, 0.45142734,
+		0.4640793, 0.3733161,
+		0.5031516, 0.34214935,
+		0.55386484, 0.33491662,
+		0.6075828, 0.34272826,
+		0.56412274, 0.36795574,
+		0.50765425, 0.37458557,
+		0.29181966, 0.78519344,
+		0.29914382, 0.7254351,
+		0.34905833, 0.6860953,
+		0.38662934, 0.68381274,
+		0.4217912, 0.6680737,
+		0.5211706, 0.6857454,
+		0.6095099, 0.72453964,
+		0.55541813, 0.81748855,
+		0.5035857, 0.8662245,
+		0.44260082, 0.88889015,
+		0.39043584, 0.8903161,
+		0.33942842, 0.8616308,
+		0.29765832, 0.78365093,
+		0.35117102, 0.72269136,
+		0.3979019, 0.7108746,
+		0.45688528, 0.70578057,
+		0.5991676, 0.7259787,
+		0.4865557, 0.8208107,
+		0.43391302, 0.84126765,
+		0.3846457, 0.841631,
+	},
+}
@@ -0,0 +1,117 @@
+package gildasaitest
+
+import (
+	"image"
+
+	gildasai "github.com/gildasch/gildas-ai"
+)
+
+// Detector returns Detections[n] on its n-th call, the last ones once all
+// were returned
+type Detector struct {
+	Fake
+	Detections [][]gildasai.Detection
+}
+
+func (d *Detector) Detect(img image.Image) ([]gildasai.Detection, error) {
+	n, err := d.record("Detect", img)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.Detections) == 0 {
+		return nil, nil
+	}
+
+	return d.Detections[last(n, len(d.Detections))], nil
+}
+
+// Landmark returns Landmarks[n] on its n-th call, the last ones once all
+// were returned, and FaceLandmarks when none is scripted
+type Landmark struct {
+	Fake
+	Landmarks []gildasai.Landmarks
+}
+
+func (l *Landmark) Detect(img image.Image) (*gildasai.Landmarks, error) {
+	n, err := l.record("Detect", img)
+	if err != nil {
+		return nil, err
+	}
+
+	landmarks := FaceLandmarks
+	if len(l.Landmarks) > 0 {
+		landmarks = l.Landmarks[last(n, len(l.Landmarks))]
+	}
+	coords := make([]float32, len(landmarks.Coords))
+	copy(coords, landmarks.Coords)
+
+	return &gildasai.Landmarks{Coords: coords}, nil
+}
+
+// Descriptor returns Descriptors[n] on its n-th call, the last ones once
+// all were returned. When none is scripted, it returns descriptors of
+// length 128 whose first value is n, so that the faces are all different.
+type Descriptor struct {
+	Fake
+	Descriptors []gildasai.Descriptors
+}
+
+func (d *Descriptor) Compute(img image.Image) (gildasai.Descriptors, error) {
+	n, err := d.record("Compute", img)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Descriptors) == 0 {
+		descriptors := make(gildasai.Descriptors, 128)
+		descriptors[0] = float32(n)
+		return descriptors, nil
+	}
+
+	return d.Descriptors[last(n, len(d.Descriptors))], nil
+}
+
+// Classifier returns Predictions[n] on its n-th call, the last ones once
+// all were returned
+type Classifier struct {
+	Fake
+	Predictions []gildasai.Predictions
+}
+
+func (c *Classifier) Classify(img image.Image) (gildasai.Predictions, error) {
+	n, err := c.record("Classify", img)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Predictions) == 0 {
+		return nil, nil
+	}
+
+	return c.Predictions[last(n, len(c.Predictions))], nil
+}
+
+// MaskDetector returns Masks[n] on its n-th call, the last ones once all
+// were returned
+type MaskDetector struct {
+	Fake
+	Masks [][]gildasai.Mask
+}
+
+func (m *MaskDetector) Detect(img image.Image) ([]gildasai.Mask, error) {
+	n, err := m.record("Detect", img)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Masks) == 0 {
+		return nil, nil
+	}
+
+	return m.Masks[last(n, len(m.Masks))], nil
+}
+
+func last(n, length int) int {
+	if n >= length {
+		return length - 1
+	}
+	return n
+}
@@ -0,0 +1,351 @@
+package gildasaitest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	gildasai "github.com/gildasch/gildas-ai"
+)
+
+// PredictionStore keeps the predictions in memory. SearchPrediction
+// returns Search, whatever its arguments.
+type PredictionStore struct {
+	Fake
+	Predictions map[string]*gildasai.PredictionItem
+	Search      []*gildasai.PredictionItem
+
+	mu sync.Mutex
+}
+
+func (s *PredictionStore) GetPrediction(id string) (*gildasai.PredictionItem, bool, error) {
+	if _, err := s.record("GetPrediction", id); err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.Predictions[id]
+	return item, ok, nil
+}
+
+func (s *PredictionStore) StorePrediction(id string, item *gildasai.PredictionItem) error {
+	if _, err := s.record("StorePrediction", id, item); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Predictions == nil {
+		s.Predictions = map[string]*gildasai.PredictionItem{}
+	}
+	s.Predictions[id] = item
+	return nil
+}
+
+func (s *PredictionStore) SearchPrediction(query, after string, n int) ([]*gildasai.PredictionItem, error) {
+	if _, err := s.record("SearchPrediction", query, after, n); err != nil {
+		return nil, err
+	}
+
+	return s.Search, nil
+}
+
+// FaceStore keeps the faces, and the sources they come from, in memory,
+// by identifier. The stored faces get consecutive IDs starting at 1.
+type FaceStore struct {
+	Fake
+	Faces   map[string][]*gildasai.FaceItem
+	Sources map[string]*gildasai.Source
+
+	mu     sync.Mutex
+	lastID int64
+}
+
+func (s *FaceStore) StoreFace(item *gildasai.FaceItem) error {
+	if _, err := s.record("StoreFace", item); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Faces == nil {
+		s.Faces = map[string][]*gildasai.FaceItem{}
+	}
+	s.lastID++
+	item.ID = s.lastID
+	s.Faces[item.Identifier] = append(s.Faces[item.Identifier], item)
+	return nil
+}
+
+func (s *FaceStore) GetFace(faceID int64) (*gildasai.FaceItem, bool, error) {
+	if _, err := s.record("GetFace", faceID); err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, items := range s.Faces {
+		for _, item := range items {
+			if item.ID == faceID {
+				return item, true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+func (s *FaceStore) GetFaces(id string) ([]*gildasai.FaceItem, bool, error) {
+	if _, err := s.record("GetFaces", id); err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items, ok := s.Faces[id]
+	return items, ok, nil
+}
+
+// GetAllFaces returns the faces sorted by identifier
+func (s *FaceStore) GetAllFaces() ([]*gildasai.FaceItem, error) {
+	if _, err := s.record("GetAllFaces"); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for id := range s.Faces {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var items []*gildasai.FaceItem
+	for _, id := range ids {
+		items = append(items, s.Faces[id]...)
+	}
+	return items, nil
+}
+
+func (s *FaceStore) GetSource(id string) (*gildasai.Source, bool, error) {
+	if _, err := s.record("GetSource", id); err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source, ok := s.Sources[id]
+	return source, ok, nil
+}
+
+func (s *FaceStore) StoreSource(source *gildasai.Source) error {
+	if _, err := s.record("StoreSource", source); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Sources == nil {
+		s.Sources = map[string]*gildasai.Source{}
+	}
+	s.Sources[source.Identifier] = source
+	return nil
+}
+
+func (s *FaceStore) GetAllSources() ([]*gildasai.Source, error) {
+	if _, err := s.record("GetAllSources"); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sources []*gildasai.Source
+	for _, source := range s.Sources {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Identifier < sources[j].Identifier
+	})
+	return sources, nil
+}
+
+// DeleteSource deletes the source and the faces of id
+func (s *FaceStore) DeleteSource(id string) error {
+	if _, err := s.record("DeleteSource", id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Sources, id)
+	delete(s.Faces, id)
+	return nil
+}
+
+// PredictedIdentifiers returns no identifiers, FaceStore not keeping
+// predictions
+func (s *FaceStore) PredictedIdentifiers() ([]string, error) {
+	if _, err := s.record("PredictedIdentifiers"); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// FaceDistanceStore keeps the distances in memory, by pair of faces, and
+// the IDs of the compared faces
+type FaceDistanceStore struct {
+	Fake
+	Distances map[string]float32
+	Compared  map[int64]bool
+
+	mu     sync.Mutex
+	stored []*gildasai.FaceDistance
+}
+
+// DistanceKey is the key of the distance between item1 and item2 in
+// FaceDistanceStore.Distances
+func DistanceKey(item1, item2 *gildasai.FaceItem) string {
+	return fmt.Sprintf("%d-%d", item1.ID, item2.ID)
+}
+
+func (s *FaceDistanceStore) StoreFaceDistance(item1, item2 *gildasai.FaceItem, distance float32) error {
+	if _, err := s.record("StoreFaceDistance", item1, item2, distance); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Distances == nil {
+		s.Distances = map[string]float32{}
+	}
+	s.Distances[DistanceKey(item1, item2)] = distance
+	s.stored = append(s.stored, &gildasai.FaceDistance{Face1: item1, Face2: item2, Distance: distance})
+	return nil
+}
+
+// StoreFaceDistances stores distances, replacing the ones already
+// stored, and marks the faces of compared
+func (s *FaceDistanceStore) StoreFaceDistances(distances []*gildasai.FaceDistance, compared []int64) error {
+	if _, err := s.record("StoreFaceDistances", distances, compared); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Distances == nil {
+		s.Distances = map[string]float32{}
+	}
+	if s.Compared == nil {
+		s.Compared = map[int64]bool{}
+	}
+	for _, d := range distances {
+		k := DistanceKey(d.Face1, d.Face2)
+		if _, ok := s.Distances[k]; ok {
+			for _, stored := range s.stored {
+				if DistanceKey(stored.Face1, stored.Face2) == k {
+					stored.Distance = d.Distance
+				}
+			}
+		} else {
+			s.stored = append(s.stored, &gildasai.FaceDistance{Face1: d.Face1, Face2: d.Face2, Distance: d.Distance})
+		}
+		s.Distances[k] = d.Distance
+	}
+	for _, faceID := range compared {
+		s.Compared[faceID] = true
+	}
+	return nil
+}
+
+// ComparedFaces returns the sorted keys of Compared
+func (s *FaceDistanceStore) ComparedFaces() ([]int64, error) {
+	if _, err := s.record("ComparedFaces"); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var faceIDs []int64
+	for faceID := range s.Compared {
+		faceIDs = append(faceIDs, faceID)
+	}
+	sort.Slice(faceIDs, func(i, j int) bool { return faceIDs[i] < faceIDs[j] })
+	return faceIDs, nil
+}
+
+func (s *FaceDistanceStore) GetFaceDistance(item1, item2 *gildasai.FaceItem) (float32, bool, error) {
+	if _, err := s.record("GetFaceDistance", item1, item2); err != nil {
+		return 0, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	distance, ok := s.Distances[DistanceKey(item1, item2)]
+	return distance, ok, nil
+}
+
+// GetAllFaceDistances returns the distances stored with StoreFaceDistance
+func (s *FaceDistanceStore) GetAllFaceDistances() ([]*gildasai.FaceDistance, error) {
+	if _, err := s.record("GetAllFaceDistances"); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	distances := append([]*gildasai.FaceDistance(nil), s.stored...)
+	sort.SliceStable(distances, func(i, j int) bool {
+		return distances[i].Distance < distances[j].Distance
+	})
+	return distances, nil
+}
+
+// FaceNeighbourStore keeps the neighbours in memory, by face ID
+type FaceNeighbourStore struct {
+	Fake
+	Neighbours map[int64]*gildasai.FaceNeighbours
+
+	mu sync.Mutex
+}
+
+func (s *FaceNeighbourStore) StoreNeighbours(neighbours []*gildasai.FaceNeighbours) error {
+	if _, err := s.record("StoreNeighbours", neighbours); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Neighbours == nil {
+		s.Neighbours = map[int64]*gildasai.FaceNeighbours{}
+	}
+	for _, n := range neighbours {
+		s.Neighbours[n.Face.ID] = n
+	}
+	return nil
+}
+
+func (s *FaceNeighbourStore) GetNeighbours(faceID int64) (*gildasai.FaceNeighbours, bool, error) {
+	if _, err := s.record("GetNeighbours", faceID); err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.Neighbours[faceID]
+	return n, ok, nil
+}
+
+func (s *FaceNeighbourStore) GetAllNeighbours() ([]*gildasai.FaceNeighbours, error) {
+	if _, err := s.record("GetAllNeighbours"); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*gildasai.FaceNeighbours
+	for _, n := range s.Neighbours {
+		list = append(list, n)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Face.ID < list[j].Face.ID })
+	return list, nil
+}
@@ -0,0 +1,878 @@
+package gildasaitest
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Store is implemented by the stores of predictions, faces, face
+// distances, face neighbours, sources and models
+type Store interface {
+	gildasai.PredictionStore
+	gildasai.FaceStore
+	gildasai.FaceDistanceStore
+	gildasai.FaceNeighbourStore
+	gildasai.SourceStore
+	gildasai.ModelStore
+}
+
+// NewStore returns an empty store and the function releasing it
+type NewStore func(t *testing.T) (s Store, close func())
+
+// TestStore runs the conformance tests every store implementation must
+// pass, each on a new store.
+func TestStore(t *testing.T, newStore NewStore) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s Store)
+	}{
+		{"GetMissingPrediction", testGetMissingPrediction},
+		{"StoreAndGetPrediction", testStoreAndGetPrediction},
+		{"StoreDuplicatePrediction", testStoreDuplicatePrediction},
+		{"SearchPredictionByLabel", testSearchPredictionByLabel},
+		{"SearchPredictionAfter", testSearchPredictionAfter},
+		{"SearchBestPredictions", testSearchBestPredictions},
+		{"StoreAndGetFaces", testStoreAndGetFaces},
+		{"StoreDuplicateFace", testStoreDuplicateFace},
+		{"FacesAreCopied", testFacesAreCopied},
+		{"StoreAndGetFaceDistance", testStoreAndGetFaceDistance},
+		{"StoreFaceDistances", testStoreFaceDistances},
+		{"StoreAndGetNeighbours", testStoreAndGetNeighbours},
+		{"DeleteNeighbours", testDeleteNeighbours},
+		{"StoreAndGetSources", testStoreAndGetSources},
+		{"DeleteSource", testDeleteSource},
+		{"PredictedIdentifiers", testPredictedIdentifiers},
+		{"OutdatedFaces", testOutdatedFaces},
+		{"ReplaceFaces", testReplaceFaces},
+		{"OutdatedPredictions", testOutdatedPredictions},
+		{"ReplacePredictions", testReplacePredictions},
+		{"ConcurrentStores", testConcurrentStores},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, close := newStore(t)
+			defer close()
+			tt.test(t, s)
+		})
+	}
+}
+
+var storeTestPredictions = []*gildasai.PredictionItem{
+	{
+		Identifier: "a wonderful picture of my ox",
+		Predictions: gildasai.Predictions{
+			{Network: "inception", Score: 0.9999, Label: "ox"},
+			{Network: "inception", Score: 0.7658, Label: "wonderful"},
+			{Network: "inception", Score: 0.01233564, Label: "dog"},
+			{Network: "inception", Score: 0.0001, Label: "personal_computer"},
+		},
+	},
+	{
+		Identifier: "a journey to the stars",
+		Predictions: gildasai.Predictions{
+			{Network: "inception", Score: 0.8593, Label: "night"},
+			{Network: "inception", Score: 0.0345, Label: "alps"},
+			{Network: "inception", Score: 0.011, Label: "fork"},
+		},
+	},
+	{
+		Identifier: "my desk",
+		Predictions: gildasai.Predictions{
+			{Network: "inception", Score: 0.769, Label: "tv"},
+			{Network: "inception", Score: 0.4789, Label: "computer_keyboard"},
+			{Network: "inception", Score: 0.1621, Label: "typewriter_keyboard"},
+			{Network: "inception", Score: 0.011, Label: "fork"},
+		},
+	},
+}
+
+func storePredictions(t *testing.T, s Store) {
+	for _, item := range storeTestPredictions {
+		require.NoError(t, s.StorePrediction(item.Identifier, item))
+	}
+}
+
+// byIdentifier sorts items, as the search results are in no particular
+// order
+func byIdentifier(items []*gildasai.PredictionItem) []*gildasai.PredictionItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Identifier < items[j].Identifier
+	})
+	return items
+}
+
+func testGetMissingPrediction(t *testing.T, s Store) {
+	item, ok, err := s.GetPrediction("a trip to the moon")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, item)
+}
+
+func testStoreAndGetPrediction(t *testing.T, s Store) {
+	require.NoError(t, s.StorePrediction("my desk", &gildasai.PredictionItem{
+		Identifier: "my desk",
+		Predictions: gildasai.Predictions{
+			{Network: "inception", Score: 0.1621, Label: "typewriter_keyboard"},
+			{Network: "inception", Score: 0.769, Label: "tv"},
+			{Network: "inception", Score: 0.011, Label: "fork"},
+			{Network: "inception", Score: 0.4789, Label: "computer_keyboard"},
+		},
+	}))
+
+	item, ok, err := s.GetPrediction("my desk")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, storeTestPredictions[2], item, "predictions are by decreasing score")
+
+	item, ok, err = s.GetPrediction("my")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, item)
+}
+
+func testStoreDuplicatePrediction(t *testing.T, s Store) {
+	storePredictions(t, s)
+
+	err := s.StorePrediction("my desk", &gildasai.PredictionItem{
+		Identifier: "my desk",
+		Predictions: gildasai.Predictions{
+			{Network: "inception", Score: 0.5, Label: "lamp"},
+			{Network: "inception", Score: 0.5, Label: "tv"},
+		},
+	})
+	assert.Error(t, err)
+
+	item, ok, err := s.GetPrediction("my desk")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, storeTestPredictions[2], item, "nothing is stored on error")
+
+	err = s.StorePrediction("my desk", &gildasai.PredictionItem{
+		Identifier: "my desk",
+		Predictions: gildasai.Predictions{
+			{Network: "mobilenet", Score: 0.5, Label: "tv"},
+		},
+	})
+	assert.NoError(t, err, "the same label of another network is not a duplicate")
+}
+
+func testSearchPredictionByLabel(t *testing.T, s Store) {
+	storePredictions(t, s)
+
+	items, err := s.SearchPrediction("keyboard", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, storeTestPredictions[2:3], items)
+
+	items, err = s.SearchPrediction("KeyBoard", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, storeTestPredictions[2:3], items, "search is case-insensitive")
+
+	items, err = s.SearchPrediction("fork", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []*gildasai.PredictionItem{
+		storeTestPredictions[1], storeTestPredictions[2],
+	}, byIdentifier(items))
+
+	items, err = s.SearchPrediction("fork", "", 1)
+	require.NoError(t, err)
+	require.Len(t, items, 1, "n limits the matching predictions")
+	assert.Contains(t, storeTestPredictions[1:], items[0])
+
+	items, err = s.SearchPrediction("mouse", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, items, 0)
+}
+
+func testSearchPredictionAfter(t *testing.T, s Store) {
+	storePredictions(t, s)
+
+	items, err := s.SearchPrediction("fork", "a journey to the stars", 10)
+	require.NoError(t, err)
+	assert.Equal(t, storeTestPredictions[2:3], items)
+
+	items, err = s.SearchPrediction("", "a wonderful picture of my ox", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []*gildasai.PredictionItem{{
+		Identifier: "my desk",
+		Predictions: gildasai.Predictions{
+			{Network: "inception", Score: 0.769, Label: "tv"},
+			{Network: "inception", Score: 0.4789, Label: "computer_keyboard"},
+		},
+	}}, items)
+
+	items, err = s.SearchPrediction("", "my desk", 10)
+	require.NoError(t, err)
+	assert.Len(t, items, 0)
+}
+
+func testSearchBestPredictions(t *testing.T, s Store) {
+	storePredictions(t, s)
+
+	items, err := s.SearchPrediction("", "", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []*gildasai.PredictionItem{
+		{
+			Identifier: "a journey to the stars",
+			Predictions: gildasai.Predictions{
+				{Network: "inception", Score: 0.8593, Label: "night"},
+			},
+		},
+		{
+			Identifier: "a wonderful picture of my ox",
+			Predictions: gildasai.Predictions{
+				{Network: "inception", Score: 0.9999, Label: "ox"},
+			},
+		},
+		{
+			Identifier: "my desk",
+			Predictions: gildasai.Predictions{
+				{Network: "inception", Score: 0.769, Label: "tv"},
+			},
+		},
+	}, byIdentifier(items))
+}
+
+var storeTestFaces = []*gildasai.FaceItem{
+	{
+		Identifier: "a wonderful picture of my ox",
+		Network:    "face-api-js",
+		Detection: gildasai.Detection{
+			Box:   image.Rect(0, 0, 30, 45),
+			Score: 0.94,
+		},
+		Landmarks: gildasai.Landmarks{
+			Coords: []float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 78754.43},
+		},
+		Descriptors: gildasai.Descriptors{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 78754.43},
+	},
+	{
+		Identifier: "a wonderful picture of my ox",
+		Network:    "face-api-js",
+		Detection: gildasai.Detection{
+			Box:   image.Rect(100, 100, 130, 145),
+			Score: 0.54,
+		},
+		Landmarks: gildasai.Landmarks{
+			Coords: []float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 78914.43},
+		},
+		Descriptors: gildasai.Descriptors{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 72914.4366},
+		Orientation: 90,
+		Quality:     0.54,
+		Pose:        gildasai.Pose{Roll: -3.5, Yaw: 12.25},
+	},
+	{
+		Identifier: "just of picture of my desk plus Jim",
+		Network:    "face-api-js",
+		Detection: gildasai.Detection{
+			Box:   image.Rect(10, 0, 20, 15),
+			Score: 0.87,
+		},
+		Landmarks: gildasai.Landmarks{
+			Coords: []float32{1, 2, 3, 4, 5, 6.4543, 7, 8, 9, 10, 11, 12, 78914.43},
+		},
+		Descriptors: gildasai.Descriptors{1, 2, 3.33534, 4, 5, 6, 7, 8, 9, 10, 11, 12, 72914.4366},
+	},
+}
+
+// storeFaces stores copies of storeTestFaces and returns them, with
+// their ID set by the store
+func storeFaces(t *testing.T, s Store) []*gildasai.FaceItem {
+	var stored []*gildasai.FaceItem
+	for _, item := range storeTestFaces {
+		c := *item
+		require.NoError(t, s.StoreFace(&c))
+		stored = append(stored, &c)
+	}
+	return stored
+}
+
+func testStoreAndGetFaces(t *testing.T, s Store) {
+	items, ok, err := s.GetFaces("a wonderful picture of my ox")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, items)
+
+	items, err = s.GetAllFaces()
+	require.NoError(t, err)
+	assert.Len(t, items, 0)
+
+	stored := storeFaces(t, s)
+	ids := map[int64]bool{}
+	for _, item := range stored {
+		assert.NotZero(t, item.ID)
+		ids[item.ID] = true
+	}
+	assert.Len(t, ids, len(stored), "the IDs are unique")
+
+	items, ok, err = s.GetFaces("a wonderful picture of my ox")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, stored[:2], items)
+
+	items, err = s.GetAllFaces()
+	require.NoError(t, err)
+	assert.Equal(t, stored, items)
+
+	item, ok, err := s.GetFace(stored[1].ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, stored[1], item)
+
+	item, ok, err = s.GetFace(stored[2].ID + 1000)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, item)
+}
+
+func testStoreDuplicateFace(t *testing.T, s Store) {
+	stored := storeFaces(t, s)
+
+	duplicate := *storeTestFaces[0]
+	duplicate.Descriptors = gildasai.Descriptors{1}
+	assert.Error(t, s.StoreFace(&duplicate))
+
+	otherNetwork := *storeTestFaces[0]
+	otherNetwork.Network = "hog"
+	assert.NoError(t, s.StoreFace(&otherNetwork))
+
+	items, err := s.GetAllFaces()
+	require.NoError(t, err)
+	assert.Equal(t, append(stored, &otherNetwork), items)
+}
+
+func testFacesAreCopied(t *testing.T, s Store) {
+	item := *storeTestFaces[0]
+	item.Descriptors = gildasai.Descriptors{1, 2, 3}
+	require.NoError(t, s.StoreFace(&item))
+	item.Descriptors[0] = 42
+
+	items, _, err := s.GetFaces(item.Identifier)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, gildasai.Descriptors{1, 2, 3}, items[0].Descriptors)
+	items[0].Descriptors[1] = 42
+
+	items, _, err = s.GetFaces(item.Identifier)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, gildasai.Descriptors{1, 2, 3}, items[0].Descriptors)
+}
+
+func testStoreAndGetFaceDistance(t *testing.T, s Store) {
+	stored := storeFaces(t, s)
+	face1, face2, face3 := stored[0], stored[1], stored[2]
+
+	_, ok, err := s.GetFaceDistance(face1, face2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.StoreFaceDistance(face1, face2, 0.42))
+	require.NoError(t, s.StoreFaceDistance(face1, face3, 0.7))
+
+	distance, ok, err := s.GetFaceDistance(face1, face2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.42), distance)
+
+	distance, ok, err = s.GetFaceDistance(face1, face3)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.7), distance)
+
+	_, ok, err = s.GetFaceDistance(face2, face1)
+	require.NoError(t, err)
+	assert.False(t, ok, "distances are stored in one direction")
+
+	assert.Error(t, s.StoreFaceDistance(face1, face2, 0.5))
+
+	notStored := *storeTestFaces[0]
+	notStored.ID = face3.ID + 1000
+	assert.Error(t, s.StoreFaceDistance(face1, &notStored, 0.5), "the faces must be stored")
+
+	require.NoError(t, s.StoreFaceDistance(face3, face2, 0.1))
+	distances, err := s.GetAllFaceDistances()
+	require.NoError(t, err)
+	identity := func(item *gildasai.FaceItem) *gildasai.FaceItem {
+		return &gildasai.FaceItem{
+			ID:         item.ID,
+			Identifier: item.Identifier,
+			Network:    item.Network,
+			Detection:  item.Detection,
+		}
+	}
+	assert.Equal(t, []*gildasai.FaceDistance{
+		{Face1: identity(face3), Face2: identity(face2), Distance: 0.1},
+		{Face1: identity(face1), Face2: identity(face2), Distance: 0.42},
+		{Face1: identity(face1), Face2: identity(face3), Distance: 0.7},
+	}, distances)
+}
+
+func testStoreFaceDistances(t *testing.T, s Store) {
+	stored := storeFaces(t, s)
+	face1, face2, face3 := stored[0], stored[1], stored[2]
+
+	compared, err := s.ComparedFaces()
+	require.NoError(t, err)
+	assert.Empty(t, compared)
+
+	require.NoError(t, s.StoreFaceDistance(face1, face2, 0.42))
+	require.NoError(t, s.StoreFaceDistances([]*gildasai.FaceDistance{
+		{Face1: face1, Face2: face2, Distance: 0.2},
+		{Face1: face1, Face2: face3, Distance: 0.3},
+	}, []int64{face3.ID, face1.ID}))
+
+	distance, ok, err := s.GetFaceDistance(face1, face2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.2), distance, "the stored distances are replaced")
+	distances, err := s.GetAllFaceDistances()
+	require.NoError(t, err)
+	assert.Len(t, distances, 2)
+
+	compared, err = s.ComparedFaces()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{face1.ID, face3.ID}, compared)
+
+	notStored := *storeTestFaces[0]
+	notStored.ID = face3.ID + 1000
+	assert.Error(t, s.StoreFaceDistances([]*gildasai.FaceDistance{
+		{Face1: face2, Face2: face3, Distance: 0.1},
+		{Face1: face1, Face2: &notStored, Distance: 0.1},
+	}, []int64{face2.ID}), "the faces must be stored")
+	assert.Error(t, s.StoreFaceDistances(nil, []int64{notStored.ID}), "the faces must be stored")
+
+	_, ok, err = s.GetFaceDistance(face2, face3)
+	require.NoError(t, err)
+	assert.False(t, ok, "the distances are stored at once")
+	compared, err = s.ComparedFaces()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{face1.ID, face3.ID}, compared)
+
+	require.NoError(t, s.DeleteSource(face1.Identifier))
+	compared, err = s.ComparedFaces()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{face3.ID}, compared, "the deleted faces are not compared anymore")
+}
+
+// identityOf returns the ID, Identifier, Network and Detection of item
+func identityOf(item *gildasai.FaceItem) *gildasai.FaceItem {
+	return &gildasai.FaceItem{
+		ID:         item.ID,
+		Identifier: item.Identifier,
+		Network:    item.Network,
+		Detection:  item.Detection,
+	}
+}
+
+func testStoreAndGetNeighbours(t *testing.T, s Store) {
+	stored := storeFaces(t, s)
+	face1, face2, face3 := stored[0], stored[1], stored[2]
+
+	_, ok, err := s.GetNeighbours(face1.ID)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.StoreNeighbours([]*gildasai.FaceNeighbours{
+		{Face: face1, Neighbours: []gildasai.FaceNeighbour{{Face: face3, Distance: 0.5}}},
+		{Face: face2},
+	}))
+	require.NoError(t, s.StoreNeighbours([]*gildasai.FaceNeighbours{
+		{Face: face1, Neighbours: []gildasai.FaceNeighbour{{Face: face2, Distance: 0.2}, {Face: face3, Distance: 0.3}}},
+	}))
+
+	expected1 := &gildasai.FaceNeighbours{
+		Face: identityOf(face1),
+		Neighbours: []gildasai.FaceNeighbour{
+			{Face: identityOf(face2), Distance: 0.2},
+			{Face: identityOf(face3), Distance: 0.3},
+		},
+	}
+	n, ok, err := s.GetNeighbours(face1.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, expected1, n, "the neighbours are replaced")
+
+	all, err := s.GetAllNeighbours()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, expected1, all[0])
+	assert.Equal(t, identityOf(face2), all[1].Face)
+	assert.Empty(t, all[1].Neighbours)
+
+	notStored := *storeTestFaces[0]
+	notStored.ID = face3.ID + 1000
+	assert.Error(t, s.StoreNeighbours([]*gildasai.FaceNeighbours{
+		{Face: face3},
+		{Face: face2, Neighbours: []gildasai.FaceNeighbour{{Face: &notStored, Distance: 0.1}}},
+	}), "the faces must be stored")
+	_, ok, err = s.GetNeighbours(face3.ID)
+	require.NoError(t, err)
+	assert.False(t, ok, "the neighbours are stored at once")
+}
+
+func testDeleteNeighbours(t *testing.T, s Store) {
+	stored := storeFaces(t, s)
+	face1, face2, face3 := stored[0], stored[1], stored[2]
+	require.Equal(t, face1.Identifier, face2.Identifier)
+
+	require.NoError(t, s.StoreNeighbours([]*gildasai.FaceNeighbours{
+		{Face: face1, Neighbours: []gildasai.FaceNeighbour{{Face: face3, Distance: 0.3}}},
+		{Face: face3, Neighbours: []gildasai.FaceNeighbour{{Face: face2, Distance: 0.2}, {Face: face1, Distance: 0.3}}},
+	}))
+
+	require.NoError(t, s.DeleteSource(face1.Identifier))
+
+	_, ok, err := s.GetNeighbours(face1.ID)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	n, ok, err := s.GetNeighbours(face3.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, n.Neighbours)
+	assert.True(t, n.Stale, "the neighbours of a deleted face are stale")
+
+	require.NoError(t, s.StoreNeighbours([]*gildasai.FaceNeighbours{{Face: face3}}))
+	n, ok, err = s.GetNeighbours(face3.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.False(t, n.Stale)
+}
+
+func testStoreAndGetSources(t *testing.T, s Store) {
+	source, ok, err := s.GetSource("/photos/b.jpg")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, source)
+
+	b := &gildasai.Source{
+		Identifier: "/photos/b.jpg",
+		Hash:       "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Size:       123456,
+		ModTime:    time.Unix(1546300800, 123456789),
+	}
+	a := &gildasai.Source{
+		Identifier: "/photos/a.jpg",
+		Hash:       "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752",
+		Size:       42,
+		ModTime:    time.Unix(1546300801, 0),
+	}
+	require.NoError(t, s.StoreSource(b))
+	require.NoError(t, s.StoreSource(a))
+
+	source, ok, err = s.GetSource("/photos/b.jpg")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assertSource(t, b, source)
+
+	changed := *b
+	changed.Hash = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	changed.Size = 654321
+	changed.ModTime = time.Unix(1546300900, 0)
+	require.NoError(t, s.StoreSource(&changed))
+
+	sources, err := s.GetAllSources()
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assertSource(t, a, sources[0])
+	assertSource(t, &changed, sources[1])
+}
+
+// assertSource compares the modification times with Equal, the stores
+// not keeping their location
+func assertSource(t *testing.T, expected, actual *gildasai.Source) {
+	assert.Equal(t, expected.Identifier, actual.Identifier)
+	assert.Equal(t, expected.Hash, actual.Hash)
+	assert.Equal(t, expected.Size, actual.Size)
+	assert.True(t, expected.ModTime.Equal(actual.ModTime),
+		"expected modification time %v, got %v", expected.ModTime, actual.ModTime)
+}
+
+func testDeleteSource(t *testing.T, s Store) {
+	for _, item := range storeTestPredictions {
+		require.NoError(t, s.StorePrediction(item.Identifier, item))
+	}
+	stored := storeFaces(t, s)
+	ox, desk := stored[0], stored[2]
+	require.NoError(t, s.StoreFaceDistance(ox, desk, 0.3))
+	require.NoError(t, s.StoreFaceDistance(desk, ox, 0.3))
+	require.NoError(t, s.StoreFaceDistance(desk, desk, 0))
+	for _, id := range []string{"a wonderful picture of my ox", desk.Identifier} {
+		require.NoError(t, s.StoreSource(&gildasai.Source{Identifier: id, ModTime: time.Unix(0, 0)}))
+	}
+
+	require.NoError(t, s.DeleteSource("a wonderful picture of my ox"))
+
+	_, ok, err := s.GetSource("a wonderful picture of my ox")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = s.GetPrediction("a wonderful picture of my ox")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = s.GetFaces("a wonderful picture of my ox")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = s.GetFace(ox.ID)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	found, err := s.SearchPrediction("ox", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, found, 0, "the deleted predictions are not indexed anymore")
+
+	distances, err := s.GetAllFaceDistances()
+	require.NoError(t, err)
+	require.Len(t, distances, 1)
+	assert.Equal(t, desk.ID, distances[0].Face1.ID)
+	assert.Equal(t, desk.ID, distances[0].Face2.ID)
+
+	_, ok, err = s.GetSource(desk.Identifier)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = s.GetPrediction("my desk")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	items, err := s.GetAllFaces()
+	require.NoError(t, err)
+	assert.Equal(t, []*gildasai.FaceItem{desk}, items)
+
+	require.NoError(t, s.DeleteSource("an unknown source"))
+
+	// the faces of the deleted source can be extracted again
+	again := *storeTestFaces[0]
+	require.NoError(t, s.StoreFace(&again))
+	assert.NotEqual(t, ox.ID, again.ID, "the IDs are not reused")
+}
+
+func testPredictedIdentifiers(t *testing.T, s Store) {
+	ids, err := s.PredictedIdentifiers()
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	storePredictions(t, s)
+	storeFaces(t, s)
+
+	ids, err = s.PredictedIdentifiers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a journey to the stars", "a wonderful picture of my ox", "my desk"}, ids)
+
+	require.NoError(t, s.DeleteSource("a journey to the stars"))
+
+	ids, err = s.PredictedIdentifiers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a wonderful picture of my ox", "my desk"}, ids)
+}
+
+// storeModelFaces stores faces of "face-api-js" computed by the models
+// v1 and v2, and a face of "hog"
+func storeModelFaces(t *testing.T, s Store) []*gildasai.FaceItem {
+	faces := []*gildasai.FaceItem{
+		{Identifier: "b.jpg", Network: "face-api-js", Model: "face-api-js@v1", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}},
+		{Identifier: "b.jpg", Network: "face-api-js", Model: "face-api-js@v2", Detection: gildasai.Detection{Box: image.Rect(10, 0, 20, 10)}},
+		{Identifier: "a.jpg", Network: "face-api-js", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}},
+		{Identifier: "c.jpg", Network: "face-api-js", Model: "face-api-js@v2", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}},
+		{Identifier: "d.jpg", Network: "hog", Model: "hog@v1", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}},
+	}
+	for _, f := range faces {
+		require.NoError(t, s.StoreFace(f))
+	}
+	return faces
+}
+
+func testOutdatedFaces(t *testing.T, s Store) {
+	ids, err := s.OutdatedFaces("face-api-js", "face-api-js@v2")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	faces := storeModelFaces(t, s)
+
+	item, _, err := s.GetFace(faces[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "face-api-js@v1", item.Model)
+
+	ids, err = s.OutdatedFaces("face-api-js", "face-api-js@v2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg", "b.jpg"}, ids)
+
+	ids, err = s.OutdatedFaces("hog", "hog@v1")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func testReplaceFaces(t *testing.T, s Store) {
+	faces := storeModelFaces(t, s)
+	require.NoError(t, s.StoreFaceDistance(faces[0], faces[3], 0.5))
+	require.NoError(t, s.StoreFaceDistance(faces[3], faces[4], 0.6))
+
+	// a face at the same place as a replaced one
+	items := []*gildasai.FaceItem{
+		{Identifier: "b.jpg", Network: "face-api-js", Model: "face-api-js@v2", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}, Descriptors: gildasai.Descriptors{1}},
+		{Identifier: "b.jpg", Network: "face-api-js", Model: "face-api-js@v2", Detection: gildasai.Detection{Box: image.Rect(30, 0, 40, 10)}, Descriptors: gildasai.Descriptors{2}},
+	}
+	require.NoError(t, s.ReplaceFaces("b.jpg", "face-api-js", items))
+	for _, item := range items {
+		assert.NotZero(t, item.ID)
+		assert.NotEqual(t, faces[0].ID, item.ID)
+		assert.NotEqual(t, faces[1].ID, item.ID)
+	}
+
+	stored, ok, err := s.GetFaces("b.jpg")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, items, stored)
+
+	_, ok, err = s.GetFace(faces[0].ID)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	distances, err := s.GetAllFaceDistances()
+	require.NoError(t, err)
+	require.Len(t, distances, 1, "the distances of the replaced faces are deleted")
+	assert.Equal(t, faces[3].ID, distances[0].Face1.ID)
+
+	ids, err := s.OutdatedFaces("face-api-js", "face-api-js@v2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg"}, ids)
+
+	// the old faces are kept when the new ones cannot be stored
+	twice := []*gildasai.FaceItem{
+		{Identifier: "a.jpg", Network: "face-api-js", Model: "face-api-js@v2", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}},
+		{Identifier: "a.jpg", Network: "face-api-js", Model: "face-api-js@v2", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}},
+	}
+	assert.Error(t, s.ReplaceFaces("a.jpg", "face-api-js", twice))
+	stored, ok, err = s.GetFaces("a.jpg")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []*gildasai.FaceItem{faces[2]}, stored)
+}
+
+func testOutdatedPredictions(t *testing.T, s Store) {
+	require.NoError(t, s.StorePrediction("b.jpg", &gildasai.PredictionItem{
+		Identifier: "b.jpg",
+		Predictions: gildasai.Predictions{
+			{Network: "xception", Model: "xception@v1", Label: "ox", Score: 0.9},
+			{Network: "resnet", Model: "resnet@v1", Label: "ox", Score: 0.8},
+		},
+	}))
+	require.NoError(t, s.StorePrediction("a.jpg", &gildasai.PredictionItem{
+		Identifier: "a.jpg",
+		Predictions: gildasai.Predictions{
+			{Network: "xception", Label: "dog", Score: 0.7},
+		},
+	}))
+	require.NoError(t, s.StorePrediction("c.jpg", &gildasai.PredictionItem{
+		Identifier: "c.jpg",
+		Predictions: gildasai.Predictions{
+			{Network: "xception", Model: "xception@v2", Label: "cat", Score: 0.6},
+		},
+	}))
+
+	item, _, err := s.GetPrediction("b.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "xception@v1", item.Predictions[0].Model)
+
+	found, err := s.SearchPrediction("cat", "", 10)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "xception@v2", found[0].Predictions[0].Model)
+
+	ids, err := s.OutdatedPredictions("xception", "xception@v2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg", "b.jpg"}, ids)
+
+	ids, err = s.OutdatedPredictions("resnet", "resnet@v1")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func testReplacePredictions(t *testing.T, s Store) {
+	require.NoError(t, s.StorePrediction("b.jpg", &gildasai.PredictionItem{
+		Identifier: "b.jpg",
+		Predictions: gildasai.Predictions{
+			{Network: "xception", Model: "xception@v1", Label: "ox", Score: 0.9},
+			{Network: "xception", Model: "xception@v1", Label: "cow", Score: 0.2},
+			{Network: "resnet", Model: "resnet@v1", Label: "ox", Score: 0.8},
+		},
+	}))
+
+	require.NoError(t, s.ReplacePredictions("b.jpg", "xception", gildasai.Predictions{
+		{Network: "xception", Model: "xception@v2", Label: "ox", Score: 0.95},
+		{Network: "xception", Model: "xception@v2", Label: "bull", Score: 0.5},
+	}))
+
+	item, ok, err := s.GetPrediction("b.jpg")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, gildasai.Predictions{
+		{Network: "xception", Model: "xception@v2", Label: "ox", Score: 0.95},
+		{Network: "resnet", Model: "resnet@v1", Label: "ox", Score: 0.8},
+		{Network: "xception", Model: "xception@v2", Label: "bull", Score: 0.5},
+	}, item.Predictions)
+
+	found, err := s.SearchPrediction("cow", "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, found, "the replaced predictions are not indexed anymore")
+
+	// the old predictions are kept when the new ones cannot be stored
+	err = s.ReplacePredictions("b.jpg", "xception", gildasai.Predictions{
+		{Network: "xception", Model: "xception@v3", Label: "ox", Score: 0.9},
+		{Network: "xception", Model: "xception@v3", Label: "ox", Score: 0.9},
+	})
+	assert.Error(t, err)
+	item, _, err = s.GetPrediction("b.jpg")
+	require.NoError(t, err)
+	assert.Len(t, item.Predictions, 3)
+	assert.Equal(t, "xception@v2", item.Predictions[0].Model)
+}
+
+func testConcurrentStores(t *testing.T, s Store) {
+	const n = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			id := fmt.Sprintf("picture %02d", i)
+			face := &gildasai.FaceItem{
+				Identifier: id,
+				Network:    "face-api-js",
+				Detection:  gildasai.Detection{Box: image.Rect(0, 0, i+1, i+1)},
+			}
+			errs <- s.StorePrediction(id, &gildasai.PredictionItem{
+				Identifier:  id,
+				Predictions: gildasai.Predictions{{Network: "inception", Score: 0.5, Label: "ox"}},
+			})
+			errs <- s.StoreFace(face)
+			errs <- s.StoreFaceDistance(face, face, 0)
+
+			_, err := s.GetAllFaces()
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	items, err := s.SearchPrediction("ox", "", n)
+	require.NoError(t, err)
+	assert.Len(t, items, n)
+
+	faces, err := s.GetAllFaces()
+	require.NoError(t, err)
+	assert.Len(t, faces, n)
+}
@@ -0,0 +1,316 @@
+package gildasai
+
+import (
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultNeighbours is the number of nearest neighbours kept per face by
+// a FaceGraph
+const DefaultNeighbours = 10
+
+// neighboursChunk is the number of faces whose neighbours are stored per
+// transaction
+const neighboursChunk = 1000
+
+// NeighboursFromEnv returns the number of neighbours kept per face set by
+// the environment variable FACE_NEIGHBOURS, DefaultNeighbours by default
+func NeighboursFromEnv() (int, error) {
+	v := os.Getenv("FACE_NEIGHBOURS")
+	if v == "" {
+		return DefaultNeighbours, nil
+	}
+
+	k, err := strconv.Atoi(v)
+	if err != nil || k <= 0 {
+		return 0, errors.Errorf("invalid FACE_NEIGHBOURS %q", v)
+	}
+	return k, nil
+}
+
+// FaceGraphStore keeps the faces and their nearest neighbours
+type FaceGraphStore interface {
+	FaceStore
+	FaceNeighbourStore
+}
+
+// FaceGraph keeps in Store the K nearest neighbours, up to MaxDistance,
+// of the comparable faces
+type FaceGraph struct {
+	Store FaceGraphStore
+	// K is DefaultNeighbours when 0
+	K int
+	// MaxDistance is the distance above which the faces are not
+	// neighbours, usually MaxFaceDistance
+	MaxDistance float32
+	// Workers is the number of CPUs when 0
+	Workers int
+}
+
+// Update finds the neighbours of the comparable faces which are not in
+// the graph yet, and finds again those of the stale faces. They become
+// neighbours of the faces of the graph they are nearer to than their
+// K-th neighbour. It returns the number of faces whose neighbours were
+// stored.
+func (g *FaceGraph) Update() (int, error) {
+	k := g.K
+	if k <= 0 {
+		k = DefaultNeighbours
+	}
+
+	faces, err := g.Store.GetAllFaces()
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting the faces")
+	}
+	nodes, err := g.Store.GetAllNeighbours()
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting the face graph")
+	}
+
+	graph := map[int64]*FaceNeighbours{}
+	upToDate := map[int64]bool{}
+	for _, n := range nodes {
+		graph[n.Face.ID] = n
+		upToDate[n.Face.ID] = !n.Stale
+	}
+
+	job := &DistanceJob{MaxDistance: g.MaxDistance, K: k, Workers: g.Workers}
+	changed := map[int64]*FaceNeighbours{}
+	for _, group := range comparableGroups(faces) {
+		m := newDescriptorMatrix(group, upToDate)
+		if len(m.rows) == 0 {
+			continue
+		}
+
+		limits := make([]float32, len(m.faces))
+		for i, face := range m.faces {
+			limits[i] = math.MaxFloat32
+			if n := graph[face.ID]; !m.isNew[i] && len(n.Neighbours) >= k {
+				limits[i] = n.Neighbours[k-1].Distance
+			}
+		}
+
+		var pairs, candidates []pairDistance
+		err := job.compareRows(m, limits, func(r blockResult) error {
+			pairs = append(pairs, r.pairs...)
+			candidates = append(candidates, r.candidates...)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		for _, row := range m.rows {
+			changed[m.faces[row].ID] = &FaceNeighbours{Face: m.faces[row]}
+		}
+		for _, p := range pairs {
+			n := changed[m.faces[p.row].ID]
+			n.Neighbours = append(n.Neighbours, FaceNeighbour{Face: m.faces[p.column], Distance: p.distance})
+		}
+		for _, c := range candidates {
+			face := m.faces[c.column]
+			n, ok := changed[face.ID]
+			if !ok {
+				n = &FaceNeighbours{
+					Face:       face,
+					Neighbours: append([]FaceNeighbour(nil), graph[face.ID].Neighbours...),
+				}
+				changed[face.ID] = n
+			}
+			n.Neighbours = insertNeighbour(n.Neighbours, FaceNeighbour{Face: m.faces[c.row], Distance: c.distance}, k)
+		}
+	}
+
+	var list []*FaceNeighbours
+	for _, n := range changed {
+		list = append(list, n)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Face.ID < list[j].Face.ID })
+
+	for start := 0; start < len(list); start += neighboursChunk {
+		end := start + neighboursChunk
+		if end > len(list) {
+			end = len(list)
+		}
+		if err := g.Store.StoreNeighbours(list[start:end]); err != nil {
+			return start, errors.Wrap(err, "error storing the face neighbours")
+		}
+	}
+
+	return len(list), nil
+}
+
+// insertNeighbour inserts neighbour in neighbours, sorted by increasing
+// distance, replacing its previous distance, and keeps the k nearest
+func insertNeighbour(neighbours []FaceNeighbour, neighbour FaceNeighbour, k int) []FaceNeighbour {
+	var updated []FaceNeighbour
+	for _, n := range neighbours {
+		if n.Face.ID != neighbour.Face.ID {
+			updated = append(updated, n)
+		}
+	}
+
+	i := sort.Search(len(updated), func(i int) bool {
+		return updated[i].Distance > neighbour.Distance
+	})
+	updated = append(updated, FaceNeighbour{})
+	copy(updated[i+1:], updated[i:])
+	updated[i] = neighbour
+
+	if len(updated) > k {
+		updated = updated[:k]
+	}
+	return updated
+}
+
+// FaceIndex keeps the descriptors of the comparable faces in memory, for
+// the faces nearest to a face to be found without reading the store
+type FaceIndex struct {
+	// faces only have their ID, Identifier, Network and Detection set
+	faces       []*FaceItem
+	descriptors []Descriptors
+}
+
+// NewFaceIndex returns the index of the comparable faces of faces
+func NewFaceIndex(faces []*FaceItem) *FaceIndex {
+	x := &FaceIndex{}
+	for _, face := range faces {
+		if !Comparable(face) {
+			continue
+		}
+		x.faces = append(x.faces, &FaceItem{
+			ID:         face.ID,
+			Identifier: face.Identifier,
+			Network:    face.Network,
+			Detection:  face.Detection,
+		})
+		x.descriptors = append(x.descriptors, face.Descriptors)
+	}
+	return x
+}
+
+// Len returns the number of faces of the index
+func (x *FaceIndex) Len() int {
+	return len(x.faces)
+}
+
+// Nearest returns the n faces of the index nearest to descriptors, up to
+// maxDistance, by increasing distance. The faces only have their ID,
+// Identifier, Network and Detection set.
+func (x *FaceIndex) Nearest(descriptors Descriptors, n int, maxDistance float32) []FaceNeighbour {
+	var nearest []FaceNeighbour
+	for i, face := range x.faces {
+		if len(x.descriptors[i]) != len(descriptors) {
+			continue
+		}
+
+		d, err := descriptors.DistanceTo(x.descriptors[i])
+		if err != nil || d > maxDistance {
+			continue
+		}
+		nearest = append(nearest, FaceNeighbour{Face: face, Distance: d})
+	}
+
+	sort.SliceStable(nearest, func(i, j int) bool { return nearest[i].Distance < nearest[j].Distance })
+	if len(nearest) > n {
+		nearest = nearest[:n]
+	}
+	return nearest
+}
+
+// FaceComponent is a connected component of a face graph: faces linked,
+// directly or not, by neighbours up to a threshold
+type FaceComponent struct {
+	// Faces are sorted by ID
+	Faces []*FaceItem
+	// Links are the distances of a minimum spanning tree of the
+	// component, by increasing distance
+	Links []*FaceDistance
+}
+
+// Components returns the connected components of the graph of nodes,
+// whose faces are linked to their neighbours up to threshold, by ID of
+// their first face
+func Components(nodes []*FaceNeighbours, threshold float32) []*FaceComponent {
+	faces := map[int64]*FaceItem{}
+	seen := map[[2]int64]bool{}
+	var links []*FaceDistance
+	for _, n := range nodes {
+		faces[n.Face.ID] = n.Face
+		for _, neighbour := range n.Neighbours {
+			if _, ok := faces[neighbour.Face.ID]; !ok {
+				faces[neighbour.Face.ID] = neighbour.Face
+			}
+			if neighbour.Distance > threshold {
+				continue
+			}
+
+			face1, face2 := n.Face, neighbour.Face
+			if face2.ID < face1.ID {
+				face1, face2 = face2, face1
+			}
+			if seen[[2]int64{face1.ID, face2.ID}] {
+				continue
+			}
+			seen[[2]int64{face1.ID, face2.ID}] = true
+			links = append(links, &FaceDistance{Face1: face1, Face2: face2, Distance: neighbour.Distance})
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool { return links[i].Distance < links[j].Distance })
+
+	parents := map[int64]int64{}
+	var root func(faceID int64) int64
+	root = func(faceID int64) int64 {
+		parent, ok := parents[faceID]
+		if !ok || parent == faceID {
+			return faceID
+		}
+		r := root(parent)
+		parents[faceID] = r
+		return r
+	}
+
+	// the links joining two components make a minimum spanning tree, as
+	// they come by increasing distance
+	var tree []*FaceDistance
+	for _, l := range links {
+		root1, root2 := root(l.Face1.ID), root(l.Face2.ID)
+		if root1 == root2 {
+			continue
+		}
+		if root2 < root1 {
+			root1, root2 = root2, root1
+		}
+		parents[root2] = root1
+		tree = append(tree, l)
+	}
+
+	var faceIDs []int64
+	for faceID := range faces {
+		faceIDs = append(faceIDs, faceID)
+	}
+	sort.Slice(faceIDs, func(i, j int) bool { return faceIDs[i] < faceIDs[j] })
+
+	byRoot := map[int64]*FaceComponent{}
+	var components []*FaceComponent
+	for _, faceID := range faceIDs {
+		r := root(faceID)
+		c, ok := byRoot[r]
+		if !ok {
+			c = &FaceComponent{}
+			byRoot[r] = c
+			components = append(components, c)
+		}
+		c.Faces = append(c.Faces, faces[faceID])
+	}
+	for _, l := range tree {
+		c := byRoot[root(l.Face1.ID)]
+		c.Links = append(c.Links, l)
+	}
+
+	return components
+}
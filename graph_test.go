@@ -0,0 +1,150 @@
+package gildasai_test
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertGraph asserts that the neighbours of each comparable face of
+// store are its k nearest, up to MaxFaceDistance
+func assertGraph(t *testing.T, store *memory.Store, k int) {
+	distances := bruteForceDistances(t, store)
+	nearest := map[int64][]gildasai.FaceNeighbour{}
+	for pair, d := range distances {
+		nearest[pair[0]] = append(nearest[pair[0]], gildasai.FaceNeighbour{Face: &gildasai.FaceItem{ID: pair[1]}, Distance: d})
+		nearest[pair[1]] = append(nearest[pair[1]], gildasai.FaceNeighbour{Face: &gildasai.FaceItem{ID: pair[0]}, Distance: d})
+	}
+
+	faces, err := store.GetAllFaces()
+	require.NoError(t, err)
+	for _, face := range faces {
+		n, ok, err := store.GetNeighbours(face.ID)
+		require.NoError(t, err)
+		if !gildasai.Comparable(face) {
+			assert.False(t, ok)
+			continue
+		}
+		require.True(t, ok, "face %d", face.ID)
+		assert.False(t, n.Stale)
+
+		expected := nearest[face.ID]
+		sort.Slice(expected, func(i, j int) bool { return expected[i].Distance < expected[j].Distance })
+		if len(expected) > k {
+			expected = expected[:k]
+		}
+		require.Len(t, n.Neighbours, len(expected), "face %d", face.ID)
+		for i, neighbour := range n.Neighbours {
+			assert.Equal(t, expected[i].Face.ID, neighbour.Face.ID, "face %d", face.ID)
+			assert.InDelta(t, expected[i].Distance, neighbour.Distance, 1e-6)
+		}
+	}
+}
+
+func TestFaceGraphUpdate(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	store := memory.NewStore()
+	storeRandomFaces(t, store, r, 40)
+
+	graph := &gildasai.FaceGraph{Store: store, K: 4, MaxDistance: gildasai.MaxFaceDistance, Workers: 2}
+	updated, err := graph.Update()
+	require.NoError(t, err)
+	assert.Equal(t, 40, updated)
+	assertGraph(t, store, 4)
+
+	updated, err = graph.Update()
+	require.NoError(t, err)
+	assert.Equal(t, 0, updated, "the graph is up to date")
+
+	storeRandomFaces(t, store, r, 15)
+	updated, err = graph.Update()
+	require.NoError(t, err)
+	assert.True(t, updated >= 15)
+	assertGraph(t, store, 4)
+
+	faces, err := store.GetAllFaces()
+	require.NoError(t, err)
+	for _, face := range faces[:10] {
+		require.NoError(t, store.DeleteSource(face.Identifier))
+	}
+	_, err = graph.Update()
+	require.NoError(t, err)
+	assertGraph(t, store, 4)
+}
+
+func TestFaceIndexNearest(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	store := memory.NewStore()
+	storeRandomFaces(t, store, r, 30)
+	require.NoError(t, store.StoreFace(&gildasai.FaceItem{
+		Identifier:  "blurry.jpg",
+		Network:     "fake",
+		Detection:   gildasai.Detection{Score: 0.5},
+		Descriptors: make(gildasai.Descriptors, 16),
+	}))
+
+	faces, err := store.GetAllFaces()
+	require.NoError(t, err)
+	index := gildasai.NewFaceIndex(faces)
+	assert.Equal(t, 30, index.Len(), "the faces which are not comparable are not indexed")
+
+	searched := faces[0].Descriptors
+	var expected []gildasai.FaceNeighbour
+	for _, face := range faces {
+		if !gildasai.Comparable(face) {
+			continue
+		}
+		d, err := searched.DistanceTo(face.Descriptors)
+		require.NoError(t, err)
+		expected = append(expected, gildasai.FaceNeighbour{Face: face, Distance: d})
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i].Distance < expected[j].Distance })
+
+	nearest := index.Nearest(searched, 5, 10)
+	require.Len(t, nearest, 5)
+	for i, n := range nearest {
+		assert.Equal(t, expected[i].Face.ID, n.Face.ID)
+		assert.Equal(t, expected[i].Face.Identifier, n.Face.Identifier)
+		assert.Equal(t, expected[i].Distance, n.Distance)
+		assert.Empty(t, n.Face.Descriptors, "the descriptors stay in the index")
+	}
+
+	nearest = index.Nearest(searched, 5, 0)
+	require.Len(t, nearest, 1, "the faces further than the max distance are skipped")
+	assert.Equal(t, faces[0].ID, nearest[0].Face.ID)
+}
+
+func TestComponents(t *testing.T) {
+	face := func(id int64) *gildasai.FaceItem { return &gildasai.FaceItem{ID: id} }
+	neighbour := func(id int64, d float32) gildasai.FaceNeighbour {
+		return gildasai.FaceNeighbour{Face: face(id), Distance: d}
+	}
+
+	components := gildasai.Components([]*gildasai.FaceNeighbours{
+		{Face: face(1), Neighbours: []gildasai.FaceNeighbour{neighbour(3, 0.1), neighbour(2, 0.3)}},
+		{Face: face(2), Neighbours: []gildasai.FaceNeighbour{neighbour(3, 0.2), neighbour(1, 0.3)}},
+		{Face: face(3), Neighbours: []gildasai.FaceNeighbour{neighbour(1, 0.1), neighbour(4, 0.5)}},
+		{Face: face(4), Neighbours: []gildasai.FaceNeighbour{neighbour(5, 0.35)}},
+	}, 0.35)
+	require.Len(t, components, 2)
+
+	ids := func(faces []*gildasai.FaceItem) []int64 {
+		var ids []int64
+		for _, f := range faces {
+			ids = append(ids, f.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids(components[0].Faces))
+	require.Len(t, components[0].Links, 2, "the links make a spanning tree")
+	assert.Equal(t, float32(0.1), components[0].Links[0].Distance)
+	assert.Equal(t, float32(0.2), components[0].Links[1].Distance)
+
+	assert.Equal(t, []int64{4, 5}, ids(components[1].Faces), "the neighbours out of the graph are linked")
+	require.Len(t, components[1].Links, 1)
+}
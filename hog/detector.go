@@ -0,0 +1,117 @@
+// Package hog is a face detector using histograms of oriented gradients
+// and a linear classifier. It is slower and less accurate than the
+// tensorflow detector of faceapi but has no dependency outside of Go.
+package hog
+
+//go:generate go run ../cmd/hogtrain -out weights.go
+
+import (
+	"image"
+	"math"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/pkg/errors"
+)
+
+type Detector struct {
+	Weights []float32
+	Bias    float32
+	// MinSize is the width, in pixels, of the smallest face detected
+	MinSize int
+	// ScaleFactor is the ratio between two successive sizes of window
+	ScaleFactor float64
+	// Threshold is the minimum classifier margin of a detection
+	Threshold float32
+	// Overlap is the intersection over union above which two detections
+	// are merged
+	Overlap float64
+}
+
+// NewDetector returns a detector using the bundled weights
+func NewDetector() *Detector {
+	return &Detector{
+		Weights:     defaultWeights,
+		Bias:        defaultBias,
+		MinSize:     WindowWidth,
+		ScaleFactor: 1.2,
+		Threshold:   0,
+		Overlap:     0.3,
+	}
+}
+
+func (d *Detector) Detect(img image.Image) ([]gildasai.Detection, error) {
+	if len(d.Weights) != FeaturesLength {
+		return nil, errors.Errorf("expected %d weights, got %d", FeaturesLength, len(d.Weights))
+	}
+	if d.ScaleFactor <= 1 {
+		return nil, errors.Errorf("scale factor must be greater than 1, got %f", d.ScaleFactor)
+	}
+
+	origin := img.Bounds().Min
+	full := toGray(img, img.Bounds())
+
+	scale := float64(WindowWidth) / float64(d.MinSize)
+	level := full.resample(0, 0, float64(full.w), float64(full.h),
+		int(float64(full.w)*scale), int(float64(full.h)*scale))
+
+	var candidates []gildasai.Detection
+	for level.w >= WindowWidth && level.h >= WindowHeight {
+		sx := float64(full.w) / float64(level.w)
+		sy := float64(full.h) / float64(level.h)
+
+		g := computeGrid(level)
+		for y := 0; y+windowBlocksY <= g.h; y++ {
+			for x := 0; x+windowBlocksX <= g.w; x++ {
+				margin := g.score(d.Weights, x, y) + d.Bias
+				if margin < d.Threshold {
+					continue
+				}
+
+				candidates = append(candidates, gildasai.Detection{
+					Box: image.Rect(
+						int(math.Round(float64(x*cellSize)*sx)),
+						int(math.Round(float64(y*cellSize)*sy)),
+						int(math.Round(float64(x*cellSize+WindowWidth)*sx)),
+						int(math.Round(float64(y*cellSize+WindowHeight)*sy))).Add(origin),
+					Score: score(margin),
+					Class: 1,
+				})
+			}
+		}
+
+		level = level.resample(0, 0, float64(level.w), float64(level.h),
+			int(float64(level.w)/d.ScaleFactor), int(float64(level.h)/d.ScaleFactor))
+	}
+
+	return gildasai.MergeDetections(candidates, d.Overlap), nil
+}
+
+// WindowFeatures returns the descriptor of the window covering box in img,
+// as seen by the detector. It is used to train the classifier.
+func WindowFeatures(img image.Image, box image.Rectangle) []float32 {
+	// one cell of context around the window so that the gradients on the
+	// border are computed as they are on a whole image
+	cx := float64(box.Dx()) / WindowWidth * cellSize
+	cy := float64(box.Dy()) / WindowHeight * cellSize
+
+	r := image.Rect(
+		box.Min.X-int(math.Ceil(cx)), box.Min.Y-int(math.Ceil(cy)),
+		box.Max.X+int(math.Ceil(cx)), box.Max.Y+int(math.Ceil(cy))).Intersect(img.Bounds())
+	if r.Empty() {
+		return make([]float32, FeaturesLength)
+	}
+
+	g := toGray(img, r).resample(
+		float64(box.Min.X-r.Min.X)-cx, float64(box.Min.Y-r.Min.Y)-cy,
+		float64(box.Max.X-r.Min.X)+cx, float64(box.Max.Y-r.Min.Y)+cy,
+		WindowWidth+2*cellSize, WindowHeight+2*cellSize)
+
+	return computeGrid(g).window(1, 1)
+}
+
+// score maps the classifier margin to (0, 1). Margins of the faces are
+// mostly above 0.2, which becomes 0.6, the usual detection threshold used
+// with the tensorflow detector.
+func score(margin float32) float32 {
+	return float32(1 / (1 + math.Exp(-2*float64(margin))))
+}
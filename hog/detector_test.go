@@ -0,0 +1,73 @@
+package hog
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tensorflowDetections are the faces found by the faceapi detector on
+// testdata/group.jpg, which is not part of the training pictures
+var tensorflowDetections = []image.Rectangle{
+	image.Rect(1462, 247, 1747, 591),
+	image.Rect(3250, 355, 3525, 700),
+	image.Rect(766, 436, 1155, 909),
+	image.Rect(2003, 398, 2234, 699),
+	image.Rect(3715, 484, 4191, 933),
+}
+
+func TestDetectCompareToTensorflow(t *testing.T) {
+	img, err := imageutils.FromFile("../testdata/group.jpg")
+	require.NoError(t, err)
+
+	detections, err := NewDetector().Detect(img)
+	require.NoError(t, err)
+
+	found := 0
+	for _, expected := range tensorflowDetections {
+		for _, d := range gildasai.Above(detections, 0.6) {
+			if gildasai.IntersectionOverUnion(expected, d.Box) > 0.5 {
+				found++
+				break
+			}
+		}
+	}
+
+	// the last face has a beanie, which the tensorflow detector includes
+	// in the box
+	assert.True(t, found >= 4, "found %d faces out of %d", found, len(tensorflowDetections))
+	assert.True(t, len(gildasai.Above(detections, 0.6)) <= 2*len(tensorflowDetections),
+		"too many detections: %d", len(gildasai.Above(detections, 0.6)))
+}
+
+func TestWindowFeaturesLikeDetector(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 160, 160))
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		x, y := r.Intn(160), r.Intn(160)
+		img.Set(x, y, color.RGBA{R: uint8(r.Intn(256)), G: uint8(r.Intn(256)), A: 255})
+	}
+
+	g := computeGrid(toGray(img, img.Bounds()))
+
+	// the window of top-left block (3, 4) is at pixels (24, 32)
+	expected := g.window(3, 4)
+	actual := WindowFeatures(img, image.Rect(24, 32, 24+WindowWidth, 32+WindowHeight))
+
+	require.Len(t, actual, FeaturesLength)
+	assert.InDeltaSlice(t, expected, actual, 1e-5)
+}
+
+func TestDetectWrongWeights(t *testing.T) {
+	d := NewDetector()
+	d.Weights = d.Weights[1:]
+
+	_, err := d.Detect(image.NewRGBA(image.Rect(0, 0, 100, 100)))
+	assert.Error(t, err)
+}
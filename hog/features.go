@@ -0,0 +1,245 @@
+package hog
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	cellSize    = 8
+	bins        = 9
+	blockCells  = 2
+	blockLength = blockCells * blockCells * bins
+
+	// WindowWidth and WindowHeight are the size, in pixels, of the window
+	// the classifier is applied on. Their ratio is the one of the boxes of
+	// the tensorflow face detector.
+	WindowWidth  = 64
+	WindowHeight = 80
+
+	windowBlocksX = WindowWidth/cellSize - blockCells + 1
+	windowBlocksY = WindowHeight/cellSize - blockCells + 1
+
+	// FeaturesLength is the length of the descriptor of one window
+	FeaturesLength = windowBlocksX * windowBlocksY * blockLength
+)
+
+// gray is a grayscale image with float values between 0 and 1
+type gray struct {
+	w, h int
+	pix  []float32
+}
+
+// toGray converts the part r of img, which must be inside of its bounds
+func toGray(img image.Image, r image.Rectangle) *gray {
+	g := &gray{w: r.Dx(), h: r.Dy(), pix: make([]float32, r.Dx()*r.Dy())}
+
+	switch img := img.(type) {
+	case *image.RGBA:
+		for y := 0; y < g.h; y++ {
+			row := img.Pix[img.PixOffset(r.Min.X, r.Min.Y+y):]
+			for x := 0; x < g.w; x++ {
+				g.pix[y*g.w+x] = luminance(row[4*x], row[4*x+1], row[4*x+2])
+			}
+		}
+	case *image.NRGBA:
+		for y := 0; y < g.h; y++ {
+			row := img.Pix[img.PixOffset(r.Min.X, r.Min.Y+y):]
+			for x := 0; x < g.w; x++ {
+				g.pix[y*g.w+x] = luminance(row[4*x], row[4*x+1], row[4*x+2])
+			}
+		}
+	case *image.YCbCr:
+		for y := 0; y < g.h; y++ {
+			row := img.Y[img.YOffset(r.Min.X, r.Min.Y+y):]
+			for x := 0; x < g.w; x++ {
+				g.pix[y*g.w+x] = float32(row[x]) / 255
+			}
+		}
+	default:
+		for y := 0; y < g.h; y++ {
+			for x := 0; x < g.w; x++ {
+				c := color.GrayModel.Convert(img.At(r.Min.X+x, r.Min.Y+y)).(color.Gray)
+				g.pix[y*g.w+x] = float32(c.Y) / 255
+			}
+		}
+	}
+
+	return g
+}
+
+func luminance(r, g, b uint8) float32 {
+	return (0.299*float32(r) + 0.587*float32(g) + 0.114*float32(b)) / 255
+}
+
+// grid holds the normalized blocks descriptors of a whole image
+type grid struct {
+	w, h   int // in blocks
+	blocks []float32
+}
+
+func (g *grid) block(x, y int) []float32 {
+	i := (y*g.w + x) * blockLength
+	return g.blocks[i : i+blockLength]
+}
+
+// window returns the descriptor of the window whose top-left block is at
+// (x, y).
+func (g *grid) window(x, y int) []float32 {
+	out := make([]float32, 0, FeaturesLength)
+	for by := 0; by < windowBlocksY; by++ {
+		for bx := 0; bx < windowBlocksX; bx++ {
+			out = append(out, g.block(x+bx, y+by)...)
+		}
+	}
+	return out
+}
+
+// score is the dot product of weights with the descriptor of the window
+// whose top-left block is at (x, y).
+func (g *grid) score(weights []float32, x, y int) float32 {
+	var sum float32
+	i := 0
+	for by := 0; by < windowBlocksY; by++ {
+		for bx := 0; bx < windowBlocksX; bx++ {
+			for _, v := range g.block(x+bx, y+by) {
+				sum += v * weights[i]
+				i++
+			}
+		}
+	}
+	return sum
+}
+
+// computeGrid computes the histograms of oriented gradients of every cell
+// of img, then normalizes them by overlapping blocks of 2x2 cells.
+func computeGrid(img *gray) *grid {
+	cw, ch := img.w/cellSize, img.h/cellSize
+	if cw < blockCells || ch < blockCells {
+		return &grid{}
+	}
+
+	cells := make([]float32, cw*ch*bins)
+	for y := 1; y < ch*cellSize-1 && y < img.h-1; y++ {
+		for x := 1; x < cw*cellSize-1 && x < img.w-1; x++ {
+			dx := img.pix[y*img.w+x+1] - img.pix[y*img.w+x-1]
+			dy := img.pix[(y+1)*img.w+x] - img.pix[(y-1)*img.w+x]
+
+			magnitude := float32(math.Sqrt(float64(dx*dx + dy*dy)))
+			if magnitude == 0 {
+				continue
+			}
+
+			// unsigned orientation in [0, bins)
+			angle := math.Atan2(float64(dy), float64(dx))
+			if angle < 0 {
+				angle += math.Pi
+			}
+			pos := angle / math.Pi * bins
+			b0 := int(pos) % bins
+			b1 := (b0 + 1) % bins
+			f := float32(pos - math.Floor(pos))
+
+			cell := ((y/cellSize)*cw + x/cellSize) * bins
+			cells[cell+b0] += magnitude * (1 - f)
+			cells[cell+b1] += magnitude * f
+		}
+	}
+
+	g := &grid{w: cw - blockCells + 1, h: ch - blockCells + 1}
+	g.blocks = make([]float32, g.w*g.h*blockLength)
+	for by := 0; by < g.h; by++ {
+		for bx := 0; bx < g.w; bx++ {
+			block := g.block(bx, by)
+			i := 0
+			for cy := by; cy < by+blockCells; cy++ {
+				for cx := bx; cx < bx+blockCells; cx++ {
+					copy(block[i:i+bins], cells[(cy*cw+cx)*bins:])
+					i += bins
+				}
+			}
+			normalize(block)
+		}
+	}
+
+	return g
+}
+
+// normalize applies the L2-Hys normalization to block
+func normalize(block []float32) {
+	const eps, clip = 1e-3, 0.2
+
+	l2 := func() float32 {
+		var sum float32
+		for _, v := range block {
+			sum += v * v
+		}
+		return float32(math.Sqrt(float64(sum + eps*eps)))
+	}
+
+	norm := l2()
+	for i := range block {
+		block[i] /= norm
+		if block[i] > clip {
+			block[i] = clip
+		}
+	}
+
+	norm = l2()
+	for i := range block {
+		block[i] /= norm
+	}
+}
+
+// resample returns the w x h image covering the rectangle (x0, y0)-(x1, y1)
+// of g, each pixel being the average of the pixels it covers. Pixels out
+// of g are replaced by the nearest ones.
+func (g *gray) resample(x0, y0, x1, y1 float64, w, h int) *gray {
+	out := &gray{w: w, h: h, pix: make([]float32, w*h)}
+	if w <= 0 || h <= 0 {
+		return out
+	}
+
+	clamp := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= max {
+			return max - 1
+		}
+		return v
+	}
+
+	// source ranges of every column and row of out
+	ranges := func(from, to float64, n int) [][2]int {
+		r := make([][2]int, n)
+		step := (to - from) / float64(n)
+		for i := range r {
+			start := int(math.Floor(from + float64(i)*step))
+			end := int(math.Floor(from + float64(i+1)*step))
+			if end <= start {
+				end = start + 1
+			}
+			r[i] = [2]int{start, end}
+		}
+		return r
+	}
+	cols := ranges(x0, x1, w)
+	rows := ranges(y0, y1, h)
+
+	for y, rr := range rows {
+		for x, cr := range cols {
+			var sum float32
+			for sy := rr[0]; sy < rr[1]; sy++ {
+				line := g.pix[clamp(sy, g.h)*g.w:]
+				for sx := cr[0]; sx < cr[1]; sx++ {
+					sum += line[clamp(sx, g.w)]
+				}
+			}
+			out.pix[y*w+x] = sum / float32((rr[1]-rr[0])*(cr[1]-cr[0]))
+		}
+	}
+
+	return out
+}
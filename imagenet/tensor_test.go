@@ -0,0 +1,49 @@
+package imagenet
+
+import (
+	"image"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyFakeModel(t *testing.T) {
+	model := &backend.FakeModel{Outputs: map[string]*backend.Tensor{
+		"predictions": backend.NewFloat32([]int64{1, 3}, []float32{0.1, 0.7, 0.2}),
+	}}
+	m := &Model{
+		model:           model,
+		ID:              "fake",
+		InputLayer:      "input",
+		OutputLayer:     "predictions",
+		ImageMode:       ImageModeCaffe,
+		Labels:          DefaultLabels,
+		ImageHeight:     8,
+		ImageWidth:      6,
+		IndexCorrection: 1,
+	}
+
+	preds, err := m.Classify(image.NewRGBA(image.Rect(0, 0, 30, 20)))
+	require.NoError(t, err)
+
+	require.Len(t, model.Inputs, 1)
+	input := model.Inputs[0]["input"]
+	assert.Equal(t, []int64{1, 8, 6, 3}, input.Shape)
+	assert.InDelta(t, -103.939, input.Float32[0], 1e-4)
+
+	assert.Equal(t, gildasai.Predictions{
+		{Network: "fake", Score: 0.1, Label: "goldfish"},
+		{Network: "fake", Score: 0.7, Label: "great_white_shark"},
+		{Network: "fake", Score: 0.2, Label: "tiger_shark"},
+	}, preds)
+}
+
+func TestClassifyFakeModelUnknownImageMode(t *testing.T) {
+	m := &Model{model: &backend.FakeModel{}, ImageMode: "other", ImageHeight: 8, ImageWidth: 8}
+
+	_, err := m.Classify(image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	assert.Error(t, err)
+}
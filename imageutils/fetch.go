@@ -0,0 +1,336 @@
+package imageutils
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultFetchTimeout = 20 * time.Second
+	DefaultMaxBytes     = 32 << 20
+	// DefaultMaxPixels is about 7000x7000 pixels
+	DefaultMaxPixels    = 50000000
+	DefaultMaxRedirects = 3
+)
+
+var (
+	ErrTooLarge         = errors.New("image too large")
+	ErrTooManyPixels    = errors.New("image with too many pixels")
+	ErrForbiddenAddress = errors.New("forbidden address")
+)
+
+// Fetcher fetches remote images within limits
+type Fetcher struct {
+	// Timeout is the maximum duration of a fetch, redirects and body
+	// included. 0 means no limit.
+	Timeout time.Duration
+	// MaxBytes is the maximum size of an image. 0 means no limit.
+	MaxBytes int64
+	// MaxPixels is the maximum width times height of an image, or of all
+	// the frames of a GIF together. 0 means no limit.
+	MaxPixels int
+	// MaxRedirects is the number of redirects followed
+	MaxRedirects int
+	// AllowPrivate allows fetching from loopback, private and link-local
+	// addresses
+	AllowPrivate bool
+}
+
+// DefaultFetcher fetches images from public addresses only, within the
+// default limits
+func DefaultFetcher() *Fetcher {
+	return &Fetcher{
+		Timeout:      DefaultFetchTimeout,
+		MaxBytes:     DefaultMaxBytes,
+		MaxPixels:    DefaultMaxPixels,
+		MaxRedirects: DefaultMaxRedirects,
+	}
+}
+
+// RemoteFetcher is the Fetcher of FromURL and GIFFromURL
+var RemoteFetcher = DefaultFetcher()
+
+// Image fetches and decodes the image at rawurl
+func (f *Fetcher) Image(rawurl string) (image.Image, error) {
+	data, err := f.fetch(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Decode(data)
+}
+
+// GIF fetches and decodes all the frames of the GIF at rawurl
+func (f *Fetcher) GIF(rawurl string) (*gif.GIF, error) {
+	data, err := f.fetch(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.DecodeGIF(data)
+}
+
+// Decode decodes the image of data, up to MaxPixels
+func (f *Fetcher) Decode(data []byte) (image.Image, error) {
+	if err := f.checkPixels(data); err != nil {
+		return nil, err
+	}
+
+	img, _, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode image")
+	}
+
+	return img, nil
+}
+
+// DecodeGIF decodes all the frames of the GIF of data, up to MaxPixels
+// for all the frames
+func (f *Fetcher) DecodeGIF(data []byte) (*gif.GIF, error) {
+	if err := f.checkPixels(data); err != nil {
+		return nil, err
+	}
+	if err := f.checkFramePixels(data); err != nil {
+		return nil, err
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode image")
+	}
+
+	return g, nil
+}
+
+// checkPixels reads the dimensions of the image of data before it is
+// decoded
+func (f *Fetcher) checkPixels(data []byte) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode image")
+	}
+
+	if f.MaxPixels > 0 && config.Width*config.Height > f.MaxPixels {
+		return errors.Wrapf(ErrTooManyPixels, "image of %dx%d pixels", config.Width, config.Height)
+	}
+	return nil
+}
+
+// checkFramePixels adds up the pixels of the frames of the GIF of data,
+// read from their descriptors before they are decoded
+func (f *Fetcher) checkFramePixels(data []byte) error {
+	if f.MaxPixels <= 0 {
+		return nil
+	}
+
+	errInvalid := errors.New("failed to decode image: invalid gif")
+
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return errInvalid
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipBlocks skips the data sub-blocks from i
+	skipBlocks := func() bool {
+		for i < len(data) {
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	pixels := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension
+			i += 2
+			if !skipBlocks() {
+				return errInvalid
+			}
+		case 0x2c: // image descriptor
+			if i+10 > len(data) {
+				return errInvalid
+			}
+			width := int(data[i+5]) | int(data[i+6])<<8
+			height := int(data[i+7]) | int(data[i+8])<<8
+			pixels += width * height
+			if pixels > f.MaxPixels {
+				return errors.Wrapf(ErrTooManyPixels, "gif of more than %d pixels", f.MaxPixels)
+			}
+
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&0x07 + 1)
+			}
+			i++ // LZW minimum code size
+			if !skipBlocks() {
+				return errInvalid
+			}
+		case 0x3b: // trailer
+			return nil
+		default:
+			return errInvalid
+		}
+	}
+
+	return nil
+}
+
+func (f *Fetcher) fetch(rawurl string) ([]byte, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid url %q", rawurl)
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client().Get(u.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %q", rawurl)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to get %q: %s", rawurl, resp.Status)
+	}
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if !strings.HasPrefix(mediaType, "image/") && mediaType != "application/octet-stream" {
+			return nil, errors.Errorf("%q is not an image but %q", rawurl, contentType)
+		}
+	}
+
+	if f.MaxBytes > 0 && resp.ContentLength > f.MaxBytes {
+		return nil, errors.Wrapf(ErrTooLarge, "%q is %d bytes", rawurl, resp.ContentLength)
+	}
+
+	var body io.Reader = resp.Body
+	if f.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, f.MaxBytes+1)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %q", rawurl)
+	}
+	if f.MaxBytes > 0 && int64(len(data)) > f.MaxBytes {
+		return nil, errors.Wrapf(ErrTooLarge, "%q is more than %d bytes", rawurl, f.MaxBytes)
+	}
+
+	if detected := http.DetectContentType(data); !strings.HasPrefix(detected, "image/") {
+		return nil, errors.Errorf("%q is not an image but %q", rawurl, detected)
+	}
+
+	return data, nil
+}
+
+func (f *Fetcher) client() *http.Client {
+	dialer := &net.Dialer{Timeout: f.Timeout}
+	if !f.AllowPrivate {
+		// the addresses are checked once resolved, for each connection,
+		// redirects included
+		dialer.Control = checkAddress
+	}
+
+	return &http.Client{
+		// no proxy, as the checked addresses would be the proxy's
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   f.Timeout,
+			ResponseHeaderTimeout: f.Timeout,
+			DisableKeepAlives:     true,
+		},
+		Timeout: f.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.MaxRedirects {
+				return errors.Errorf("stopped after %d redirects", f.MaxRedirects)
+			}
+			return checkScheme(req.URL)
+		},
+	}
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("unsupported scheme %q of %q", u.Scheme, u.String())
+	}
+	return nil
+}
+
+var privateNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	// 6to4 and NAT64 addresses embed IPv4 ones
+	"2002::/16",
+	"64:ff9b::/96",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}
+
+// privateIP tells if ip is not a public unicast address
+func privateIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAddress is a net.Dialer Control refusing the private addresses
+func checkAddress(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || privateIP(ip) {
+		return errors.Wrapf(ErrForbiddenAddress, "%s", host)
+	}
+	return nil
+}
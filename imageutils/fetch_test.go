@@ -0,0 +1,214 @@
+package imageutils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fetchServer(t *testing.T) *httptest.Server {
+	encode := func(width, height int) []byte {
+		var b bytes.Buffer
+		require.NoError(t, png.Encode(&b, image.NewRGBA(image.Rect(0, 0, width, height))))
+		return b.Bytes()
+	}
+	small, large := encode(40, 20), encode(2000, 1000)
+
+	var animated bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&animated, &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 40, 20), []color.Color{color.Black}),
+			image.NewPaletted(image.Rect(0, 0, 40, 20), []color.Color{color.White}),
+		},
+		Delay: []int{10, 10},
+	}))
+
+	// 200 frames of 400x400 pixels
+	var frames bytes.Buffer
+	bomb := &gif.GIF{}
+	for i := 0; i < 200; i++ {
+		bomb.Image = append(bomb.Image, image.NewPaletted(image.Rect(0, 0, 400, 400), []color.Color{color.Black}))
+		bomb.Delay = append(bomb.Delay, 10)
+	}
+	require.NoError(t, gif.EncodeAll(&frames, bomb))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/frames.gif", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/gif")
+		w.Write(frames.Bytes())
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(small)
+	})
+	mux.HandleFunc("/untyped", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(small)
+	})
+	mux.HandleFunc("/large.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(large)
+	})
+	mux.HandleFunc("/chunked.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		for chunk := large; len(chunk) > 0; {
+			n := 100
+			if n > len(chunk) {
+				n = len(chunk)
+			}
+			w.Write(chunk[:n])
+			w.(http.Flusher).Flush()
+			chunk = chunk[n:]
+		}
+	})
+	mux.HandleFunc("/animated.gif", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/gif")
+		w.Write(animated.Bytes())
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/text.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("not an image"))
+	})
+	mux.HandleFunc("/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write(small)
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if n == 0 {
+			http.Redirect(w, r, "/image.png", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestFetcher(t *testing.T) {
+	server := fetchServer(t)
+	defer server.Close()
+
+	f := DefaultFetcher()
+	f.AllowPrivate = true
+
+	img, err := f.Image(server.URL + "/image.png")
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+
+	img, err = f.Image(server.URL + "/untyped")
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), img.Bounds())
+
+	g, err := f.GIF(server.URL + "/animated.gif")
+	require.NoError(t, err)
+	assert.Len(t, g.Image, 2)
+
+	for _, path := range []string{"/missing.png", "/page.html", "/text.png"} {
+		_, err = f.Image(server.URL + path)
+		assert.Error(t, err, path)
+	}
+
+	for _, u := range []string{"file:///etc/passwd", "ftp://example.com/image.png", "image.png"} {
+		_, err = f.Image(u)
+		assert.Error(t, err, u)
+	}
+}
+
+func TestFetcherLimits(t *testing.T) {
+	server := fetchServer(t)
+	defer server.Close()
+
+	f := &Fetcher{AllowPrivate: true, MaxBytes: 1000, MaxRedirects: 2}
+
+	_, err := f.Image(server.URL + "/image.png")
+	require.NoError(t, err)
+
+	_, err = f.Image(server.URL + "/large.png")
+	assert.Equal(t, ErrTooLarge, errors.Cause(err))
+
+	// without Content-Length, the body is read up to MaxBytes
+	_, err = f.Image(server.URL + "/chunked.png")
+	assert.Equal(t, ErrTooLarge, errors.Cause(err))
+
+	f.MaxBytes = 0
+	f.MaxPixels = 1000 * 1000
+	_, err = f.Image(server.URL + "/large.png")
+	assert.Equal(t, ErrTooManyPixels, errors.Cause(err))
+	_, err = f.DecodeGIF([]byte("GIF89a"))
+	assert.Error(t, err)
+
+	// each frame is below MaxPixels, not all of them
+	_, err = f.GIF(server.URL + "/frames.gif")
+	assert.Equal(t, ErrTooManyPixels, errors.Cause(err))
+	f.MaxPixels = 200 * 400 * 400
+	g, err := f.GIF(server.URL + "/frames.gif")
+	require.NoError(t, err)
+	assert.Len(t, g.Image, 200)
+	f.MaxPixels = 1000 * 1000
+
+	_, err = f.Image(server.URL + "/redirect/1")
+	assert.NoError(t, err, "2 redirects")
+	_, err = f.Image(server.URL + "/redirect/2")
+	assert.Error(t, err, "3 redirects")
+
+	f.Timeout = 50 * time.Millisecond
+	_, err = f.Image(server.URL + "/slow.png")
+	assert.Error(t, err)
+}
+
+func TestFetcherPrivateNetworks(t *testing.T) {
+	server := fetchServer(t)
+	defer server.Close()
+
+	_, err := DefaultFetcher().Image(server.URL + "/image.png")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrForbiddenAddress.Error())
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	_, err = DefaultFetcher().Image("http://localhost:" + port + "/image.png")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrForbiddenAddress.Error())
+
+	for ip, private := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.20.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fd00::1":          true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+		"224.0.0.1":        true,
+		"192.0.0.170":      true,
+		"198.19.0.1":       true,
+		"2002:7f00:1::":    true,
+		"64:ff9b::a00:1":   true,
+		"8.8.8.8":          false,
+		"172.32.0.1":       false,
+		"2001:4860::8888":  false,
+	} {
+		assert.Equal(t, private, privateIP(net.ParseIP(ip)), ip)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	_ "image/png"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"strings"
 
@@ -38,36 +37,14 @@ func FromFile(filename string) (image.Image, error) {
 	return img, nil
 }
 
+// FromURL fetches the image at url with RemoteFetcher
 func FromURL(url string) (image.Image, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get %q", url)
-	}
-	defer resp.Body.Close()
-
-	img, _, err := Decode(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to decode image")
-	}
-
-	return img, nil
-
+	return RemoteFetcher.Image(url)
 }
 
+// GIFFromURL fetches the GIF at url with RemoteFetcher
 func GIFFromURL(url string) (*gif.GIF, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get %q", url)
-	}
-	defer resp.Body.Close()
-
-	g, err := gif.DecodeAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to decode image")
-	}
-
-	return g, nil
-
+	return RemoteFetcher.GIF(url)
 }
 
 func Scaled(img image.Image, height, width uint) image.Image {
@@ -0,0 +1,167 @@
+// Package preprocess converts images to the flat NHWC buffers fed to the
+// models.
+package preprocess
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/pkg/errors"
+)
+
+// Order is the order of the color channels in the buffers
+type Order int
+
+const (
+	RGB Order = iota
+	BGR
+)
+
+// Options tell how a channel value v, between 0 and 255, is converted:
+// (v - Mean[c]) / Std, with c the index of the channel in Order
+type Options struct {
+	Order Order
+	Mean  [3]float32
+	// Std is the value the centered channels are divided by. 0 means 1.
+	Std float32
+}
+
+var (
+	// Raw keeps the channel values between 0 and 255
+	Raw = Options{}
+	// Tensorflow scales the values between -1 and 1
+	Tensorflow = Options{Mean: [3]float32{127.5, 127.5, 127.5}, Std: 127.5}
+	// TensorflowPositive scales the values between 0 and 1
+	TensorflowPositive = Options{Std: 255}
+	// Caffe centers the values on the means of imagenet, in BGR order
+	Caffe = Options{Order: BGR, Mean: [3]float32{103.939, 116.779, 123.68}}
+)
+
+// Float32 is a batch of images converted to float32 values
+type Float32 struct {
+	// Shape is [batch, height, width, 3]
+	Shape []int64
+	Data  []float32
+}
+
+// Float32s converts imgs, which must all have the same size, to a batch
+// of float32 values
+func Float32s(imgs []image.Image, opts Options) (*Float32, error) {
+	h, w, err := batchSize(imgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var lut [3][256]float32
+	std := opts.Std
+	if std == 0 {
+		std = 1
+	}
+	for c := range lut {
+		for v := range lut[c] {
+			lut[c][v] = (float32(v) - opts.Mean[c]) / std
+		}
+	}
+
+	out := &Float32{
+		Shape: []int64{int64(len(imgs)), int64(h), int64(w), 3},
+		Data:  make([]float32, len(imgs)*h*w*3),
+	}
+
+	r, b := 0, 2
+	if opts.Order == BGR {
+		r, b = 2, 0
+	}
+
+	rgb := make([]uint8, h*w*3)
+	for n, img := range imgs {
+		readRGB(rgb, img)
+		data := out.Data[n*h*w*3 : (n+1)*h*w*3]
+		for i := 0; i < len(rgb); i += 3 {
+			data[i+r] = lut[r][rgb[i]]
+			data[i+1] = lut[1][rgb[i+1]]
+			data[i+b] = lut[b][rgb[i+2]]
+		}
+	}
+
+	return out, nil
+}
+
+// Uint8 is a batch of images converted to uint8 values, in RGB order
+type Uint8 struct {
+	// Shape is [batch, height, width, 3]
+	Shape []int64
+	Data  []uint8
+}
+
+// Uint8s converts imgs, which must all have the same size, to a batch of
+// uint8 values
+func Uint8s(imgs []image.Image) (*Uint8, error) {
+	h, w, err := batchSize(imgs)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Uint8{
+		Shape: []int64{int64(len(imgs)), int64(h), int64(w), 3},
+		Data:  make([]uint8, len(imgs)*h*w*3),
+	}
+
+	size := h * w * 3
+	for n, img := range imgs {
+		readRGB(out.Data[n*size:(n+1)*size], img)
+	}
+
+	return out, nil
+}
+
+func batchSize(imgs []image.Image) (h, w int, err error) {
+	if len(imgs) == 0 {
+		return 0, 0, errors.New("no image to convert")
+	}
+
+	size := imgs[0].Bounds().Size()
+	for i, img := range imgs[1:] {
+		if img.Bounds().Size() != size {
+			return 0, 0, errors.Errorf("image %d has size %v, expected %v",
+				i+1, img.Bounds().Size(), size)
+		}
+	}
+
+	return size.Y, size.X, nil
+}
+
+// readRGB writes to dst the 8 bits, alpha-premultiplied, colors of the
+// pixels of img, row by row
+func readRGB(dst []uint8, img image.Image) {
+	bounds := img.Bounds()
+	i := 0
+
+	switch img := img.(type) {
+	case *image.RGBA:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+			for j := 0; j < len(row); j += 4 {
+				dst[i], dst[i+1], dst[i+2] = row[j], row[j+1], row[j+2]
+				i += 3
+			}
+		}
+	case *image.YCbCr:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				yi, ci := img.YOffset(x, y), img.COffset(x, y)
+				r, g, b, _ := color.YCbCr{Y: img.Y[yi], Cb: img.Cb[ci], Cr: img.Cr[ci]}.RGBA()
+				dst[i], dst[i+1], dst[i+2] = uint8(r>>8), uint8(g>>8), uint8(b>>8)
+				i += 3
+			}
+		}
+	default:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				dst[i], dst[i+1], dst[i+2] = uint8(r>>8), uint8(g>>8), uint8(b>>8)
+				i += 3
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package preprocess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nestedFloat32 is the conversion the models used to do, pixel by pixel
+// through At to nested slices
+func nestedFloat32(img image.Image, opts Options) [1][][][3]float32 {
+	var out [1][][][3]float32
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	std := opts.Std
+	if std == 0 {
+		std = 1
+	}
+
+	for j := 0; j < h; j++ {
+		out[0] = append(out[0], make([][3]float32, w))
+	}
+
+	for i := 0; i < w; i++ {
+		for j := 0; j < h; j++ {
+			r, g, b, _ := img.At(i, j).RGBA()
+			if opts.Order == BGR {
+				r, b = b, r
+			}
+			out[0][j][i][0] = (float32(r>>8) - opts.Mean[0]) / std
+			out[0][j][i][1] = (float32(g>>8) - opts.Mean[1]) / std
+			out[0][j][i][2] = (float32(b>>8) - opts.Mean[2]) / std
+		}
+	}
+
+	return out
+}
+
+func flatten(nested [1][][][3]float32) []float32 {
+	var out []float32
+	for _, row := range nested[0] {
+		for _, p := range row {
+			out = append(out, p[:]...)
+		}
+	}
+	return out
+}
+
+func randomImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	r := rand.New(rand.NewSource(1))
+	r.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func testImages(t testing.TB) map[string]image.Image {
+	rgba := randomImage(64, 48)
+
+	nrgba := image.NewNRGBA(rgba.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), rgba, image.ZP, draw.Src)
+	nrgba.Set(3, 4, color.NRGBA{R: 200, G: 100, B: 50, A: 128})
+
+	jpeg, err := imageutils.FromFile("../../faceapi/1.jpg")
+	require.NoError(t, err)
+	_, ok := jpeg.(*image.YCbCr)
+	require.True(t, ok, "expected a YCbCr image, got %T", jpeg)
+
+	return map[string]image.Image{
+		"rgba":  rgba,
+		"nrgba": nrgba,
+		"ycbcr": jpeg,
+		"gray":  image.NewGray(image.Rect(0, 0, 10, 7)),
+	}
+}
+
+func TestFloat32sLikeNested(t *testing.T) {
+	for name, img := range testImages(t) {
+		for _, opts := range []Options{Raw, Tensorflow, TensorflowPositive, Caffe} {
+			batch, err := Float32s([]image.Image{img}, opts)
+			require.NoError(t, err)
+
+			b := img.Bounds()
+			assert.Equal(t, []int64{1, int64(b.Dy()), int64(b.Dx()), 3}, batch.Shape, name)
+			assert.Equal(t, flatten(nestedFloat32(img, opts)), batch.Data, name)
+		}
+	}
+}
+
+func TestUint8sLikeNested(t *testing.T) {
+	for name, img := range testImages(t) {
+		batch, err := Uint8s([]image.Image{img, img})
+		require.NoError(t, err)
+
+		var expected []uint8
+		for _, v := range flatten(nestedFloat32(img, Raw)) {
+			expected = append(expected, uint8(v))
+		}
+		expected = append(expected, expected...)
+
+		b := img.Bounds()
+		assert.Equal(t, []int64{2, int64(b.Dy()), int64(b.Dx()), 3}, batch.Shape, name)
+		assert.Equal(t, expected, batch.Data, name)
+	}
+}
+
+func TestFloat32sSubImage(t *testing.T) {
+	img := randomImage(20, 20)
+	sub := img.SubImage(image.Rect(5, 6, 15, 16))
+
+	moved := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(moved, moved.Bounds(), sub, sub.Bounds().Min, draw.Src)
+
+	batch, err := Float32s([]image.Image{sub, moved}, Raw)
+	require.NoError(t, err)
+	assert.Equal(t, batch.Data[:300], batch.Data[300:])
+}
+
+func TestFloat32sSizes(t *testing.T) {
+	_, err := Float32s(nil, Raw)
+	assert.Error(t, err)
+
+	_, err = Float32s([]image.Image{randomImage(10, 10), randomImage(10, 11)}, Raw)
+	assert.Error(t, err)
+}
+
+func benchmarkImages(b *testing.B) map[string]image.Image {
+	images := testImages(b)
+	delete(images, "gray")
+	return images
+}
+
+func BenchmarkNested(b *testing.B) {
+	for name, img := range benchmarkImages(b) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				nestedFloat32(img, Caffe)
+			}
+		})
+	}
+}
+
+func BenchmarkFloat32s(b *testing.B) {
+	for name, img := range benchmarkImages(b) {
+		b.Run(name, func(b *testing.B) {
+			imgs := []image.Image{img}
+			for i := 0; i < b.N; i++ {
+				Float32s(imgs, Caffe)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package preprocess
+
+import (
+	"github.com/gildasch/gildas-ai/backend"
+)
+
+// Tensor returns the batch as a tensor, sharing its values
+func (f *Float32) Tensor() *backend.Tensor {
+	return backend.NewFloat32(f.Shape, f.Data)
+}
+
+// Tensor returns the batch as a tensor, sharing its values
+func (u *Uint8) Tensor() *backend.Tensor {
+	return backend.NewUint8(u.Shape, u.Data)
+}
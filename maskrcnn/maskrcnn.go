@@ -6,20 +6,19 @@ import (
 	"math"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
 	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/gildasch/gildas-ai/imageutils/preprocess"
 	"github.com/pkg/errors"
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 )
 
 type RCNN struct {
-	model *tf.SavedModel
+	model backend.Model
 }
 
-var confInterOpParallelismThreads1 = []byte{0x28, 0x01}
-
 func NewRCNN(modelPath, tagName string) (*RCNN, error) {
-	model, err := tf.LoadSavedModel(modelPath, []string{tagName}, &tf.SessionOptions{
-		Config: confInterOpParallelismThreads1})
+	model, err := backend.LoadSavedModel(modelPath, []string{tagName},
+		&backend.Options{InterOpThreads: 1})
 	if err != nil {
 		return nil, errors.Wrapf(err,
 			"failed to load saved model %q / tag %q", modelPath, tagName)
@@ -29,38 +28,52 @@ func NewRCNN(modelPath, tagName string) (*RCNN, error) {
 }
 
 func (r *RCNN) Close() error {
-	return r.model.Session.Close()
+	return r.model.Close()
 }
 
+const (
+	// detectionSize is the size of a detection: y1, x1, y2, x2, class
+	// and score
+	detectionSize = 6
+	maskSize      = 28
+)
+
 func (r *RCNN) Detect(img image.Image) ([]gildasai.Mask, error) {
 	detections, masks, err := r.detect(img)
 	if err != nil {
 		return nil, err
 	}
 
+	detectionValues, maskValues := detections.Row(0), masks.Row(0)
+	numDetections := int(detections.Shape[1])
+	numClasses := int(masks.Shape[4])
+	maskStride := maskSize * maskSize * numClasses
+
 	var gmasks []gildasai.Mask
-	for i := range detections.Values[0] {
-		classID := int(detections.Values[0][i][4])
-		if classID == 0 {
+	for i := 0; i < numDetections; i++ {
+		d := detectionValues[i*detectionSize : (i+1)*detectionSize]
+
+		classID := int(d[4])
+		if classID <= 0 || classID >= len(classes) || classID >= numClasses {
 			continue
 		}
 
 		m := gildasai.Mask{
 			Box: image.Rectangle{
 				Min: image.Point{
-					X: int(detections.Values[0][i][1] * float32(img.Bounds().Dx())),
-					Y: int(detections.Values[0][i][0] * float32(img.Bounds().Dy())),
+					X: int(d[1] * float32(img.Bounds().Dx())),
+					Y: int(d[0] * float32(img.Bounds().Dy())),
 				},
 				Max: image.Point{
-					X: int(detections.Values[0][i][3] * float32(img.Bounds().Dx())),
-					Y: int(detections.Values[0][i][2] * float32(img.Bounds().Dy())),
+					X: int(d[3] * float32(img.Bounds().Dx())),
+					Y: int(d[2] * float32(img.Bounds().Dy())),
 				},
 			},
-			Score: detections.Values[0][i][5],
+			Score: d[5],
 			Label: classes[classID],
 		}
 
-		m.Mask = spread(masks.Values[0][i], classID, m.Box)
+		m.Mask = spread(maskValues[i*maskStride:(i+1)*maskStride], numClasses, classID, m.Box)
 
 		gmasks = append(gmasks, m)
 	}
@@ -68,14 +81,6 @@ func (r *RCNN) Detect(img image.Image) ([]gildasai.Mask, error) {
 	return gmasks, nil
 }
 
-type Detections struct {
-	Values [][][]float32
-}
-
-type Masks struct {
-	Values [][][][][]float32
-}
-
 var classes = []string{
 	"BG", "person", "bicycle", "car", "motorcycle", "airplane",
 	"bus", "train", "truck", "boat", "traffic light",
@@ -93,11 +98,14 @@ var classes = []string{
 	"sink", "refrigerator", "book", "clock", "vase", "scissors",
 	"teddy bear", "hair drier", "toothbrush"}
 
-func spread(maskArray [][][]float32, classID int, box image.Rectangle) image.Image {
-	mask := image.NewGray(image.Rect(0, 0, 28, 28))
+// spread returns the mask of classID, from the maskSize x maskSize x
+// numClasses values of a detection, resized to box
+func spread(maskValues []float32, numClasses, classID int, box image.Rectangle) image.Image {
+	mask := image.NewGray(image.Rect(0, 0, maskSize, maskSize))
 	for x := mask.Bounds().Min.X; x < mask.Bounds().Max.X; x++ {
 		for y := mask.Bounds().Min.Y; y < mask.Bounds().Max.Y; y++ {
-			mask.Set(x, y, color.Gray{Y: uint8(float32(255) * maskArray[x][y][classID])})
+			v := maskValues[(x*maskSize+y)*numClasses+classID]
+			mask.Set(x, y, color.Gray{Y: uint8(float32(255) * v)})
 		}
 	}
 	maskResized := imageutils.Scaled(mask, uint(box.Bounds().Dx()), uint(box.Bounds().Dy()))
@@ -116,59 +124,42 @@ func spread(maskArray [][][]float32, classID int, box image.Rectangle) image.Ima
 	return maskImage
 }
 
-func (r *RCNN) detect(img image.Image) (*Detections, *Masks, error) {
+func (r *RCNN) detect(img image.Image) (detections, masks *backend.Tensor, err error) {
 	imgTensor, meta, anchors, err := makeInputs(img)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "error converting image to tensor")
 	}
 
-	result, err := r.model.Session.Run(
-		map[tf.Output]*tf.Tensor{
-			r.model.Graph.Operation("input_image").Output(0):      imgTensor,
-			r.model.Graph.Operation("input_image_meta").Output(0): meta,
-			r.model.Graph.Operation("input_anchors").Output(0):    anchors,
+	result, err := r.model.Run(
+		map[string]*backend.Tensor{
+			"input_image":      imgTensor,
+			"input_image_meta": meta,
+			"input_anchors":    anchors,
 		},
-		[]tf.Output{
-			r.model.Graph.Operation("mrcnn_detection/Reshape_1").Output(0),
-			r.model.Graph.Operation("mrcnn_class/Reshape_1").Output(0),
-			r.model.Graph.Operation("mrcnn_bbox/Reshape").Output(0),
-			r.model.Graph.Operation("mrcnn_mask/Reshape_1").Output(0),
-			r.model.Graph.Operation("ROI/packed_2").Output(0),
-			r.model.Graph.Operation("rpn_class/concat").Output(0),
-			r.model.Graph.Operation("rpn_bbox/concat").Output(0),
-		},
-		nil,
-	)
+		[]string{
+			"mrcnn_detection/Reshape_1",
+			"mrcnn_mask/Reshape_1",
+		})
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "error running the model session")
 	}
 
-	if len(result) < 1 {
-		return nil, nil, errors.New("result is empty")
-	}
-
-	detections, ok := result[0].Value().([][][]float32)
-	if !ok {
-		return nil, nil, errors.Errorf("result has unexpected type %T", result[0].Value())
+	if len(result) < 2 {
+		return nil, nil, errors.New("result is incomplete")
 	}
 
-	if len(detections) < 1 {
-		return nil, nil, errors.New("predictions are empty")
+	detections, masks = result[0], result[1]
+	if err := detections.CheckFloat32(1, -1, detectionSize); err != nil {
+		return nil, nil, errors.Wrap(err, "unexpected detections")
 	}
-
-	masks, ok := result[3].Value().([][][][][]float32)
-	if !ok {
-		return nil, nil, errors.Errorf("result has unexpected type %T", result[3].Value())
-	}
-
-	if len(masks) < 1 {
-		return nil, nil, errors.New("predictions are empty")
+	if err := masks.CheckFloat32(1, detections.Shape[1], maskSize, maskSize, -1); err != nil {
+		return nil, nil, errors.Wrap(err, "unexpected masks")
 	}
 
-	return &Detections{Values: detections}, &Masks{Values: masks}, nil
+	return detections, masks, nil
 }
 
-func makeInputs(img image.Image) (imgTensor, meta, anchors *tf.Tensor, err error) {
+func makeInputs(img image.Image) (imgTensor, meta, anchors *backend.Tensor, err error) {
 	resized := imageutils.Scaled(img, 1024, 1024)
 
 	imgTensor, err = imageToTensor(resized)
@@ -176,62 +167,45 @@ func makeInputs(img image.Image) (imgTensor, meta, anchors *tf.Tensor, err error
 		return nil, nil, nil, err
 	}
 
-	meta, err = composeImageMeta(0, img.Bounds(), resized.Bounds(), resized.Bounds(),
-		float32(resized.Bounds().Dy())/float32(img.Bounds().Dy()), 81)
-	if err != nil {
-		return nil, nil, nil, err
-	}
+	meta = composeImageMeta(0, img.Bounds(), resized.Bounds(), resized.Bounds(),
+		float32(resized.Bounds().Dy())/float32(img.Bounds().Dy()), len(classes))
 
-	anchors, err = getAnchors(resized.Bounds())
-	if err != nil {
-		return nil, nil, nil, err
-	}
+	anchors = getAnchors(resized.Bounds())
 
 	return imgTensor, meta, anchors, nil
 }
 
-func imageToTensor(img image.Image) (*tf.Tensor, error) {
-	imageHeight, imageWidth := img.Bounds().Dy(), img.Bounds().Dx()
-
-	var image [1][][][3]float32
-
-	for j := 0; j < int(imageHeight); j++ {
-		image[0] = append(image[0], make([][3]float32, imageWidth))
-	}
+// imageOptions centers the channels on the MEAN_PIXEL of the model config
+var imageOptions = preprocess.Options{
+	Mean: [3]float32{123.7, 116.8, 103.9},
+}
 
-	for i := 0; i < int(imageWidth); i++ {
-		for j := 0; j < int(imageHeight); j++ {
-			r, g, b, _ := img.At(i, j).RGBA()
-			image[0][j][i][0] = convert(r) - 123.7
-			image[0][j][i][1] = convert(g) - 116.8
-			image[0][j][i][2] = convert(b) - 103.9
-		}
+func imageToTensor(img image.Image) (*backend.Tensor, error) {
+	batch, err := preprocess.Float32s([]image.Image{img}, imageOptions)
+	if err != nil {
+		return nil, err
 	}
 
-	return tf.NewTensor(image)
-}
-
-func convert(value uint32) float32 {
-	return float32(value >> 8)
+	return batch.Tensor(), nil
 }
 
 func composeImageMeta(imageID int, originalBounds, resizedBounds, window image.Rectangle,
-	scale float32, numClasses int) (*tf.Tensor, error) {
-	var meta [1][]float32
+	scale float32, numClasses int) *backend.Tensor {
+	var meta []float32
 
-	meta[0] = append(meta[0], float32(imageID))
-	meta[0] = append(meta[0], float32(originalBounds.Dy()), float32(originalBounds.Dx()), 3)
-	meta[0] = append(meta[0], float32(resizedBounds.Dy()), float32(resizedBounds.Dx()), 3)
-	meta[0] = append(meta[0],
+	meta = append(meta, float32(imageID))
+	meta = append(meta, float32(originalBounds.Dy()), float32(originalBounds.Dx()), 3)
+	meta = append(meta, float32(resizedBounds.Dy()), float32(resizedBounds.Dx()), 3)
+	meta = append(meta,
 		float32(window.Min.Y), float32(window.Min.X),
 		float32(window.Max.Y), float32(window.Max.X))
-	meta[0] = append(meta[0], scale)
-	meta[0] = append(meta[0], make([]float32, numClasses)...)
+	meta = append(meta, scale)
+	meta = append(meta, make([]float32, numClasses)...)
 
-	return tf.NewTensor(meta)
+	return backend.NewFloat32([]int64{1, int64(len(meta))}, meta)
 }
 
-func getAnchors(imageBounds image.Rectangle) (*tf.Tensor, error) {
+func getAnchors(imageBounds image.Rectangle) *backend.Tensor {
 	backboneStrides := []int{4, 8, 16, 32, 64}
 	backboneShapes := computeBackboneShapes(imageBounds, backboneStrides)
 
@@ -248,7 +222,12 @@ func getAnchors(imageBounds image.Rectangle) (*tf.Tensor, error) {
 
 	normalizeAnchors(a, imageBounds)
 
-	return tf.NewTensor(a)
+	var values []float32
+	for _, anchor := range a[0] {
+		values = append(values, anchor[:]...)
+	}
+
+	return backend.NewFloat32([]int64{1, int64(len(a[0])), 4}, values)
 }
 
 func computeBackboneShapes(imageBounds image.Rectangle, backboneStrides []int) [][]float32 {
@@ -2,17 +2,23 @@ package maskrcnn
 
 import (
 	"fmt"
+	"image"
 	"os"
 	"testing"
 
 	"github.com/fogleman/gg"
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/backend"
 	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRunRCNN(t *testing.T) {
+	if backend.Default == nil {
+		t.Skip(backend.ErrNoBackend)
+	}
+
 	var modelsRoot = os.Getenv("MODELS_ROOT")
 	if modelsRoot != "" {
 		modelsRoot += "mask/"
@@ -72,3 +78,36 @@ func inEpsilon(t *testing.T, expected, actual [][4]float32, epsilon float64) {
 		}
 	}
 }
+
+func TestDetectFakeModel(t *testing.T) {
+	detections := []float32{
+		0.1, 0.2, 0.5, 0.6, 1, 0.9,
+		0, 0, 1, 1, 0, 0,
+	}
+	masks := make([]float32, 2*28*28*81)
+	for i := 1; i < 28*28*81; i += 81 {
+		masks[i] = 1
+	}
+
+	model := &backend.FakeModel{Outputs: map[string]*backend.Tensor{
+		"mrcnn_detection/Reshape_1": backend.NewFloat32([]int64{1, 2, 6}, detections),
+		"mrcnn_mask/Reshape_1":      backend.NewFloat32([]int64{1, 2, 28, 28, 81}, masks),
+	}}
+	r := &RCNN{model: model}
+
+	found, err := r.Detect(image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	require.NoError(t, err)
+
+	require.Len(t, model.Inputs, 1)
+	assert.Equal(t, []int64{1, 1024, 1024, 3}, model.Inputs[0]["input_image"].Shape)
+	assert.Equal(t, []int64{1, 93}, model.Inputs[0]["input_image_meta"].Shape)
+	assert.Equal(t, []int64{1, 261888, 4}, model.Inputs[0]["input_anchors"].Shape)
+
+	require.Len(t, found, 1)
+	assert.Equal(t, image.Rect(20, 5, 60, 25), found[0].Box)
+	assert.Equal(t, "person", found[0].Label)
+	assert.Equal(t, float32(0.9), found[0].Score)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), found[0].Mask.Bounds())
+	_, _, _, a := found[0].Mask.At(10, 10).RGBA()
+	assert.NotZero(t, a)
+}
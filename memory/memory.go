@@ -0,0 +1,686 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/pkg/errors"
+)
+
+// Store keeps the predictions, faces, face distances and sources in
+// memory. It
+// implements the same interfaces, with the same semantics, as
+// sqlite.Store and is safe for concurrent use.
+type Store struct {
+	mu sync.RWMutex
+
+	// predictions are in insertion order
+	predictions []prediction
+	predKeys    map[predictionKey]struct{}
+
+	faces map[int64]*gildasai.FaceItem
+	// faceIDs are in insertion order
+	faceIDs  []int64
+	faceKeys map[faceKey]struct{}
+	lastID   int64
+
+	distances map[distanceKey]float32
+	// distanceKeys are in insertion order
+	distanceKeys []distanceKey
+	compared     map[int64]bool
+
+	neighbours map[int64]*neighbours
+
+	sources map[string]gildasai.Source
+}
+
+type prediction struct {
+	id string
+	gildasai.Prediction
+}
+
+type predictionKey struct {
+	id, network, label string
+}
+
+type faceKey struct {
+	id, network string
+	detection   gildasai.Detection
+}
+
+type distanceKey struct {
+	face1, face2 int64
+}
+
+type neighbours struct {
+	faceIDs   []int64
+	distances []float32
+	stale     bool
+}
+
+func NewStore() *Store {
+	return &Store{
+		predKeys:   map[predictionKey]struct{}{},
+		faces:      map[int64]*gildasai.FaceItem{},
+		faceKeys:   map[faceKey]struct{}{},
+		distances:  map[distanceKey]float32{},
+		compared:   map[int64]bool{},
+		neighbours: map[int64]*neighbours{},
+		sources:    map[string]gildasai.Source{},
+	}
+}
+
+func (s *Store) GetPrediction(id string) (*gildasai.PredictionItem, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var preds gildasai.Predictions
+	for _, p := range s.predictions {
+		if p.id == id {
+			preds = append(preds, p.Prediction)
+		}
+	}
+
+	if len(preds) == 0 {
+		return nil, false, nil
+	}
+
+	sortByScore(preds)
+	return &gildasai.PredictionItem{
+		Identifier:  id,
+		Predictions: preds}, true, nil
+}
+
+// StorePrediction stores the predictions of item under item.Identifier.
+// Like in sqlite, storing twice the same label for an identifier and a
+// network is an error, in which case none of the predictions is stored.
+func (s *Store) StorePrediction(id string, item *gildasai.PredictionItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := map[predictionKey]struct{}{}
+	for _, p := range item.Predictions {
+		key := predictionKey{item.Identifier, p.Network, p.Label}
+		if _, ok := s.predKeys[key]; ok {
+			return errors.Errorf("prediction %q of network %q already stored for %q", p.Label, p.Network, item.Identifier)
+		}
+		if _, ok := seen[key]; ok {
+			return errors.Errorf("prediction %q of network %q is twice in %q", p.Label, p.Network, item.Identifier)
+		}
+		seen[key] = struct{}{}
+	}
+
+	for _, p := range item.Predictions {
+		s.predKeys[predictionKey{item.Identifier, p.Network, p.Label}] = struct{}{}
+		s.predictions = append(s.predictions, prediction{id: item.Identifier, Prediction: p})
+	}
+
+	return nil
+}
+
+// SearchPrediction behaves like its sqlite counterpart. With a query, it
+// returns all the predictions of the identifiers having a label
+// containing query, case-insensitively, among the first n matching
+// predictions. Without, it returns the n best predictions. In both cases,
+// only the identifiers after after are considered.
+func (s *Store) SearchPrediction(query, after string, n int) ([]*gildasai.PredictionItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var candidates []prediction
+	for _, p := range s.predictions {
+		if after == "" || p.id > after {
+			candidates = append(candidates, p)
+		}
+	}
+
+	var found []prediction
+	if query != "" {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].id < candidates[j].id
+		})
+
+		query = strings.ToLower(query)
+		ids := map[string]bool{}
+		matching := 0
+		for _, p := range candidates {
+			if n >= 0 && matching >= n {
+				break
+			}
+			if strings.Contains(strings.ToLower(p.Label), query) {
+				ids[p.id] = true
+				matching++
+			}
+		}
+
+		for _, p := range s.predictions {
+			if ids[p.id] {
+				found = append(found, p)
+			}
+		}
+	} else {
+		found = candidates
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Score > found[j].Score
+	})
+	if query == "" && n >= 0 && len(found) > n {
+		found = found[:n]
+	}
+
+	var items []*gildasai.PredictionItem
+	byID := map[string]*gildasai.PredictionItem{}
+	for _, p := range found {
+		item, ok := byID[p.id]
+		if !ok {
+			item = &gildasai.PredictionItem{Identifier: p.id}
+			byID[p.id] = item
+			items = append(items, item)
+		}
+		item.Predictions = append(item.Predictions, p.Prediction)
+	}
+
+	return items, nil
+}
+
+func sortByScore(preds gildasai.Predictions) {
+	sort.SliceStable(preds, func(i, j int) bool {
+		return preds[i].Score > preds[j].Score
+	})
+}
+
+func keyOf(item *gildasai.FaceItem) faceKey {
+	return faceKey{item.Identifier, item.Network, item.Detection}
+}
+
+// StoreFace stores a copy of item. Storing twice the same detection for
+// an identifier and a network is an error.
+func (s *Store) StoreFace(item *gildasai.FaceItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := keyOf(item)
+	if _, ok := s.faceKeys[key]; ok {
+		return errors.Errorf("face %v of network %q already stored for %q", item.Detection.Box, item.Network, item.Identifier)
+	}
+
+	s.lastID++
+	item.ID = s.lastID
+	s.faceKeys[key] = struct{}{}
+	s.faces[item.ID] = copyFace(item)
+	s.faceIDs = append(s.faceIDs, item.ID)
+	return nil
+}
+
+func (s *Store) GetFace(faceID int64) (*gildasai.FaceItem, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.faces[faceID]
+	if !ok {
+		return nil, false, nil
+	}
+	return copyFace(f), true, nil
+}
+
+func (s *Store) GetFaces(id string) ([]*gildasai.FaceItem, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []*gildasai.FaceItem
+	for _, faceID := range s.faceIDs {
+		if f := s.faces[faceID]; f.Identifier == id {
+			items = append(items, copyFace(f))
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, false, nil
+	}
+
+	return items, true, nil
+}
+
+// GetAllFaces returns the faces in the order they were stored
+func (s *Store) GetAllFaces() ([]*gildasai.FaceItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []*gildasai.FaceItem
+	for _, faceID := range s.faceIDs {
+		items = append(items, copyFace(s.faces[faceID]))
+	}
+
+	return items, nil
+}
+
+// copyFace returns a copy of item not sharing its slices, so that the
+// caller can modify the faces it stores or gets
+func copyFace(item *gildasai.FaceItem) *gildasai.FaceItem {
+	c := *item
+	c.Landmarks.Coords = copyFloats(item.Landmarks.Coords)
+	c.Descriptors = gildasai.Descriptors(copyFloats(item.Descriptors))
+	return &c
+}
+
+func copyFloats(f []float32) []float32 {
+	if f == nil {
+		return nil
+	}
+	c := make([]float32, len(f))
+	copy(c, f)
+	return c
+}
+
+// StoreFaceDistance stores the distance from item1 to item2, which must
+// have been stored. The distance from item2 to item1 is a different
+// entry. Storing twice the same distance is an error.
+func (s *Store) StoreFaceDistance(item1, item2 *gildasai.FaceItem, distance float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range []*gildasai.FaceItem{item1, item2} {
+		if _, ok := s.faces[item.ID]; !ok {
+			return errors.Errorf("face %d of %q is not stored", item.ID, item.Identifier)
+		}
+	}
+
+	key := distanceKey{item1.ID, item2.ID}
+	if _, ok := s.distances[key]; ok {
+		return errors.Errorf("distance between faces of %q and %q already stored", item1.Identifier, item2.Identifier)
+	}
+
+	s.distances[key] = distance
+	s.distanceKeys = append(s.distanceKeys, key)
+	return nil
+}
+
+func (s *Store) GetFaceDistance(item1, item2 *gildasai.FaceItem) (float32, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	distance, ok := s.distances[distanceKey{item1.ID, item2.ID}]
+	return distance, ok, nil
+}
+
+func (s *Store) GetAllFaceDistances() ([]*gildasai.FaceDistance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var distances []*gildasai.FaceDistance
+	for _, key := range s.distanceKeys {
+		distances = append(distances, &gildasai.FaceDistance{
+			Face1:    s.identity(key.face1),
+			Face2:    s.identity(key.face2),
+			Distance: s.distances[key],
+		})
+	}
+
+	sort.SliceStable(distances, func(i, j int) bool {
+		return distances[i].Distance < distances[j].Distance
+	})
+	return distances, nil
+}
+
+// StoreFaceDistances stores distances, replacing the ones already
+// stored, and marks the faces of compared. Nothing is stored if one of
+// the faces is not.
+func (s *Store) StoreFaceDistances(distances []*gildasai.FaceDistance, compared []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range distances {
+		for _, item := range []*gildasai.FaceItem{d.Face1, d.Face2} {
+			if _, ok := s.faces[item.ID]; !ok {
+				return errors.Errorf("face %d of %q is not stored", item.ID, item.Identifier)
+			}
+		}
+	}
+	for _, faceID := range compared {
+		if _, ok := s.faces[faceID]; !ok {
+			return errors.Errorf("face %d is not stored", faceID)
+		}
+	}
+
+	for _, d := range distances {
+		key := distanceKey{d.Face1.ID, d.Face2.ID}
+		if _, ok := s.distances[key]; !ok {
+			s.distanceKeys = append(s.distanceKeys, key)
+		}
+		s.distances[key] = d.Distance
+	}
+	for _, faceID := range compared {
+		s.compared[faceID] = true
+	}
+	return nil
+}
+
+func (s *Store) ComparedFaces() ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var faceIDs []int64
+	for faceID := range s.compared {
+		faceIDs = append(faceIDs, faceID)
+	}
+	sort.Slice(faceIDs, func(i, j int) bool { return faceIDs[i] < faceIDs[j] })
+	return faceIDs, nil
+}
+
+// StoreNeighbours replaces the neighbours of the faces of neighbours.
+// Nothing is stored if one of the faces is not.
+func (s *Store) StoreNeighbours(list []*gildasai.FaceNeighbours) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range list {
+		items := []*gildasai.FaceItem{n.Face}
+		for _, neighbour := range n.Neighbours {
+			items = append(items, neighbour.Face)
+		}
+		for _, item := range items {
+			if _, ok := s.faces[item.ID]; !ok {
+				return errors.Errorf("face %d of %q is not stored", item.ID, item.Identifier)
+			}
+		}
+	}
+
+	for _, n := range list {
+		stored := &neighbours{}
+		for _, neighbour := range n.Neighbours {
+			stored.faceIDs = append(stored.faceIDs, neighbour.Face.ID)
+			stored.distances = append(stored.distances, neighbour.Distance)
+		}
+		s.neighbours[n.Face.ID] = stored
+	}
+	return nil
+}
+
+func (s *Store) GetNeighbours(faceID int64) (*gildasai.FaceNeighbours, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.neighbours[faceID]; !ok {
+		return nil, false, nil
+	}
+	return s.faceNeighbours(faceID), true, nil
+}
+
+func (s *Store) GetAllNeighbours() ([]*gildasai.FaceNeighbours, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var faceIDs []int64
+	for faceID := range s.neighbours {
+		faceIDs = append(faceIDs, faceID)
+	}
+	sort.Slice(faceIDs, func(i, j int) bool { return faceIDs[i] < faceIDs[j] })
+
+	var list []*gildasai.FaceNeighbours
+	for _, faceID := range faceIDs {
+		list = append(list, s.faceNeighbours(faceID))
+	}
+	return list, nil
+}
+
+func (s *Store) faceNeighbours(faceID int64) *gildasai.FaceNeighbours {
+	stored := s.neighbours[faceID]
+	n := &gildasai.FaceNeighbours{
+		Face:  s.identity(faceID),
+		Stale: stored.stale,
+	}
+	for i, neighbourID := range stored.faceIDs {
+		n.Neighbours = append(n.Neighbours, gildasai.FaceNeighbour{
+			Face:     s.identity(neighbourID),
+			Distance: stored.distances[i],
+		})
+	}
+	return n
+}
+
+// identity returns the face of faceID without its landmarks and
+// descriptors
+func (s *Store) identity(faceID int64) *gildasai.FaceItem {
+	f := s.faces[faceID]
+	return &gildasai.FaceItem{
+		ID:         f.ID,
+		Identifier: f.Identifier,
+		Network:    f.Network,
+		Detection:  f.Detection,
+	}
+}
+
+func (s *Store) GetSource(id string) (*gildasai.Source, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	source, ok := s.sources[id]
+	if !ok {
+		return nil, false, nil
+	}
+	return &source, true, nil
+}
+
+func (s *Store) StoreSource(source *gildasai.Source) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sources[source.Identifier] = *source
+	return nil
+}
+
+func (s *Store) GetAllSources() ([]*gildasai.Source, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sources []*gildasai.Source
+	for _, source := range s.sources {
+		source := source
+		sources = append(sources, &source)
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Identifier < sources[j].Identifier
+	})
+	return sources, nil
+}
+
+func (s *Store) DeleteSource(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var predictions []prediction
+	for _, p := range s.predictions {
+		if p.id == id {
+			delete(s.predKeys, predictionKey{id, p.Network, p.Label})
+			continue
+		}
+		predictions = append(predictions, p)
+	}
+	s.predictions = predictions
+
+	deleted := map[int64]bool{}
+	for _, faceID := range s.faceIDs {
+		if s.faces[faceID].Identifier == id {
+			deleted[faceID] = true
+		}
+	}
+	s.deleteFaces(deleted)
+
+	delete(s.sources, id)
+	return nil
+}
+
+// deleteFaces deletes the faces and their distances
+func (s *Store) deleteFaces(faces map[int64]bool) {
+	if len(faces) == 0 {
+		return
+	}
+
+	var faceIDs []int64
+	for _, faceID := range s.faceIDs {
+		if faces[faceID] {
+			delete(s.faceKeys, keyOf(s.faces[faceID]))
+			delete(s.faces, faceID)
+			delete(s.compared, faceID)
+			continue
+		}
+		faceIDs = append(faceIDs, faceID)
+	}
+	s.faceIDs = faceIDs
+
+	var distanceKeys []distanceKey
+	for _, key := range s.distanceKeys {
+		if faces[key.face1] || faces[key.face2] {
+			delete(s.distances, key)
+			continue
+		}
+		distanceKeys = append(distanceKeys, key)
+	}
+	s.distanceKeys = distanceKeys
+
+	for faceID, stored := range s.neighbours {
+		if faces[faceID] {
+			delete(s.neighbours, faceID)
+			continue
+		}
+
+		kept := &neighbours{stale: stored.stale}
+		for i, neighbourID := range stored.faceIDs {
+			if faces[neighbourID] {
+				kept.stale = true
+				continue
+			}
+			kept.faceIDs = append(kept.faceIDs, neighbourID)
+			kept.distances = append(kept.distances, stored.distances[i])
+		}
+		s.neighbours[faceID] = kept
+	}
+}
+
+func (s *Store) OutdatedFaces(network, model string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := map[string]bool{}
+	for _, f := range s.faces {
+		if f.Network == network && f.Model != model {
+			ids[f.Identifier] = true
+		}
+	}
+	return sortedKeys(ids), nil
+}
+
+func (s *Store) PredictedIdentifiers() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := map[string]bool{}
+	for _, p := range s.predictions {
+		ids[p.id] = true
+	}
+	return sortedKeys(ids), nil
+}
+
+func (s *Store) OutdatedPredictions(network, model string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := map[string]bool{}
+	for _, p := range s.predictions {
+		if p.Network == network && p.Model != model {
+			ids[p.id] = true
+		}
+	}
+	return sortedKeys(ids), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ReplaceFaces replaces the faces of network of id by items, which must
+// not be stored twice, and deletes the distances of the replaced faces
+func (s *Store) ReplaceFaces(id, network string, items []*gildasai.FaceItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	replaced := map[int64]bool{}
+	replacedKeys := map[faceKey]bool{}
+	for _, faceID := range s.faceIDs {
+		if f := s.faces[faceID]; f.Identifier == id && f.Network == network {
+			replaced[faceID] = true
+			replacedKeys[keyOf(f)] = true
+		}
+	}
+
+	keys := map[faceKey]struct{}{}
+	for _, item := range items {
+		key := keyOf(item)
+		_, stored := s.faceKeys[key]
+		_, twice := keys[key]
+		if (stored && !replacedKeys[key]) || twice {
+			return errors.Errorf("face %v of network %q already stored for %q", item.Detection.Box, item.Network, item.Identifier)
+		}
+		keys[key] = struct{}{}
+	}
+
+	s.deleteFaces(replaced)
+	for _, item := range items {
+		s.lastID++
+		item.ID = s.lastID
+		s.faceKeys[keyOf(item)] = struct{}{}
+		s.faces[item.ID] = copyFace(item)
+		s.faceIDs = append(s.faceIDs, item.ID)
+	}
+
+	return nil
+}
+
+// ReplacePredictions replaces the predictions of network of id
+func (s *Store) ReplacePredictions(id, network string, predictions gildasai.Predictions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := map[predictionKey]struct{}{}
+	for _, p := range predictions {
+		key := predictionKey{id, p.Network, p.Label}
+		_, stored := s.predKeys[key]
+		_, twice := keys[key]
+		if (stored && p.Network != network) || twice {
+			return errors.Errorf("prediction %q of network %q already stored for %q", p.Label, p.Network, id)
+		}
+		keys[key] = struct{}{}
+	}
+
+	var kept []prediction
+	for _, p := range s.predictions {
+		if p.id == id && p.Network == network {
+			delete(s.predKeys, predictionKey{id, p.Network, p.Label})
+			continue
+		}
+		kept = append(kept, p)
+	}
+	s.predictions = kept
+
+	for _, p := range predictions {
+		s.predKeys[predictionKey{id, p.Network, p.Label}] = struct{}{}
+		s.predictions = append(s.predictions, prediction{id: id, Prediction: p})
+	}
+
+	return nil
+}
+
+// Close does nothing, it is there for Store to be closed like the other
+// stores
+func (s *Store) Close() error {
+	return nil
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/gildasch/gildas-ai/gildasaitest"
+)
+
+func TestStore(t *testing.T) {
+	gildasaitest.TestStore(t, func(t *testing.T) (gildasaitest.Store, func()) {
+		return NewStore(), func() {}
+	})
+}
@@ -0,0 +1,155 @@
+package gildasai
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+// OrientationMode tells the Extractor how to look for faces which are not
+// upright, in scans or photos without EXIF orientation.
+type OrientationMode int
+
+const (
+	// UprightOnly looks for faces on the image as it is
+	UprightOnly OrientationMode = iota
+	// RetryRotated looks for faces on the image rotated by 90, 180 and
+	// 270 degrees when none is found on the image as it is
+	RetryRotated
+	// BestRotation looks for faces on the 4 orientations and keeps the
+	// one with the best detection
+	BestRotation
+)
+
+// orientations are the rotations, in degrees counter-clockwise, tried by
+// the extractor
+var orientations = []int{0, 90, 180, 270}
+
+// extraction is the result of extract on one orientation of an image
+type extraction struct {
+	detections        []Detection
+	cropped           []image.Image
+	landmarks         []Landmarks
+	landmarksOnImages [][]image.Point
+	centered          []image.Image
+	descriptors       []Descriptors
+	poses             []Pose
+	orientation       int
+}
+
+func (x *extraction) bestScore() float32 {
+	var best float32
+	for _, d := range x.detections {
+		if d.Score > best {
+			best = d.Score
+		}
+	}
+	return best
+}
+
+// extractOriented runs the extraction on the orientations of img required
+// by e.Orientation. Detections, landmarks and crops are in the coordinates
+// of img, while centered faces and descriptors come from the upright face.
+func (e *Extractor) extractOriented(img image.Image, detectionThreshold float32, skip int) (*extraction, error) {
+	upright, err := e.extractUpright(img, detectionThreshold, skip)
+	if err != nil && err != ErrNoFaceDetected {
+		return nil, err
+	}
+
+	switch e.Orientation {
+	case RetryRotated:
+		if len(upright.detections) > 0 {
+			return upright, nil
+		}
+	case BestRotation:
+	default:
+		return upright, err
+	}
+
+	best := upright
+	for _, o := range orientations[1:] {
+		x, rerr := e.extractUpright(rotate(img, o), detectionThreshold, skip)
+		if rerr != nil && rerr != ErrNoFaceDetected {
+			return nil, rerr
+		}
+		if len(x.detections) == 0 || x.bestScore() <= best.bestScore() {
+			continue
+		}
+
+		x.orientation = o
+		best = x
+
+		if e.Orientation == RetryRotated {
+			break
+		}
+	}
+
+	if best.orientation == 0 {
+		return upright, err
+	}
+
+	best.toOriginal(img)
+	return best, nil
+}
+
+// toOriginal maps the detections and landmarks of x, found on img rotated
+// by x.orientation, back to img.
+func (x *extraction) toOriginal(img image.Image) {
+	b := img.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+
+	for i, d := range x.detections {
+		rotatedBox := d.Box
+
+		x1, y1 := unrotate(float64(rotatedBox.Min.X), float64(rotatedBox.Min.Y), x.orientation, w, h)
+		x2, y2 := unrotate(float64(rotatedBox.Max.X), float64(rotatedBox.Max.Y), x.orientation, w, h)
+		box := image.Rect(int(x1), int(y1), int(x2), int(y2)).Add(b.Min)
+		x.detections[i].Box = box
+
+		var coords []float32
+		l := x.landmarks[i]
+		for j := 0; j < len(l.Coords)-1; j += 2 {
+			px, py := unrotate(
+				float64(rotatedBox.Min.X)+float64(l.Coords[j])*float64(rotatedBox.Dx()),
+				float64(rotatedBox.Min.Y)+float64(l.Coords[j+1])*float64(rotatedBox.Dy()),
+				x.orientation, w, h)
+			coords = append(coords,
+				float32((px+float64(b.Min.X)-float64(box.Min.X))/float64(box.Dx())),
+				float32((py+float64(b.Min.Y)-float64(box.Min.Y))/float64(box.Dy())))
+		}
+		x.landmarks[i] = Landmarks{Coords: coords}
+
+		cropped := image.NewRGBA(box)
+		draw.Draw(cropped, box, img, box.Min, draw.Src)
+		x.cropped[i] = cropped
+		x.landmarksOnImages[i] = x.landmarks[i].PointsOnImage(cropped)
+	}
+}
+
+// rotate returns img rotated by orientation degrees counter-clockwise
+func rotate(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 90:
+		return imaging.Rotate90(img)
+	case 180:
+		return imaging.Rotate180(img)
+	case 270:
+		return imaging.Rotate270(img)
+	}
+	return img
+}
+
+// unrotate maps the point (x, y) of an image of size w x h rotated by
+// orientation degrees counter-clockwise to the image before the rotation.
+func unrotate(x, y float64, orientation int, w, h float64) (float64, float64) {
+	switch orientation {
+	case 90:
+		return w - y, x
+	case 180:
+		return w - x, h - y
+	case 270:
+		return y, h - x
+	}
+	return x, y
+}
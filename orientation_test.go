@@ -0,0 +1,92 @@
+package gildasai
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnrotate(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	img.Set(3, 7, color.RGBA{R: 255, A: 255})
+
+	for _, o := range orientations {
+		rotated := rotate(img, o)
+
+		var found image.Point
+		b := rotated.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if r, _, _, _ := rotated.At(x, y).RGBA(); r > 0 {
+					found = image.Pt(x, y)
+				}
+			}
+		}
+
+		x, y := unrotate(float64(found.X)+0.5, float64(found.Y)+0.5, o, 20, 10)
+		assert.Equal(t, 3.5, x, "orientation %d", o)
+		assert.Equal(t, 7.5, y, "orientation %d", o)
+	}
+}
+
+func TestExtractRetryRotated(t *testing.T) {
+	e := &Extractor{
+		Network: "test",
+		Detector: &mockDetector{detect: [][]Detection{
+			{},
+			{{Box: image.Rect(10, 20, 70, 100), Score: 0.9}},
+		}},
+		Landmark:    &mockLandmark{landmarks: []*Landmarks{gaspardLandmarks}},
+		Descriptor:  &mockDescriptor{descriptors: []Descriptors{{1, 2}}},
+		Orientation: RetryRotated,
+	}
+
+	items, err := e.ExtractItems(image.NewRGBA(image.Rect(0, 0, 200, 100)))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	assert.Equal(t, 90, items[0].Orientation)
+	assert.Equal(t, "test", items[0].Network)
+	assert.Equal(t, image.Rect(100, 10, 180, 70), items[0].Detection.Box)
+	assert.Equal(t, Descriptors{1, 2}, items[0].Descriptors)
+
+	// rotated back by 90 degrees clockwise, (u, v) in the box becomes
+	// (1-v, u)
+	require.Len(t, items[0].Landmarks.Coords, len(gaspardLandmarks.Coords))
+	assert.InDelta(t, 1-gaspardLandmarks.Coords[1], items[0].Landmarks.Coords[0], 1e-5)
+	assert.InDelta(t, gaspardLandmarks.Coords[0], items[0].Landmarks.Coords[1], 1e-5)
+}
+
+func TestExtractBestRotation(t *testing.T) {
+	e := &Extractor{
+		Detector: &mockDetector{detect: [][]Detection{
+			{{Box: image.Rect(10, 10, 70, 90), Score: 0.7}},
+			{},
+			{{Box: image.Rect(20, 10, 80, 90), Score: 0.9}},
+			{},
+		}},
+		Landmark:    &mockLandmark{landmarks: []*Landmarks{gaspardLandmarks, gaspardLandmarks}},
+		Descriptor:  &mockDescriptor{descriptors: []Descriptors{{1}, {2}}},
+		Orientation: BestRotation,
+	}
+
+	items, err := e.ExtractItems(image.NewRGBA(image.Rect(0, 0, 100, 100)))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	assert.Equal(t, 180, items[0].Orientation)
+	assert.Equal(t, image.Rect(20, 10, 80, 90), items[0].Detection.Box)
+	assert.Equal(t, Descriptors{2}, items[0].Descriptors)
+}
+
+func TestExtractUprightOnly(t *testing.T) {
+	e := &Extractor{
+		Detector: &mockDetector{detect: [][]Detection{{}}},
+	}
+
+	_, err := e.ExtractItems(image.NewRGBA(image.Rect(0, 0, 100, 100)))
+	assert.Equal(t, ErrNoFaceDetected, err)
+}
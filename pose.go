@@ -0,0 +1,55 @@
+package gildasai
+
+import (
+	"image"
+	"math"
+)
+
+// Pose is the rotation, in degrees, of an upright face
+type Pose struct {
+	// Roll is the angle of the line between the eyes with the horizontal,
+	// positive when the eye on the right of the image is the lowest
+	Roll float32
+	// Yaw is the rotation around the vertical axis, positive when the
+	// nose points to the right of the image
+	Yaw float32
+}
+
+// Pose estimates the pose of the face of box from its 68 landmarks. It
+// returns the zero Pose when the landmarks are missing.
+func (l *Landmarks) Pose(box image.Rectangle) Pose {
+	if len(l.Coords) < 68*2 {
+		return Pose{}
+	}
+
+	point := func(i int) (x, y float64) {
+		return float64(l.Coords[2*i]) * float64(box.Dx()),
+			float64(l.Coords[2*i+1]) * float64(box.Dy())
+	}
+	center := func(from, to int) (x, y float64) {
+		for i := from; i <= to; i++ {
+			px, py := point(i)
+			x, y = x+px, y+py
+		}
+		n := float64(to - from + 1)
+		return x / n, y / n
+	}
+
+	leftEyeX, leftEyeY := center(36, 41)
+	rightEyeX, rightEyeY := center(42, 47)
+	roll := math.Atan2(rightEyeY-leftEyeY, rightEyeX-leftEyeX)
+
+	// the nose tip is in the middle of the jaw ends when facing the camera
+	leftJawX, _ := point(0)
+	rightJawX, _ := point(16)
+	noseX, _ := point(30)
+	var yaw float64
+	if width := rightJawX - leftJawX; width > 0 {
+		yaw = math.Asin(math.Max(-1, math.Min(1, 2*(noseX-leftJawX)/width-1)))
+	}
+
+	return Pose{
+		Roll: float32(roll * 180 / math.Pi),
+		Yaw:  float32(yaw * 180 / math.Pi),
+	}
+}
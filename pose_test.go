@@ -0,0 +1,51 @@
+package gildasai
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syntheticLandmarks places the jaw ends, the eyes and the nose tip of a
+// face of 100x100 pixels
+func syntheticLandmarks(noseX, rightEyeY float32) *Landmarks {
+	l := &Landmarks{Coords: make([]float32, 68*2)}
+	set := func(i int, x, y float32) {
+		l.Coords[2*i], l.Coords[2*i+1] = x, y
+	}
+
+	set(0, 0.1, 0.4)
+	set(16, 0.9, 0.4)
+	for i := 36; i <= 41; i++ {
+		set(i, 0.3, 0.4)
+	}
+	for i := 42; i <= 47; i++ {
+		set(i, 0.7, rightEyeY)
+	}
+	set(30, noseX, 0.6)
+
+	return l
+}
+
+func TestPose(t *testing.T) {
+	box := image.Rect(0, 0, 100, 100)
+
+	pose := syntheticLandmarks(0.5, 0.4).Pose(box)
+	assert.InDelta(t, 0, pose.Yaw, 1e-3)
+	assert.InDelta(t, 0, pose.Roll, 1e-3)
+
+	pose = syntheticLandmarks(0.7, 0.4).Pose(box)
+	assert.InDelta(t, 30, pose.Yaw, 1e-3)
+	assert.InDelta(t, 0, pose.Roll, 1e-3)
+
+	pose = syntheticLandmarks(0.5, 0.8).Pose(box)
+	assert.InDelta(t, 45, pose.Roll, 1e-3)
+
+	assert.Equal(t, Pose{}, (&Landmarks{}).Pose(box))
+
+	// Gaspard tilts his head to the left of the image
+	pose = gaspardLandmarks.Pose(gaspardBounds)
+	assert.InDelta(t, -15.5, pose.Roll, 1, "roll %f", pose.Roll)
+	assert.True(t, pose.Yaw > -30 && pose.Yaw < 30, "yaw %f", pose.Yaw)
+}
@@ -0,0 +1,137 @@
+package gildasai
+
+import (
+	"context"
+
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/pkg/errors"
+)
+
+// ReextractFaces recomputes, with extractor, the faces of network
+// extractor.Network computed by another model than
+// extractor.Fingerprint. The faces of an image are replaced at once, the
+// old ones being kept until the new ones are stored and when the image
+// cannot be extracted. Cancelling ctx stops it once the image being
+// extracted is.
+func ReextractFaces(ctx context.Context, extractor *Extractor, store ModelStore) (*ModelUpdate, error) {
+	if extractor.Fingerprint == "" {
+		return nil, errors.New("the extractor has no fingerprint")
+	}
+
+	ids, err := store.OutdatedFaces(extractor.Network, extractor.Fingerprint)
+	if err != nil {
+		return nil, errors.Wrap(err, "error looking for the outdated faces")
+	}
+
+	return startUpdate(ctx, ids, func(id string) error {
+		items, err := faceItems(id, extractor)
+		if err != nil {
+			return err
+		}
+
+		err = store.ReplaceFaces(id, extractor.Network, items)
+		if err != nil {
+			return errors.Wrapf(err, "error replacing the faces of %q", id)
+		}
+		return nil
+	}), nil
+}
+
+// Reclassify recomputes, with classifier, the predictions of network
+// computed by another model than model, the fingerprint of classifier,
+// the 10 best predictions being stored. The predictions of an image are replaced at once, the old ones being
+// kept until the new ones are stored. Cancelling ctx stops it once the
+// image being classified is.
+func Reclassify(ctx context.Context, classifier Classifier, network, model string, store ModelStore) (*ModelUpdate, error) {
+	if model == "" {
+		return nil, errors.New("the classifier has no fingerprint")
+	}
+
+	ids, err := store.OutdatedPredictions(network, model)
+	if err != nil {
+		return nil, errors.Wrap(err, "error looking for the outdated predictions")
+	}
+
+	return startUpdate(ctx, ids, func(id string) error {
+		img, err := imageutils.FromFile(id)
+		if err != nil {
+			return errors.Wrapf(err, "error reading image from file %q", id)
+		}
+
+		preds, err := classifier.Classify(img)
+		if err != nil {
+			return errors.Wrapf(err, "error classifying image %q", id)
+		}
+
+		err = store.ReplacePredictions(id, network, preds.Best(10))
+		if err != nil {
+			return errors.Wrapf(err, "error replacing the predictions of %q", id)
+		}
+		return nil
+	}), nil
+}
+
+// ModelUpdate is a re-extraction or a re-classification running in the
+// background
+type ModelUpdate struct {
+	// Total is the number of images to update
+	Total int
+
+	events  chan ModelUpdateEvent
+	updated int
+	err     error
+}
+
+// ModelUpdateEvent tells how an image was updated
+type ModelUpdateEvent struct {
+	ID string
+	// Err is the error of a failed image, kept as it was
+	Err error
+}
+
+// Events are the events of the images, as they are updated. They are
+// closed once the update is over.
+func (u *ModelUpdate) Events() <-chan ModelUpdateEvent {
+	return u.events
+}
+
+// Wait waits for the end of the update, discarding the events which were
+// not read, and returns the number of images updated. The error is the
+// one of the context when the update was stopped before its end.
+func (u *ModelUpdate) Wait() (int, error) {
+	for range u.events {
+	}
+	return u.updated, u.err
+}
+
+// startUpdate runs do on each of ids in the background until ctx is done
+func startUpdate(ctx context.Context, ids []string, do func(id string) error) *ModelUpdate {
+	u := &ModelUpdate{
+		Total:  len(ids),
+		events: make(chan ModelUpdateEvent),
+	}
+
+	go func() {
+		defer close(u.events)
+
+		for _, id := range ids {
+			if ctx.Err() != nil {
+				break
+			}
+
+			err := do(id)
+			if err == nil {
+				u.updated++
+			}
+
+			select {
+			case u.events <- ModelUpdateEvent{ID: id, Err: err}:
+			case <-ctx.Done():
+			}
+		}
+
+		u.err = ctx.Err()
+	}()
+
+	return u
+}
@@ -0,0 +1,194 @@
+package gildasai_test
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runUpdate waits for the end of an update and returns the ids it went
+// through, its errors and the number of images updated
+func runUpdate(t *testing.T, u *gildasai.ModelUpdate, err error) ([]string, []error, int) {
+	require.NoError(t, err)
+
+	var ids []string
+	var failures []error
+	for event := range u.Events() {
+		ids = append(ids, event.ID)
+		if event.Err != nil {
+			failures = append(failures, event.Err)
+		}
+	}
+
+	n, err := u.Wait()
+	require.NoError(t, err)
+	return ids, failures, n
+}
+
+func TestReextractFaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reextract")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	outdated, current, missing := filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png"), filepath.Join(dir, "c.png")
+	writePNG(t, outdated)
+	writePNG(t, current)
+
+	store := memory.NewStore()
+	old := []*gildasai.FaceItem{
+		{Identifier: outdated, Network: "fake", Model: "fake@v1", Detection: gildasai.Detection{Box: image.Rect(0, 0, 10, 10)}},
+		{Identifier: current, Network: "fake", Model: "fake@v2"},
+		{Identifier: missing, Network: "fake"},
+	}
+	for _, f := range old {
+		require.NoError(t, store.StoreFace(f))
+	}
+
+	e := &gildasai.Extractor{
+		Network:     "fake",
+		Fingerprint: "fake@v2",
+		Detector:    &gildasaitest.Detector{Detections: [][]gildasai.Detection{twoFaces()}},
+		Landmark:    &gildasaitest.Landmark{},
+		Descriptor:  &gildasaitest.Descriptor{},
+	}
+
+	u, err := gildasai.ReextractFaces(context.Background(), e, store)
+	require.NoError(t, err)
+	assert.Equal(t, 2, u.Total)
+	ids, failures, n := runUpdate(t, u, err)
+	assert.Equal(t, []string{outdated, missing}, ids)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, 1, n)
+
+	faces, _, err := store.GetFaces(outdated)
+	require.NoError(t, err)
+	require.Len(t, faces, 2)
+	for _, f := range faces {
+		assert.Equal(t, "fake@v2", f.Model)
+	}
+
+	faces, _, err = store.GetFaces(missing)
+	require.NoError(t, err)
+	assert.Equal(t, []*gildasai.FaceItem{old[2]}, faces, "the faces are kept when the image cannot be read")
+
+	stale, err := store.OutdatedFaces("fake", "fake@v2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{missing}, stale)
+
+	e.Fingerprint = ""
+	_, err = gildasai.ReextractFaces(context.Background(), e, store)
+	assert.Error(t, err)
+}
+
+func TestReclassify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reclassify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	photo := filepath.Join(dir, "a.png")
+	writePNG(t, photo)
+
+	store := memory.NewStore()
+	require.NoError(t, store.StorePrediction(photo, &gildasai.PredictionItem{
+		Identifier: photo,
+		Predictions: gildasai.Predictions{
+			{Network: "xception", Model: "xception@v1", Label: "cow", Score: 0.4},
+		},
+	}))
+
+	classifier := &gildasaitest.Classifier{Predictions: []gildasai.Predictions{{
+		{Network: "xception", Model: "xception@v2", Label: "ox", Score: 0.9},
+	}}}
+
+	u, err := gildasai.Reclassify(context.Background(), classifier, "xception", "xception@v2", store)
+	require.NoError(t, err)
+	assert.Equal(t, 1, u.Total)
+	_, failures, n := runUpdate(t, u, err)
+	assert.Empty(t, failures)
+	assert.Equal(t, 1, n)
+
+	item, _, err := store.GetPrediction(photo)
+	require.NoError(t, err)
+	assert.Equal(t, classifier.Predictions[0], item.Predictions)
+}
+
+func TestReclassifyBest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reclassify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	photo := filepath.Join(dir, "a.png")
+	writePNG(t, photo)
+
+	store := memory.NewStore()
+	require.NoError(t, store.StorePrediction(photo, &gildasai.PredictionItem{
+		Identifier: photo,
+		Predictions: gildasai.Predictions{
+			{Network: "xception", Model: "xception@v1", Label: "cow", Score: 0.4},
+		},
+	}))
+
+	var preds, best gildasai.Predictions
+	for i := 0; i < 15; i++ {
+		preds = append(preds, gildasai.Prediction{
+			Network: "xception", Model: "xception@v2",
+			Label: fmt.Sprintf("label%d", i), Score: float32(i) / 15,
+		})
+	}
+	for i := 14; i >= 5; i-- {
+		best = append(best, preds[i])
+	}
+	classifier := &gildasaitest.Classifier{Predictions: []gildasai.Predictions{preds}}
+
+	u, err := gildasai.Reclassify(context.Background(), classifier, "xception", "xception@v2", store)
+	_, failures, n := runUpdate(t, u, err)
+	assert.Empty(t, failures)
+	assert.Equal(t, 1, n)
+
+	item, _, err := store.GetPrediction(photo)
+	require.NoError(t, err)
+	assert.Equal(t, best, item.Predictions, "only the 10 best predictions are stored")
+}
+
+func TestReextractFacesStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reextract")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := memory.NewStore()
+	for i := 0; i < 5; i++ {
+		photo := filepath.Join(dir, fmt.Sprintf("%d.png", i))
+		writePNG(t, photo)
+		require.NoError(t, store.StoreFace(&gildasai.FaceItem{Identifier: photo, Network: "fake", Model: "fake@v1"}))
+	}
+
+	detector := &gildasaitest.Detector{}
+	e := &gildasai.Extractor{
+		Network:     "fake",
+		Fingerprint: "fake@v2",
+		Detector:    detector,
+		Landmark:    &gildasaitest.Landmark{},
+		Descriptor:  &gildasaitest.Descriptor{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	u, err := gildasai.ReextractFaces(ctx, e, store)
+	require.NoError(t, err)
+	<-u.Events()
+	cancel()
+
+	n, err := u.Wait()
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, n < 5, "the update stops with the image being extracted")
+	assert.Equal(t, n, detector.CallCount("Detect"))
+}
@@ -0,0 +1,375 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/pkg/errors"
+)
+
+// Migration upgrades the schema of the store to Version. The first
+// migrations work on the files created before the schema was versioned,
+// whose tables already exist.
+type Migration struct {
+	Version     int
+	Description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations are sorted by version, starting at 1
+var migrations = []Migration{
+	{1, "create the predictions table", execMigration(`
+create table if not exists predictions (
+    id      text not null,
+    network text not null,
+    label   text not null,
+    score   real not null,
+    created timestamp default CURRENT_TIMESTAMP,
+    primary key (id, network, label)
+)`)},
+	{2, "create the faces table", execMigration(`
+create table if not exists faces (
+    id          text not null,
+    network     text not null,
+    detection   text not null,
+    landmarks   text not null,
+    descriptors text not null,
+    created     timestamp default CURRENT_TIMESTAMP,
+    primary key (id, network, detection)
+)`)},
+	{3, "add the orientation of the faces", func(tx *sql.Tx) error {
+		return addColumnIfMissing(tx, "faces", "orientation", "integer not null default 0")
+	}},
+	{4, "create the face_distances table", execMigration(`
+create table if not exists face_distances (
+    id1         text not null,
+    network1    text not null,
+    detection1  text not null,
+    id2         text not null,
+    network2    text not null,
+    detection2  text not null,
+    distance    real not null,
+    created     timestamp default CURRENT_TIMESTAMP,
+    primary key (id1, network1, detection1, id2, network2, detection2)
+)`)},
+	{5, "add the quality, the pose and the update time of the faces", func(tx *sql.Tx) error {
+		for _, c := range []struct{ column, definition string }{
+			{"quality", "real not null default 0"},
+			{"roll", "real not null default 0"},
+			{"yaw", "real not null default 0"},
+			{"updated", "timestamp"},
+		} {
+			if err := addColumnIfMissing(tx, "faces", c.column, c.definition); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`update faces set updated = created where updated is null`)
+		return err
+	}},
+	{6, "key the faces by integer id and store their landmarks and descriptors as blobs", faceIDs},
+	{7, "create the sources table", execMigration(`
+create table if not exists sources (
+    id       text not null primary key,
+    hash     text not null,
+    size     integer not null,
+    mod_time integer not null,
+    updated  timestamp default CURRENT_TIMESTAMP
+)`)},
+	{8, "add the fingerprint of the models of the faces and predictions", func(tx *sql.Tx) error {
+		for _, table := range []string{"faces", "predictions"} {
+			if err := addColumnIfMissing(tx, table, "model", "text not null default ''"); err != nil {
+				return err
+			}
+		}
+		return execMigration(`
+create index if not exists faces_network_model on faces(network, model);
+create index if not exists predictions_network_model on predictions(network, model);`)(tx)
+	}},
+	{9, "create the compared_faces table", execMigration(`
+create table if not exists compared_faces (
+    face_id integer not null primary key references faces(face_id) on delete cascade,
+    created timestamp default CURRENT_TIMESTAMP
+)`)},
+	{10, "create the face_graph and face_neighbours tables", execMigration(`
+create table if not exists face_graph (
+    face_id    integer not null primary key references faces(face_id) on delete cascade,
+    neighbours integer not null
+);
+create table if not exists face_neighbours (
+    face_id      integer not null references faces(face_id) on delete cascade,
+    neighbour_id integer not null references faces(face_id) on delete cascade,
+    distance     real not null,
+    primary key (face_id, neighbour_id)
+);`)},
+}
+
+func execMigration(stmt string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return errors.Wrapf(err, "error running the SQL for DB migration %q", stmt)
+		}
+		return nil
+	}
+}
+
+// faceIDs replaces the JSON detection of the keys of faces and
+// face_distances by the integer face_id
+func faceIDs(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+alter table faces rename to faces_v5;
+alter table face_distances rename to face_distances_v5;
+
+create table faces (
+    face_id     integer primary key autoincrement,
+    id          text not null,
+    network     text not null,
+    box_min_x   integer not null,
+    box_min_y   integer not null,
+    box_max_x   integer not null,
+    box_max_y   integer not null,
+    score       real not null,
+    class       real not null,
+    landmarks   blob not null,
+    descriptors blob not null,
+    orientation integer not null default 0,
+    quality     real not null default 0,
+    roll        real not null default 0,
+    yaw         real not null default 0,
+    created     timestamp default CURRENT_TIMESTAMP,
+    updated     timestamp,
+    unique (id, network, box_min_x, box_min_y, box_max_x, box_max_y, score, class)
+);
+
+create table face_distances (
+    face_id1 integer not null references faces(face_id) on delete cascade,
+    face_id2 integer not null references faces(face_id) on delete cascade,
+    distance real not null,
+    created  timestamp default CURRENT_TIMESTAMP,
+    primary key (face_id1, face_id2)
+);`)
+	if err != nil {
+		return errors.Wrap(err, "error creating the tables keyed by face_id")
+	}
+
+	type oldFace struct {
+		id, network, detection, landmarks, descriptors string
+		orientation                                    int
+		quality, roll, yaw                             float32
+		created, updated                               interface{}
+	}
+	var faces []oldFace
+	rows, err := tx.Query(`
+select id, network, detection, landmarks, descriptors, orientation, quality, roll, yaw, created, updated
+from faces_v5`)
+	if err != nil {
+		return errors.Wrap(err, "error reading the faces")
+	}
+	for rows.Next() {
+		var f oldFace
+		err = rows.Scan(&f.id, &f.network, &f.detection, &f.landmarks, &f.descriptors,
+			&f.orientation, &f.quality, &f.roll, &f.yaw, &f.created, &f.updated)
+		if err != nil {
+			rows.Close()
+			return errors.Wrap(err, "error reading the faces")
+		}
+		faces = append(faces, f)
+	}
+	rows.Close()
+
+	// faceIDs are the new ids by old key
+	faceIDs := map[[3]string]int64{}
+	for _, f := range faces {
+		var detection gildasai.Detection
+		var landmarks gildasai.Landmarks
+		var descriptors gildasai.Descriptors
+		if err := json.Unmarshal([]byte(f.detection), &detection); err != nil {
+			return errors.Wrapf(err, "invalid detection %q of %q", f.detection, f.id)
+		}
+		if err := json.Unmarshal([]byte(f.landmarks), &landmarks); err != nil {
+			return errors.Wrapf(err, "invalid landmarks of %q", f.id)
+		}
+		if err := json.Unmarshal([]byte(f.descriptors), &descriptors); err != nil {
+			return errors.Wrapf(err, "invalid descriptors of %q", f.id)
+		}
+
+		box := detection.Box
+		res, err := tx.Exec(`
+insert into faces(id, network,
+  box_min_x, box_min_y, box_max_x, box_max_y, score, class,
+  landmarks, descriptors, orientation, quality, roll, yaw, created, updated)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+			f.id, f.network,
+			box.Min.X, box.Min.Y, box.Max.X, box.Max.Y, detection.Score, detection.Class,
+			floatsToBlob(landmarks.Coords), floatsToBlob(descriptors),
+			f.orientation, f.quality, f.roll, f.yaw, f.created, f.updated)
+		if err != nil {
+			return errors.Wrapf(err, "error copying face %q of %q", f.detection, f.id)
+		}
+		faceIDs[[3]string{f.id, f.network, f.detection}], err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+	}
+
+	type oldDistance struct {
+		face1, face2 [3]string
+		distance     float32
+		created      interface{}
+	}
+	var distances []oldDistance
+	rows, err = tx.Query(`
+select id1, network1, detection1, id2, network2, detection2, distance, created
+from face_distances_v5`)
+	if err != nil {
+		return errors.Wrap(err, "error reading the face distances")
+	}
+	for rows.Next() {
+		var d oldDistance
+		err = rows.Scan(&d.face1[0], &d.face1[1], &d.face1[2],
+			&d.face2[0], &d.face2[1], &d.face2[2], &d.distance, &d.created)
+		if err != nil {
+			rows.Close()
+			return errors.Wrap(err, "error reading the face distances")
+		}
+		distances = append(distances, d)
+	}
+	rows.Close()
+
+	for _, d := range distances {
+		id1, ok1 := faceIDs[d.face1]
+		id2, ok2 := faceIDs[d.face2]
+		if !ok1 || !ok2 {
+			continue // the distance to a face which was not stored
+		}
+
+		_, err = tx.Exec(`
+insert into face_distances(face_id1, face_id2, distance, created)
+values ($1, $2, $3, $4)`, id1, id2, d.distance, d.created)
+		if err != nil {
+			return errors.Wrap(err, "error copying the face distances")
+		}
+	}
+
+	_, err = tx.Exec(`
+drop table face_distances_v5;
+drop table faces_v5;`)
+	if err != nil {
+		return errors.Wrap(err, "error dropping the tables keyed by detection")
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the version of the schema of the store, 0 for a
+// new file or one created before the schema was versioned
+func (c *Store) SchemaVersion() (int, error) {
+	var tables int
+	err := c.QueryRow(`
+select count(*) from sqlite_master
+where type = 'table' and name = 'schema_version'`).Scan(&tables)
+	if err != nil {
+		return 0, errors.Wrap(err, "error looking for the schema_version table")
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	err = c.QueryRow(`select max(version) from schema_version`).Scan(&version)
+	if err != nil {
+		return 0, errors.Wrap(err, "error reading the schema version")
+	}
+
+	return int(version.Int64), nil
+}
+
+// Migrate applies, in order, the migrations the store is missing and
+// returns them. With dryRun, it only returns them.
+func (c *Store) Migrate(dryRun bool) ([]Migration, error) {
+	version, err := c.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+
+	if dryRun {
+		return pending, nil
+	}
+
+	for i, m := range pending {
+		if err := c.apply(m); err != nil {
+			return pending[:i], errors.Wrapf(err, "error migrating to version %d (%s)", m.Version, m.Description)
+		}
+	}
+
+	return pending, nil
+}
+
+func (c *Store) apply(m Migration) error {
+	tx, err := c.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+create table if not exists schema_version (
+    version     integer not null primary key,
+    description text not null,
+    applied     timestamp default CURRENT_TIMESTAMP
+)`)
+	if err != nil {
+		return errors.Wrap(err, "error creating the schema_version table")
+	}
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+insert into schema_version(version, description)
+values ($1, $2)`, m.Version, m.Description)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds the column to the tables created before it was
+// introduced
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query("pragma table_info(" + table + ")")
+	if err != nil {
+		return errors.Wrapf(err, "error reading the columns of table %q", table)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return errors.Wrapf(err, "error reading the columns of table %q", table)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	stmt := "alter table " + table + " add column " + column + " " + definition
+	if _, err := tx.Exec(stmt); err != nil {
+		return errors.Wrapf(err, "error running the SQL for DB migration %q", stmt)
+	}
+
+	return nil
+}
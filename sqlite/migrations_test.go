@@ -0,0 +1,182 @@
+package sqlite
+
+import (
+	"database/sql"
+	"image"
+	"os"
+	"testing"
+
+	gildasai "github.com/gildasch/gildas-ai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func versions(migrations []Migration) []int {
+	var v []int
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Description)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	defer os.Remove("/tmp/gildasai.test.sqlite")
+
+	s, err := Open("/tmp/gildasai.test.sqlite")
+	require.NoError(t, err)
+	defer s.Close()
+
+	pending, err := s.Migrate(true)
+	require.NoError(t, err)
+	assert.Equal(t, versions(migrations), versions(pending))
+
+	version, err := s.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	var tables int
+	require.NoError(t, s.QueryRow(`select count(*) from sqlite_master where type = 'table'`).Scan(&tables))
+	assert.Equal(t, 0, tables, "a dry run does not touch the file")
+
+	applied, err := s.Migrate(false)
+	require.NoError(t, err)
+	assert.Equal(t, versions(migrations), versions(applied))
+
+	version, err = s.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+
+	pending, err = s.Migrate(true)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestMigrateUnversionedFile(t *testing.T) {
+	defer os.Remove("/tmp/gildasai.test.sqlite")
+
+	// the schema before the migrations
+	db, err := sql.Open("sqlite3", "/tmp/gildasai.test.sqlite")
+	require.NoError(t, err)
+	_, err = db.Exec(`
+create table predictions (
+    id      text not null,
+    network text not null,
+    label   text not null,
+    score   real not null,
+    created timestamp default CURRENT_TIMESTAMP,
+    primary key (id, network, label)
+);
+create table faces (
+    id          text not null,
+    network     text not null,
+    detection   text not null,
+    landmarks   text not null,
+    descriptors text not null,
+    orientation integer not null default 0,
+    created     timestamp default CURRENT_TIMESTAMP,
+    primary key (id, network, detection)
+);
+insert into faces(id, network, detection, landmarks, descriptors, orientation)
+values ('old', 'face-api-js', '{}', '{}', '[]', 180);`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := NewStore("/tmp/gildasai.test.sqlite")
+	require.NoError(t, err)
+	defer s.Close()
+
+	version, err := s.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+
+	actual, ok, err := s.GetFaces("old")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 180, actual[0].Orientation)
+	assert.Equal(t, float32(0), actual[0].Quality)
+
+	var updated sql.NullString
+	require.NoError(t, s.QueryRow(`select updated from faces where id = 'old'`).Scan(&updated))
+	assert.True(t, updated.Valid)
+
+	err = s.StoreFace(testFaceItems[1])
+	require.NoError(t, err)
+	actual, ok, err = s.GetFaces(testFaceItems[1].Identifier)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, testFaceItems[1], actual[0])
+}
+
+func TestMigrateFaceIDs(t *testing.T) {
+	defer os.Remove("/tmp/gildasai.test.sqlite")
+
+	s, err := Open("/tmp/gildasai.test.sqlite")
+	require.NoError(t, err)
+	defer s.Close()
+
+	// a file at version 5, with its faces keyed by JSON detection
+	for _, m := range migrations[:5] {
+		require.NoError(t, s.apply(m))
+	}
+	_, err = s.Exec(`
+insert into faces(id, network, detection, landmarks, descriptors, orientation, quality)
+values
+  ('a.jpg', 'face-api-js', '{"Box":{"Min":{"X":1,"Y":2},"Max":{"X":3,"Y":4}},"Score":0.9,"Class":1}', '{"Coords":[1,2]}', '[0.5,0.25]', 90, 0.8),
+  ('b.jpg', 'face-api-js', '{"Box":{"Min":{"X":5,"Y":6},"Max":{"X":7,"Y":8}},"Score":0.7,"Class":1}', '{"Coords":[3,4]}', '[0.125]', 0, 0.6);
+insert into face_distances(id1, network1, detection1, id2, network2, detection2, distance)
+values
+  ('a.jpg', 'face-api-js', '{"Box":{"Min":{"X":1,"Y":2},"Max":{"X":3,"Y":4}},"Score":0.9,"Class":1}',
+   'b.jpg', 'face-api-js', '{"Box":{"Min":{"X":5,"Y":6},"Max":{"X":7,"Y":8}},"Score":0.7,"Class":1}', 0.42),
+  ('a.jpg', 'face-api-js', '{"Box":{"Min":{"X":1,"Y":2},"Max":{"X":3,"Y":4}},"Score":0.9,"Class":1}',
+   'gone.jpg', 'face-api-js', '{}', 0.1);`)
+	require.NoError(t, err)
+
+	applied, err := s.Migrate(false)
+	require.NoError(t, err)
+	assert.Equal(t, versions(migrations[5:]), versions(applied))
+
+	faces, err := s.GetAllFaces()
+	require.NoError(t, err)
+	assert.Equal(t, []*gildasai.FaceItem{
+		{
+			ID:          1,
+			Identifier:  "a.jpg",
+			Network:     "face-api-js",
+			Detection:   gildasai.Detection{Box: image.Rect(1, 2, 3, 4), Score: 0.9, Class: 1},
+			Landmarks:   gildasai.Landmarks{Coords: []float32{1, 2}},
+			Descriptors: gildasai.Descriptors{0.5, 0.25},
+			Orientation: 90,
+			Quality:     0.8,
+		},
+		{
+			ID:          2,
+			Identifier:  "b.jpg",
+			Network:     "face-api-js",
+			Detection:   gildasai.Detection{Box: image.Rect(5, 6, 7, 8), Score: 0.7, Class: 1},
+			Landmarks:   gildasai.Landmarks{Coords: []float32{3, 4}},
+			Descriptors: gildasai.Descriptors{0.125},
+			Quality:     0.6,
+		},
+	}, faces)
+
+	distances, err := s.GetAllFaceDistances()
+	require.NoError(t, err)
+	require.Len(t, distances, 1, "the distance to a missing face is dropped")
+	assert.Equal(t, int64(1), distances[0].Face1.ID)
+	assert.Equal(t, int64(2), distances[0].Face2.ID)
+	assert.Equal(t, float32(0.42), distances[0].Distance)
+
+	var tables int
+	require.NoError(t, s.QueryRow(`
+select count(*) from sqlite_master
+where type = 'table' and name like '%_v5'`).Scan(&tables))
+	assert.Equal(t, 0, tables)
+}
@@ -2,7 +2,11 @@ package sqlite
 
 import (
 	"database/sql"
-	"encoding/json"
+	"encoding/binary"
+	"image"
+	"math"
+	"strings"
+	"time"
 
 	gildasai "github.com/gildasch/gildas-ai"
 	_ "github.com/mattn/go-sqlite3"
@@ -13,67 +17,45 @@ type Store struct {
 	*sql.DB
 }
 
+// NewStore opens the sqlite file and migrates it to the latest schema
 func NewStore(filename string) (*Store, error) {
-	db, err := sql.Open("sqlite3", filename)
+	s, err := Open(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	createPredictionsDBStmt := `
-create table if not exists predictions (
-    id      text not null,
-    network text not null,
-    label   text not null,
-    score   real not null,
-    created timestamp default CURRENT_TIMESTAMP,
-    primary key (id, network, label)
-)
-	`
-	_, err = db.Exec(createPredictionsDBStmt)
+	_, err = s.Migrate(false)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error running the SQL for DB creation %q\n", createPredictionsDBStmt)
+		s.Close()
+		return nil, err
 	}
 
-	createFaceDBStmt := `
-create table if not exists faces (
-    id          text not null,
-    network     text not null,
-    detection   text not null,
-    landmarks   text not null,
-    descriptors text not null,
-    created     timestamp default CURRENT_TIMESTAMP,
-    primary key (id, network, detection)
-)
-	`
-	_, err = db.Exec(createFaceDBStmt)
-	if err != nil {
-		return nil, errors.Wrapf(err, "error running the SQL for DB creation %q\n", createFaceDBStmt)
-	}
-
-	createFaceDistancesDBStmt := `
-create table if not exists face_distances (
-    id1         text not null,
-    network1    text not null,
-    detection1  text not null,
-    id2         text not null,
-    network2    text not null,
-    detection2  text not null,
-    distance    real not null,
-    created     timestamp default CURRENT_TIMESTAMP,
-    primary key (id1, network1, detection1, id2, network2, detection2)
-)
-	`
-	_, err = db.Exec(createFaceDistancesDBStmt)
+	return s, nil
+}
+
+// Open opens the sqlite file without migrating it. The foreign keys are
+// enforced. The filename may have the query params of the driver.
+func Open(filename string) (*Store, error) {
+	db, err := sql.Open("sqlite3", foreignKeysDSN(filename))
 	if err != nil {
-		return nil, errors.Wrapf(err, "error running the SQL for DB creation %q\n", createFaceDBStmt)
+		return nil, err
 	}
 
 	return &Store{db}, nil
 }
 
+// foreignKeysDSN adds the param enforcing the foreign keys to the query
+// params of filename
+func foreignKeysDSN(filename string) string {
+	if strings.Contains(filename, "?") {
+		return filename + "&_foreign_keys=1"
+	}
+	return filename + "?_foreign_keys=1"
+}
+
 func (c *Store) GetPrediction(id string) (*gildasai.PredictionItem, bool, error) {
 	rows, err := c.Query(`
-select network, label, score
+select network, model, label, score
 from predictions
 where id = $1
 order by score desc`, id)
@@ -84,14 +66,15 @@ order by score desc`, id)
 
 	var preds gildasai.Predictions
 	for rows.Next() {
-		var network, label string
+		var network, model, label string
 		var score float32
-		err = rows.Scan(&network, &label, &score)
+		err = rows.Scan(&network, &model, &label, &score)
 		if err != nil {
 			return nil, true, err
 		}
 		preds = append(preds, gildasai.Prediction{
 			Network: network,
+			Model:   model,
 			Label:   label,
 			Score:   score,
 		})
@@ -113,14 +96,9 @@ func (c *Store) StorePrediction(id string, item *gildasai.PredictionItem) error
 	}
 	defer tx.Rollback()
 
-	for _, p := range item.Predictions {
-		_, err := tx.Exec(`
-insert into predictions(id, network, label, score)
-values ($1, $2, $3, $4)`,
-			item.Identifier, p.Network, p.Label, p.Score)
-		if err != nil {
-			return err
-		}
+	err = insertPredictions(tx, item.Identifier, item.Predictions)
+	if err != nil {
+		return err
 	}
 
 	err = tx.Commit()
@@ -131,13 +109,27 @@ values ($1, $2, $3, $4)`,
 	return nil
 }
 
+func insertPredictions(tx *sql.Tx, id string, predictions gildasai.Predictions) error {
+	for _, p := range predictions {
+		_, err := tx.Exec(`
+insert into predictions(id, network, model, label, score)
+values ($1, $2, $3, $4, $5)`,
+			id, p.Network, p.Model, p.Label, p.Score)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c *Store) SearchPrediction(query, after string, n int) ([]*gildasai.PredictionItem, error) {
 	var rows *sql.Rows
 	var err error
 
 	if query != "" && after != "" {
 		rows, err = c.Query(`
-select id, network, label, score
+select id, network, model, label, score
 from predictions
 where id in (
   select id from predictions
@@ -147,7 +139,7 @@ where id in (
 order by score desc`, "%"+query+"%", after, n)
 	} else if query != "" {
 		rows, err = c.Query(`
-select id, network, label, score
+select id, network, model, label, score
 from predictions
 where id in (
   select id from predictions
@@ -157,14 +149,14 @@ where id in (
 order by score desc`, "%"+query+"%", n)
 	} else if after != "" {
 		rows, err = c.Query(`
-select distinct id, network, label, score
+select distinct id, network, model, label, score
 from predictions
 where id > $2
 order by score desc
 limit $3`, after, n)
 	} else {
 		rows, err = c.Query(`
-select distinct id, network, label, score
+select distinct id, network, model, label, score
 from predictions
 order by score desc
 limit $3`, n)
@@ -176,15 +168,16 @@ limit $3`, n)
 
 	preds := map[string]gildasai.Predictions{}
 	for rows.Next() {
-		var id, networks, label string
+		var id, networks, model, label string
 		var score float32
-		err := rows.Scan(&id, &networks, &label, &score)
+		err := rows.Scan(&id, &networks, &model, &label, &score)
 		if err != nil {
 			return nil, errors.Wrapf(err, "error scanning sqlite store")
 		}
 
 		preds[id] = append(preds[id], gildasai.Prediction{
 			Network: networks,
+			Model:   model,
 			Label:   label,
 			Score:   score})
 	}
@@ -198,24 +191,90 @@ limit $3`, n)
 	return items, nil
 }
 
-func (c *Store) StoreFace(item *gildasai.FaceItem) error {
-	detection, err := json.Marshal(item.Detection)
+// faceColumns are the columns read by scanFace
+const faceColumns = `face_id, id, network, model,
+  box_min_x, box_min_y, box_max_x, box_max_y, score, class,
+  landmarks, descriptors, orientation, quality, roll, yaw`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFace(row scanner) (*gildasai.FaceItem, error) {
+	var item gildasai.FaceItem
+	var box struct{ minX, minY, maxX, maxY int }
+	var landmarks, descriptors []byte
+	err := row.Scan(&item.ID, &item.Identifier, &item.Network, &item.Model,
+		&box.minX, &box.minY, &box.maxX, &box.maxY, &item.Detection.Score, &item.Detection.Class,
+		&landmarks, &descriptors, &item.Orientation, &item.Quality, &item.Pose.Roll, &item.Pose.Yaw)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	landmarks, err := json.Marshal(item.Landmarks)
+	item.Detection.Box = image.Rect(box.minX, box.minY, box.maxX, box.maxY)
+
+	item.Landmarks.Coords, err = blobToFloats(landmarks)
 	if err != nil {
-		return err
+		return nil, errors.Wrapf(err, "invalid landmarks of face %d", item.ID)
+	}
+	descrs, err := blobToFloats(descriptors)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid descriptors of face %d", item.ID)
+	}
+	item.Descriptors = gildasai.Descriptors(descrs)
+
+	return &item, nil
+}
+
+// floatsToBlob encodes f as little-endian float32s
+func floatsToBlob(f []float32) []byte {
+	// an empty but not nil slice, for the column not to be NULL
+	b := make([]byte, 4*len(f))
+	for i, v := range f {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+func blobToFloats(b []byte) ([]float32, error) {
+	if len(b)%4 != 0 {
+		return nil, errors.Errorf("blob of %d bytes is not made of float32s", len(b))
+	}
+	if len(b) == 0 {
+		return nil, nil
 	}
-	descriptors, err := json.Marshal(item.Descriptors)
+
+	f := make([]float32, len(b)/4)
+	for i := range f {
+		f[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return f, nil
+}
+
+func (c *Store) StoreFace(item *gildasai.FaceItem) error {
+	return insertFace(c, item)
+}
+
+// execer is a *sql.DB or a *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertFace(db execer, item *gildasai.FaceItem) error {
+	box := item.Detection.Box
+	res, err := db.Exec(`
+insert into faces(id, network, model,
+  box_min_x, box_min_y, box_max_x, box_max_y, score, class,
+  landmarks, descriptors, orientation, quality, roll, yaw, updated)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, CURRENT_TIMESTAMP)`,
+		item.Identifier, item.Network, item.Model,
+		box.Min.X, box.Min.Y, box.Max.X, box.Max.Y, item.Detection.Score, item.Detection.Class,
+		floatsToBlob(item.Landmarks.Coords), floatsToBlob(item.Descriptors),
+		item.Orientation, item.Quality, item.Pose.Roll, item.Pose.Yaw)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.Exec(`
-insert into faces(id, network, detection, landmarks, descriptors)
-values ($1, $2, $3, $4, $5)`,
-		item.Identifier, item.Network, string(detection), string(landmarks), string(descriptors))
+	item.ID, err = res.LastInsertId()
 	if err != nil {
 		return err
 	}
@@ -223,136 +282,510 @@ values ($1, $2, $3, $4, $5)`,
 	return nil
 }
 
+func (c *Store) GetFace(faceID int64) (*gildasai.FaceItem, bool, error) {
+	item, err := scanFace(c.QueryRow(`
+select `+faceColumns+`
+from faces
+where face_id = $1`, faceID))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return item, true, nil
+}
+
 func (c *Store) GetFaces(id string) ([]*gildasai.FaceItem, bool, error) {
-	rows, err := c.Query(`
-select id, network, detection, landmarks, descriptors
+	items, err := c.queryFaces(`
+select `+faceColumns+`
 from faces
-where id = $1`, id)
+where id = $1
+order by face_id`, id)
 	if err != nil {
 		return nil, false, err
 	}
+
+	if len(items) == 0 {
+		return nil, false, nil
+	}
+
+	return items, true, nil
+}
+
+func (c *Store) GetAllFaces() ([]*gildasai.FaceItem, error) {
+	return c.queryFaces(`
+select ` + faceColumns + `
+from faces
+order by face_id`)
+}
+
+func (c *Store) queryFaces(query string, args ...interface{}) ([]*gildasai.FaceItem, error) {
+	rows, err := c.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var items []*gildasai.FaceItem
 	for rows.Next() {
-		var item gildasai.FaceItem
-		var detection, landmarks, descriptors string
-		err = rows.Scan(&item.Identifier, &item.Network, &detection, &landmarks, &descriptors)
+		item, err := scanFace(rows)
 		if err != nil {
-			return nil, false, err
+			return nil, err
 		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (c *Store) StoreFaceDistance(item1, item2 *gildasai.FaceItem, distance float32) error {
+	_, err := c.Exec(`
+insert into face_distances(face_id1, face_id2, distance)
+values ($1, $2, $3)`,
+		item1.ID, item2.ID, distance)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
 
-		err = json.Unmarshal([]byte(detection), &item.Detection)
+func (c *Store) GetFaceDistance(item1, item2 *gildasai.FaceItem) (float32, bool, error) {
+	var distance float32
+	err := c.QueryRow(`
+select distance
+from face_distances
+where face_id1 = $1 and face_id2 = $2`,
+		item1.ID, item2.ID).Scan(&distance)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return distance, true, nil
+}
+
+func (c *Store) GetAllFaceDistances() ([]*gildasai.FaceDistance, error) {
+	rows, err := c.Query(`
+select
+  f1.face_id, f1.id, f1.network, f1.box_min_x, f1.box_min_y, f1.box_max_x, f1.box_max_y, f1.score, f1.class,
+  f2.face_id, f2.id, f2.network, f2.box_min_x, f2.box_min_y, f2.box_max_x, f2.box_max_y, f2.score, f2.class,
+  d.distance
+from face_distances d
+join faces f1 on f1.face_id = d.face_id1
+join faces f2 on f2.face_id = d.face_id2
+order by d.distance`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var distances []*gildasai.FaceDistance
+	for rows.Next() {
+		d := gildasai.FaceDistance{
+			Face1: &gildasai.FaceItem{},
+			Face2: &gildasai.FaceItem{},
+		}
+		var box1, box2 struct{ minX, minY, maxX, maxY int }
+		err = rows.Scan(
+			&d.Face1.ID, &d.Face1.Identifier, &d.Face1.Network,
+			&box1.minX, &box1.minY, &box1.maxX, &box1.maxY, &d.Face1.Detection.Score, &d.Face1.Detection.Class,
+			&d.Face2.ID, &d.Face2.Identifier, &d.Face2.Network,
+			&box2.minX, &box2.minY, &box2.maxX, &box2.maxY, &d.Face2.Detection.Score, &d.Face2.Detection.Class,
+			&d.Distance)
 		if err != nil {
-			return nil, false, err
+			return nil, err
 		}
-		err = json.Unmarshal([]byte(landmarks), &item.Landmarks)
+		d.Face1.Detection.Box = image.Rect(box1.minX, box1.minY, box1.maxX, box1.maxY)
+		d.Face2.Detection.Box = image.Rect(box2.minX, box2.minY, box2.maxX, box2.maxY)
+
+		distances = append(distances, &d)
+	}
+
+	return distances, rows.Err()
+}
+
+// StoreFaceDistances stores distances, replacing the ones already
+// stored, and marks the faces of compared in the same transaction
+func (c *Store) StoreFaceDistances(distances []*gildasai.FaceDistance, compared []int64) error {
+	tx, err := c.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare(`
+insert or replace into face_distances(face_id1, face_id2, distance)
+values ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, d := range distances {
+		_, err = insert.Exec(d.Face1.ID, d.Face2.ID, d.Distance)
 		if err != nil {
-			return nil, false, err
+			return errors.Wrapf(err, "error storing the distance between faces %d and %d", d.Face1.ID, d.Face2.ID)
 		}
-		err = json.Unmarshal([]byte(descriptors), &item.Descriptors)
+	}
+
+	mark, err := tx.Prepare(`insert or ignore into compared_faces(face_id) values ($1)`)
+	if err != nil {
+		return err
+	}
+	defer mark.Close()
+
+	for _, faceID := range compared {
+		_, err = mark.Exec(faceID)
 		if err != nil {
-			return nil, false, err
+			return errors.Wrapf(err, "error marking face %d as compared", faceID)
 		}
+	}
 
-		items = append(items, &item)
+	return tx.Commit()
+}
+
+func (c *Store) ComparedFaces() ([]int64, error) {
+	rows, err := c.Query(`select face_id from compared_faces order by face_id`)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	if len(items) == 0 {
+	var faceIDs []int64
+	for rows.Next() {
+		var faceID int64
+		if err := rows.Scan(&faceID); err != nil {
+			return nil, err
+		}
+		faceIDs = append(faceIDs, faceID)
+	}
+
+	return faceIDs, rows.Err()
+}
+
+// StoreNeighbours replaces the neighbours of the faces of neighbours in
+// the same transaction. The number of neighbours stored is kept to tell
+// when some of them were deleted.
+func (c *Store) StoreNeighbours(neighbours []*gildasai.FaceNeighbours) error {
+	tx, err := c.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare(`
+insert into face_neighbours(face_id, neighbour_id, distance)
+values ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, n := range neighbours {
+		_, err = tx.Exec(`delete from face_neighbours where face_id = $1`, n.Face.ID)
+		if err != nil {
+			return errors.Wrapf(err, "error deleting the neighbours of face %d", n.Face.ID)
+		}
+		_, err = tx.Exec(`
+insert or replace into face_graph(face_id, neighbours)
+values ($1, $2)`, n.Face.ID, len(n.Neighbours))
+		if err != nil {
+			return errors.Wrapf(err, "error storing face %d in the graph", n.Face.ID)
+		}
+
+		for _, neighbour := range n.Neighbours {
+			_, err = insert.Exec(n.Face.ID, neighbour.Face.ID, neighbour.Distance)
+			if err != nil {
+				return errors.Wrapf(err, "error storing the neighbour %d of face %d", neighbour.Face.ID, n.Face.ID)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (c *Store) GetNeighbours(faceID int64) (*gildasai.FaceNeighbours, bool, error) {
+	neighbours, err := c.neighbours(`where g.face_id = $1`, `where n.face_id = $1`, faceID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(neighbours) == 0 {
 		return nil, false, nil
 	}
 
-	return items, true, nil
+	return neighbours[0], true, nil
 }
 
-func (c *Store) GetAllFaces() ([]*gildasai.FaceItem, error) {
+func (c *Store) GetAllNeighbours() ([]*gildasai.FaceNeighbours, error) {
+	return c.neighbours("", "")
+}
+
+const identityColumns = `f.face_id, f.id, f.network, f.box_min_x, f.box_min_y, f.box_max_x, f.box_max_y, f.score, f.class`
+
+// identity holds the identityColumns of a face
+type identity struct {
+	item gildasai.FaceItem
+	box  struct{ minX, minY, maxX, maxY int }
+}
+
+func (i *identity) dest() []interface{} {
+	return []interface{}{
+		&i.item.ID, &i.item.Identifier, &i.item.Network,
+		&i.box.minX, &i.box.minY, &i.box.maxX, &i.box.maxY, &i.item.Detection.Score, &i.item.Detection.Class,
+	}
+}
+
+func (i *identity) face() *gildasai.FaceItem {
+	item := i.item
+	item.Detection.Box = image.Rect(i.box.minX, i.box.minY, i.box.maxX, i.box.maxY)
+	return &item
+}
+
+// neighbours returns the faces of the graph matching whereGraph, by face
+// ID, with their neighbours matching whereNeighbours
+func (c *Store) neighbours(whereGraph, whereNeighbours string, args ...interface{}) ([]*gildasai.FaceNeighbours, error) {
 	rows, err := c.Query(`
-select id, network, detection, landmarks, descriptors
-from faces`)
+select g.neighbours, `+identityColumns+`
+from face_graph g
+join faces f on f.face_id = g.face_id
+`+whereGraph+`
+order by g.face_id`, args...)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "error querying the face graph")
 	}
 	defer rows.Close()
 
-	var items []*gildasai.FaceItem
+	var list []*gildasai.FaceNeighbours
+	byID := map[int64]*gildasai.FaceNeighbours{}
+	counts := map[int64]int{}
 	for rows.Next() {
-		var item gildasai.FaceItem
-		var detection, landmarks, descriptors string
-		err = rows.Scan(&item.Identifier, &item.Network, &detection, &landmarks, &descriptors)
-		if err != nil {
+		var count int
+		var i identity
+		if err := rows.Scan(append([]interface{}{&count}, i.dest()...)...); err != nil {
 			return nil, err
 		}
+		n := &gildasai.FaceNeighbours{Face: i.face()}
+		list = append(list, n)
+		byID[n.Face.ID] = n
+		counts[n.Face.ID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		err = json.Unmarshal([]byte(detection), &item.Detection)
-		if err != nil {
+	rows, err = c.Query(`
+select n.face_id, n.distance, `+identityColumns+`
+from face_neighbours n
+join faces f on f.face_id = n.neighbour_id
+`+whereNeighbours+`
+order by n.face_id, n.distance`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying the face neighbours")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var faceID int64
+		var distance float32
+		var i identity
+		if err := rows.Scan(append([]interface{}{&faceID, &distance}, i.dest()...)...); err != nil {
 			return nil, err
 		}
-		err = json.Unmarshal([]byte(landmarks), &item.Landmarks)
+		if n, ok := byID[faceID]; ok {
+			n.Neighbours = append(n.Neighbours, gildasai.FaceNeighbour{Face: i.face(), Distance: distance})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, n := range list {
+		n.Stale = len(n.Neighbours) < counts[n.Face.ID]
+	}
+	return list, nil
+}
+
+func (c *Store) GetSource(id string) (*gildasai.Source, bool, error) {
+	source := gildasai.Source{Identifier: id}
+	var modTime int64
+	err := c.QueryRow(`
+select hash, size, mod_time
+from sources
+where id = $1`, id).Scan(&source.Hash, &source.Size, &modTime)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	source.ModTime = time.Unix(0, modTime)
+
+	return &source, true, nil
+}
+
+func (c *Store) StoreSource(source *gildasai.Source) error {
+	_, err := c.Exec(`
+insert or replace into sources(id, hash, size, mod_time, updated)
+values ($1, $2, $3, $4, CURRENT_TIMESTAMP)`,
+		source.Identifier, source.Hash, source.Size, source.ModTime.UnixNano())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Store) GetAllSources() ([]*gildasai.Source, error) {
+	rows, err := c.Query(`
+select id, hash, size, mod_time
+from sources
+order by id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []*gildasai.Source
+	for rows.Next() {
+		var source gildasai.Source
+		var modTime int64
+		err = rows.Scan(&source.Identifier, &source.Hash, &source.Size, &modTime)
 		if err != nil {
 			return nil, err
 		}
-		err = json.Unmarshal([]byte(descriptors), &item.Descriptors)
+		source.ModTime = time.Unix(0, modTime)
+		sources = append(sources, &source)
+	}
+
+	return sources, rows.Err()
+}
+
+// DeleteSource deletes the source of id with its faces and its
+// predictions, the distances of the faces being deleted with them
+func (c *Store) DeleteSource(id string) error {
+	tx, err := c.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"predictions", "faces", "sources"} {
+		_, err = tx.Exec(`delete from `+table+` where id = $1`, id)
 		if err != nil {
-			return nil, err
+			return errors.Wrapf(err, "error deleting the %s of %q", table, id)
 		}
+	}
 
-		items = append(items, &item)
+	return tx.Commit()
+}
+
+func (c *Store) PredictedIdentifiers() ([]string, error) {
+	rows, err := c.Query(`
+select distinct id
+from predictions
+order by id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying the predicted identifiers")
 	}
+	defer rows.Close()
 
-	return items, nil
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
 }
 
-func (c *Store) StoreFaceDistance(item1, item2 *gildasai.FaceItem, distance float32) error {
-	detection1, err := json.Marshal(item1.Detection)
+func (c *Store) OutdatedFaces(network, model string) ([]string, error) {
+	return c.outdated("faces", network, model)
+}
+
+func (c *Store) OutdatedPredictions(network, model string) ([]string, error) {
+	return c.outdated("predictions", network, model)
+}
+
+func (c *Store) outdated(table, network, model string) ([]string, error) {
+	rows, err := c.Query(`
+select distinct id
+from `+table+`
+where network = $1 and model != $2
+order by id`, network, model)
 	if err != nil {
-		return err
+		return nil, errors.Wrapf(err, "error querying the outdated %s", table)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
-	detection2, err := json.Marshal(item2.Detection)
+
+	return ids, rows.Err()
+}
+
+// ReplaceFaces deletes the faces of network of id, with their distances,
+// and stores items in the same transaction
+func (c *Store) ReplaceFaces(id, network string, items []*gildasai.FaceItem) error {
+	tx, err := c.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = c.Exec(`
-insert into face_distances(id1, network1, detection1, id2, network2, detection2, distance)
-values ($1, $2, $3, $4, $5, $6, $7)`,
-		item1.Identifier, item1.Network, string(detection1),
-		item2.Identifier, item2.Network, string(detection2), distance)
+	_, err = tx.Exec(`delete from faces where id = $1 and network = $2`, id, network)
 	if err != nil {
+		return errors.Wrapf(err, "error deleting the faces of %q", id)
+	}
+
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		// the IDs are only set once committed
+		stored := *item
+		if err := insertFace(tx, &stored); err != nil {
+			return errors.Wrapf(err, "error storing the faces of %q", id)
+		}
+		ids[i] = stored.ID
+	}
+
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
+	for i, item := range items {
+		item.ID = ids[i]
+	}
 	return nil
 }
 
-func (c *Store) GetFaceDistance(item1, item2 *gildasai.FaceItem) (float32, bool, error) {
-	detection1, err := json.Marshal(item1.Detection)
+func (c *Store) ReplacePredictions(id, network string, predictions gildasai.Predictions) error {
+	tx, err := c.Begin()
 	if err != nil {
-		return 0, false, err
+		return err
 	}
-	detection2, err := json.Marshal(item2.Detection)
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`delete from predictions where id = $1 and network = $2`, id, network)
 	if err != nil {
-		return 0, false, err
+		return errors.Wrapf(err, "error deleting the predictions of %q", id)
 	}
 
-	var distance float32
-	err = c.QueryRow(`
-select distance
-from face_distances
-where
-  id1       = $1 and
-  network1  = $2 and
-  detection1 = $3 and
-  id2       = $4 and
-  network2  = $5 and
-  detection2 = $6`,
-		item1.Identifier, item1.Network, string(detection1),
-		item2.Identifier, item2.Network, string(detection2)).Scan(&distance)
-	if err == sql.ErrNoRows {
-		return 0, false, nil
-	}
-	if err != nil {
-		return 0, false, err
+	if err := insertPredictions(tx, id, predictions); err != nil {
+		return errors.Wrapf(err, "error storing the predictions of %q", id)
 	}
 
-	return distance, true, nil
+	return tx.Commit()
 }
@@ -1,11 +1,14 @@
 package sqlite
 
 import (
+	"database/sql"
 	"image"
+	"io/ioutil"
 	"os"
 	"testing"
 
 	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -54,6 +57,19 @@ func TestStoreAndSearch(t *testing.T) {
 	assert.Len(t, actuals, 0)
 }
 
+func TestOpenWithParams(t *testing.T) {
+	s, err := Open("/tmp/gildasai.test.sqlite?_busy_timeout=2000")
+	require.NoError(t, err)
+	defer s.Close()
+	defer os.Remove("/tmp/gildasai.test.sqlite")
+
+	var foreignKeys, busyTimeout int
+	require.NoError(t, s.QueryRow("pragma foreign_keys").Scan(&foreignKeys))
+	require.NoError(t, s.QueryRow("pragma busy_timeout").Scan(&busyTimeout))
+	assert.Equal(t, 1, foreignKeys)
+	assert.Equal(t, 2000, busyTimeout)
+}
+
 var testPredictionItems = []*gildasai.PredictionItem{
 	{
 		Identifier: "a wonderful picture of my ox",
@@ -144,6 +160,7 @@ var testFaceItems = []*gildasai.FaceItem{
 		Descriptors: gildasai.Descriptors(
 			[]float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 72914.4366},
 		),
+		Orientation: 90,
 	},
 	{
 		Identifier: "just of picture of my desk plus Jim",
@@ -160,3 +177,55 @@ var testFaceItems = []*gildasai.FaceItem{
 		),
 	},
 }
+
+func TestAddOrientationToExistingFaces(t *testing.T) {
+	defer os.Remove("/tmp/gildasai.test.sqlite")
+
+	db, err := sql.Open("sqlite3", "/tmp/gildasai.test.sqlite")
+	require.NoError(t, err)
+	_, err = db.Exec(`
+create table faces (
+    id          text not null,
+    network     text not null,
+    detection   text not null,
+    landmarks   text not null,
+    descriptors text not null,
+    created     timestamp default CURRENT_TIMESTAMP,
+    primary key (id, network, detection)
+);
+insert into faces(id, network, detection, landmarks, descriptors)
+values ('old', 'face-api-js', '{}', '{}', '[]');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	s, err := NewStore("/tmp/gildasai.test.sqlite")
+	require.NoError(t, err)
+	defer s.Close()
+
+	actual, ok, err := s.GetFaces("old")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 0, actual[0].Orientation)
+
+	err = s.StoreFace(testFaceItems[1])
+	require.NoError(t, err)
+	actual, ok, err = s.GetFaces(testFaceItems[1].Identifier)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 90, actual[0].Orientation)
+}
+
+func TestStoreConformance(t *testing.T) {
+	gildasaitest.TestStore(t, func(t *testing.T) (gildasaitest.Store, func()) {
+		f, err := ioutil.TempFile("", "gildasai.*.sqlite")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		s, err := NewStore(f.Name())
+		require.NoError(t, err)
+		return s, func() {
+			s.Close()
+			os.Remove(f.Name())
+		}
+	})
+}
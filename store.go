@@ -1,5 +1,7 @@
 package gildasai
 
+import "time"
+
 type PredictionItem struct {
 	Identifier  string
 	Predictions Predictions
@@ -12,20 +14,121 @@ type PredictionStore interface {
 }
 
 type FaceItem struct {
-	Identifier  string
-	Network     string
+	// ID is the key of the face in the store, set by StoreFace
+	ID         int64
+	Identifier string
+	Network    string
+	// Model is the fingerprint of the models which computed the face
+	Model       string
 	Detection   Detection
 	Landmarks   Landmarks
 	Descriptors Descriptors
+	// Orientation is the rotation, in degrees counter-clockwise, of the
+	// image for the face to be upright
+	Orientation int
+	// Quality is the score of the detection times the confidence of the
+	// landmarks
+	Quality float32
+	Pose    Pose
 }
 
 type FaceStore interface {
+	// StoreFace stores item and sets its ID
 	StoreFace(item *FaceItem) error
+	GetFace(faceID int64) (*FaceItem, bool, error)
 	GetFaces(id string) ([]*FaceItem, bool, error)
 	GetAllFaces() ([]*FaceItem, error)
 }
 
+// FaceDistance is the distance from Face1 to Face2. The faces of the
+// distances returned by the stores only have their ID, Identifier,
+// Network and Detection set.
+type FaceDistance struct {
+	Face1, Face2 *FaceItem
+	Distance     float32
+}
+
+// FaceDistanceStore keeps the distances between stored faces, by their
+// ID
 type FaceDistanceStore interface {
 	StoreFaceDistance(item1, item2 *FaceItem, distance float32) error
 	GetFaceDistance(item1, item2 *FaceItem) (float32, bool, error)
+	// GetAllFaceDistances returns the distances by increasing distance
+	GetAllFaceDistances() ([]*FaceDistance, error)
+	// StoreFaceDistances stores, at once, distances, replacing the ones
+	// already stored, and marks the faces of compared as compared to the
+	// other faces
+	StoreFaceDistances(distances []*FaceDistance, compared []int64) error
+	// ComparedFaces returns the sorted IDs of the faces marked as
+	// compared
+	ComparedFaces() ([]int64, error)
+}
+
+// FaceNeighbour is a neighbour of a face, at Distance
+type FaceNeighbour struct {
+	Face     *FaceItem
+	Distance float32
+}
+
+// FaceNeighbours are the nearest neighbours of Face, by increasing
+// distance. The faces only have their ID, Identifier, Network and
+// Detection set.
+type FaceNeighbours struct {
+	Face       *FaceItem
+	Neighbours []FaceNeighbour
+	// Stale tells that some of the neighbours were deleted since they
+	// were stored
+	Stale bool
+}
+
+// FaceNeighbourStore keeps the nearest neighbours of the faces, the
+// neighbours of a deleted face being marked stale
+type FaceNeighbourStore interface {
+	// StoreNeighbours replaces, at once, the neighbours of the faces of
+	// neighbours
+	StoreNeighbours(neighbours []*FaceNeighbours) error
+	GetNeighbours(faceID int64) (*FaceNeighbours, bool, error)
+	// GetAllNeighbours returns the neighbours of the faces by face ID
+	GetAllNeighbours() ([]*FaceNeighbours, error)
+}
+
+// Source is the file the faces and the predictions of an identifier come
+// from, as it was when they were extracted
+type Source struct {
+	Identifier string
+	// Hash is the hexadecimal SHA-256 of the content of the file
+	Hash    string
+	Size    int64
+	ModTime time.Time
+}
+
+type SourceStore interface {
+	GetSource(id string) (*Source, bool, error)
+	// StoreSource stores source, replacing the one of its identifier
+	StoreSource(source *Source) error
+	// GetAllSources returns the sources sorted by identifier
+	GetAllSources() ([]*Source, error)
+	// DeleteSource deletes the source of id with its faces, their
+	// distances and its predictions
+	DeleteSource(id string) error
+	// PredictedIdentifiers returns the sorted identifiers having
+	// predictions, whether they have a source or not
+	PredictedIdentifiers() ([]string, error)
+}
+
+// ModelStore finds the faces and predictions computed by other versions
+// of the models and replaces them
+type ModelStore interface {
+	// OutdatedFaces returns the sorted identifiers having faces of
+	// network computed by another model than model
+	OutdatedFaces(network, model string) ([]string, error)
+	// ReplaceFaces replaces, at once, the faces of network of id by items
+	// and sets their ID
+	ReplaceFaces(id, network string, items []*FaceItem) error
+	// OutdatedPredictions returns the sorted identifiers having
+	// predictions of network computed by another model than model
+	OutdatedPredictions(network, model string) ([]string, error)
+	// ReplacePredictions replaces, at once, the predictions of network of
+	// id by predictions
+	ReplacePredictions(id, network string, predictions Predictions) error
 }
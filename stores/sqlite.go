@@ -0,0 +1,16 @@
+//go:build cgo
+// +build cgo
+
+package stores
+
+import "github.com/gildasch/gildas-ai/sqlite"
+
+func init() {
+	openers["sqlite"] = func(path string) (Store, error) {
+		s, err := sqlite.NewStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
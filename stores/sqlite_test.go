@@ -0,0 +1,31 @@
+//go:build cgo
+// +build cgo
+
+package stores
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gildasch/gildas-ai/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSqlite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stores")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, url := range []string{
+		"sqlite://" + filepath.Join(dir, "store.sqlite"),
+		filepath.Join(dir, ".inception.sqlite"),
+	} {
+		s, err := Open(url)
+		require.NoError(t, err, url)
+		assert.IsType(t, &sqlite.Store{}, s, url)
+		assert.NoError(t, s.Close())
+	}
+}
@@ -0,0 +1,72 @@
+// Package stores opens the store implementations by URL:
+//
+//	sqlite:///path/to/file.sqlite
+//	bolt:///path/to/file.bolt
+//	memory://
+//
+// A URL without scheme is the filename of a sqlite store. The sqlite
+// store is only available in the binaries built with cgo.
+package stores
+
+import (
+	"strings"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/bolt"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/pkg/errors"
+)
+
+// Store is implemented by all the stores
+type Store interface {
+	gildasai.PredictionStore
+	gildasai.FaceStore
+	gildasai.FaceDistanceStore
+	gildasai.FaceNeighbourStore
+	gildasai.SourceStore
+	gildasai.ModelStore
+	Close() error
+}
+
+// openers open a store from the path of its URL, by scheme
+var openers = map[string]func(path string) (Store, error){
+	"bolt": func(path string) (Store, error) {
+		s, err := bolt.NewStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	},
+	"memory": func(string) (Store, error) {
+		return memory.NewStore(), nil
+	},
+}
+
+// Open returns the store of storeURL
+func Open(storeURL string) (Store, error) {
+	scheme, path := "sqlite", storeURL
+	if i := strings.Index(storeURL, "://"); i > 0 {
+		scheme, path = storeURL[:i], storeURL[i+len("://"):]
+	} else if storeURL == "memory:" {
+		scheme, path = "memory", ""
+	}
+
+	open, ok := openers[scheme]
+	if !ok && scheme == "sqlite" {
+		return nil, errors.Errorf("cannot open %q: sqlite stores need a binary built with cgo", storeURL)
+	}
+	if !ok {
+		return nil, errors.Errorf("cannot open %q: unknown store %q", storeURL, scheme)
+	}
+
+	if path == "" && scheme != "memory" {
+		return nil, errors.Errorf("cannot open %q: missing file", storeURL)
+	}
+
+	s, err := open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening store %q", storeURL)
+	}
+
+	return s, nil
+}
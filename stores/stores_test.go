@@ -0,0 +1,41 @@
+package stores
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gildasch/gildas-ai/bolt"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stores")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		url      string
+		expected interface{}
+	}{
+		{"memory://", &memory.Store{}},
+		{"memory:", &memory.Store{}},
+		{"bolt://" + filepath.Join(dir, "store.bolt"), &bolt.Store{}},
+	}
+
+	for _, tt := range tests {
+		s, err := Open(tt.url)
+		require.NoError(t, err, tt.url)
+		assert.IsType(t, tt.expected, s, tt.url)
+		assert.NoError(t, s.Close())
+	}
+
+	_, err = Open("mongodb://localhost")
+	assert.Error(t, err)
+
+	_, err = Open("bolt://")
+	assert.Error(t, err)
+}
@@ -15,7 +15,29 @@ import (
 	"github.com/pkg/errors"
 )
 
+type SwapOptions struct {
+	Blur float64
+
+	// Swap are the regions of the destination faces replaced by the
+	// source face. The whole face is swapped when both Swap and Keep are
+	// empty.
+	Swap []SwapRegion
+	// Keep are the regions of the destination faces left untouched, even
+	// when they are inside of a swapped region.
+	Keep []SwapRegion
+}
+
+func (o SwapOptions) regional() bool {
+	return len(o.Swap) > 0 || len(o.Keep) > 0
+}
+
 func FaceSwap(extractor *Extractor, detector Landmark, dest, src image.Image, blur float64) (image.Image, error) {
+	return FaceSwapWithOptions(extractor, detector, dest, src, SwapOptions{Blur: blur})
+}
+
+func FaceSwapWithOptions(extractor *Extractor, detector Landmark, dest, src image.Image, opts SwapOptions) (image.Image, error) {
+	blur := opts.Blur
+
 	srcLandmarks, srcCrops, err := extractor.ExtractLandmarks(src)
 	if err != nil {
 		return nil, errors.Wrap(err, "error extracting landmarks from src")
@@ -25,17 +47,14 @@ func FaceSwap(extractor *Extractor, detector Landmark, dest, src image.Image, bl
 		return nil, errors.New("no face detected in src image")
 	}
 
-	destBlurred := imaging.Blur(dest, blur)
-	destBlurredAligned := image.NewRGBA(dest.Bounds())
-	draw.Draw(destBlurredAligned, dest.Bounds(), destBlurred, image.ZP, draw.Src)
-	destLandmarks, destCrops, err := extractor.ExtractLandmarks(destBlurredAligned)
+	destLandmarks, destCrops, err := extractor.ExtractLandmarks(blurred(dest, blur))
 	if err != nil {
 		return nil, errors.Wrap(err, "error extracting landmarks from dest")
 	}
 
 	for i := range destLandmarks {
 		fmt.Println("bounds before", destCrops[i].Bounds())
-		destCrops[i], err = swap(detector, srcCrops[0], destCrops[i], blur)
+		destCrops[i], err = swap(detector, srcCrops[0], destCrops[i], opts)
 		if err != nil {
 			return nil, errors.Wrapf(err, "error swapping face %d", i)
 		}
@@ -54,15 +73,23 @@ func FaceSwap(extractor *Extractor, detector Landmark, dest, src image.Image, bl
 	return out, nil
 }
 
+// blurred returns img blurred by sigma, with the bounds of img, as the
+// faces of the destination of a swap are detected in
+func blurred(img image.Image, sigma float64) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, img.Bounds(), imaging.Blur(img, sigma), image.ZP, draw.Src)
+	return out
+}
+
 var counter = 1
 
-func swap(detector Landmark, src, dest image.Image, blur float64) (image.Image, error) {
+func swap(detector Landmark, src, dest image.Image, opts SwapOptions) (image.Image, error) {
+	blur := opts.Blur
+
 	// gg.SavePNG(fmt.Sprintf("out-swap-crop-src-%d.png", counter), src)
 	// gg.SavePNG(fmt.Sprintf("out-swap-crop-dest-%d.png", counter), dest)
 
-	destBlurred := imaging.Blur(dest, blur)
-	destBlurredAligned := image.NewRGBA(dest.Bounds())
-	draw.Draw(destBlurredAligned, dest.Bounds(), destBlurred, image.ZP, draw.Src)
+	destBlurredAligned := blurred(dest, blur)
 
 	src = resize.Resize(uint(dest.Bounds().Dx()), uint(dest.Bounds().Dy()), src, resize.NearestNeighbor)
 
@@ -90,10 +117,15 @@ func swap(detector Landmark, src, dest image.Image, blur float64) (image.Image,
 	out := image.NewRGBA(dest.Bounds())
 	draw.Draw(out, out.Bounds(), dest, dest.Bounds().Min, draw.Src)
 
-	maskIn := image.NewRGBA(out.Bounds())
-	mask := maskFromPolygon(maskIn, simplify(destLandmarks))
-	maskAligned := image.NewRGBA(out.Bounds())
-	draw.Draw(maskAligned, out.Bounds(), mask, image.ZP, draw.Src)
+	var maskAligned draw.Image
+	if opts.regional() {
+		maskAligned = regionsMask(out.Bounds(), destLandmarks, opts.Swap, opts.Keep)
+	} else {
+		maskIn := image.NewRGBA(out.Bounds())
+		mask := maskFromPolygon(maskIn, simplify(destLandmarks))
+		maskAligned = image.NewRGBA(out.Bounds())
+		draw.Draw(maskAligned, out.Bounds(), mask, image.ZP, draw.Src)
+	}
 
 	// gg.SavePNG(fmt.Sprintf("out-swap-mask-%d.png", counter), distorted)
 
@@ -274,7 +306,7 @@ func blend(on *image.RGBA, to image.Image) {
 	}
 }
 
-func feather(on, mask, to *image.RGBA, center image.Point) {
+func feather(on *image.RGBA, mask image.Image, to *image.RGBA, center image.Point) {
 	center.X += on.Bounds().Min.X
 	center.Y += on.Bounds().Min.Y
 
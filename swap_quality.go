@@ -0,0 +1,132 @@
+package gildasai
+
+import (
+	"image"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+type SwapQuality struct {
+	Faces []FaceSwapQuality
+}
+
+type FaceSwapQuality struct {
+	// Detection is the face of the destination
+	Detection Detection
+	// Failed tells that no face of the swapped image was found at the
+	// position of the face of the destination, the distances and the
+	// error being left to 0
+	Failed bool
+	// DistanceToSource is the descriptors distance between the swapped
+	// face and the source face. The lower the better.
+	DistanceToSource float32
+	// DistanceToDestination is the descriptors distance between the
+	// swapped face and the face it replaced. The higher the better.
+	DistanceToDestination float32
+	// LandmarksError is the mean distance between the landmarks of the
+	// swapped face and the ones of the face it replaced, relative to the
+	// size of the face.
+	LandmarksError float32
+}
+
+// swapMatchIoU is the intersection over union above which a face of the
+// swapped image is the one of the destination
+const swapMatchIoU = 0.5
+
+// FaceSwapWithQuality swaps the faces and evaluates the result with
+// EvaluateSwap, against the blurred dest the faces were detected in.
+func FaceSwapWithQuality(extractor *Extractor, detector Landmark, dest, src image.Image, opts SwapOptions) (image.Image, *SwapQuality, error) {
+	swapped, err := FaceSwapWithOptions(extractor, detector, dest, src, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quality, err := EvaluateSwap(extractor, blurred(dest, opts.Blur), src, swapped)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error evaluating the swap")
+	}
+
+	return swapped, quality, nil
+}
+
+// EvaluateSwap detects the faces of swapped and compares each of them,
+// matched to a face of dest by the intersection over union of their
+// boxes, to the first face of src and to the face of dest. The faces of
+// dest without a matching face in swapped are reported as failed.
+func EvaluateSwap(extractor *Extractor, dest, src, swapped image.Image) (*SwapQuality, error) {
+	_, _, _, _, _, srcDescrs, err := extractor.extract(src, 0.4, none)
+	if err != nil {
+		return nil, errors.Wrap(err, "error extracting src face")
+	}
+	if len(srcDescrs) == 0 {
+		return nil, errors.New("no face detected in src image")
+	}
+
+	destDetections, _, destLandmarks, _, _, destDescrs, err := extractor.extract(dest, 0.4, none)
+	if err != nil {
+		return nil, errors.Wrap(err, "error extracting dest faces")
+	}
+
+	swappedDetections, _, swappedLandmarks, _, _, swappedDescrs, err := extractor.extract(swapped, 0.4, none)
+	if err != nil && err != ErrNoFaceDetected {
+		return nil, errors.Wrap(err, "error extracting swapped faces")
+	}
+
+	quality := &SwapQuality{}
+	matched := map[int]bool{}
+	for i, d := range destDetections {
+		j := -1
+		best := swapMatchIoU
+		for k, s := range swappedDetections {
+			if iou := IntersectionOverUnion(d.Box, s.Box); !matched[k] && iou > best {
+				j, best = k, iou
+			}
+		}
+		if j < 0 {
+			quality.Faces = append(quality.Faces, FaceSwapQuality{Detection: d, Failed: true})
+			continue
+		}
+		matched[j] = true
+
+		toSource, err := swappedDescrs[j].DistanceTo(srcDescrs[0])
+		if err != nil {
+			return nil, err
+		}
+		toDestination, err := swappedDescrs[j].DistanceTo(destDescrs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		quality.Faces = append(quality.Faces, FaceSwapQuality{
+			Detection:             d,
+			DistanceToSource:      toSource,
+			DistanceToDestination: toDestination,
+			LandmarksError:        landmarksError(&swappedLandmarks[j], &destLandmarks[i]),
+		})
+	}
+
+	return quality, nil
+}
+
+// landmarksError is the mean distance between the points of l1 and l2.
+// As landmarks coordinates are relative to the detection box, so is the
+// error.
+func landmarksError(l1, l2 *Landmarks) float32 {
+	n := len(l1.Coords)
+	if len(l2.Coords) < n {
+		n = len(l2.Coords)
+	}
+	if n < 2 {
+		return 0
+	}
+
+	sum := 0.0
+	for i := 0; i < n-1; i += 2 {
+		dx := float64(l1.Coords[i] - l2.Coords[i])
+		dy := float64(l1.Coords[i+1] - l2.Coords[i+1])
+		sum += math.Sqrt(dx*dx + dy*dy)
+	}
+
+	return float32(sum / float64(n/2))
+}
@@ -0,0 +1,185 @@
+package gildasai
+
+import (
+	"image"
+	"testing"
+
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDescriptor struct {
+	descriptors []Descriptors
+}
+
+func (m *mockDescriptor) Compute(img image.Image) (Descriptors, error) {
+	if len(m.descriptors) == 0 {
+		return nil, errors.New("no more descriptors available")
+	}
+	defer func() { m.descriptors = m.descriptors[1:] }()
+
+	return m.descriptors[0], nil
+}
+
+func TestEvaluateSwap(t *testing.T) {
+	srcBox := image.Rect(10, 10, 110, 110)
+	destBox1 := image.Rect(0, 0, 100, 100)
+	destBox2 := image.Rect(100, 0, 200, 100)
+
+	extractor := &Extractor{
+		Detector: &mockDetector{detect: [][]Detection{
+			{{Box: srcBox, Score: 0.9}},
+			{{Box: destBox1, Score: 0.9}, {Box: destBox2, Score: 0.9}},
+			// the faces of the swapped image, matched by their boxes
+			{{Box: destBox2, Score: 0.9}, {Box: destBox1.Add(image.Pt(2, 2)), Score: 0.9}},
+		}},
+		Landmark: &mockLandmark{landmarks: []*Landmarks{
+			gaspardLandmarks,                // src
+			gaspardLandmarks,                // dest 1
+			gaspardLandmarks,                // dest 2
+			gaspardLandmarks,                // swapped 2
+			shifted(gaspardLandmarks, 0.05), // swapped 1
+		}},
+		Descriptor: &mockDescriptor{descriptors: []Descriptors{
+			{0, 0}, // src
+			{3, 4}, // dest 1
+			{0, 1}, // dest 2
+			{0, 1}, // swapped 2
+			{0, 1}, // swapped 1
+		}},
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+
+	quality, err := EvaluateSwap(extractor, img, img, img)
+	require.NoError(t, err)
+	require.Len(t, quality.Faces, 2)
+
+	assert.Equal(t, destBox1, quality.Faces[0].Detection.Box)
+	assert.False(t, quality.Faces[0].Failed)
+	assert.InDelta(t, 1, quality.Faces[0].DistanceToSource, 0.0001)
+	assert.InDelta(t, 4.2426, quality.Faces[0].DistanceToDestination, 0.0001)
+	assert.InDelta(t, 0.05, quality.Faces[0].LandmarksError, 0.0001)
+
+	assert.Equal(t, destBox2, quality.Faces[1].Detection.Box)
+	assert.InDelta(t, 1, quality.Faces[1].DistanceToSource, 0.0001)
+	assert.InDelta(t, 0, quality.Faces[1].DistanceToDestination, 0.0001)
+	assert.InDelta(t, 0, quality.Faces[1].LandmarksError, 0.0001)
+}
+
+func shifted(l *Landmarks, dy float32) *Landmarks {
+	out := &Landmarks{Coords: make([]float32, len(l.Coords))}
+	for i, c := range l.Coords {
+		if i%2 == 1 {
+			c += dy
+		}
+		out.Coords[i] = c
+	}
+	return out
+}
+
+func TestEvaluateSwapNoSwappedFace(t *testing.T) {
+	destBox1 := image.Rect(0, 0, 100, 100)
+	destBox2 := image.Rect(100, 0, 200, 100)
+
+	extractor := &Extractor{
+		Detector: &mockDetector{detect: [][]Detection{
+			{{Box: destBox1, Score: 0.9}},
+			{{Box: destBox1, Score: 0.9}, {Box: destBox2, Score: 0.9}},
+			// the first face was destroyed by the swap
+			{{Box: destBox2, Score: 0.9}},
+		}},
+		Landmark: &mockLandmark{landmarks: []*Landmarks{
+			gaspardLandmarks, gaspardLandmarks, gaspardLandmarks, gaspardLandmarks,
+		}},
+		Descriptor: &mockDescriptor{descriptors: []Descriptors{
+			{0, 0}, {3, 4}, {0, 1}, {0, 0},
+		}},
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+
+	quality, err := EvaluateSwap(extractor, img, img, img)
+	require.NoError(t, err)
+	require.Len(t, quality.Faces, 2)
+	assert.Equal(t, FaceSwapQuality{Detection: Detection{Box: destBox1, Score: 0.9}, Failed: true}, quality.Faces[0])
+	assert.False(t, quality.Faces[1].Failed)
+	assert.InDelta(t, 0, quality.Faces[1].DistanceToSource, 0.0001)
+
+	// no face at all in the swapped image
+	extractor.Detector = &mockDetector{detect: [][]Detection{
+		{{Box: destBox1, Score: 0.9}},
+		{{Box: destBox1, Score: 0.9}},
+		{},
+	}}
+	extractor.Landmark = &mockLandmark{landmarks: []*Landmarks{gaspardLandmarks, gaspardLandmarks}}
+	extractor.Descriptor = &mockDescriptor{descriptors: []Descriptors{{0, 0}, {3, 4}}}
+
+	quality, err = EvaluateSwap(extractor, img, img, img)
+	require.NoError(t, err)
+	require.Len(t, quality.Faces, 1)
+	assert.True(t, quality.Faces[0].Failed)
+}
+
+func TestEvaluateSwapNoSourceFace(t *testing.T) {
+	extractor := &Extractor{
+		Detector: &mockDetector{detect: [][]Detection{{}}},
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+
+	_, err := EvaluateSwap(extractor, img, img, img)
+	assert.Error(t, err)
+}
+
+// recordingDetector detects the same faces in all the images, which it
+// records
+type recordingDetector struct {
+	detections []Detection
+	images     []image.Image
+}
+
+func (d *recordingDetector) Detect(img image.Image) ([]Detection, error) {
+	d.images = append(d.images, img)
+	return d.detections, nil
+}
+
+type constantLandmark struct{}
+
+func (constantLandmark) Detect(img image.Image) (*Landmarks, error) {
+	return &Landmarks{Coords: append([]float32(nil), gaspardLandmarks.Coords...)}, nil
+}
+
+type constantDescriptor struct{}
+
+func (constantDescriptor) Compute(img image.Image) (Descriptors, error) {
+	return Descriptors{0, 1}, nil
+}
+
+func TestFaceSwapWithQualityBlurred(t *testing.T) {
+	src, err := imageutils.FromFile("testdata/gab.png")
+	require.NoError(t, err)
+	dest, err := imageutils.FromFile("testdata/syl.png")
+	require.NoError(t, err)
+
+	detector := &recordingDetector{detections: []Detection{{Box: dest.Bounds(), Score: 0.9}}}
+	extractor := &Extractor{
+		Detector:   detector,
+		Landmark:   constantLandmark{},
+		Descriptor: constantDescriptor{},
+	}
+
+	swapped, quality, err := FaceSwapWithQuality(extractor, constantLandmark{}, dest, src, SwapOptions{Blur: 2})
+	require.NoError(t, err)
+	require.Len(t, quality.Faces, 1)
+
+	// src and dest are detected by the swap, then by the evaluation
+	// along with the swapped image
+	require.Len(t, detector.images, 5)
+	assert.Equal(t, detector.images[1], detector.images[3], "the quality is evaluated on the blurred dest")
+	assert.NotEqual(t, dest, detector.images[3])
+	assert.Equal(t, swapped, detector.images[4], "the faces are detected again on the swapped image")
+	assert.False(t, quality.Faces[0].Failed)
+}
@@ -0,0 +1,104 @@
+package gildasai
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// SwapRegion is a part of the face delimited by a polygon built from the
+// 68 landmarks points.
+type SwapRegion struct {
+	Polygon func(landmarks []image.Point) []image.Point
+	// Feather is the radius, in pixels, of the blur smoothing the border
+	// of the region.
+	Feather float64
+}
+
+var (
+	// RegionFace is the region swapped by default: the jaw line and the
+	// raised eyebrows.
+	RegionFace = SwapRegion{Polygon: simplify, Feather: 3}
+
+	RegionRightEye = SwapRegion{Polygon: landmarksPolygon(0.6, 36, 37, 38, 39, 40, 41), Feather: 2}
+	RegionLeftEye  = SwapRegion{Polygon: landmarksPolygon(0.6, 42, 43, 44, 45, 46, 47), Feather: 2}
+	RegionNose     = SwapRegion{Polygon: landmarksPolygon(0.2, 27, 35, 34, 33, 32, 31), Feather: 3}
+	RegionMouth    = SwapRegion{Polygon: landmarksPolygon(0.2, 48, 49, 50, 51, 52, 53, 54, 55, 56, 57, 58, 59), Feather: 3}
+)
+
+// Swap options for the most common partial swaps
+var (
+	SwapExpressionPreserving = SwapOptions{
+		Swap: []SwapRegion{RegionFace},
+		Keep: []SwapRegion{RegionRightEye, RegionLeftEye, RegionMouth},
+	}
+	SwapEyesOnly = SwapOptions{
+		Swap: []SwapRegion{RegionRightEye, RegionLeftEye},
+	}
+)
+
+// landmarksPolygon returns the polygon joining the given landmarks points,
+// moved away from their center by grow times their distance to it.
+func landmarksPolygon(grow float64, indices ...int) func([]image.Point) []image.Point {
+	return func(landmarks []image.Point) []image.Point {
+		var cx, cy float64
+		for _, i := range indices {
+			cx += float64(landmarks[i].X)
+			cy += float64(landmarks[i].Y)
+		}
+		cx /= float64(len(indices))
+		cy /= float64(len(indices))
+
+		var out []image.Point
+		for _, i := range indices {
+			p := landmarks[i]
+			out = append(out, image.Point{
+				X: p.X + int(grow*(float64(p.X)-cx)),
+				Y: p.Y + int(grow*(float64(p.Y)-cy)),
+			})
+		}
+		return out
+	}
+}
+
+// regionsMask returns a mask covering the union of the swap regions minus
+// the union of the keep regions.
+func regionsMask(bounds image.Rectangle, landmarks []image.Point, swap, keep []SwapRegion) *image.Alpha {
+	if len(swap) == 0 {
+		swap = []SwapRegion{RegionFace}
+	}
+
+	swapMask := unionMask(bounds, landmarks, swap)
+	keepMask := unionMask(bounds, landmarks, keep)
+
+	out := image.NewAlpha(bounds)
+	for i := range out.Pix {
+		out.Pix[i] = uint8(uint16(swapMask.Pix[i]) * uint16(255-keepMask.Pix[i]) / 255)
+	}
+
+	return out
+}
+
+func unionMask(bounds image.Rectangle, landmarks []image.Point, regions []SwapRegion) *image.Alpha {
+	out := image.NewAlpha(bounds)
+
+	for _, r := range regions {
+		// maskFromPolygon and the blur both return images starting at (0, 0)
+		var mask image.Image = maskFromPolygon(image.NewRGBA(bounds), r.Polygon(landmarks))
+		if r.Feather > 0 {
+			mask = imaging.Blur(mask, r.Feather)
+		}
+
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				_, _, _, a := mask.At(x-bounds.Min.X, y-bounds.Min.Y).RGBA()
+				if uint8(a>>8) > out.AlphaAt(x, y).A {
+					out.SetAlpha(x, y, color.Alpha{A: uint8(a >> 8)})
+				}
+			}
+		}
+	}
+
+	return out
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gildasch/gildas-ai/imageutils"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -80,7 +81,7 @@ func TestSwap(t *testing.T) {
 			landmarkResults["syl.png"]...),
 	}
 
-	out, err := swap(landmark, src, dest, 0)
+	out, err := swap(landmark, src, dest, SwapOptions{})
 	require.NoError(t, err)
 
 	imageutils.AssertImageEqual(t, "testdata/swap-expected.png", out)
@@ -109,6 +110,39 @@ func TestMaskFromPolygonWithBounds(t *testing.T) {
 	imageutils.AssertImageEqual(t, "testdata/maskFromPolygonWithBounds-expected.png", mask)
 }
 
+func TestRegionsMask(t *testing.T) {
+	cropped := image.NewRGBA(gaspardBounds)
+	landmarks := gaspardLandmarks.PointsOnImage(cropped)
+
+	rightEye := centerOf(landmarks[36:42])
+	noseTip := landmarks[30]
+	mouth := centerOf(landmarks[48:60])
+
+	full := regionsMask(gaspardBounds, landmarks, []SwapRegion{RegionFace}, nil)
+	assert.Equal(t, uint8(255), full.AlphaAt(rightEye.X, rightEye.Y).A)
+	assert.Equal(t, uint8(255), full.AlphaAt(noseTip.X, noseTip.Y).A)
+	assert.Equal(t, uint8(0), full.AlphaAt(gaspardBounds.Min.X, gaspardBounds.Min.Y).A)
+
+	expression := regionsMask(gaspardBounds, landmarks,
+		SwapExpressionPreserving.Swap, SwapExpressionPreserving.Keep)
+	assert.Equal(t, uint8(0), expression.AlphaAt(rightEye.X, rightEye.Y).A)
+	assert.Equal(t, uint8(0), expression.AlphaAt(mouth.X, mouth.Y).A)
+	assert.Equal(t, uint8(255), expression.AlphaAt(noseTip.X, noseTip.Y).A)
+
+	eyes := regionsMask(gaspardBounds, landmarks, SwapEyesOnly.Swap, SwapEyesOnly.Keep)
+	assert.Equal(t, uint8(255), eyes.AlphaAt(rightEye.X, rightEye.Y).A)
+	assert.Equal(t, uint8(0), eyes.AlphaAt(noseTip.X, noseTip.Y).A)
+	assert.Equal(t, uint8(0), eyes.AlphaAt(mouth.X, mouth.Y).A)
+}
+
+func centerOf(points []image.Point) image.Point {
+	var sum image.Point
+	for _, p := range points {
+		sum = sum.Add(p)
+	}
+	return sum.Div(len(points))
+}
+
 var detectResults = map[string][]Detection{
 	"gab.png": []Detection{
 		{Box: image.Rect(36, 55, 181, 281), Score: 0.9171224, Class: 1}},
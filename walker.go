@@ -0,0 +1,314 @@
+package gildasai
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SymlinkPolicy tells how FolderWalker handles the symbolic links
+type SymlinkPolicy int
+
+const (
+	// SkipSymlinks ignores the symbolic links
+	SkipSymlinks SymlinkPolicy = iota
+	// FollowSymlinks lists the files the links point to under the path of
+	// the links, each folder being walked once
+	FollowSymlinks
+)
+
+// DefaultImagePatterns match the files of the image formats read by
+// imageutils
+var DefaultImagePatterns = []string{"*.jpg", "*.jpeg", "*.png", "*.gif"}
+
+// FolderWalker lists the files of a folder to process. The zero value
+// lists the files at the root of the folder, hidden ones excepted.
+type FolderWalker struct {
+	// Recursive walks the subfolders
+	Recursive bool
+	Symlinks  SymlinkPolicy
+	// Include are the glob patterns of the files listed, all when empty.
+	// Exclude are the patterns of the files and folders skipped. The
+	// patterns containing a / are matched against the path relative to
+	// the root, the others against the name, case-insensitively.
+	Include, Exclude []string
+	// Hidden lists the files and walks the folders whose name starts
+	// with a dot
+	Hidden bool
+	// MinSize and MaxSize are the limits, in bytes, of the size of the
+	// files. 0 means no limit.
+	MinSize, MaxSize int64
+}
+
+// DefaultFolderWalker lists the images of a folder and its subfolders
+func DefaultFolderWalker() *FolderWalker {
+	return &FolderWalker{
+		Recursive: true,
+		Include:   append([]string(nil), DefaultImagePatterns...),
+	}
+}
+
+// FolderWalkerFromEnv returns DefaultFolderWalker configured by the
+// environment variables FOLDER_RECURSIVE, FOLDER_SYMLINKS (skip or
+// follow), FOLDER_INCLUDE and FOLDER_EXCLUDE (comma separated patterns),
+// FOLDER_HIDDEN, FOLDER_MIN_SIZE and FOLDER_MAX_SIZE
+func FolderWalkerFromEnv() (*FolderWalker, error) {
+	return folderWalkerFromEnv(os.Getenv)
+}
+
+func folderWalkerFromEnv(getenv func(string) string) (*FolderWalker, error) {
+	w := DefaultFolderWalker()
+
+	for _, b := range []struct {
+		env   string
+		value *bool
+	}{
+		{"FOLDER_RECURSIVE", &w.Recursive},
+		{"FOLDER_HIDDEN", &w.Hidden},
+	} {
+		if v := getenv(b.env); v != "" {
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s %q", b.env, v)
+			}
+			*b.value = parsed
+		}
+	}
+
+	switch v := getenv("FOLDER_SYMLINKS"); v {
+	case "", "skip":
+		w.Symlinks = SkipSymlinks
+	case "follow":
+		w.Symlinks = FollowSymlinks
+	default:
+		return nil, errors.Errorf("invalid FOLDER_SYMLINKS %q", v)
+	}
+
+	if v := getenv("FOLDER_INCLUDE"); v != "" {
+		w.Include = splitPatterns(v)
+	}
+	if v := getenv("FOLDER_EXCLUDE"); v != "" {
+		w.Exclude = splitPatterns(v)
+	}
+
+	for _, s := range []struct {
+		env   string
+		value *int64
+	}{
+		{"FOLDER_MIN_SIZE", &w.MinSize},
+		{"FOLDER_MAX_SIZE", &w.MaxSize},
+	} {
+		if v := getenv(s.env); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s %q", s.env, v)
+			}
+			*s.value = parsed
+		}
+	}
+
+	if err := w.checkPatterns(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func splitPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+func (w *FolderWalker) checkPatterns() error {
+	for _, p := range append(append([]string{}, w.Include...), w.Exclude...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return errors.Wrapf(err, "invalid pattern %q", p)
+		}
+	}
+	return nil
+}
+
+// Files returns the files of root, sorted by path
+func (w *FolderWalker) Files(root string) ([]string, error) {
+	files, _, err := w.list(root)
+	return files, err
+}
+
+// list returns the files of root and the folders walked, root included,
+// sorted by path
+func (w *FolderWalker) list(root string) (files, folders []string, err error) {
+	if err := w.checkPatterns(); err != nil {
+		return nil, nil, err
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error reading folder %q", root)
+	}
+
+	folders = []string{root}
+	err = w.walk(root, "", map[string]bool{realRoot: true}, &files, &folders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(files)
+	sort.Strings(folders)
+	return files, folders, nil
+}
+
+// walk adds to files and folders the files and the subfolders of dir,
+// whose path relative to the root is rel. visited are the real paths of
+// the folders already walked.
+func (w *FolderWalker) walk(dir, rel string, visited map[string]bool, files, folders *[]string) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "error reading folder %q", dir)
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		path := filepath.Join(dir, name)
+		relPath := filepath.ToSlash(filepath.Join(rel, name))
+
+		info, ok := w.follow(path, relPath, info)
+		if !ok {
+			continue
+		}
+
+		if info.IsDir() {
+			if !w.Recursive {
+				continue
+			}
+			realPath, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return errors.Wrapf(err, "error reading folder %q", path)
+			}
+			if visited[realPath] {
+				continue
+			}
+			visited[realPath] = true
+
+			*folders = append(*folders, path)
+			if err := w.walk(path, relPath, visited, files, folders); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if w.includes(relPath, info) {
+			*files = append(*files, path)
+		}
+	}
+
+	return nil
+}
+
+// follow returns the info of the file or folder path, whose path
+// relative to the root is rel and whose own info is info, following the
+// symbolic links, and whether it is skipped for being hidden, excluded, a
+// skipped or broken link
+func (w *FolderWalker) follow(path, rel string, info os.FileInfo) (os.FileInfo, bool) {
+	name := info.Name()
+	if !w.Hidden && strings.HasPrefix(name, ".") {
+		return nil, false
+	}
+	if matchAny(w.Exclude, rel, name) {
+		return nil, false
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		return info, true
+	}
+	if w.Symlinks == SkipSymlinks {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		// a broken link
+		return nil, false
+	}
+	return info, true
+}
+
+// includes tells if the file of info, whose path relative to the root
+// is rel, is listed
+func (w *FolderWalker) includes(rel string, info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if len(w.Include) > 0 && !matchAny(w.Include, rel, info.Name()) {
+		return false
+	}
+	if (w.MinSize > 0 && info.Size() < w.MinSize) || (w.MaxSize > 0 && info.Size() > w.MaxSize) {
+		return false
+	}
+	return true
+}
+
+// Match tells if the file path of the folder root is listed by Files
+func (w *FolderWalker) Match(root, path string) bool {
+	info, rel, ok := w.lookup(root, path)
+	return ok && !info.IsDir() && w.includes(rel, info)
+}
+
+// lookup returns the info of the file or folder path of root and its
+// path relative to root, and whether it is reached by walking root
+func (w *FolderWalker) lookup(root, path string) (os.FileInfo, string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, "", false
+	}
+
+	names := strings.Split(filepath.ToSlash(rel), "/")
+	if !w.Recursive && len(names) > 1 {
+		return nil, "", false
+	}
+
+	var info os.FileInfo
+	current := root
+	for i, name := range names {
+		current = filepath.Join(current, name)
+		rel = strings.Join(names[:i+1], "/")
+
+		lstat, err := os.Lstat(current)
+		if err != nil {
+			return nil, "", false
+		}
+		var ok bool
+		info, ok = w.follow(current, rel, lstat)
+		if !ok {
+			return nil, "", false
+		}
+		if i < len(names)-1 && !info.IsDir() {
+			return nil, "", false
+		}
+	}
+
+	return info, rel, true
+}
+
+// matchAny tells if one of patterns matches the relative path rel or the
+// name of a file
+func matchAny(patterns []string, rel, name string) bool {
+	for _, p := range patterns {
+		target := name
+		if strings.Contains(p, "/") {
+			target = rel
+		}
+		if ok, _ := filepath.Match(strings.ToLower(p), strings.ToLower(target)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package gildasai
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// walkerTestFolder creates the files of sizes under a temporary folder,
+// and a link to the folder itself in sub, and returns the folder
+func walkerTestFolder(t *testing.T, sizes map[string]int) string {
+	dir, err := ioutil.TempDir("", "walker")
+	require.NoError(t, err)
+
+	for name, size := range sizes {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, make([]byte, size), 0644))
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, os.Symlink(dir, filepath.Join(dir, "sub", "loop")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "b.png"), filepath.Join(dir, "link.png")))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing.png"), filepath.Join(dir, "broken.png")))
+
+	return dir
+}
+
+var walkerTestSizes = map[string]int{
+	"b.png":               10,
+	"a.JPG":               20,
+	"notes.txt":           30,
+	".hidden.jpg":         40,
+	"sub/c.jpg":           50,
+	"sub/raw/d.jpg":       60,
+	"sub/.thumbs/e.jpg":   70,
+	"thumbnails/f.jpg":    80,
+	"thumbnails/g.gif":    90,
+	"sub/raw/h.png":       100,
+	"sub/deeper/i.jpeg":   110,
+	"sub/deeper/j.tiff":   120,
+	"sub/deeper/k.png.gz": 130,
+}
+
+func TestFolderWalkerFiles(t *testing.T) {
+	dir := walkerTestFolder(t, walkerTestSizes)
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name     string
+		walker   FolderWalker
+		expected []string
+	}{{
+		name:     "zero value",
+		walker:   FolderWalker{},
+		expected: []string{"a.JPG", "b.png", "notes.txt"},
+	}, {
+		name:   "hidden",
+		walker: FolderWalker{Hidden: true},
+		expected: []string{
+			".hidden.jpg", "a.JPG", "b.png", "notes.txt",
+		},
+	}, {
+		name:   "recursive images",
+		walker: *DefaultFolderWalker(),
+		expected: []string{
+			"a.JPG", "b.png", "sub/c.jpg", "sub/deeper/i.jpeg",
+			"sub/raw/d.jpg", "sub/raw/h.png", "thumbnails/f.jpg", "thumbnails/g.gif",
+		},
+	}, {
+		name: "exclude names and paths",
+		walker: FolderWalker{
+			Recursive: true,
+			Include:   []string{"*.jpg", "*.PNG"},
+			Exclude:   []string{"thumbnails", "sub/raw/*.jpg"},
+		},
+		expected: []string{"a.JPG", "b.png", "sub/c.jpg", "sub/raw/h.png"},
+	}, {
+		name: "include paths",
+		walker: FolderWalker{
+			Recursive: true,
+			Include:   []string{"sub/*/*"},
+		},
+		expected: []string{
+			"sub/deeper/i.jpeg", "sub/deeper/j.tiff", "sub/deeper/k.png.gz",
+			"sub/raw/d.jpg", "sub/raw/h.png",
+		},
+	}, {
+		name: "size limits",
+		walker: FolderWalker{
+			Recursive: true,
+			MinSize:   20,
+			MaxSize:   60,
+		},
+		expected: []string{"a.JPG", "notes.txt", "sub/c.jpg", "sub/raw/d.jpg"},
+	}, {
+		name: "follow symlinks",
+		walker: FolderWalker{
+			Recursive: true,
+			Symlinks:  FollowSymlinks,
+			Include:   []string{"*.png"},
+		},
+		// the loop to the root is not walked again
+		expected: []string{"b.png", "link.png", "sub/raw/h.png"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files, err := tc.walker.Files(dir)
+			require.NoError(t, err)
+
+			var expected []string
+			for _, f := range tc.expected {
+				expected = append(expected, filepath.Join(dir, filepath.FromSlash(f)))
+			}
+			assert.Equal(t, expected, files)
+		})
+	}
+}
+
+func TestFolderWalkerFilesErrors(t *testing.T) {
+	_, err := (&FolderWalker{}).Files("/does/not/exist")
+	assert.Error(t, err)
+
+	dir, err := ioutil.TempDir("", "walker")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = (&FolderWalker{Include: []string{"[a-"}}).Files(dir)
+	assert.Error(t, err)
+}
+
+func TestFolderWalkerFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	w, err := folderWalkerFromEnv(env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultFolderWalker(), w)
+
+	w, err = folderWalkerFromEnv(env(map[string]string{
+		"FOLDER_RECURSIVE": "false",
+		"FOLDER_HIDDEN":    "1",
+		"FOLDER_SYMLINKS":  "follow",
+		"FOLDER_INCLUDE":   "*.jpg, *.png",
+		"FOLDER_EXCLUDE":   "thumbnails,,*/raw/*",
+		"FOLDER_MIN_SIZE":  "1000",
+		"FOLDER_MAX_SIZE":  "50000000",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, &FolderWalker{
+		Recursive: false,
+		Hidden:    true,
+		Symlinks:  FollowSymlinks,
+		Include:   []string{"*.jpg", "*.png"},
+		Exclude:   []string{"thumbnails", "*/raw/*"},
+		MinSize:   1000,
+		MaxSize:   50000000,
+	}, w)
+
+	for _, vars := range []map[string]string{
+		{"FOLDER_RECURSIVE": "maybe"},
+		{"FOLDER_SYMLINKS": "sometimes"},
+		{"FOLDER_EXCLUDE": "[a-"},
+		{"FOLDER_MAX_SIZE": "big"},
+	} {
+		_, err := folderWalkerFromEnv(env(vars))
+		assert.Error(t, err, "%v", vars)
+	}
+}
+
+func TestFolderWalkerMatch(t *testing.T) {
+	dir := walkerTestFolder(t, walkerTestSizes)
+	defer os.RemoveAll(dir)
+
+	w := &FolderWalker{
+		Recursive: true,
+		Include:   DefaultImagePatterns,
+		Exclude:   []string{"thumbnails"},
+		MaxSize:   100,
+	}
+	files, err := w.Files(dir)
+	require.NoError(t, err)
+
+	for name := range walkerTestSizes {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Equal(t, contains(files, path), w.Match(dir, path), name)
+	}
+	for _, path := range []string{
+		dir,
+		filepath.Join(dir, "sub"),
+		filepath.Join(dir, "link.png"),
+		filepath.Join(dir, "sub", "loop", "b.png"),
+		filepath.Join(dir, "missing.png"),
+		filepath.Join(filepath.Dir(dir), "b.png"),
+	} {
+		assert.False(t, w.Match(dir, path), path)
+	}
+}
+
+func contains(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,644 @@
+package gildasai
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gildasch/gildas-ai/imageutils"
+	"github.com/pkg/errors"
+)
+
+// Watcher reports the paths created, modified or removed under a folder
+type Watcher interface {
+	// Changes are the changed paths, closed once the watcher is closed
+	Changes() <-chan string
+	Errors() <-chan error
+	Close() error
+}
+
+// NewWatcher watches the files of root listed by walker with inotify,
+// or by listing them every interval when inotify is not available
+func NewWatcher(root string, walker *FolderWalker, interval time.Duration) (Watcher, error) {
+	w, err := NewNotifyWatcher(root, walker)
+	if err == nil {
+		return w, nil
+	}
+
+	return NewPollingWatcher(root, walker, interval)
+}
+
+type notifyWatcher struct {
+	root    string
+	walker  *FolderWalker
+	watcher *fsnotify.Watcher
+	// visited are the real paths of the folders watched
+	visited map[string]bool
+	// watched are the real paths of the folders watched, by path
+	watched map[string]string
+
+	changes chan string
+	errs    chan error
+	closing chan struct{}
+	once    sync.Once
+}
+
+// NewNotifyWatcher watches, with inotify, the files of root listed by
+// walker. The folders created are watched as they appear.
+func NewNotifyWatcher(root string, walker *FolderWalker) (Watcher, error) {
+	_, folders, err := walker.list(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the inotify watcher")
+	}
+
+	w := &notifyWatcher{
+		root:    root,
+		walker:  walker,
+		watcher: fw,
+		visited: map[string]bool{},
+		watched: map[string]string{},
+		changes: make(chan string),
+		errs:    make(chan error),
+		closing: make(chan struct{}),
+	}
+
+	for _, folder := range folders {
+		if err := w.add(folder); err != nil {
+			fw.Close()
+			return nil, err
+		}
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *notifyWatcher) Changes() <-chan string { return w.changes }
+
+func (w *notifyWatcher) Errors() <-chan error { return w.errs }
+
+func (w *notifyWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.closing)
+		err = w.watcher.Close()
+	})
+	return err
+}
+
+// add watches folder if it was not watched yet
+func (w *notifyWatcher) add(folder string) error {
+	realPath, err := filepath.EvalSymlinks(folder)
+	if err != nil {
+		return errors.Wrapf(err, "error reading folder %q", folder)
+	}
+	if w.visited[realPath] {
+		return nil
+	}
+
+	if err := w.watcher.Add(folder); err != nil {
+		return errors.Wrapf(err, "error watching folder %q", folder)
+	}
+	w.visited[realPath] = true
+	w.watched[folder] = realPath
+	return nil
+}
+
+// removed forgets the watched folders at or under path, so that they are
+// watched again if they are created again
+func (w *notifyWatcher) removed(path string) {
+	prefix := path + string(filepath.Separator)
+	for folder, realPath := range w.watched {
+		if folder != path && !strings.HasPrefix(folder, prefix) {
+			continue
+		}
+		// inotify drops the watches of deleted folders, not of renamed ones
+		w.watcher.Remove(folder)
+		delete(w.watched, folder)
+		delete(w.visited, realPath)
+	}
+}
+
+func (w *notifyWatcher) run() {
+	defer close(w.changes)
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, _, ok := w.walker.lookup(w.root, event.Name); ok && info.IsDir() {
+					w.created(event.Name)
+					continue
+				}
+			}
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.removed(event.Name)
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+				w.send(event.Name)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.sendError(errors.Wrap(err, "error watching the folder"))
+		}
+	}
+}
+
+// created watches the new folder and its subfolders and sends the files
+// they already have
+func (w *notifyWatcher) created(folder string) {
+	if !w.walker.Recursive {
+		return
+	}
+
+	rel, err := filepath.Rel(w.root, folder)
+	if err != nil {
+		w.sendError(errors.Wrapf(err, "error reading folder %q", folder))
+		return
+	}
+
+	realPath, err := filepath.EvalSymlinks(folder)
+	if err != nil {
+		w.sendError(errors.Wrapf(err, "error reading folder %q", folder))
+		return
+	}
+
+	// the folders watched are not walked again, add marks the new ones
+	visited := map[string]bool{realPath: true}
+	for path := range w.visited {
+		visited[path] = true
+	}
+
+	var files []string
+	folders := []string{folder}
+	err = w.walker.walk(folder, filepath.ToSlash(rel), visited, &files, &folders)
+	if err != nil {
+		w.sendError(err)
+	}
+
+	for _, f := range folders {
+		if err := w.add(f); err != nil {
+			w.sendError(err)
+		}
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		w.send(file)
+	}
+}
+
+func (w *notifyWatcher) send(path string) {
+	select {
+	case w.changes <- path:
+	case <-w.closing:
+	}
+}
+
+func (w *notifyWatcher) sendError(err error) {
+	select {
+	case w.errs <- err:
+	case <-w.closing:
+	}
+}
+
+type pollingWatcher struct {
+	root     string
+	walker   *FolderWalker
+	interval time.Duration
+	known    map[string]fileState
+	// settling are the states of the changed files not reported yet,
+	// as seen by the last poll
+	settling map[string]fileState
+
+	changes chan string
+	errs    chan error
+	closing chan struct{}
+	once    sync.Once
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// NewPollingWatcher watches the files of root listed by walker by
+// listing them every interval and comparing their size and modification
+// time. A changed file is reported once two polls in a row see the same
+// size and modification time, so that the files still being written are
+// not.
+func NewPollingWatcher(root string, walker *FolderWalker, interval time.Duration) (Watcher, error) {
+	if interval <= 0 {
+		return nil, errors.Errorf("invalid polling interval %v", interval)
+	}
+
+	w := &pollingWatcher{
+		root:     root,
+		walker:   walker,
+		interval: interval,
+		changes:  make(chan string),
+		errs:     make(chan error),
+		closing:  make(chan struct{}),
+		settling: map[string]fileState{},
+	}
+
+	known, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.known = known
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *pollingWatcher) Changes() <-chan string { return w.changes }
+
+func (w *pollingWatcher) Errors() <-chan error { return w.errs }
+
+func (w *pollingWatcher) Close() error {
+	w.once.Do(func() { close(w.closing) })
+	return nil
+}
+
+// snapshot returns the state of the files of the folder
+func (w *pollingWatcher) snapshot() (map[string]fileState, error) {
+	files, err := w.walker.Files(w.root)
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]fileState{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			// removed since it was listed
+			continue
+		}
+		states[file] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	return states, nil
+}
+
+func (w *pollingWatcher) run() {
+	defer close(w.changes)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.closing:
+			return
+		}
+
+		states, err := w.snapshot()
+		if err != nil {
+			select {
+			case w.errs <- err:
+			case <-w.closing:
+				return
+			}
+			continue
+		}
+
+		changed := w.settled(states)
+
+		sort.Strings(changed)
+		for _, file := range changed {
+			select {
+			case w.changes <- file:
+			case <-w.closing:
+				return
+			}
+		}
+	}
+}
+
+// settled updates the known states with states and returns the files
+// removed and the files which changed and then kept the same state for
+// two polls
+func (w *pollingWatcher) settled(states map[string]fileState) []string {
+	var changed []string
+	for file, state := range states {
+		if known, ok := w.known[file]; ok && known.equal(state) {
+			delete(w.settling, file)
+			continue
+		}
+		if last, ok := w.settling[file]; ok && last.equal(state) {
+			w.known[file] = state
+			delete(w.settling, file)
+			changed = append(changed, file)
+			continue
+		}
+		w.settling[file] = state
+	}
+
+	for file := range w.known {
+		if _, ok := states[file]; !ok {
+			delete(w.known, file)
+			changed = append(changed, file)
+		}
+	}
+	for file := range w.settling {
+		if _, ok := states[file]; !ok {
+			delete(w.settling, file)
+		}
+	}
+
+	return changed
+}
+
+func (s fileState) equal(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// Debounce sends the changed paths in sorted batches, each path once it
+// did not change for delay. The batches are closed, the pending paths
+// being sent at once, when changes is closed.
+func Debounce(changes <-chan string, delay time.Duration) <-chan []string {
+	batches := make(chan []string)
+
+	go func() {
+		defer close(batches)
+
+		pending := map[string]time.Time{}
+		ready := map[string]bool{}
+		var timer *time.Timer
+		var expired <-chan time.Time
+
+		for {
+			var out chan []string
+			if len(ready) > 0 {
+				out = batches
+			}
+
+			select {
+			case path, ok := <-changes:
+				if !ok {
+					for path := range pending {
+						ready[path] = true
+					}
+					if len(ready) > 0 {
+						batches <- sortedPaths(ready)
+					}
+					return
+				}
+				pending[path] = time.Now()
+				delete(ready, path)
+			case <-expired:
+			case out <- sortedPaths(ready):
+				ready = map[string]bool{}
+			}
+
+			// the paths which did not change for delay are ready, the timer
+			// expires when the next one is
+			now := time.Now()
+			next := time.Duration(-1)
+			for path, changed := range pending {
+				left := delay - now.Sub(changed)
+				if left <= 0 {
+					ready[path] = true
+					delete(pending, path)
+					continue
+				}
+				if next < 0 || left < next {
+					next = left
+				}
+			}
+
+			if timer != nil {
+				timer.Stop()
+				timer, expired = nil, nil
+			}
+			if next >= 0 {
+				timer = time.NewTimer(next)
+				expired = timer.C
+			}
+		}
+	}()
+
+	return batches
+}
+
+func sortedPaths(paths map[string]bool) []string {
+	var sorted []string
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// WatchStore keeps the faces, their neighbours and the predictions of
+// the files of a watched folder
+type WatchStore interface {
+	FolderStore
+	FaceNeighbourStore
+	ModelStore
+}
+
+// FolderUpdater keeps the faces, their neighbours and the predictions of
+// the files of a folder up to date
+type FolderUpdater struct {
+	Root      string
+	Walker    *FolderWalker
+	Extractor *Extractor
+	// Classifiers classify the new and modified files, the 10 best
+	// predictions being stored
+	Classifiers []Classifier
+	Store       WatchStore
+	// Neighbours is the number of neighbours kept per face,
+	// DefaultNeighbours when 0
+	Neighbours int
+}
+
+// Update extracts the faces of paths which are new or modified, stores
+// their predictions, and deletes the faces and predictions of the paths
+// removed. The face graph is then updated. The paths not listed by the
+// walker are ignored.
+func (u *FolderUpdater) Update(paths []string) (*FolderReport, []error) {
+	report := &FolderReport{}
+	var errs []error
+
+	var changed []string
+	for _, path := range paths {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			removed, err := u.remove(path)
+			report.Removed = append(report.Removed, removed...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if !u.Walker.Match(u.Root, path) {
+			continue
+		}
+
+		status, failures := updateFile(path, u.Extractor, u.Store)
+		report.add(path, status)
+		errs = append(errs, failures...)
+		if status == FileAdded || status == FileModified {
+			changed = append(changed, path)
+		}
+	}
+
+	for _, file := range changed {
+		errs = append(errs, u.classify(file)...)
+	}
+
+	if len(changed) > 0 || len(report.Removed) > 0 {
+		graph := &FaceGraph{Store: u.Store, K: u.Neighbours, MaxDistance: MaxFaceDistance}
+		if _, err := graph.Update(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return report, errs
+}
+
+// remove deletes the faces and the predictions of the removed file path,
+// or of the files of the removed folder path, and returns them. The files
+// having neither a source nor faces, such as the ones only having
+// predictions, are found by collecting the garbage of path.
+func (u *FolderUpdater) remove(path string) ([]string, error) {
+	_, hasSource, err := u.Store.GetSource(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading the source of %q", path)
+	}
+	_, hasFaces, err := u.Store.GetFaces(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading the faces of %q", path)
+	}
+
+	if hasSource || hasFaces {
+		if err := u.Store.DeleteSource(path); err != nil {
+			return nil, errors.Wrapf(err, "error deleting removed file %q", path)
+		}
+		return []string{path}, nil
+	}
+
+	return CollectGarbage(path, u.Store)
+}
+
+// classify stores the predictions of the classifiers for file
+func (u *FolderUpdater) classify(file string) []error {
+	if len(u.Classifiers) == 0 {
+		return nil
+	}
+
+	img, err := imageutils.FromFile(file)
+	if err != nil {
+		return []error{errors.Wrapf(err, "error reading image from file %q", file)}
+	}
+
+	var errs []error
+	for _, classifier := range u.Classifiers {
+		preds, err := classifier.Classify(img)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "error classifying image %q", file))
+			continue
+		}
+
+		best := preds.Best(10)
+		if len(best) == 0 {
+			continue
+		}
+		err = u.Store.ReplacePredictions(file, best[0].Network, best)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "error storing the predictions of %q", file))
+		}
+	}
+
+	return errs
+}
+
+// Watch updates, first, all the files of the folder and then the files
+// changed under watcher once they did not change for delay, until
+// watcher is closed. The reports of the updates are sent on reports, the
+// errors on errs, both being closed at the end.
+func (u *FolderUpdater) Watch(watcher Watcher, delay time.Duration) (reports chan *FolderReport, errs chan error) {
+	reports = make(chan *FolderReport)
+	errs = make(chan error)
+	batches := Debounce(watcher.Changes(), delay)
+
+	go func() {
+		defer close(reports)
+		defer close(errs)
+
+		update := func(paths []string) {
+			report, failures := u.Update(paths)
+			for _, err := range failures {
+				errs <- err
+			}
+			reports <- report
+		}
+
+		files, err := u.Walker.Files(u.Root)
+		if err != nil {
+			errs <- err
+		} else {
+			update(files)
+		}
+
+		for {
+			select {
+			case batch, ok := <-batches:
+				if !ok {
+					return
+				}
+				update(batch)
+			case err := <-watcher.Errors():
+				errs <- err
+			}
+		}
+	}()
+
+	return reports, errs
+}
+
+// WatcherFromEnv returns the watcher of the files of root listed by
+// walker and the delay for their changes to settle, configured by the
+// environment variables WATCH_DELAY, 2s by default, and WATCH_POLL, the
+// interval of the polling watcher used instead of inotify, 30s when
+// inotify is not available
+func WatcherFromEnv(root string, walker *FolderWalker) (Watcher, time.Duration, error) {
+	delay := 2 * time.Second
+	if v := os.Getenv("WATCH_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid WATCH_DELAY %q", v)
+		}
+		delay = d
+	}
+
+	if v := os.Getenv("WATCH_POLL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid WATCH_POLL %q", v)
+		}
+		w, err := NewPollingWatcher(root, walker, interval)
+		return w, delay, err
+	}
+
+	w, err := NewWatcher(root, walker, 30*time.Second)
+	return w, delay, err
+}
@@ -0,0 +1,285 @@
+package gildasai_test
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gildasai "github.com/gildasch/gildas-ai"
+	"github.com/gildasch/gildas-ai/gildasaitest"
+	"github.com/gildasch/gildas-ai/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebounce(t *testing.T) {
+	changes := make(chan string)
+	batches := gildasai.Debounce(changes, 50*time.Millisecond)
+
+	changes <- "b"
+	changes <- "a"
+	changes <- "b"
+
+	select {
+	case batch := <-batches:
+		assert.Equal(t, []string{"a", "b"}, batch)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch sent")
+	}
+
+	changes <- "c"
+	close(changes)
+
+	batch, ok := <-batches
+	assert.True(t, ok)
+	assert.Equal(t, []string{"c"}, batch, "the pending paths are sent when the changes are closed")
+	_, ok = <-batches
+	assert.False(t, ok)
+}
+
+// nextChange returns the next change of w, failing after a while
+func nextChange(t *testing.T, w gildasai.Watcher) string {
+	select {
+	case path := <-w.Changes():
+		return path
+	case err := <-w.Errors():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+	return ""
+}
+
+func TestWatchers(t *testing.T) {
+	for name, newWatcher := range map[string]func(root string, walker *gildasai.FolderWalker) (gildasai.Watcher, error){
+		"notify": gildasai.NewNotifyWatcher,
+		"polling": func(root string, walker *gildasai.FolderWalker) (gildasai.Watcher, error) {
+			return gildasai.NewPollingWatcher(root, walker, 20*time.Millisecond)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "watch")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			writePNG(t, filepath.Join(dir, "old.png"))
+
+			w, err := newWatcher(dir, gildasai.DefaultFolderWalker())
+			require.NoError(t, err)
+
+			photo := filepath.Join(dir, "new", "photo.png")
+			require.NoError(t, os.Mkdir(filepath.Dir(photo), 0755))
+			writePNG(t, photo)
+			for nextChange(t, w) != photo {
+			}
+
+			require.NoError(t, os.Remove(filepath.Join(dir, "old.png")))
+			for nextChange(t, w) != filepath.Join(dir, "old.png") {
+			}
+
+			// a folder deleted and created again is watched again
+			require.NoError(t, os.RemoveAll(filepath.Dir(photo)))
+			for nextChange(t, w) != photo {
+			}
+			// the removal of the folder is reported too
+			for quiet := false; !quiet; {
+				select {
+				case <-w.Changes():
+				case <-time.After(200 * time.Millisecond):
+					quiet = true
+				}
+			}
+			require.NoError(t, os.Mkdir(filepath.Dir(photo), 0755))
+			// let the watcher see the folder before its file
+			time.Sleep(50 * time.Millisecond)
+			again := filepath.Join(dir, "new", "again.png")
+			writePNG(t, again)
+			for nextChange(t, w) != again {
+			}
+
+			require.NoError(t, w.Close())
+			for range w.Changes() {
+			}
+		})
+	}
+}
+
+func TestPollingWatcherWaitsForWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := gildasai.NewPollingWatcher(dir, gildasai.DefaultFolderWalker(), 50*time.Millisecond)
+	require.NoError(t, err)
+	defer w.Close()
+
+	photo := filepath.Join(dir, "photo.png")
+	f, err := os.Create(photo)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// the file growing at every poll is not reported
+	for i := 0; i < 40; i++ {
+		_, err := f.Write([]byte("growing"))
+		require.NoError(t, err)
+		select {
+		case path := <-w.Changes():
+			t.Fatalf("%q reported while being written", path)
+		case err := <-w.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	assert.Equal(t, photo, nextChange(t, w), "the file is reported once written")
+}
+
+func TestFolderUpdater(t *testing.T) {
+	dir, err := ioutil.TempDir("", "update")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png")
+	writePNG(t, a)
+	writePNG(t, b)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644))
+
+	descriptors := func(first float32) gildasai.Descriptors {
+		d := make(gildasai.Descriptors, 128)
+		d[0] = first
+		return d
+	}
+
+	store := memory.NewStore()
+	classifier := &gildasaitest.Classifier{Predictions: []gildasai.Predictions{{
+		{Network: "xception", Model: "xception@v1", Label: "ox", Score: 0.9},
+	}}}
+	u := &gildasai.FolderUpdater{
+		Root:   dir,
+		Walker: gildasai.DefaultFolderWalker(),
+		Extractor: &gildasai.Extractor{
+			Network: "fake",
+			Detector: &gildasaitest.Detector{Detections: [][]gildasai.Detection{{
+				{Box: image.Rect(10, 10, 110, 130), Score: 0.95},
+			}}},
+			Landmark: &gildasaitest.Landmark{},
+			Descriptor: &gildasaitest.Descriptor{Descriptors: []gildasai.Descriptors{
+				descriptors(0), descriptors(0.25),
+			}},
+		},
+		Classifiers: []gildasai.Classifier{classifier},
+		Store:       store,
+	}
+
+	report, errs := u.Update([]string{a, b, filepath.Join(dir, "notes.txt")})
+	assert.Empty(t, errs)
+	assert.Equal(t, &gildasai.FolderReport{Added: []string{a, b}}, report)
+
+	faces, err := store.GetAllFaces()
+	require.NoError(t, err)
+	require.Len(t, faces, 2)
+
+	neighbours, ok, err := store.GetNeighbours(faces[1].ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, neighbours.Neighbours, 1)
+	assert.Equal(t, faces[0].ID, neighbours.Neighbours[0].Face.ID)
+	assert.Equal(t, float32(0.25), neighbours.Neighbours[0].Distance)
+
+	item, ok, err := store.GetPrediction(b)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, classifier.Predictions[0], item.Predictions)
+
+	report, errs = u.Update([]string{a})
+	assert.Empty(t, errs)
+	assert.Equal(t, &gildasai.FolderReport{Unchanged: []string{a}}, report)
+	assert.Equal(t, 2, classifier.CallCount("Classify"))
+
+	require.NoError(t, os.Remove(b))
+	report, errs = u.Update([]string{b})
+	assert.Empty(t, errs)
+	assert.Equal(t, &gildasai.FolderReport{Removed: []string{b}}, report)
+
+	_, ok, err = store.GetFaces(b)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	neighbours, ok, err = store.GetNeighbours(faces[0].ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Empty(t, neighbours.Neighbours)
+	assert.False(t, neighbours.Stale, "the graph is updated after the removal")
+
+	// a file only having predictions, such as one stored by the API
+	predicted := filepath.Join(dir, "predicted.png")
+	require.NoError(t, store.StorePrediction(predicted, &gildasai.PredictionItem{
+		Identifier:  predicted,
+		Predictions: gildasai.Predictions{{Network: "xception", Label: "ox", Score: 0.9}},
+	}))
+	report, errs = u.Update([]string{predicted})
+	assert.Empty(t, errs)
+	assert.Equal(t, &gildasai.FolderReport{Removed: []string{predicted}}, report)
+
+	_, ok, err = store.GetPrediction(predicted)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFolderUpdaterWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "existing.png")
+	writePNG(t, existing)
+
+	walker := gildasai.DefaultFolderWalker()
+	w, err := gildasai.NewPollingWatcher(dir, walker, 20*time.Millisecond)
+	require.NoError(t, err)
+
+	u := &gildasai.FolderUpdater{
+		Root:   dir,
+		Walker: walker,
+		Extractor: &gildasai.Extractor{
+			Network:    "fake",
+			Detector:   &gildasaitest.Detector{},
+			Landmark:   &gildasaitest.Landmark{},
+			Descriptor: &gildasaitest.Descriptor{},
+		},
+		Store: memory.NewStore(),
+	}
+
+	reports, errs := u.Watch(w, 100*time.Millisecond)
+	next := func() *gildasai.FolderReport {
+		select {
+		case report := <-reports:
+			return report
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no report sent")
+		}
+		return nil
+	}
+
+	assert.Equal(t, &gildasai.FolderReport{Added: []string{existing}}, next(),
+		"the files are updated when the watch starts")
+
+	added := filepath.Join(dir, "sub", "added.png")
+	require.NoError(t, os.Mkdir(filepath.Dir(added), 0755))
+	// the image is written aside and moved in place, whole
+	tmp, err := ioutil.TempFile("", "added")
+	require.NoError(t, err)
+	require.NoError(t, tmp.Close())
+	defer os.Remove(tmp.Name())
+	writePNG(t, tmp.Name())
+	require.NoError(t, os.Rename(tmp.Name(), added))
+	assert.Equal(t, &gildasai.FolderReport{Added: []string{added}}, next())
+
+	require.NoError(t, w.Close())
+	for range reports {
+	}
+}